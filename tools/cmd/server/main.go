@@ -1,14 +1,30 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/encryption"
 	"github.com/aletheia/backend/internal/handlers"
+	"github.com/aletheia/backend/internal/mfa"
 	mw "github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/migrate"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/paystack"
+	"github.com/aletheia/backend/internal/ratelimit"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	gotrue "github.com/supabase-community/gotrue-go"
 	supabase "github.com/supabase-community/supabase-go"
 )
 
@@ -16,29 +32,167 @@ func main() {
 	// Load .env file (ignored if not found, e.g. in production)
 	_ = godotenv.Load("../.env")
 
+	// Maintenance commands run instead of the server: `server keygen`
+	// prints a new encryption key, `server reencrypt` rotates stored data,
+	// `server migrate` manages the database schema
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	if command == "keygen" {
+		fmt.Println(encryption.NewKey())
+		return
+	}
+	if command == "migrate" {
+		runMigrations(getEnv("DATABASE_URL", ""), os.Args[2:])
+		return
+	}
+
 	// Load environment variables
 	supabaseURL := getEnv("SUPABASE_URL", "https://mnwjsmkawisyisauxeyy.supabase.co")
 	supabaseKey := getEnv("SUPABASE_ANON_KEY", "")
+	serviceRoleKey := getEnv("SUPABASE_SERVICE_ROLE_KEY", "")
+	appURL := getEnv("APP_URL", "http://localhost:8080")
 	port := getEnv("PORT", "8080")
 
 	if supabaseKey == "" {
-		log.Fatal("SUPABASE_ANON_KEY is required")
+		log.Fatal("SUPABASE_ANON_KEY is required: sign-in and avatars always go through Supabase Auth and Storage")
 	}
 
-	// Initialize Supabase client
-	client, err := supabase.NewClient(supabaseURL, supabaseKey, &supabase.ClientOptions{})
+	// Encrypt personal data before it reaches the database. The key file
+	// can also be passed inline in ENCRYPTION_KEYS on hosts without files.
+	var cipher *encryption.Cipher
+	var keyring *encryption.LocalKeyring
+	var err error
+	if path := getEnv("ENCRYPTION_KEY_FILE", ""); path != "" {
+		keyring, err = encryption.LoadKeyFile(path)
+	} else if keys := getEnv("ENCRYPTION_KEYS", ""); keys != "" {
+		keyring, err = encryption.ParseKeyring([]byte(keys))
+	}
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	if keyring != nil {
+		cipher = encryption.NewCipher(keyring, keyring.IndexKey())
+		http.DefaultTransport = encryption.NewTransport(http.DefaultTransport, supabaseURL+supabase.REST_URL, cipher, encryption.Sensitive)
+	} else {
+		log.Println("⚠️  ENCRYPTION_KEY_FILE not set — emails and phone numbers are stored unencrypted")
+	}
+
+	if command == "reencrypt" {
+		reencrypt(supabaseURL, firstNonEmpty(serviceRoleKey, supabaseKey), cipher)
+		return
+	}
+
+	// Initialize Supabase client. Every table has row-level security, which
+	// the service-role key bypasses; the API applies its own access rules.
+	// The anon key only works against a database without the migrations.
+	client, err := supabase.NewClient(supabaseURL, firstNonEmpty(serviceRoleKey, supabaseKey), &supabase.ClientOptions{})
 	if err != nil {
 		log.Fatal("Failed to initialize Supabase client:", err)
 	}
 
+	// Service-role client for Auth admin operations (password resets etc.)
+	var adminClient *supabase.Client
+	var adminAuth gotrue.Client
+	if serviceRoleKey != "" {
+		adminClient, err = supabase.NewClient(supabaseURL, serviceRoleKey, &supabase.ClientOptions{})
+		if err != nil {
+			log.Fatal("Failed to initialize Supabase admin client:", err)
+		}
+		adminAuth = adminClient.Auth
+	} else {
+		log.Println("⚠️  SUPABASE_SERVICE_ROLE_KEY not set — using the anon key, which row-level security blocks; password reset and phone sign-in are disabled")
+	}
+
+	// The store layer goes through PostgREST unless DATA_STORE=postgres,
+	// which connects to DATABASE_URL directly for real transactions, row
+	// locks and aggregates. Every table the API reads or writes, including
+	// the audit log and the notifications log, goes through the selected
+	// store. Supabase itself stays mandatory for Auth (sign-up, sign-in,
+	// JWT validation and the admin API) and for Storage (avatars), plus
+	// RATE_LIMIT_STORE=supabase and the reencrypt command when used.
+	st := store.NewSupabase(client)
+	if getEnv("DATA_STORE", "supabase") == "postgres" {
+		pool, err := connectPostgres(getEnv("DATABASE_URL", ""))
+		if err != nil {
+			log.Fatalf("Failed to connect to the database: %v", err)
+		}
+		defer pool.Close()
+		st = store.NewPostgres(pool, cipher)
+		log.Println("🐘 Data store: Postgres (direct connection)")
+	}
+
+	// Initialize notification providers (log to stdout when no keys are set)
+	var emailSender notify.EmailSender = notify.LogSender{}
+	if key := getEnv("RESEND_API_KEY", ""); key != "" {
+		emailSender = notify.NewResendSender(key, getEnv("EMAIL_FROM", "Aletheia <no-reply@aletheia.ng>"))
+	}
+	var smsSender notify.SMSSender = notify.LogSender{}
+	if key := getEnv("TERMII_API_KEY", ""); key != "" {
+		smsSender = notify.NewTermiiSender(key, getEnv("TERMII_SENDER_ID", "Aletheia"))
+	}
+	notifier := notify.New(st.Notifications, emailSender, smsSender, appURL)
+
+	// OTP codes are HMAC'd with this secret; a random one only suits local dev
+	otpSecret := []byte(getEnv("OTP_SECRET", ""))
+	if len(otpSecret) == 0 {
+		otpSecret = make([]byte, 32)
+		rand.Read(otpSecret)
+		log.Println("⚠️  OTP_SECRET not set — using a random per-process secret")
+	}
+
+	var paystackClient *paystack.Client
+	if key := getEnv("PAYSTACK_SECRET_KEY", ""); key != "" {
+		paystackClient = paystack.New(key)
+	} else {
+		log.Println("⚠️  PAYSTACK_SECRET_KEY not set — webhooks are rejected and reconciliation is disabled")
+	}
+
+	// Rate limits live in process memory unless RATE_LIMIT_STORE=supabase,
+	// which shares them between instances through the rate_limit_take RPC
+	var rateStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if getEnv("RATE_LIMIT_STORE", "memory") == "supabase" {
+		// A client of its own: Rpc reports errors through shared client state
+		rlClient, err := supabase.NewClient(supabaseURL, firstNonEmpty(serviceRoleKey, supabaseKey), &supabase.ClientOptions{})
+		if err != nil {
+			log.Fatalf("Failed to create rate limit client: %v", err)
+		}
+		rateStore = ratelimit.NewSupabaseStore(rlClient)
+	}
+	limiter := ratelimit.New(rateStore)
+
+	// Client IPs, which rate limits key on, come from X-Forwarded-For only
+	// as far as the proxies in front of the server wrote it
+	if n := getEnv("TRUSTED_PROXIES", ""); n != "" {
+		if audit.TrustedProxies, err = strconv.Atoi(n); err != nil || audit.TrustedProxies < 0 {
+			log.Fatal("TRUSTED_PROXIES must be the number of proxies in front of the server")
+		}
+	} else {
+		log.Println("⚠️  TRUSTED_PROXIES not set — X-Forwarded-For is ignored and clients are identified by socket address")
+	}
+
+	resolver := access.NewResolver(st)
+	auditLog := audit.New(st.Audit)
+	mfaService := mfa.NewService(st.MFA, "Aletheia")
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(client)
-	buildingsHandler := handlers.NewBuildingsHandler(client)
-	paymentsHandler := handlers.NewPaymentsHandler(client)
-	invitationsHandler := handlers.NewInvitationsHandler(client)
-	maintenanceHandler := handlers.NewMaintenanceHandler(client)
-	documentsHandler := handlers.NewDocumentsHandler(client)
-	dashboardHandler := handlers.NewDashboardHandler(client)
+	authHandler := handlers.NewAuthHandler(client.Auth, adminAuth, st, notifier, mfaService, otpSecret, auditLog)
+	buildingsHandler := handlers.NewBuildingsHandler(st, auditLog)
+	paymentsHandler := handlers.NewPaymentsHandler(st, paystackClient, auditLog)
+	invitationsHandler := handlers.NewInvitationsHandler(st, notifier, auditLog)
+	maintenanceHandler := handlers.NewMaintenanceHandler(st, auditLog)
+	documentsHandler := handlers.NewDocumentsHandler(st, auditLog)
+	tenanciesHandler := handlers.NewTenanciesHandler(st, auditLog)
+	dashboardHandler := handlers.NewDashboardHandler(st)
+	staffHandler := handlers.NewStaffHandler(st, notifier, auditLog)
+	organisationsHandler := handlers.NewOrganisationsHandler(st, notifier, resolver, auditLog)
+	adminHandler := handlers.NewAdminHandler(st, notifier, paystackClient, auditLog)
+	apiKeysHandler := handlers.NewAPIKeysHandler(st, auditLog)
+	auditHandler := handlers.NewAuditHandler(st)
+	searchHandler := handlers.NewSearchHandler(st)
+	importsHandler := handlers.NewImportsHandler(st, notifier, auditLog)
+	accountHandler := handlers.NewAccountHandler(adminAuth, st, handlers.SupabaseAvatars{Storage: client.Storage}, notifier, mfaService, otpSecret, auditLog)
 
 	// Create router
 	mux := http.NewServeMux()
@@ -47,46 +201,138 @@ func main() {
 	// PUBLIC ROUTES (no auth required) - v1
 	// ============================================
 	mux.HandleFunc("POST /api/v1/auth/signup", authHandler.Signup)
-	mux.HandleFunc("POST /api/v1/auth/login", authHandler.Login)
-	mux.HandleFunc("POST /api/v1/auth/accept-invite", authHandler.AcceptInvite)
-	mux.HandleFunc("GET /api/v1/invitations/verify", invitationsHandler.GetInviteByToken)
-	mux.HandleFunc("POST /api/v1/webhooks/paystack", paymentsHandler.PaystackWebhook)
+	mux.Handle("POST /api/v1/auth/login", mw.RateLimit(limiter, mw.RatePolicy{Route: "login", PerIP: ratelimit.PerMinute(20), PerAccount: ratelimit.PerMinute(10), Account: mw.BodyField("email")})(http.HandlerFunc(authHandler.Login)))
+	mux.Handle("POST /api/v1/auth/login/2fa", mw.RateLimit(limiter, mw.RatePolicy{Route: "login_2fa", PerIP: ratelimit.PerMinute(20)})(http.HandlerFunc(authHandler.LoginMFA)))
+	mux.Handle("POST /api/v1/auth/accept-invite", mw.RateLimit(limiter, mw.RatePolicy{Route: "accept_invite", PerIP: ratelimit.PerMinute(10)})(http.HandlerFunc(authHandler.AcceptInvite)))
+	// Each code request sends a paid SMS: few per IP, on top of the
+	// per-number cooldown the handler applies
+	mux.Handle("POST /api/v1/auth/otp/request", mw.RateLimit(limiter, mw.RatePolicy{Route: "otp_request", PerIP: ratelimit.Limit{PerMinute: 2, Burst: 5}})(http.HandlerFunc(authHandler.RequestOTP)))
+	mux.Handle("POST /api/v1/auth/otp/verify", mw.RateLimit(limiter, mw.RatePolicy{Route: "otp_verify", PerIP: ratelimit.PerMinute(10), PerAccount: ratelimit.PerMinute(5), Account: mw.BodyField("phone")})(http.HandlerFunc(authHandler.VerifyOTP)))
+	mux.Handle("POST /api/v1/auth/password/forgot", mw.RateLimit(limiter, mw.RatePolicy{Route: "password_forgot", PerIP: ratelimit.PerMinute(10), PerAccount: ratelimit.Limit{PerMinute: 1, Burst: 3}, Account: mw.BodyField("email")})(http.HandlerFunc(authHandler.ForgotPassword)))
+	mux.HandleFunc("POST /api/v1/auth/password/reset", authHandler.ResetPassword)
+	mux.HandleFunc("POST /api/v1/auth/email/verify", authHandler.VerifyEmail)
+	mux.HandleFunc("POST /api/v1/auth/email/change/confirm", accountHandler.ConfirmEmailChange)
+	mux.Handle("GET /api/v1/invitations/verify", mw.RateLimit(limiter, mw.RatePolicy{Route: "invite_verify", PerIP: ratelimit.PerMinute(30)})(http.HandlerFunc(invitationsHandler.GetInviteByToken)))
+	mux.Handle("POST /api/v1/webhooks/paystack", mw.RateLimit(limiter, mw.RatePolicy{Route: "paystack_webhook", PerIP: ratelimit.PerMinute(120)})(http.HandlerFunc(paymentsHandler.PaystackWebhook)))
 
 	// ============================================
 	// AUTHENTICATED ROUTES - v1
 	// ============================================
-	authMw := mw.AuthMiddleware(supabaseURL, supabaseKey)
+	baseAuth := mw.AuthMiddleware(supabaseURL, st, limiter)
+	impersonation := mw.Impersonation(st, auditLog)
+	authMw := func(next http.Handler) http.Handler { return baseAuth(impersonation(next)) }
+
+	// --- Account ---
+	mux.Handle("POST /api/v1/auth/email/resend", authMw(http.HandlerFunc(authHandler.ResendVerification)))
+	mux.Handle("POST /api/v1/auth/roles", authMw(http.HandlerFunc(authHandler.AddRole)))
+	mux.Handle("GET /api/v1/me", authMw(http.HandlerFunc(accountHandler.GetProfile)))
+	mux.Handle("PATCH /api/v1/me", authMw(http.HandlerFunc(accountHandler.UpdateProfile)))
+	mux.Handle("POST /api/v1/me/phone/confirm", authMw(http.HandlerFunc(accountHandler.ConfirmPhoneChange)))
+	mux.Handle("POST /api/v1/me/avatar", authMw(http.HandlerFunc(accountHandler.UploadAvatar)))
+	mux.Handle("DELETE /api/v1/me/avatar", authMw(http.HandlerFunc(accountHandler.DeleteAvatar)))
+	mux.Handle("GET /api/v1/me/export", authMw(mw.RequireMFA(mfaService, st.Lockouts)(http.HandlerFunc(accountHandler.ExportData))))
+	mux.Handle("DELETE /api/v1/me", authMw(mw.RequireMFA(mfaService, st.Lockouts)(http.HandlerFunc(accountHandler.DeleteAccount))))
+
+	// --- API Keys (Landlord integrations) ---
+	mux.Handle("GET /api/v1/api-keys", authMw(mw.RequireRole("landlord")(http.HandlerFunc(apiKeysHandler.ListAPIKeys))))
+	mux.Handle("POST /api/v1/api-keys", authMw(mw.RequireRole("landlord")(mw.RequireMFA(mfaService, st.Lockouts)(http.HandlerFunc(apiKeysHandler.CreateAPIKey)))))
+	mux.Handle("DELETE /api/v1/api-keys/{id}", authMw(mw.RequireRole("landlord")(http.HandlerFunc(apiKeysHandler.RevokeAPIKey))))
+
+	// --- Two-Factor Authentication (Landlord) ---
+	mux.Handle("GET /api/v1/auth/2fa", authMw(mw.RequireRole("landlord")(http.HandlerFunc(authHandler.MFAStatus))))
+	mux.Handle("POST /api/v1/auth/2fa/enroll", authMw(mw.RequireRole("landlord")(http.HandlerFunc(authHandler.EnrollMFA))))
+	mux.Handle("POST /api/v1/auth/2fa/confirm", authMw(mw.RequireRole("landlord")(http.HandlerFunc(authHandler.ConfirmMFA))))
+	mux.Handle("POST /api/v1/auth/2fa/recovery-codes", authMw(mw.RequireRole("landlord")(http.HandlerFunc(authHandler.RegenerateRecoveryCodes))))
+	mux.Handle("PUT /api/v1/auth/2fa/settings", authMw(mw.RequireRole("landlord")(http.HandlerFunc(authHandler.UpdateMFASettings))))
+	mux.Handle("POST /api/v1/auth/2fa/disable", authMw(mw.RequireRole("landlord")(http.HandlerFunc(authHandler.DisableMFA))))
 
 	// --- Dashboard ---
-	mux.Handle("GET /api/v1/dashboard/landlord", authMw(mw.RequireRole("landlord")(http.HandlerFunc(dashboardHandler.LandlordDashboard))))
+	mux.Handle("GET /api/v1/dashboard/landlord", authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(dashboardHandler.LandlordDashboard))))
 	mux.Handle("GET /api/v1/dashboard/tenant", authMw(mw.RequireRole("tenant")(http.HandlerFunc(dashboardHandler.TenantDashboard))))
 
-	// --- Buildings (Landlord only) ---
-	mux.Handle("GET /api/v1/buildings", authMw(mw.RequireRole("landlord")(http.HandlerFunc(buildingsHandler.ListBuildings))))
-	mux.Handle("POST /api/v1/buildings", authMw(mw.RequireRole("landlord")(http.HandlerFunc(buildingsHandler.CreateBuilding))))
-	mux.Handle("GET /api/v1/buildings/{id}", authMw(mw.RequireRole("landlord")(http.HandlerFunc(buildingsHandler.GetBuilding))))
-	mux.Handle("PUT /api/v1/buildings/{id}", authMw(mw.RequireRole("landlord")(http.HandlerFunc(buildingsHandler.UpdateBuilding))))
+	// --- Search (across the caller's buildings) ---
+	mux.Handle("GET /api/v1/search", authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(searchHandler.Search))))
+
+	// --- Buildings (Landlord & staff, per-building permissions) ---
+	mux.Handle("GET /api/v1/buildings", mw.AllowAPIKey(mw.ScopeBuildingsRead)(authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(buildingsHandler.ListBuildings)))))
+	mux.Handle("POST /api/v1/buildings", authMw(mw.RequireRole("landlord")(mw.RequireVerifiedEmail(http.HandlerFunc(buildingsHandler.CreateBuilding)))))
+	mux.Handle("GET /api/v1/buildings/{id}", mw.AllowAPIKey(mw.ScopeBuildingsRead)(authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(buildingsHandler.GetBuilding)))))
+	mux.Handle("PUT /api/v1/buildings/{id}", authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(buildingsHandler.UpdateBuilding))))
+	mux.Handle("POST /api/v1/buildings/{id}/archive", authMw(mw.RequirePermission(resolver, access.ManageOwnership)(http.HandlerFunc(buildingsHandler.ArchiveBuilding))))
+	mux.Handle("POST /api/v1/buildings/{id}/restore", authMw(mw.RequirePermission(resolver, access.ManageOwnership)(http.HandlerFunc(buildingsHandler.RestoreBuilding))))
+	mux.Handle("POST /api/v1/buildings/{id}/import", authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(importsHandler.ImportBuilding))))
+
+	// --- Co-ownership ---
+	mux.Handle("GET /api/v1/buildings/{id}/owners", authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(buildingsHandler.ListOwners))))
+	mux.Handle("PUT /api/v1/buildings/{id}/owners", authMw(mw.RequirePermission(resolver, access.ManageOwnership)(http.HandlerFunc(buildingsHandler.SetOwners))))
+	mux.Handle("GET /api/v1/buildings/{id}/statement", authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(buildingsHandler.OwnerStatement))))
+
+	// --- Building Staff ---
+	mux.Handle("GET /api/v1/buildings/{id}/staff", authMw(mw.RequirePermission(resolver, access.ManageStaff)(http.HandlerFunc(staffHandler.ListStaff))))
+	mux.Handle("POST /api/v1/buildings/{id}/staff", authMw(mw.RequirePermission(resolver, access.ManageStaff)(http.HandlerFunc(staffHandler.InviteStaff))))
+	mux.Handle("DELETE /api/v1/buildings/{id}/staff/{memberId}", authMw(mw.RequirePermission(resolver, access.ManageStaff)(http.HandlerFunc(staffHandler.RevokeStaff))))
+	mux.Handle("POST /api/v1/staff/accept", authMw(http.HandlerFunc(staffHandler.AcceptStaffInvite)))
 
-	// --- Units (Landlord) ---
-	mux.Handle("GET /api/v1/buildings/{id}/units", authMw(mw.RequireRole("landlord")(http.HandlerFunc(buildingsHandler.ListUnits))))
-	mux.Handle("POST /api/v1/units", authMw(mw.RequireRole("landlord")(http.HandlerFunc(buildingsHandler.CreateUnit))))
+	// --- Organisations (property management companies) ---
+	mux.Handle("POST /api/v1/organisations", authMw(mw.RequireRole("landlord")(mw.RequireVerifiedEmail(http.HandlerFunc(organisationsHandler.CreateOrganisation)))))
+	mux.Handle("GET /api/v1/organisations", authMw(http.HandlerFunc(organisationsHandler.ListOrganisations)))
+	mux.Handle("POST /api/v1/organisations/accept", authMw(http.HandlerFunc(organisationsHandler.AcceptInvite)))
+	mux.Handle("GET /api/v1/organisations/{id}/members", authMw(http.HandlerFunc(organisationsHandler.ListMembers)))
+	mux.Handle("POST /api/v1/organisations/{id}/members", authMw(http.HandlerFunc(organisationsHandler.InviteMember)))
+	mux.Handle("DELETE /api/v1/organisations/{id}/members/{memberId}", authMw(http.HandlerFunc(organisationsHandler.RevokeMember)))
+	mux.Handle("GET /api/v1/organisations/{id}/portfolio", authMw(http.HandlerFunc(organisationsHandler.Portfolio)))
+	mux.Handle("GET /api/v1/organisations/{id}/statements", authMw(http.HandlerFunc(organisationsHandler.OwnerStatement)))
+	mux.Handle("PUT /api/v1/buildings/{id}/organisation", authMw(mw.RequireRole("landlord")(http.HandlerFunc(organisationsHandler.AssignBuilding))))
+
+	// --- Units ---
+	mux.Handle("GET /api/v1/buildings/{id}/units", mw.AllowAPIKey(mw.ScopeUnitsRead)(authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(buildingsHandler.ListUnits)))))
+	mux.Handle("POST /api/v1/buildings/{id}/units/generate", mw.AllowAPIKey(mw.ScopeUnitsWrite)(authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(buildingsHandler.GenerateUnits)))))
+	mux.Handle("POST /api/v1/units", mw.AllowAPIKey(mw.ScopeUnitsWrite)(authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(buildingsHandler.CreateUnit)))))
+	mux.Handle("GET /api/v1/units/{id}", mw.AllowAPIKey(mw.ScopeUnitsRead)(authMw(mw.RequirePermission(resolver, access.ViewBuilding)(http.HandlerFunc(buildingsHandler.GetUnit)))))
+	mux.Handle("PUT /api/v1/units/{id}", mw.AllowAPIKey(mw.ScopeUnitsWrite)(authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(buildingsHandler.UpdateUnit)))))
+	mux.Handle("POST /api/v1/units/{id}/archive", authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(buildingsHandler.ArchiveUnit))))
+	mux.Handle("POST /api/v1/units/{id}/restore", authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(buildingsHandler.RestoreUnit))))
+
+	// --- Tenancies ---
+	mux.Handle("GET /api/v1/tenancies/{id}", mw.AllowAPIKey(mw.ScopeUnitsRead)(authMw(mw.WithGrants(resolver)(http.HandlerFunc(tenanciesHandler.GetTenancy)))))
+	mux.Handle("PUT /api/v1/tenancies/{id}", mw.AllowAPIKey(mw.ScopeUnitsWrite)(authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(tenanciesHandler.UpdateTenancy)))))
+	mux.Handle("POST /api/v1/tenancies/{id}/end", mw.AllowAPIKey(mw.ScopeUnitsWrite)(authMw(mw.RequirePermission(resolver, access.ManageBuilding)(http.HandlerFunc(tenanciesHandler.EndTenancy)))))
 
 	// --- Payments ---
-	mux.Handle("POST /api/v1/payments/initialize", authMw(mw.RequireRole("tenant")(http.HandlerFunc(paymentsHandler.InitializePayment))))
-	mux.Handle("GET /api/v1/payments", authMw(http.HandlerFunc(paymentsHandler.ListPayments)))
+	mux.Handle("POST /api/v1/payments/initialize", authMw(mw.RequireRole("tenant")(mw.RateLimit(limiter, mw.RatePolicy{Route: "payment_init", PerIP: ratelimit.PerMinute(30), PerAccount: ratelimit.PerMinute(5)})(http.HandlerFunc(paymentsHandler.InitializePayment)))))
+	mux.Handle("POST /api/v1/payments/offline", mw.AllowAPIKey(mw.ScopePaymentsWrite)(authMw(mw.RequirePermission(resolver, access.RecordPayments)(http.HandlerFunc(paymentsHandler.RecordOfflinePayment)))))
+	mux.Handle("GET /api/v1/payments", mw.AllowAPIKey(mw.ScopePaymentsRead)(authMw(mw.WithGrants(resolver)(http.HandlerFunc(paymentsHandler.ListPayments)))))
 
-	// --- Invitations (Landlord) ---
-	mux.Handle("POST /api/v1/invitations", authMw(mw.RequireRole("landlord")(http.HandlerFunc(invitationsHandler.SendInvite))))
-	mux.Handle("GET /api/v1/invitations", authMw(mw.RequireRole("landlord")(http.HandlerFunc(invitationsHandler.ListInvitations))))
+	// --- Invitations ---
+	mux.Handle("POST /api/v1/invitations", authMw(mw.RequirePermission(resolver, access.InviteTenants)(http.HandlerFunc(invitationsHandler.SendInvite))))
+	mux.Handle("GET /api/v1/invitations", authMw(mw.RequirePermission(resolver, access.InviteTenants)(http.HandlerFunc(invitationsHandler.ListInvitations))))
+	mux.Handle("POST /api/v1/invitations/accept", authMw(http.HandlerFunc(invitationsHandler.ClaimInvite)))
 
 	// --- Maintenance Requests ---
 	mux.Handle("POST /api/v1/maintenance", authMw(mw.RequireRole("tenant")(http.HandlerFunc(maintenanceHandler.CreateRequest))))
-	mux.Handle("GET /api/v1/maintenance", authMw(http.HandlerFunc(maintenanceHandler.ListRequests)))
-	mux.Handle("PUT /api/v1/maintenance/{id}/status", authMw(mw.RequireRole("landlord")(http.HandlerFunc(maintenanceHandler.UpdateRequestStatus))))
+	mux.Handle("GET /api/v1/maintenance", mw.AllowAPIKey(mw.ScopeMaintenanceRead)(authMw(mw.WithGrants(resolver)(http.HandlerFunc(maintenanceHandler.ListRequests)))))
+	mux.Handle("PUT /api/v1/maintenance/{id}/status", authMw(mw.RequirePermission(resolver, access.ManageMaintenance)(http.HandlerFunc(maintenanceHandler.UpdateRequestStatus))))
 
 	// --- Documents ---
-	mux.Handle("POST /api/v1/documents", authMw(http.HandlerFunc(documentsHandler.UploadDocument)))
-	mux.Handle("GET /api/v1/documents", authMw(http.HandlerFunc(documentsHandler.ListDocuments)))
+	mux.Handle("POST /api/v1/documents", authMw(mw.WithGrants(resolver)(http.HandlerFunc(documentsHandler.UploadDocument))))
+	mux.Handle("GET /api/v1/documents", authMw(mw.WithGrants(resolver)(http.HandlerFunc(documentsHandler.ListDocuments))))
+
+	// --- Audit log (building owners and platform admins) ---
+	mux.Handle("GET /api/v1/audit", authMw(mw.WithGrants(resolver)(http.HandlerFunc(auditHandler.ListAuditLog))))
+
+	// ============================================
+	// PLATFORM ADMIN ROUTES - v1
+	// ============================================
+	adminMw := func(next http.Handler) http.Handler { return authMw(mw.RequireRole("admin")(next)) }
+
+	mux.Handle("GET /api/v1/admin/users", adminMw(http.HandlerFunc(adminHandler.SearchUsers)))
+	mux.Handle("GET /api/v1/admin/buildings", adminMw(http.HandlerFunc(adminHandler.SearchBuildings)))
+	mux.Handle("GET /api/v1/admin/payments", adminMw(http.HandlerFunc(adminHandler.SearchPayments)))
+	mux.Handle("POST /api/v1/admin/payments/{id}/reconcile", adminMw(http.HandlerFunc(adminHandler.ReconcilePayment)))
+	mux.Handle("GET /api/v1/admin/webhook-events", adminMw(http.HandlerFunc(adminHandler.ListWebhookEvents)))
+	mux.Handle("POST /api/v1/admin/invitations/{id}/resend", adminMw(http.HandlerFunc(adminHandler.ResendInvite)))
+	mux.Handle("POST /api/v1/admin/impersonate", adminMw(http.HandlerFunc(adminHandler.StartImpersonation)))
+	mux.Handle("DELETE /api/v1/admin/impersonate/{id}", adminMw(http.HandlerFunc(adminHandler.EndImpersonation)))
 
 	// ============================================
 	// STATIC FILE SERVER (frontend)
@@ -94,15 +340,155 @@ func main() {
 	fs := http.FileServer(http.Dir("../web"))
 	mux.Handle("/", fs)
 
-	// Wrap everything with CORS
-	handler := mw.CORSMiddleware(mux)
+	// Browsers may only call the API from allowed origins, e.g.
+	// CORS_ALLOWED_ORIGINS=https://aletheia.ng,https://*.vercel.app
+	corsConfig := mw.DefaultCORSConfig()
+	corsConfig.AllowedOrigins = splitList(getEnv("CORS_ALLOWED_ORIGINS", appURL))
+	if v := getEnv("CORS_ALLOWED_METHODS", ""); v != "" {
+		corsConfig.AllowedMethods = splitList(v)
+	}
+	if v := getEnv("CORS_ALLOWED_HEADERS", ""); v != "" {
+		corsConfig.AllowedHeaders = splitList(v)
+	}
+	corsConfig.AllowCredentials = getEnv("CORS_ALLOW_CREDENTIALS", "true") == "true"
+	csp := getEnv("CONTENT_SECURITY_POLICY", mw.DefaultFrontendCSP)
+
+	// Wrap everything with security headers and CORS, and tag each request with an ID
+	handler := mw.SecurityHeaders(csp)(mw.CORS(corsConfig)(mw.RequestID(mux)))
 
 	fmt.Printf("🚀 Aletheia server running on http://localhost:%s\n", port)
-	fmt.Println("📋 API endpoints: 22 routes registered")
+	fmt.Println("📋 API endpoints: 86 routes registered")
 	fmt.Println("🗄️  Database: Supabase (manged)")
 	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
 
+// reencrypt encrypts plaintext left from before encryption was enabled and
+// moves values under old keys to the current one
+func reencrypt(supabaseURL, key string, cipher *encryption.Cipher) {
+	if cipher == nil {
+		log.Fatal("reencrypt needs ENCRYPTION_KEY_FILE or ENCRYPTION_KEYS")
+	}
+	raw, err := supabase.NewClient(supabaseURL, key, &supabase.ClientOptions{
+		Headers: map[string]string{encryption.BypassHeader: "1"},
+	})
+	if err != nil {
+		log.Fatal("Failed to initialize Supabase client:", err)
+	}
+
+	updated, err := encryption.Reencrypt(raw, cipher, encryption.Sensitive)
+	for table, n := range updated {
+		fmt.Printf("🔐 %s: %d rows re-encrypted\n", table, n)
+	}
+	if err != nil {
+		log.Fatalf("reencrypt stopped: %v (safe to run again)", err)
+	}
+	fmt.Println("✅ All sensitive fields are encrypted under the current key")
+}
+
+// connectPostgres opens a connection pool and checks it works. Use the
+// direct connection or session pooler: the transaction pooler does not
+// keep the prepared statements pgx relies on.
+func connectPostgres(databaseURL string) (*pgxpool.Pool, error) {
+	if databaseURL == "" {
+		return nil, fmt.Errorf("DATA_STORE=postgres needs DATABASE_URL")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// runMigrations applies or reverts the embedded schema migrations:
+// `migrate up`, `migrate down [n|all]` (one step by default) or
+// `migrate status`. databaseURL is the Postgres connection string; on
+// Supabase use the direct connection, not the transaction pooler.
+func runMigrations(databaseURL string, args []string) {
+	usage := "usage: server migrate up | down [n|all] | status"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	if databaseURL == "" {
+		log.Fatal("migrate needs DATABASE_URL")
+	}
+
+	ctx := context.Background()
+	m, err := migrate.Open(ctx, databaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to the database: %v", err)
+	}
+	defer m.Close(ctx)
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("⬆️  %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("✅ %d migrations applied; the database is up to date\n", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if args[1] == "all" {
+				steps = -1
+			} else if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(usage)
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			fmt.Printf("⬇️  %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("✅ %d migrations reverted\n", len(reverted))
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-28s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitList parses a comma-separated env value, dropping blanks
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -0,0 +1,203 @@
+package access
+
+import (
+	"sort"
+
+	"github.com/aletheia/backend/internal/store"
+)
+
+// Role is a user's role within a single building or organisation
+type Role string
+
+const (
+	RoleOwner      Role = "owner"
+	RoleManager    Role = "manager"
+	RoleCaretaker  Role = "caretaker"
+	RoleAccountant Role = "accountant"
+	RoleAdmin      Role = "admin"    // organisation administrator
+	RoleCoOwner    Role = "co_owner" // co-owner without management rights
+)
+
+// Permission is a fine-grained capability on a building
+type Permission string
+
+const (
+	ViewBuilding      Permission = "building:view"
+	ManageBuilding    Permission = "building:manage" // edit details, create units
+	ManageStaff       Permission = "staff:manage"
+	InviteTenants     Permission = "tenants:invite"
+	RecordPayments    Permission = "payments:record" // offline cash / transfer payments
+	ViewFinancials    Permission = "financials:view"
+	ManageMaintenance Permission = "maintenance:manage"
+	ManageDocuments   Permission = "documents:manage"
+	ManageOwnership   Permission = "ownership:manage" // co-owners and shares
+	ViewOwnStatement  Permission = "statement:view_own"
+	ViewAuditLog      Permission = "audit:view"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		ViewBuilding, ManageBuilding, ManageStaff, InviteTenants,
+		RecordPayments, ViewFinancials, ManageMaintenance, ManageDocuments,
+		ManageOwnership, ViewOwnStatement, ViewAuditLog,
+	},
+	RoleCoOwner: {
+		ViewBuilding, ViewOwnStatement,
+	},
+	RoleAdmin: {
+		ViewBuilding, ManageBuilding, ManageStaff, InviteTenants,
+		RecordPayments, ViewFinancials, ManageMaintenance, ManageDocuments,
+		ViewAuditLog,
+	},
+	RoleManager: {
+		ViewBuilding, ManageBuilding, InviteTenants,
+		RecordPayments, ViewFinancials, ManageMaintenance, ManageDocuments,
+	},
+	RoleCaretaker: {
+		ViewBuilding, RecordPayments, ManageMaintenance,
+	},
+	RoleAccountant: {
+		ViewBuilding, RecordPayments, ViewFinancials,
+	},
+}
+
+// ValidStaffRole reports whether role can be granted through a staff invite.
+// Ownership comes from creating the building, not from an invite.
+func ValidStaffRole(role Role) bool {
+	return role == RoleManager || role == RoleCaretaker || role == RoleAccountant
+}
+
+// ValidOrgRole reports whether role can be granted through an organisation
+// invite. Owner members are the organisation's clients: they get nothing
+// org-wide and only see the buildings they own.
+func ValidOrgRole(role Role) bool {
+	return role == RoleAdmin || role == RoleOwner || ValidStaffRole(role)
+}
+
+// PermissionsFor returns the permissions held by a role
+func PermissionsFor(role Role) []Permission {
+	return rolePermissions[role]
+}
+
+// Grants maps building ID → the permissions a user holds on that building
+type Grants map[string]map[Permission]bool
+
+// Can reports whether the grants include perm on the building
+func (g Grants) Can(buildingID string, perm Permission) bool {
+	return g[buildingID][perm]
+}
+
+// Any reports whether perm is held on at least one building
+func (g Grants) Any(perm Permission) bool {
+	for _, perms := range g {
+		if perms[perm] {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildingIDs returns the sorted IDs of buildings where perm is held
+func (g Grants) BuildingIDs(perm Permission) []string {
+	ids := []string{}
+	for id, perms := range g {
+		if perms[perm] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (g Grants) add(buildingID string, role Role) {
+	if g[buildingID] == nil {
+		g[buildingID] = map[Permission]bool{}
+	}
+	for _, p := range rolePermissions[role] {
+		g[buildingID][p] = true
+	}
+}
+
+// Resolver loads a user's grants from building ownership and active
+// building and organisation memberships
+type Resolver struct {
+	store *store.Store
+}
+
+func NewResolver(st *store.Store) *Resolver {
+	return &Resolver{store: st}
+}
+
+// Grants returns every building the user can act on and what they can do there
+func (r *Resolver) Grants(userID string) (Grants, error) {
+	grants := Grants{}
+
+	// Co-owners listed on a building manage it only when designated to
+	coOwned, err := r.store.Owners.ListOwnerships(userID)
+	if err != nil {
+		return nil, err
+	}
+	managing := map[string]bool{}
+	for _, o := range coOwned {
+		managing[o.BuildingID] = o.Managing
+		if o.Managing {
+			grants.add(o.BuildingID, RoleOwner)
+		} else {
+			grants.add(o.BuildingID, RoleCoOwner)
+		}
+	}
+
+	// The landlord who created a building owns it outright unless the
+	// ownership list says otherwise
+	owned, err := r.store.Buildings.LandlordBuildings(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range owned {
+		if m, listed := managing[b.ID]; !listed || m {
+			grants.add(b.ID, RoleOwner)
+		}
+	}
+
+	staffRoles, err := r.store.Staff.StaffRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	for buildingID, role := range staffRoles {
+		grants.add(buildingID, Role(role))
+	}
+
+	// Organisation staff act on every building the organisation manages
+	orgRoles, err := r.OrgRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	for orgID, role := range orgRoles {
+		if role == RoleOwner {
+			continue
+		}
+		managed, err := r.store.Organisations.OrganisationBuildings(orgID, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range managed {
+			grants.add(b.ID, role)
+		}
+	}
+
+	return grants, nil
+}
+
+// OrgRoles returns organisation ID → the user's role for each organisation
+// they are an active member of
+func (r *Resolver) OrgRoles(userID string) (map[string]Role, error) {
+	members, err := r.store.Organisations.OrganisationRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	roles := map[string]Role{}
+	for orgID, role := range members {
+		roles[orgID] = Role(role)
+	}
+	return roles, nil
+}
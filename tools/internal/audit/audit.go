@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// RequestIDHeader carries the request ID set by middleware.RequestID
+const RequestIDHeader = "X-Request-ID"
+
+// Entry is one recorded action
+type Entry struct {
+	ActorID      string
+	ActorRole    string
+	Action       string // e.g. "admin.payment.reconcile"
+	ResourceType string // e.g. "payment"
+	ResourceID   string
+	BuildingID   string // lets building owners read entries about their buildings
+	Before       interface{}
+	After        interface{}
+	Metadata     map[string]interface{}
+}
+
+// Logger writes entries to the append-only audit log
+type Logger struct {
+	store store.AuditStore
+}
+
+func New(st store.AuditStore) *Logger {
+	return &Logger{store: st}
+}
+
+// Record stores an entry with the request's IP, user agent and request ID,
+// and the field-level diff between Before and After. Failures are logged
+// rather than returned: an audit outage must not block the action, but it
+// must be visible. A nil Logger records nothing.
+func (l *Logger) Record(r *http.Request, e Entry) {
+	if l == nil {
+		return
+	}
+	entry := models.AuditEntry{
+		ActorID:      nullable(e.ActorID),
+		ActorRole:    e.ActorRole,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   nullable(e.ResourceID),
+		BuildingID:   nullable(e.BuildingID),
+		IP:           ClientIP(r),
+		UserAgent:    r.UserAgent(),
+		RequestID:    nullable(r.Header.Get(RequestIDHeader)),
+	}
+	var err error
+	if changes := Diff(e.Before, e.After); changes != nil {
+		entry.Changes, err = json.Marshal(changes)
+	}
+	if err == nil && e.Metadata != nil {
+		entry.Metadata, err = json.Marshal(redactContacts(e.Metadata))
+	}
+	if err == nil {
+		err = l.store.RecordAuditEntry(entry)
+	}
+	if err != nil {
+		log.Printf("audit: failed to record %s by %s: %v", e.Action, e.ActorID, err)
+	}
+}
+
+// Change is one field's value before and after an action
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// ignoredFields never appear in a diff: timestamps change on every write
+// and secrets must not be copied into the log
+var ignoredFields = []string{"updated_at", "created_at"}
+
+var secretMarkers = []string{"password", "token", "secret", "hash"}
+
+// contactFields hold emails and phone numbers. The log keeps whether they
+// were set, changed or cleared, never the address or number itself.
+var contactFields = []string{"email", "phone", "pending_email", "pending_phone", "tenant_email", "tenant_phone"}
+
+const redacted = "[redacted]"
+
+// Diff compares two values by their JSON fields and returns the fields that
+// differ. A nil before records a create, a nil after a delete. Secret-looking
+// fields are reported as changed without their values, and contact fields
+// only say whether a value was there.
+func Diff(before, after interface{}) map[string]Change {
+	from, to := fields(before), fields(after)
+	changes := map[string]Change{}
+	for key := range keys(from, to) {
+		if slices.Contains(ignoredFields, key) || reflect.DeepEqual(from[key], to[key]) {
+			continue
+		}
+		if secret(key) {
+			changes[key] = Change{From: redacted, To: redacted}
+			continue
+		}
+		if slices.Contains(contactFields, key) {
+			changes[key] = Change{From: redactValue(from[key]), To: redactValue(to[key])}
+			continue
+		}
+		changes[key] = Change{From: from[key], To: to[key]}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func fields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	return m
+}
+
+func keys(maps ...map[string]interface{}) map[string]bool {
+	all := map[string]bool{}
+	for _, m := range maps {
+		for k := range m {
+			all[k] = true
+		}
+	}
+	return all
+}
+
+// redactContacts returns meta with the values of contact fields replaced
+func redactContacts(meta map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(meta))
+	for k, v := range meta {
+		if slices.Contains(contactFields, k) {
+			v = redactValue(v)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	if v == nil || v == "" {
+		return v
+	}
+	return redacted
+}
+
+func secret(key string) bool {
+	for _, marker := range secretMarkers {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedProxies is how many reverse proxies in front of the server append
+// the address they were connected from to X-Forwarded-For. Hops to the left
+// of theirs are whatever the client sent and are never used; with 0 the
+// header is ignored. Set once at startup.
+var TrustedProxies int
+
+// ClientIP returns the caller's IP: the X-Forwarded-For hop appended by the
+// outermost trusted proxy, or the socket address when there is none
+func ClientIP(r *http.Request) string {
+	if TrustedProxies > 0 {
+		var hops []string
+		for _, fwd := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(fwd, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		if len(hops) >= TrustedProxies {
+			return hops[len(hops)-TrustedProxies]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
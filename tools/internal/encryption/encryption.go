@@ -0,0 +1,297 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Prefix marks an encrypted value. The full format is
+//
+//	enc:v2:<key ID>:<wrapped data key>:<table.column>:<nonce + ciphertext>
+//
+// with both binary parts base64url-encoded. Carrying the wrapped data key
+// makes every value decryptable on its own (envelope encryption). The
+// table.column the value is stored in is the GCM additional data, so it
+// cannot be edited, and a value copied into another column is refused when
+// read back. Values in the older enc:v1: format have no column and still
+// decrypt until `server reencrypt` rewrites them.
+const Prefix = "enc:v2:"
+
+const prefixV1 = "enc:v1:"
+
+var (
+	ErrMalformed   = errors.New("encryption: malformed ciphertext")
+	ErrWrongColumn = errors.New("encryption: value was encrypted for a different column")
+)
+
+// dataKey is a data-encryption key and its wrapped form
+type dataKey struct {
+	keyID   string
+	plain   []byte
+	wrapped string
+}
+
+// Cipher encrypts field values with data keys wrapped by a KeyProvider.
+// One data key is used per process and KEK, so a KMS is called once at
+// startup rather than per value; unwrapped keys are cached for reads.
+type Cipher struct {
+	keys     KeyProvider
+	indexKey []byte
+
+	mu      sync.Mutex
+	current *dataKey
+	cache   map[string][]byte // keyID + ":" + wrapped → plain data key
+}
+
+func NewCipher(keys KeyProvider, indexKey []byte) *Cipher {
+	return &Cipher{keys: keys, indexKey: indexKey, cache: map[string][]byte{}}
+}
+
+// Encrypt returns the ciphertext for plaintext stored in column, given as
+// "table.column"
+func (c *Cipher) Encrypt(column, plaintext string) (string, error) {
+	if !strings.Contains(column, ".") || strings.Contains(column, ":") {
+		return "", fmt.Errorf("encryption: column %q must be table.column", column)
+	}
+	dk, err := c.dataKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dk.plain)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(plaintext), []byte(column))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + dk.keyID + ":" + dk.wrapped + ":" + column + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt. Values without the prefix are returned as they
+// are, so rows written before encryption was enabled still read.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	plaintext, _, err := c.decrypt(value)
+	return plaintext, err
+}
+
+// DecryptColumn is Decrypt for a value read from column ("table.column"):
+// it fails with ErrWrongColumn if the value was encrypted for another one
+func (c *Cipher) DecryptColumn(column, value string) (string, error) {
+	plaintext, sealedFor, err := c.decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	if sealedFor != "" && sealedFor != column {
+		return "", ErrWrongColumn
+	}
+	return plaintext, nil
+}
+
+// decrypt returns the plaintext of value and the table.column it was
+// encrypted for, which is "" for plaintext and enc:v1: values
+func (c *Cipher) decrypt(value string) (string, string, error) {
+	var keyID, wrapped, column, body string
+	switch {
+	case strings.HasPrefix(value, Prefix):
+		parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+		if len(parts) != 4 {
+			return "", "", ErrMalformed
+		}
+		keyID, wrapped, column, body = parts[0], parts[1], parts[2], parts[3]
+	case strings.HasPrefix(value, prefixV1):
+		parts := strings.Split(strings.TrimPrefix(value, prefixV1), ":")
+		if len(parts) != 3 {
+			return "", "", ErrMalformed
+		}
+		keyID, wrapped, body = parts[0], parts[1], parts[2]
+	default:
+		return value, "", nil
+	}
+
+	plainKey, err := c.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", "", err
+	}
+	aead, err := newGCM(plainKey)
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return "", "", ErrMalformed
+	}
+	var additional []byte
+	if column != "" {
+		additional = []byte(column)
+	}
+	plaintext, err := open(aead, sealed, additional)
+	if err != nil {
+		return "", "", err
+	}
+	return string(plaintext), column, nil
+}
+
+// NeedsRotation reports whether value is plaintext, in the old enc:v1:
+// format or under an old KEK
+func (c *Cipher) NeedsRotation(value string) bool {
+	if !strings.HasPrefix(value, Prefix) {
+		return true
+	}
+	keyID, _, _ := strings.Cut(strings.TrimPrefix(value, Prefix), ":")
+	return keyID != c.keys.CurrentKeyID()
+}
+
+// BlindIndex returns a keyed hash of a normalised value, so exact-match
+// lookups work without storing the value in the clear. kind separates
+// domains: the same string as an email and as a name hashes differently.
+func (c *Cipher) BlindIndex(kind, value string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(kind + ":" + Normalize(kind, value)))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// EncryptRow encrypts the given fields of a row (column → value) of table
+// in place and fills in their blind index columns. Null and empty values
+// stay as they are and have no index; values already encrypted are left
+// alone.
+func (c *Cipher) EncryptRow(table string, row map[string]interface{}, fields []Field) error {
+	for _, f := range fields {
+		v, present := row[f.Column]
+		if !present {
+			continue
+		}
+		s, isString := v.(string)
+		if !isString || s == "" || IsEncrypted(s) {
+			if f.Index != "" && !isString {
+				row[f.Index] = nil
+			}
+			continue
+		}
+		enc, err := c.Encrypt(f.storedIn(table), s)
+		if err != nil {
+			return err
+		}
+		row[f.Column] = enc
+		if f.Index != "" {
+			row[f.Index] = c.BlindIndex(f.Kind, s)
+		}
+	}
+	return nil
+}
+
+// DecryptJSON decrypts every encrypted string in a JSON document, at any
+// depth, so embedded rows from other tables are covered too. An embedded
+// row doesn't say which table it came from, so only the column is checked:
+// a value under an object key must have been encrypted for that column.
+func (c *Cipher) DecryptJSON(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc, err := c.decryptValue("", doc)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(doc)
+}
+
+// decryptValue decrypts v, found under the object key key ("" in arrays
+// and at the top level)
+func (c *Cipher) decryptValue(key string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		plaintext, sealedFor, err := c.decrypt(v)
+		if err != nil {
+			return nil, err
+		}
+		if _, column, _ := strings.Cut(sealedFor, "."); key != "" && sealedFor != "" && column != key {
+			return nil, ErrWrongColumn
+		}
+		return plaintext, nil
+	case []interface{}:
+		for i := range v {
+			d, err := c.decryptValue("", v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = d
+		}
+	case map[string]interface{}:
+		for k := range v {
+			d, err := c.decryptValue(k, v[k])
+			if err != nil {
+				return nil, err
+			}
+			v[k] = d
+		}
+	}
+	return v, nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt, in either
+// format
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix) || strings.HasPrefix(value, prefixV1)
+}
+
+// HasCiphertext reports whether a response body may hold encrypted values
+// and needs DecryptJSON
+func HasCiphertext(body []byte) bool {
+	return bytes.Contains(body, []byte(Prefix)) || bytes.Contains(body, []byte(prefixV1))
+}
+
+// dataKey returns the data key for the current KEK, creating and wrapping
+// a new one after a rotation
+func (c *Cipher) dataKey() (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keyID := c.keys.CurrentKeyID()
+	if c.current != nil && c.current.keyID == keyID {
+		return c.current, nil
+	}
+
+	plain := make([]byte, 32)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, err
+	}
+	wrapped, err := c.keys.Wrap(keyID, plain)
+	if err != nil {
+		return nil, err
+	}
+	dk := &dataKey{keyID: keyID, plain: plain, wrapped: base64.RawURLEncoding.EncodeToString(wrapped)}
+	c.current = dk
+	c.cache[keyID+":"+dk.wrapped] = plain
+	return dk, nil
+}
+
+func (c *Cipher) unwrap(keyID, wrapped string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if plain, ok := c.cache[keyID+":"+wrapped]; ok {
+		return plain, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	plain, err := c.keys.Unwrap(keyID, raw)
+	if err != nil {
+		return nil, err
+	}
+	c.cache[keyID+":"+wrapped] = plain
+	return plain, nil
+}
@@ -0,0 +1,245 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestCipher returns a cipher over a keyring holding the keys "old" and
+// "new", with "old" current
+func newTestCipher(t *testing.T) (*Cipher, *LocalKeyring) {
+	t.Helper()
+	file, _ := json.Marshal(keyFile{
+		Current:  "old",
+		Keys:     map[string]string{"old": NewKey(), "new": NewKey()},
+		IndexKey: NewKey(),
+	})
+	keyring, err := ParseKeyring(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCipher(keyring, keyring.IndexKey()), keyring
+}
+
+func TestRoundTrip(t *testing.T) {
+	c, _ := newTestCipher(t)
+
+	tests := []struct {
+		name, column, plaintext string
+	}{
+		{"email", "profiles.email", "ada@example.com"},
+		{"phone", "profiles.phone", "+2348030000003"},
+		{"unicode", "invitations.email", "ọlá@example.ng"},
+		{"separators", "building_members.email", "a:b@example.com"},
+		{"empty", "phone_otps.phone", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := c.Encrypt(tt.column, tt.plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncrypted(enc) || strings.Contains(enc, tt.plaintext) && tt.plaintext != "" {
+				t.Fatalf("Encrypt = %q, want ciphertext", enc)
+			}
+			for _, decrypt := range []func(string) (string, error){
+				c.Decrypt,
+				func(v string) (string, error) { return c.DecryptColumn(tt.column, v) },
+			} {
+				if got, err := decrypt(enc); err != nil || got != tt.plaintext {
+					t.Errorf("decrypted %q, %v; want %q", got, err, tt.plaintext)
+				}
+			}
+		})
+	}
+
+	// Plaintext from before encryption was turned on reads as it is
+	if got, err := c.Decrypt("ada@example.com"); err != nil || got != "ada@example.com" {
+		t.Errorf("Decrypt(plaintext) = %q, %v", got, err)
+	}
+	if _, err := c.Encrypt("email", "ada@example.com"); err == nil {
+		t.Error("Encrypt without a table succeeded")
+	}
+}
+
+func TestColumnBinding(t *testing.T) {
+	c, _ := newTestCipher(t)
+	enc, err := c.Encrypt("profiles.email", "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(enc, Prefix), ":")
+
+	tests := []struct {
+		name   string
+		decode func() (string, error)
+		want   error
+	}{
+		{"same column", func() (string, error) { return c.DecryptColumn("profiles.email", enc) }, nil},
+		{"other table", func() (string, error) { return c.DecryptColumn("invitations.email", enc) }, ErrWrongColumn},
+		{"other column", func() (string, error) { return c.DecryptColumn("profiles.phone", enc) }, ErrWrongColumn},
+		{"relabelled", func() (string, error) {
+			forged := Prefix + strings.Join([]string{parts[0], parts[1], "invitations.email", parts[3]}, ":")
+			return c.DecryptColumn("invitations.email", forged)
+		}, errAny},
+		{"truncated", func() (string, error) { return c.Decrypt(Prefix + strings.Join(parts[:3], ":")) }, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.decode()
+			switch {
+			case tt.want == nil && err != nil:
+				t.Errorf("err = %v, want nil", err)
+			case tt.want == errAny && err == nil:
+				t.Error("forged value decrypted")
+			case tt.want != nil && tt.want != errAny && !errors.Is(err, tt.want):
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// errAny stands for any error in the tables above
+var errAny = errors.New("any error")
+
+func TestDecryptJSON(t *testing.T) {
+	c, _ := newTestCipher(t)
+	email, _ := c.Encrypt("profiles.email", "ada@example.com")
+	phone, _ := c.Encrypt("profiles.phone", "+2348030000003")
+
+	tests := []struct {
+		name, body, want string
+		err              error
+	}{
+		{"row", `{"id":"1","email":"` + email + `"}`, `{"email":"ada@example.com","id":"1"}`, nil},
+		{"embedded", `[{"tenant":{"email":"` + email + `","phone":"` + phone + `"}}]`, `[{"tenant":{"email":"ada@example.com","phone":"+2348030000003"}}]`, nil},
+		{"array value", `["` + email + `"]`, `["ada@example.com"]`, nil},
+		{"numbers kept", `{"amount":6000000000000000001}`, `{"amount":6000000000000000001}`, nil},
+		{"moved column", `{"phone":"` + email + `"}`, "", ErrWrongColumn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.DecryptJSON([]byte(tt.body))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("DecryptJSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	c, keyring := newTestCipher(t)
+	old, err := c.Encrypt("profiles.email", "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := legacyV1(t, c, "ada@example.com")
+
+	keyring.current = "new"
+	fresh, err := c.Encrypt("profiles.email", "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(fresh, Prefix+"new:") {
+		t.Fatalf("after rotation Encrypt = %q, want the new key", fresh)
+	}
+
+	tests := []struct {
+		name, value string
+		rotate      bool
+	}{
+		{"plaintext", "ada@example.com", true},
+		{"enc:v1", v1, true},
+		{"old key", old, true},
+		{"current key", fresh, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.NeedsRotation(tt.value); got != tt.rotate {
+				t.Errorf("NeedsRotation = %v, want %v", got, tt.rotate)
+			}
+
+			// Old values stay readable until reencrypt has rewritten them
+			if got, err := c.Decrypt(tt.value); err != nil || got != "ada@example.com" {
+				t.Errorf("Decrypt = %q, %v", got, err)
+			}
+
+			row := map[string]interface{}{"id": "1", "email": tt.value}
+			update, err := rotateRow(c, "profiles", Sensitive["profiles"], row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.rotate {
+				if len(update) != 0 {
+					t.Errorf("rotateRow rewrote a current value: %v", update)
+				}
+				return
+			}
+			enc, _ := update["email"].(string)
+			if !strings.HasPrefix(enc, Prefix+"new:") || c.NeedsRotation(enc) {
+				t.Errorf("rotated to %q, want the new key", enc)
+			}
+			if got, err := c.DecryptColumn("profiles.email", enc); err != nil || got != "ada@example.com" {
+				t.Errorf("rotated value decrypts to %q, %v", got, err)
+			}
+			if update["email_bidx"] != c.BlindIndex(KindEmail, "ada@example.com") {
+				t.Errorf("email_bidx = %v", update["email_bidx"])
+			}
+		})
+	}
+
+	// A value moved from another column is refused, not re-bound
+	row := map[string]interface{}{"id": "1", "phone": old}
+	if _, err := rotateRow(c, "profiles", Sensitive["profiles"], row); !errors.Is(err, ErrWrongColumn) {
+		t.Errorf("rotateRow of a moved value: err = %v, want ErrWrongColumn", err)
+	}
+}
+
+// legacyV1 encrypts plaintext the way enc:v1: values were written, without
+// additional data
+func legacyV1(t *testing.T, c *Cipher, plaintext string) string {
+	t.Helper()
+	dk, err := c.dataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := newGCM(dk.plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := seal(aead, []byte(plaintext), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prefixV1 + dk.keyID + ":" + dk.wrapped + ":" + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+func TestBlindIndex(t *testing.T) {
+	c, _ := newTestCipher(t)
+
+	tests := []struct {
+		name       string
+		kindA, a   string
+		kindB, b   string
+		wantEquals bool
+	}{
+		{"email case", KindEmail, "Ada@Example.com", KindEmail, "ada@example.com", true},
+		{"email spaces", KindEmail, " ada@example.com ", KindEmail, "ada@example.com", true},
+		{"phone", KindPhone, "+2348030000003", KindPhone, "+2348030000003", true},
+		{"different values", KindEmail, "ada@example.com", KindEmail, "bo@example.com", false},
+		{"kinds are separate", KindEmail, "+2348030000003", KindPhone, "+2348030000003", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.BlindIndex(tt.kindA, tt.a) == c.BlindIndex(tt.kindB, tt.b); got != tt.wantEquals {
+				t.Errorf("indexes equal = %v, want %v", got, tt.wantEquals)
+			}
+		})
+	}
+}
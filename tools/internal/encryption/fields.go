@@ -0,0 +1,75 @@
+package encryption
+
+import "strings"
+
+// Kinds of sensitive value; they decide normalisation and the blind index
+// domain
+const (
+	KindEmail = "email"
+	KindPhone = "phone"
+	KindText  = "text"
+)
+
+// Field is a column stored encrypted
+type Field struct {
+	Column string
+	Kind   string
+	Index  string // blind index column; "" when the field is never looked up
+	// Stored is the table.column a function argument is written to, which
+	// its ciphertext is bound to; columns of tables are bound to themselves
+	Stored string
+}
+
+// storedIn is the table.column f's values in table are encrypted for
+func (f Field) storedIn(table string) string {
+	if f.Stored != "" {
+		return f.Stored
+	}
+	return table + "." + f.Column
+}
+
+// Sensitive lists the encrypted columns of each table. Adding a column
+// here (and its index column in the database) is all it takes to encrypt
+// it; run `server reencrypt` afterwards to encrypt existing rows.
+var Sensitive = map[string][]Field{
+	"profiles": {
+		{Column: "email", Kind: KindEmail, Index: "email_bidx"},
+		{Column: "phone", Kind: KindPhone, Index: "phone_bidx"},
+		{Column: "pending_email", Kind: KindEmail},
+		{Column: "pending_phone", Kind: KindPhone},
+	},
+	"invitations": {
+		{Column: "email", Kind: KindEmail, Index: "email_bidx"},
+		{Column: "phone", Kind: KindPhone, Index: "phone_bidx"},
+	},
+	"building_members": {
+		{Column: "email", Kind: KindEmail, Index: "email_bidx"},
+		{Column: "phone", Kind: KindPhone, Index: "phone_bidx"},
+	},
+	"organisation_members": {
+		{Column: "email", Kind: KindEmail, Index: "email_bidx"},
+		{Column: "phone", Kind: KindPhone, Index: "phone_bidx"},
+	},
+	"phone_otps": {
+		{Column: "phone", Kind: KindPhone, Index: "phone_bidx"},
+	},
+	// Arguments of database functions that write or look up the columns above
+	"rpc/accept_invitation": {
+		{Column: "p_email", Kind: KindEmail, Index: "p_email_bidx", Stored: "profiles.email"},
+		{Column: "p_phone", Kind: KindPhone, Index: "p_phone_bidx", Stored: "profiles.phone"},
+	},
+	"rpc/search_portfolio": {
+		{Column: "p_email", Kind: KindEmail, Index: "p_email_bidx"},
+		{Column: "p_phone", Kind: KindPhone, Index: "p_phone_bidx"},
+	},
+}
+
+// Normalize puts a value in the form its blind index is computed over:
+// emails are case-insensitive, phones are already E.164
+func Normalize(kind, value string) string {
+	value = strings.TrimSpace(value)
+	if kind == KindEmail {
+		return strings.ToLower(value)
+	}
+	return value
+}
@@ -0,0 +1,163 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyProvider holds the key-encryption keys (KEKs) that wrap data keys.
+// LocalKeyring keeps them in a file; a KMS-backed provider would send
+// Wrap/Unwrap to the KMS and never see the KEK itself. Key IDs are stored
+// in every ciphertext, so old keys must stay available until rotated out.
+type KeyProvider interface {
+	// CurrentKeyID is the KEK new data keys are wrapped with
+	CurrentKeyID() string
+	Wrap(keyID string, dataKey []byte) ([]byte, error)
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+var ErrUnknownKey = errors.New("encryption: unknown key ID")
+
+// LocalKeyring is a KeyProvider backed by a JSON key file:
+//
+//	{
+//	  "current": "2026-10",
+//	  "keys": {"2026-10": "<base64 32 bytes>", "2025-04": "<base64 32 bytes>"},
+//	  "index_key": "<base64 32 bytes>"
+//	}
+//
+// To rotate, add a key, point "current" at it, restart, then run
+// `server reencrypt`; the old key can be removed once that finishes.
+// The index key cannot be rotated this way: blind indexes would have to
+// be rebuilt, so it is kept separate from the KEKs.
+type LocalKeyring struct {
+	current  string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+type keyFile struct {
+	Current  string            `json:"current"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// LoadKeyFile reads a key file from disk
+func LoadKeyFile(path string) (*LocalKeyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyring(data)
+}
+
+// ParseKeyring parses key file contents, for hosts that pass secrets in
+// environment variables rather than files
+func ParseKeyring(data []byte) (*LocalKeyring, error) {
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("encryption: invalid key file: %w", err)
+	}
+	if _, ok := f.Keys[f.Current]; !ok {
+		return nil, fmt.Errorf("encryption: current key %q is not in keys", f.Current)
+	}
+
+	k := &LocalKeyring{current: f.Current, keys: map[string]cipher.AEAD{}}
+	for id, encoded := range f.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("encryption: key ID %q must be non-empty and contain no colons", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: key %q: %w", id, err)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+
+	indexKey, err := decodeKey(f.IndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: index_key: %w", err)
+	}
+	k.indexKey = indexKey
+	return k, nil
+}
+
+func (k *LocalKeyring) CurrentKeyID() string {
+	return k.current
+}
+
+// IndexKey is the HMAC key for blind indexes
+func (k *LocalKeyring) IndexKey() []byte {
+	return k.indexKey
+}
+
+func (k *LocalKeyring) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return seal(aead, dataKey, nil)
+}
+
+func (k *LocalKeyring) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return open(aead, wrapped, nil)
+}
+
+// NewKey returns a random 256-bit key, base64-encoded for a key file
+func NewKey() string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("not valid base64")
+	}
+	if len(key) != 32 {
+		return nil, errors.New("must be 32 bytes")
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, returned in front of the ciphertext,
+// authenticating additional data alongside
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encryption: ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
@@ -0,0 +1,96 @@
+package encryption
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	postgrest "github.com/supabase-community/postgrest-go"
+	supabase "github.com/supabase-community/supabase-go"
+)
+
+const reencryptPage = 500
+
+// rpcPrefix marks Sensitive entries for database function calls
+const rpcPrefix = "rpc/"
+
+// Reencrypt brings every sensitive column up to date: plaintext left from
+// before encryption was enabled is encrypted, values under an old KEK are
+// re-encrypted under the current one, and blind indexes are filled in.
+// raw must send BypassHeader so it reads the stored values. It is safe to
+// re-run and returns how many rows were rewritten per table.
+func Reencrypt(raw *supabase.Client, c *Cipher, fields map[string][]Field) (map[string]int, error) {
+	tables := make([]string, 0, len(fields))
+	for t := range fields {
+		// Function arguments are encrypted in flight; nothing is stored under them
+		if !strings.HasPrefix(t, rpcPrefix) {
+			tables = append(tables, t)
+		}
+	}
+	sort.Strings(tables)
+
+	updated := map[string]int{}
+	for _, table := range tables {
+		cols := []string{"id"}
+		for _, f := range fields[table] {
+			cols = append(cols, f.Column)
+		}
+
+		for from := 0; ; from += reencryptPage {
+			data, _, err := raw.From(table).Select(strings.Join(cols, ", "), "exact", false).Order("id", &postgrest.OrderOpts{Ascending: true}).Range(from, from+reencryptPage-1, "").Execute()
+			if err != nil {
+				return updated, err
+			}
+			var rows []map[string]interface{}
+			json.Unmarshal(data, &rows)
+
+			for _, row := range rows {
+				update, err := rotateRow(c, table, fields[table], row)
+				if err != nil {
+					return updated, err
+				}
+				if len(update) == 0 {
+					continue
+				}
+				id, _ := row["id"].(string)
+				if _, _, err := raw.From(table).Update(update, "", "").Eq("id", id).Execute(); err != nil {
+					return updated, err
+				}
+				updated[table]++
+			}
+
+			if len(rows) < reencryptPage {
+				break
+			}
+		}
+	}
+	return updated, nil
+}
+
+// rotateRow returns the columns of a row of table that need rewriting. A
+// value encrypted for another column stops the run rather than being
+// re-encrypted for this one.
+func rotateRow(c *Cipher, table string, fields []Field, row map[string]interface{}) (map[string]interface{}, error) {
+	update := map[string]interface{}{}
+	for _, f := range fields {
+		value, ok := row[f.Column].(string)
+		if !ok || value == "" || !c.NeedsRotation(value) {
+			continue
+		}
+		column := f.storedIn(table)
+		plaintext, err := c.DecryptColumn(column, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s of %v: %w", column, row["id"], err)
+		}
+		enc, err := c.Encrypt(column, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		update[f.Column] = enc
+		if f.Index != "" {
+			update[f.Index] = c.BlindIndex(f.Kind, plaintext)
+		}
+	}
+	return update, nil
+}
@@ -0,0 +1,334 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// BypassHeader on an outgoing request makes Transport pass it through
+// untouched; `server reencrypt` uses it to see the stored ciphertext
+const BypassHeader = "X-Encryption-Bypass"
+
+// Transport encrypts sensitive fields on their way to PostgREST and
+// decrypts them on the way back. supabase-go keeps its PostgREST client
+// private, so this is installed as http.DefaultTransport and only touches
+// requests under restURL; everything else passes straight through.
+//
+// On requests to a table in fields it:
+//   - encrypts designated columns in insert/update/upsert bodies and fills
+//     in their blind index columns
+//   - rewrites eq, neq and in filters on those columns (including inside
+//     or/and groups) to match the blind index, and on_conflict targets
+//     to the index column
+//   - rejects any other filter on them (like, gt, …), which could only
+//     ever compare ciphertext
+//
+// Every JSON response, including embedded resources from other tables, is
+// scanned for encrypted values and decrypted.
+type Transport struct {
+	base    http.RoundTripper
+	restURL string
+	cipher  *Cipher
+	fields  map[string][]Field
+}
+
+func NewTransport(base http.RoundTripper, restURL string, cipher *Cipher, fields map[string][]Field) *Transport {
+	return &Transport{base: base, restURL: strings.TrimSuffix(restURL, "/") + "/", cipher: cipher, fields: fields}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(req.URL.String(), t.restURL) {
+		return t.base.RoundTrip(req)
+	}
+	if req.Header.Get(BypassHeader) != "" {
+		req = req.Clone(req.Context())
+		req.Header.Del(BypassHeader)
+		return t.base.RoundTrip(req)
+	}
+
+	table, _, _ := strings.Cut(strings.TrimPrefix(req.URL.String(), t.restURL), "?")
+	if fields := t.fields[table]; len(fields) > 0 {
+		var err error
+		if req, err = t.encryptRequest(req, table, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if HasCiphertext(body) {
+		if body, err = t.cipher.DecryptJSON(body); err != nil {
+			return nil, err
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+// encryptRequest returns a copy of req to table with its filters and body
+// rewritten
+func (t *Transport) encryptRequest(req *http.Request, table string, fields []Field) (*http.Request, error) {
+	out := req.Clone(req.Context())
+
+	q := out.URL.Query()
+	if err := t.rewriteQuery(q, fields); err != nil {
+		return nil, err
+	}
+	out.URL.RawQuery = q.Encode()
+
+	if req.Body != nil && (req.Method == http.MethodPost || req.Method == http.MethodPatch || req.Method == http.MethodPut) {
+		raw, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			if raw, err = t.encryptBody(raw, table, fields); err != nil {
+				return nil, err
+			}
+		}
+		out.Body = io.NopCloser(bytes.NewReader(raw))
+		out.ContentLength = int64(len(raw))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(raw)), nil
+		}
+	}
+	return out, nil
+}
+
+// encryptBody encrypts designated columns in a row or array of rows
+func (t *Transport) encryptBody(raw []byte, table string, fields []Field) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+
+	var rows []interface{}
+	switch b := body.(type) {
+	case []interface{}:
+		rows = b
+	case map[string]interface{}:
+		rows = []interface{}{b}
+	}
+	for _, r := range rows {
+		if row, ok := r.(map[string]interface{}); ok {
+			if err := t.cipher.EncryptRow(table, row, fields); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return marshal(body)
+}
+
+// rewriteQuery points filters on designated columns at their blind indexes
+func (t *Transport) rewriteQuery(q url.Values, fields []Field) error {
+	for _, f := range fields {
+		values, ok := q[f.Column]
+		if !ok {
+			continue
+		}
+		q.Del(f.Column)
+		for _, v := range values {
+			col, filter, err := t.rewriteFilter(f, v)
+			if err != nil {
+				return err
+			}
+			q.Add(col, filter)
+		}
+	}
+
+	for key, values := range q {
+		if key != "or" && key != "and" && key != "not.or" && key != "not.and" {
+			continue
+		}
+		for i, v := range values {
+			inner, ok := groupBody(v)
+			if !ok {
+				continue
+			}
+			rewritten, err := t.rewriteGroup(inner, fields)
+			if err != nil {
+				return err
+			}
+			values[i] = "(" + rewritten + ")"
+		}
+	}
+
+	if target := q.Get("on_conflict"); target != "" {
+		cols := strings.Split(target, ",")
+		for i, c := range cols {
+			if f, ok := findField(fields, strings.TrimSpace(c)); ok {
+				if f.Index == "" {
+					return fmt.Errorf("encryption: cannot upsert on %s, it has no blind index", f.Column)
+				}
+				cols[i] = f.Index
+			}
+		}
+		q.Set("on_conflict", strings.Join(cols, ","))
+	}
+	return nil
+}
+
+// rewriteFilter turns "op.value" on an encrypted column into the matching
+// filter on its blind index. is.null/is.not_null stay on the column.
+func (t *Transport) rewriteFilter(f Field, filter string) (string, string, error) {
+	negate := ""
+	if rest, ok := strings.CutPrefix(filter, "not."); ok {
+		negate, filter = "not.", rest
+	}
+	op, arg, _ := strings.Cut(filter, ".")
+
+	switch op {
+	case "is":
+		return f.Column, negate + filter, nil
+	case "eq", "neq", "in":
+		if f.Index == "" {
+			return "", "", fmt.Errorf("encryption: %s is encrypted without a blind index and cannot be filtered", f.Column)
+		}
+	default:
+		return "", "", fmt.Errorf("encryption: %s filter on encrypted column %s is not supported", op, f.Column)
+	}
+
+	if op != "in" {
+		if arg == "" {
+			// An empty string is stored unencrypted
+			return f.Column, negate + filter, nil
+		}
+		return f.Index, negate + op + "." + t.cipher.BlindIndex(f.Kind, unquote(arg)), nil
+	}
+
+	list, ok := groupBody(arg)
+	if !ok {
+		return "", "", fmt.Errorf("encryption: malformed in filter on %s", f.Column)
+	}
+	items := splitTopLevel(list)
+	for i, item := range items {
+		items[i] = t.cipher.BlindIndex(f.Kind, unquote(item))
+	}
+	return f.Index, negate + "in.(" + strings.Join(items, ",") + ")", nil
+}
+
+// rewriteGroup rewrites the conditions of an or/and group, e.g.
+// "email.eq.a@b.c,full_name.ilike.*a*", recursing into nested groups
+func (t *Transport) rewriteGroup(list string, fields []Field) (string, error) {
+	conds := splitTopLevel(list)
+	for i, cond := range conds {
+		if prefix, inner, ok := nestedGroup(cond); ok {
+			rewritten, err := t.rewriteGroup(inner, fields)
+			if err != nil {
+				return "", err
+			}
+			conds[i] = prefix + "(" + rewritten + ")"
+			continue
+		}
+
+		col, filter, ok := strings.Cut(cond, ".")
+		if !ok {
+			continue
+		}
+		f, ok := findField(fields, col)
+		if !ok {
+			continue
+		}
+		newCol, newFilter, err := t.rewriteFilter(f, filter)
+		if err != nil {
+			return "", err
+		}
+		conds[i] = newCol + "." + newFilter
+	}
+	return strings.Join(conds, ","), nil
+}
+
+func findField(fields []Field, column string) (Field, bool) {
+	for _, f := range fields {
+		if f.Column == column {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// nestedGroup splits "and(a,b)" into "and" and "a,b"
+func nestedGroup(cond string) (string, string, bool) {
+	for _, prefix := range []string{"not.or", "not.and", "or", "and"} {
+		if rest, ok := strings.CutPrefix(cond, prefix); ok {
+			if inner, ok := groupBody(rest); ok {
+				return prefix, inner, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// groupBody strips the parentheses from "(a,b)"
+func groupBody(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return "", false
+	}
+	return s[1 : len(s)-1], true
+}
+
+// splitTopLevel splits on commas outside parentheses and double quotes
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '(':
+			if !quoted {
+				depth++
+			}
+		case ')':
+			if !quoted {
+				depth--
+			}
+		case ',':
+			if !quoted && depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// marshal encodes without HTML escaping, so values round-trip unchanged
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,178 @@
+package encryption
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testRestURL = "https://db.example.com/rest/v1/"
+
+// recorder answers every request with body and keeps the last one it saw
+type recorder struct {
+	req  *http.Request
+	sent []byte
+	body string
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.req = req
+	if req.Body != nil {
+		r.sent, _ = io.ReadAll(req.Body)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(r.body)),
+	}, nil
+}
+
+func newTestTransport(t *testing.T) (*Transport, *recorder, *Cipher) {
+	t.Helper()
+	c, _ := newTestCipher(t)
+	rec := &recorder{body: "[]"}
+	return NewTransport(rec, testRestURL, c, Sensitive), rec, c
+}
+
+func TestTransportFilters(t *testing.T) {
+	tr, rec, c := newTestTransport(t)
+	email := c.BlindIndex(KindEmail, "ada@example.com")
+	phone := c.BlindIndex(KindPhone, "+2348030000003")
+	other := c.BlindIndex(KindPhone, "+2348030000004")
+
+	tests := []struct {
+		name  string
+		query string
+		want  url.Values // nil when the request must be refused
+	}{
+		{"eq", "email=eq.Ada@Example.com", url.Values{"email_bidx": {"eq." + email}}},
+		{"neq", "email=neq.ada@example.com", url.Values{"email_bidx": {"neq." + email}}},
+		{"not eq", "email=not.eq.ada@example.com", url.Values{"email_bidx": {"not.eq." + email}}},
+		{"in", `phone=in.(+2348030000003,"+2348030000004")`, url.Values{"phone_bidx": {"in.(" + phone + "," + other + ")"}}},
+		{"is null", "phone=is.null", url.Values{"phone": {"is.null"}}},
+		{"empty string", "email=eq.", url.Values{"email": {"eq."}}},
+		{"other columns", "full_name=ilike.*ada*&email=eq.ada@example.com", url.Values{"full_name": {"ilike.*ada*"}, "email_bidx": {"eq." + email}}},
+		{"or group", "or=(email.eq.ada@example.com,full_name.ilike.*ada*)", url.Values{"or": {"(email_bidx.eq." + email + ",full_name.ilike.*ada*)"}}},
+		{"nested group", "or=(and(email.eq.ada@example.com,role.eq.tenant),phone.in.(+2348030000003))", url.Values{"or": {"(and(email_bidx.eq." + email + ",role.eq.tenant),phone_bidx.in.(" + phone + "))"}}},
+		{"not and group", "not.and=(phone.eq.+2348030000003,phone.is.null)", url.Values{"not.and": {"(phone_bidx.eq." + phone + ",phone.is.null)"}}},
+		{"like", "email=ilike.*ada*", nil},
+		{"range", "phone=gt.+234", nil},
+		{"like in a group", "or=(full_name.ilike.*ada*,email.like.*ada*)", nil},
+		{"no blind index", "pending_email=eq.ada@example.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec.req = nil
+			// The PostgREST client escapes "+", which would otherwise read as a space
+			req, _ := http.NewRequest(http.MethodGet, testRestURL+"profiles?"+strings.ReplaceAll(tt.query, "+", "%2B"), nil)
+			_, err := tr.RoundTrip(req)
+			if tt.want == nil {
+				if err == nil || rec.req != nil {
+					t.Fatalf("filter %s was sent", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.req.URL.Query(); got.Encode() != tt.want.Encode() {
+				t.Errorf("query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransportWrites(t *testing.T) {
+	tr, rec, c := newTestTransport(t)
+
+	tests := []struct {
+		name, table, query, body string
+		conflict                 string // on_conflict sent on, "" when none
+		refused                  bool
+		column                   string // table.column the email is bound to
+	}{
+		{name: "insert row", table: "profiles", body: `{"full_name":"Ada","email":"ada@example.com"}`, column: "profiles.email"},
+		{name: "insert rows", table: "invitations", body: `[{"email":"ada@example.com"},{"email":"ada@example.com"}]`, column: "invitations.email"},
+		{name: "upsert", table: "building_members", query: "on_conflict=building_id,email", body: `{"building_id":"b1","email":"ada@example.com"}`, conflict: "building_id,email_bidx", column: "building_members.email"},
+		{name: "upsert without index", table: "profiles", query: "on_conflict=pending_email", body: `{"pending_email":"ada@example.com"}`, refused: true},
+		{name: "function arguments", table: "rpc/accept_invitation", body: `{"p_token":"t","p_email":"ada@example.com"}`, column: "profiles.email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec.req, rec.sent = nil, nil
+			target := testRestURL + tt.table
+			if tt.query != "" {
+				target += "?" + tt.query
+			}
+			req, _ := http.NewRequest(http.MethodPost, target, strings.NewReader(tt.body))
+			_, err := tr.RoundTrip(req)
+			if tt.refused {
+				if err == nil || rec.req != nil {
+					t.Fatal("request was sent")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rec.req.URL.Query().Get("on_conflict"); got != tt.conflict {
+				t.Errorf("on_conflict = %q, want %q", got, tt.conflict)
+			}
+
+			var rows []map[string]interface{}
+			if strings.HasPrefix(tt.body, "[") {
+				json.Unmarshal(rec.sent, &rows)
+			} else {
+				var row map[string]interface{}
+				json.Unmarshal(rec.sent, &row)
+				rows = append(rows, row)
+			}
+			field, index := "email", "email_bidx"
+			if strings.HasPrefix(tt.table, rpcPrefix) {
+				field, index = "p_email", "p_email_bidx"
+			}
+			for _, row := range rows {
+				enc, _ := row[field].(string)
+				if got, err := c.DecryptColumn(tt.column, enc); err != nil || got != "ada@example.com" || enc == got {
+					t.Errorf("sent %s = %q (%q, %v), want ciphertext for %s", field, enc, got, err, tt.column)
+				}
+				if row[index] != c.BlindIndex(KindEmail, "ada@example.com") {
+					t.Errorf("sent %s = %v", index, row[index])
+				}
+			}
+		})
+	}
+}
+
+func TestTransportResponses(t *testing.T) {
+	tr, rec, c := newTestTransport(t)
+	email, _ := c.Encrypt("profiles.email", "ada@example.com")
+	rec.body = `[{"id":"u1","email":"` + email + `","building":{"name":"Palm Court"}}]`
+
+	for _, target := range []string{testRestURL + "profiles?id=eq.u1", testRestURL + "units?select=*,profiles(email)"} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if want := `[{"building":{"name":"Palm Court"},"email":"ada@example.com","id":"u1"}]`; string(body) != want {
+			t.Errorf("%s: body = %s, want %s", target, body, want)
+		}
+	}
+
+	// Requests that bypass the transport see the stored values
+	req, _ := http.NewRequest(http.MethodGet, testRestURL+"profiles?email=eq.x", nil)
+	req.Header.Set(BypassHeader, "1")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), email) || rec.req.URL.Query().Get("email") != "eq.x" || rec.req.Header.Get(BypassHeader) != "" {
+		t.Errorf("bypassed request was rewritten: %s %s", rec.req.URL, body)
+	}
+}
@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/mfa"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
+	"github.com/supabase-community/gotrue-go"
+	gotrue_types "github.com/supabase-community/gotrue-go/types"
+)
+
+// erasedBan keeps an erased account from ever signing in again
+const erasedBan = 100 * 365 * 24 * time.Hour
+
+// AccountHandler serves the signed-in user's own account (/me)
+type AccountHandler struct {
+	admin     gotrue.Client // service-role Auth client; nil when not configured
+	store     *store.Store
+	avatars   AvatarStorage
+	notifier  *notify.Notifier
+	mfa       *mfa.Service
+	otpSecret []byte
+	audit     *audit.Logger
+}
+
+func NewAccountHandler(admin gotrue.Client, st *store.Store, avatars AvatarStorage, notifier *notify.Notifier, mfaService *mfa.Service, otpSecret []byte, auditLog *audit.Logger) *AccountHandler {
+	return &AccountHandler{admin: admin, store: st, avatars: avatars, notifier: notifier, mfa: mfaService, otpSecret: otpSecret, audit: auditLog}
+}
+
+// exportDataset is one file pair (JSON and CSV) in a data export
+type exportDataset struct {
+	name  string
+	query func(userID string) (interface{}, error)
+}
+
+// exportedTenancy is a tenancy with the unit and building it is in
+type exportedTenancy struct {
+	models.Tenancy
+	Unit     *store.UnitRef     `json:"units"`
+	Building *store.BuildingRef `json:"buildings"`
+}
+
+// exportedActivity is the part of an audit entry a user gets back about
+// their own actions
+type exportedActivity struct {
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resource_type"`
+	ResourceID   *string   `json:"resource_id"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ExportData returns a zip of everything held about the caller, each
+// dataset as both JSON and CSV (NDPR right of access)
+func (h *AccountHandler) ExportData(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	// Support staff may look at an account, but not take its data away
+	if middleware.GetImpersonator(r) != "" {
+		respondError(w, http.StatusForbidden, "Data exports are not available while impersonating")
+		return
+	}
+
+	datasets := []exportDataset{
+		{"profile", func(id string) (interface{}, error) {
+			p, err := h.store.Profiles.GetProfile(id)
+			if err == store.ErrNotFound {
+				return []models.Profile{}, nil
+			}
+			return []models.Profile{p}, err
+		}},
+		{"tenancies", h.exportTenancies},
+		{"payments", func(id string) (interface{}, error) {
+			return allPages(func(opts store.ListOptions) (store.Page[store.PaymentListing], error) {
+				return h.store.Payments.ListPayments(store.PaymentFilter{TenantID: id}, opts)
+			}, store.ListOptions{})
+		}},
+		{"documents", func(id string) (interface{}, error) {
+			return allPages(func(opts store.ListOptions) (store.Page[models.Document], error) {
+				return h.store.Documents.ListDocuments(store.DocumentFilter{UploadedBy: id}, opts)
+			}, store.ListOptions{})
+		}},
+		{"maintenance_requests", func(id string) (interface{}, error) {
+			return allPages(func(opts store.ListOptions) (store.Page[store.MaintenanceListing], error) {
+				return h.store.Maintenance.ListMaintenanceRequests(store.MaintenanceFilter{TenantID: id}, opts)
+			}, store.ListOptions{})
+		}},
+		{"buildings", func(id string) (interface{}, error) {
+			return h.store.Buildings.LandlordBuildings(id)
+		}},
+		{"api_keys", func(id string) (interface{}, error) {
+			return h.store.APIKeys.ListAPIKeys(id)
+		}},
+		{"activity", h.exportActivity},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, ds := range datasets {
+		rows, err := ds.query(userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to export "+ds.name)
+			return
+		}
+		data, err := json.Marshal(rows)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to export "+ds.name)
+			return
+		}
+		if err := addExportDataset(zw, ds.name, data); err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to export "+ds.name)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to build export")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "account.export",
+		ResourceType: "user",
+		ResourceID:   userID,
+	})
+
+	filename := fmt.Sprintf("aletheia-export-%s.zip", time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (h *AccountHandler) exportTenancies(userID string) (interface{}, error) {
+	tenancies, err := h.store.Tenancies.TenanciesForTenant(userID)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]exportedTenancy, len(tenancies))
+	for i, t := range tenancies {
+		rows[i].Tenancy = t
+		if u, err := h.store.Units.GetUnit(t.UnitID); err == nil {
+			rows[i].Unit = &store.UnitRef{UnitNumber: u.UnitNumber}
+		} else if err != store.ErrNotFound {
+			return nil, err
+		}
+		if b, err := h.store.Buildings.GetBuilding(t.BuildingID); err == nil {
+			rows[i].Building = &store.BuildingRef{Name: b.Name, Address: b.Address}
+		} else if err != store.ErrNotFound {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+func (h *AccountHandler) exportActivity(userID string) (interface{}, error) {
+	rows := []exportedActivity{}
+	filter := store.AuditFilter{Equal: map[string]string{"actor_id": userID}}
+	for offset := 0; ; offset += store.MaxPerPage {
+		entries, err := h.store.Audit.ListAuditEntries(filter, offset, store.MaxPerPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			rows = append(rows, exportedActivity{
+				Action:       e.Action,
+				ResourceType: e.ResourceType,
+				ResourceID:   e.ResourceID,
+				IP:           e.IP,
+				UserAgent:    e.UserAgent,
+				CreatedAt:    e.CreatedAt,
+			})
+		}
+		if len(entries) < store.MaxPerPage {
+			return rows, nil
+		}
+	}
+}
+
+// addExportDataset writes name.json (as returned by the store) and
+// name.csv (one column per field, nested values as JSON) to the zip
+func addExportDataset(zw *zip.Writer, name string, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var rows []map[string]interface{}
+	if err := dec.Decode(&rows); err != nil {
+		return err
+	}
+
+	jf, err := zw.Create(name + ".json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(jf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rows); err != nil {
+		return err
+	}
+
+	cf, err := zw.Create(name + ".csv")
+	if err != nil {
+		return err
+	}
+	columns := map[string]bool{}
+	for _, row := range rows {
+		for k := range row {
+			columns[k] = true
+		}
+	}
+	header := make([]string, 0, len(columns))
+	for k := range columns {
+		header = append(header, k)
+	}
+	sort.Strings(header)
+
+	cw := csv.NewWriter(cf)
+	cw.Write(header)
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, col := range header {
+			record[i] = csvValue(row[col])
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// erasureStep is one best-effort cleanup after an account is pseudonymised
+type erasureStep struct {
+	what string
+	run  func() error
+}
+
+// DeleteAccount erases the caller's account (NDPR right to erasure). The
+// profile is pseudonymised rather than deleted, so the payment ledger,
+// leases and audit log keep pointing at a row, but nothing in it identifies
+// the person any more. Sign-in is disabled and all access is revoked.
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Confirm != "DELETE" {
+		respondError(w, http.StatusBadRequest, `Send {"confirm": "DELETE"} to erase your account`)
+		return
+	}
+
+	if h.admin == nil {
+		respondError(w, http.StatusServiceUnavailable, "Account deletion is not configured")
+		return
+	}
+
+	// Buildings and tenancies involve other people; they must be handed
+	// over or ended before the account can go
+	blockers := []struct {
+		count   func() (int, error)
+		message string
+	}{
+		{func() (int, error) {
+			buildings, err := h.store.Buildings.LandlordBuildings(userID)
+			return len(buildings), err
+		}, "Transfer or close your buildings before deleting your account"},
+		{func() (int, error) {
+			owned, err := h.store.Owners.ListOwnerships(userID)
+			return len(owned), err
+		}, "Remove yourself as a co-owner before deleting your account"},
+		{func() (int, error) {
+			tenancies, err := h.store.Tenancies.TenanciesForTenant(userID)
+			active := 0
+			for _, t := range tenancies {
+				if t.Status == "active" {
+					active++
+				}
+			}
+			return active, err
+		}, "End your tenancy before deleting your account"},
+	}
+	for _, b := range blockers {
+		count, err := b.count()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check account")
+			return
+		}
+		if count > 0 {
+			respondError(w, http.StatusConflict, b.message)
+			return
+		}
+	}
+
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	// Disable sign-in first: if anything later fails the account is locked,
+	// never half-erased but still usable
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	pseudonym := "deleted-" + userID + "@users.invalid"
+	ban := gotrue_types.BanDurationTime(erasedBan)
+	if _, err := h.admin.AdminUpdateUser(gotrue_types.AdminUpdateUserRequest{
+		UserID:       uid,
+		Email:        pseudonym,
+		Password:     generateToken() + generateToken(),
+		UserMetadata: map[string]interface{}{},
+		BanDuration:  &ban,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+
+	erased := map[string]interface{}{
+		"full_name":         "Deleted user",
+		"email":             pseudonym,
+		"phone":             nil,
+		"pending_email":     nil,
+		"pending_phone":     nil,
+		"avatar_url":        nil,
+		"avatar_thumb_url":  nil,
+		"email_verified_at": nil,
+		"phone_verified_at": nil,
+		"deleted_at":        time.Now().UTC(),
+	}
+	if _, err := h.store.Profiles.UpdateProfile(userID, erased); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to erase profile")
+		return
+	}
+
+	// Best effort from here: the account is already unusable and anonymous
+	contacts := store.ContactErasure{UserID: userID, Email: profile.Email, Pseudonym: pseudonym}
+	if profile.Phone != nil {
+		contacts.Phone = *profile.Phone
+	}
+	cleanup := []erasureStep{
+		{"api keys", func() error { return h.store.APIKeys.RevokeAPIKeys(userID) }},
+		{"staff access", func() error { return h.store.Staff.RevokeStaffAccess(userID) }},
+		{"organisation access", func() error { return h.store.Organisations.RevokeOrganisationAccess(userID) }},
+		{"staff contact details", func() error { return h.store.Staff.EraseStaffContacts(contacts) }},
+		{"organisation contact details", func() error { return h.store.Organisations.EraseOrganisationContacts(contacts) }},
+		{"avatar", func() error {
+			fullPath, thumbPath := avatarPaths(userID)
+			return h.avatars.Remove(fullPath, thumbPath)
+		}},
+		{"two-factor", func() error { return h.mfa.Disable(userID) }},
+		{"auth tokens", func() error { return h.store.AuthTokens.DeleteAuthTokens(userID) }},
+		{"login lockouts", func() error { return h.store.Lockouts.ClearLoginLockout(lockoutKey(h.otpSecret, profile.Email)) }},
+		{"invitations", func() error { return h.store.Invitations.EraseInvitationContacts(contacts) }},
+	}
+	if contacts.Phone != "" {
+		cleanup = append(cleanup, erasureStep{"phone codes", func() error { return h.store.OTPs.DeleteOTPs(contacts.Phone) }})
+	}
+	var failed []string
+	for _, c := range cleanup {
+		if err := c.run(); err != nil {
+			log.Printf("account erasure %s: %s: %v", userID, c.what, err)
+			failed = append(failed, c.what)
+		}
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "account.erase",
+		ResourceType: "user",
+		ResourceID:   userID,
+		Metadata:     map[string]interface{}{"cleanup_failed": failed},
+	})
+
+	message := "Your account has been deleted"
+	if len(failed) > 0 {
+		message += "; some access records will be cleaned up by support (" + strings.Join(failed, ", ") + ")"
+	}
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+	})
+}
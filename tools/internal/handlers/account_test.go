@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/mfa"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// fakeAvatars records the avatar paths removed instead of touching storage
+type fakeAvatars struct {
+	removed []string
+}
+
+func (a *fakeAvatars) Upload(path string, data []byte) (string, error) {
+	return "https://storage.example.com/" + path, nil
+}
+
+func (a *fakeAvatars) Remove(paths ...string) error {
+	a.removed = append(a.removed, paths...)
+	return nil
+}
+
+func newAccountHandler(f *fixture, auth *fakeAuth, avatars AvatarStorage) *AccountHandler {
+	return NewAccountHandler(auth, f.st, avatars, f.notify, mfa.NewService(f.st.MFA, "Aletheia"), []byte("otp-secret"), audit.New(f.st.Audit))
+}
+
+// exportFiles runs ExportData as userID and returns the zip's files by name
+func exportFiles(t *testing.T, h *AccountHandler, userID string) map[string][]byte {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/api/v1/me/export", nil)
+	r = r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, userID))
+	w := httptest.NewRecorder()
+	h.ExportData(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export status = %d: %s", w.Code, w.Body.String())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, zf := range zr.File {
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[zf.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	return files
+}
+
+func TestExportData(t *testing.T) {
+	f := newFixture(t)
+	h := newAccountHandler(f, &fakeAuth{}, &fakeAvatars{})
+
+	files := exportFiles(t, h, tenantID)
+	for _, name := range []string{"profile", "tenancies", "payments", "documents", "maintenance_requests", "buildings", "api_keys", "activity"} {
+		if _, ok := files[name+".json"]; !ok {
+			t.Errorf("export is missing %s.json", name)
+		}
+		if _, ok := files[name+".csv"]; !ok {
+			t.Errorf("export is missing %s.csv", name)
+		}
+	}
+
+	var profile []models.Profile
+	if err := json.Unmarshal(files["profile.json"], &profile); err != nil || len(profile) != 1 || profile[0].ID != tenantID {
+		t.Errorf("profile.json = %s", files["profile.json"])
+	}
+	var tenancies []exportedTenancy
+	if err := json.Unmarshal(files["tenancies.json"], &tenancies); err != nil || len(tenancies) != 1 {
+		t.Fatalf("tenancies.json = %s", files["tenancies.json"])
+	}
+	if tenancies[0].Unit == nil || tenancies[0].Unit.UnitNumber != "A1" || tenancies[0].Building == nil || tenancies[0].Building.Name != "Palm Court" {
+		t.Errorf("tenancy = %+v, want it with its unit and building", tenancies[0])
+	}
+
+	// The export itself is part of the next export's activity
+	files = exportFiles(t, h, tenantID)
+	var activity []exportedActivity
+	if err := json.Unmarshal(files["activity.json"], &activity); err != nil || len(activity) != 1 || activity[0].Action != "account.export" {
+		t.Errorf("activity.json = %s", files["activity.json"])
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	f := newFixture(t)
+	auth := &fakeAuth{}
+	auth.add(staffID, "dayo@example.com", "", "secret")
+	avatars := &fakeAvatars{}
+	h := newAccountHandler(f, auth, avatars)
+	del := models.DeleteAccountRequest{Confirm: "DELETE"}
+
+	call(t, h.DeleteAccount, asStaff(""), "DELETE", "/api/v1/me", models.DeleteAccountRequest{Confirm: "yes"}).expect(t, http.StatusBadRequest)
+	// Landlords and tenants have to hand things over first
+	call(t, h.DeleteAccount, asLandlord, "DELETE", "/api/v1/me", del).expect(t, http.StatusConflict)
+	call(t, h.DeleteAccount, asTenant, "DELETE", "/api/v1/me", del).expect(t, http.StatusConflict)
+
+	staff := NewStaffHandler(f.st, f.notify, nil)
+	call(t, staff.InviteStaff, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/staff", models.InviteStaffRequest{Email: "dayo@example.com", Role: "manager"}, "id", buildingA).expect(t, http.StatusCreated)
+	call(t, staff.AcceptStaffInvite, asStaff(""), "POST", "/api/v1/staff/accept", models.AcceptStaffInviteRequest{Token: f.outbox.linkToken(t, "dayo@example.com")}).expect(t, http.StatusOK)
+
+	call(t, h.DeleteAccount, asStaff(""), "DELETE", "/api/v1/me", del).expect(t, http.StatusOK)
+
+	pseudonym := "deleted-" + staffID + "@users.invalid"
+	p, err := f.st.Profiles.GetProfile(staffID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.FullName != "Deleted user" || p.Email != pseudonym || p.DeletedAt == nil {
+		t.Errorf("profile = %+v, want it pseudonymised", p)
+	}
+	if u := auth.find(func(u *fakeUser) bool { return u.id.String() == staffID }); u == nil || u.email != pseudonym || u.password == "secret" {
+		t.Errorf("auth user = %+v, want the sign-in replaced", u)
+	}
+	if roles, _ := f.st.Staff.StaffRoles(staffID); len(roles) != 0 {
+		t.Errorf("staff roles = %v after erasure", roles)
+	}
+	members, err := f.st.Staff.ListStaff(buildingA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if m.Email != nil && *m.Email == "dayo@example.com" {
+			t.Errorf("staff listing still holds the erased email: %+v", m)
+		}
+	}
+	if len(avatars.removed) != 2 {
+		t.Errorf("removed avatars %v, want the image and thumbnail", avatars.removed)
+	}
+}
+
+func TestChangeContactDetails(t *testing.T) {
+	f := newFixture(t)
+	auth := &fakeAuth{}
+	auth.add(tenantID, "chidi@example.com", "+2348030000003", "secret")
+	h := newAccountHandler(f, auth, &fakeAvatars{})
+
+	newName, takenEmail, newEmail, newPhone := " Chidi Okafor ", "ada@example.com", "chidi.okafor@example.com", "0803 000 0009"
+	call(t, h.UpdateProfile, asTenant, "PATCH", "/api/v1/me", models.UpdateProfileRequest{Email: &takenEmail}).expect(t, http.StatusConflict)
+
+	var updated models.Profile
+	call(t, h.UpdateProfile, asTenant, "PATCH", "/api/v1/me", models.UpdateProfileRequest{FullName: &newName, Email: &newEmail, Phone: &newPhone}).expect(t, http.StatusOK).decode(t, &updated)
+	if updated.FullName != "Chidi Okafor" || updated.Email != "chidi@example.com" || updated.PendingEmail == nil || updated.PendingPhone == nil || *updated.PendingPhone != "+2348030000009" {
+		t.Fatalf("updated = %+v, want the name changed and the contact changes pending", updated)
+	}
+
+	// Email: the link goes to the new address
+	call(t, h.ConfirmEmailChange, caller{}, "POST", "/api/v1/me/email/confirm", models.VerifyEmailRequest{Token: f.outbox.linkToken(t, newEmail)}).expect(t, http.StatusOK)
+	// Phone: the code goes to the new number
+	call(t, h.ConfirmPhoneChange, asTenant, "POST", "/api/v1/me/phone/confirm", models.ConfirmPhoneRequest{Code: "000000"}).expect(t, http.StatusUnauthorized)
+	call(t, h.ConfirmPhoneChange, asTenant, "POST", "/api/v1/me/phone/confirm", models.ConfirmPhoneRequest{Code: f.outbox.smsCode(t, "+2348030000009")}).expect(t, http.StatusOK)
+
+	p, err := f.st.Profiles.FindProfileByEmail(newEmail)
+	if err != nil || p.ID != tenantID {
+		t.Fatalf("profile by new email = %+v, %v", p, err)
+	}
+	if p.Phone == nil || *p.Phone != "+2348030000009" || p.PendingEmail != nil || p.PendingPhone != nil || p.PhoneVerifiedAt == nil {
+		t.Errorf("profile = %+v, want both changes applied", p)
+	}
+	if u := auth.find(func(u *fakeUser) bool { return u.id.String() == tenantID }); u.email != newEmail || u.phone != "+2348030000009" {
+		t.Errorf("auth user has %s / %s, want the new contact details", u.email, u.phone)
+	}
+	if _, err := f.st.Profiles.FindProfileByEmail("chidi@example.com"); err != store.ErrNotFound {
+		t.Errorf("old email still finds a profile: %v", err)
+	}
+
+	// The audit log records that the contact details changed, not what to
+	entries, err := f.st.Audit.ListAuditEntries(store.AuditFilter{Equal: map[string]string{"resource_id": tenantID}}, 0, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[string]bool{}
+	for _, e := range entries {
+		actions[e.Action] = true
+		for _, raw := range []json.RawMessage{e.Changes, e.Metadata} {
+			if s := string(raw); strings.Contains(s, "example.com") || strings.Contains(s, "803000000") {
+				t.Errorf("%s holds a contact detail: %s", e.Action, s)
+			}
+		}
+	}
+	for _, action := range []string{"account.profile.update", "account.email.change", "account.phone.change"} {
+		if !actions[action] {
+			t.Errorf("no %s entry", action)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/paystack"
+	"github.com/aletheia/backend/internal/phone"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	adminSearchLimit  = 50
+	impersonationTTL  = 30 * time.Minute
+	webhookEventLimit = 100
+)
+
+// AdminHandler serves the platform support console under /api/v1/admin.
+// Every action, including searches, is written to the audit log.
+type AdminHandler struct {
+	store    *store.Store
+	notifier *notify.Notifier
+	paystack *paystack.Client
+	audit    *audit.Logger
+}
+
+func NewAdminHandler(st *store.Store, notifier *notify.Notifier, ps *paystack.Client, auditLog *audit.Logger) *AdminHandler {
+	return &AdminHandler{store: st, notifier: notifier, paystack: ps, audit: auditLog}
+}
+
+// SearchUsers finds profiles by ID, exact email or phone, or name (?q=).
+// Emails and phones are encrypted, so only whole values can match.
+func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	q := searchTerm(r.URL.Query().Get("q"))
+	if q == "" {
+		respondError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	h.record(r, "admin.search.users", "user", "", map[string]interface{}{"q": auditedTerm(q)})
+
+	users, err := h.findUsers(q)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to search users")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    users,
+	})
+}
+
+// findUsers looks q up as a profile ID, email or phone, and otherwise as
+// part of a name
+func (h *AdminHandler) findUsers(q string) ([]models.Profile, error) {
+	var (
+		p   models.Profile
+		err error
+	)
+	if _, uerr := uuid.Parse(q); uerr == nil {
+		p, err = h.store.Profiles.GetProfile(q)
+	} else if strings.Contains(q, "@") {
+		p, err = h.store.Profiles.FindProfileByEmail(strings.ToLower(q))
+	} else if number, perr := phone.NormalizeNG(q); perr == nil {
+		p, err = h.store.Profiles.FindProfileByPhone(number)
+	} else {
+		return h.store.Admin.FindProfilesByName(q, adminSearchLimit)
+	}
+	if err == store.ErrNotFound {
+		return []models.Profile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []models.Profile{p}, nil
+}
+
+// auditedTerm is how a user search is written to the audit log: emails and
+// phone numbers are personal data the log must not hold in plain text
+func auditedTerm(q string) string {
+	if strings.Contains(q, "@") {
+		return "[email]"
+	}
+	if _, err := phone.NormalizeNG(q); err == nil {
+		return "[phone]"
+	}
+	return q
+}
+
+// SearchBuildings finds buildings by ID, landlord ID, name or address (?q=)
+func (h *AdminHandler) SearchBuildings(w http.ResponseWriter, r *http.Request) {
+	q := searchTerm(r.URL.Query().Get("q"))
+	if q == "" {
+		respondError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	h.record(r, "admin.search.buildings", "building", "", map[string]interface{}{"q": q})
+
+	buildings, err := h.store.Admin.FindBuildings(q, adminSearchLimit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to search buildings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    buildings,
+	})
+}
+
+// SearchPayments finds payments by payment/tenant/building/unit ID or by
+// Paystack reference or transaction ID (?q=), optionally by ?status=
+func (h *AdminHandler) SearchPayments(w http.ResponseWriter, r *http.Request) {
+	q := searchTerm(r.URL.Query().Get("q"))
+	status := r.URL.Query().Get("status")
+	if q == "" && status == "" {
+		respondError(w, http.StatusBadRequest, "q or status is required")
+		return
+	}
+	h.record(r, "admin.search.payments", "payment", "", map[string]interface{}{"q": q, "status": status})
+
+	payments, err := h.store.Admin.FindPayments(q, status, adminSearchLimit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to search payments")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    payments,
+	})
+}
+
+// ListWebhookEvents returns recent provider webhook deliveries, newest
+// first, optionally filtered by ?reference= and ?event=
+func (h *AdminHandler) ListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	reference := r.URL.Query().Get("reference")
+	event := r.URL.Query().Get("event")
+	h.record(r, "admin.webhooks.list", "webhook_event", "", map[string]interface{}{"reference": reference, "event": event})
+
+	events, err := h.store.Admin.ListWebhookEvents(reference, event, webhookEventLimit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch webhook events")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    events,
+	})
+}
+
+// ReconcilePayment re-checks a payment against Paystack and applies the result
+func (h *AdminHandler) ReconcilePayment(w http.ResponseWriter, r *http.Request) {
+	paymentID := getPathParam(r, "id")
+
+	before, err := h.store.Payments.GetPayment(paymentID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Payment not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch payment")
+		return
+	}
+
+	after, err := reconcilePayment(h.store.Payments, h.paystack, before)
+	meta := map[string]interface{}{"status_before": before.Status, "status_after": after.Status}
+	if err != nil {
+		meta["error"] = err.Error()
+	}
+	h.record(r, "admin.payment.reconcile", "payment", paymentID, meta)
+
+	if err == errPaystackNotConfigured {
+		respondError(w, http.StatusServiceUnavailable, "Paystack is not configured")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Reconciliation failed: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    after,
+		Message: "Payment reconciled",
+	})
+}
+
+// ResendInvite sends a pending tenant invitation again
+func (h *AdminHandler) ResendInvite(w http.ResponseWriter, r *http.Request) {
+	inviteID := getPathParam(r, "id")
+
+	invite, err := h.store.Invitations.GetInvitation(inviteID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Invitation not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch invitation")
+		return
+	}
+	if invite.Status != "pending" {
+		respondError(w, http.StatusConflict, "Only pending invitations can be resent")
+		return
+	}
+
+	var buildingName, unitNumber string
+	if invite.Unit != nil {
+		unitNumber = invite.Unit.UnitNumber
+	}
+	if invite.Buildings != nil && invite.Buildings.Building != nil {
+		buildingName = invite.Buildings.Building.Name
+	}
+	err = sendTenantInvite(h.notifier, invite.Invitation, buildingName, unitNumber)
+	meta := map[string]interface{}{}
+	if err != nil {
+		meta["error"] = err.Error()
+	}
+	h.record(r, "admin.invitation.resend", "invitation", inviteID, meta)
+
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Failed to send invitation: "+err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Invitation resent",
+	})
+}
+
+// StartImpersonation opens a short read-only session as another user. The
+// returned token goes in the X-Impersonate header alongside the admin's own
+// bearer token.
+func (h *AdminHandler) StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	adminID := middleware.GetUserID(r)
+
+	var req models.StartImpersonationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.UserID == "" || req.Reason == "" {
+		respondError(w, http.StatusBadRequest, "user_id and reason are required")
+		return
+	}
+
+	target, err := h.store.Profiles.GetProfile(req.UserID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+	if target.Role == "admin" || slices.Contains(target.Roles, "admin") {
+		respondError(w, http.StatusForbidden, "Admins cannot be impersonated")
+		return
+	}
+
+	token := generateToken() + generateToken()
+	created, err := h.store.Admin.CreateImpersonation(models.ImpersonationSession{
+		AdminID:   adminID,
+		UserID:    req.UserID,
+		Reason:    req.Reason,
+		ExpiresAt: time.Now().UTC().Add(impersonationTTL),
+	}, hashToken(token))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to start impersonation")
+		return
+	}
+
+	h.record(r, "admin.impersonation.start", "user", req.UserID, map[string]interface{}{"reason": req.Reason, "session_id": created.ID})
+
+	respondJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": created.ID,
+			"token":      token,
+			"header":     middleware.ImpersonateHeader,
+			"expires_at": created.ExpiresAt,
+		},
+		Message: "Read-only impersonation started",
+	})
+}
+
+// EndImpersonation closes one of the admin's impersonation sessions early
+func (h *AdminHandler) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	sessionID := getPathParam(r, "id")
+
+	ended, err := h.store.Admin.EndImpersonation(sessionID, middleware.GetUserID(r))
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Impersonation session not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to end impersonation")
+		return
+	}
+
+	h.record(r, "admin.impersonation.end", "user", ended.UserID, map[string]interface{}{"session_id": sessionID})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Impersonation ended",
+	})
+}
+
+func (h *AdminHandler) record(r *http.Request, action, resourceType, resourceID string, meta map[string]interface{}) {
+	h.audit.Record(r, audit.Entry{
+		ActorID:      middleware.GetUserID(r),
+		ActorRole:    "admin",
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Metadata:     meta,
+	})
+}
+
+// searchTerm trims a free-text query and drops characters that have
+// meaning in PostgREST filter syntax, which the Supabase store builds its
+// searches from
+func searchTerm(q string) string {
+	q = strings.Map(func(c rune) rune {
+		if strings.ContainsRune(",()*\\\"", c) {
+			return -1
+		}
+		return c
+	}, strings.TrimSpace(q))
+	if len(q) > 100 {
+		q = q[:100]
+	}
+	return q
+}
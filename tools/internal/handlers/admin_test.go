@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const adminID = "10000000-0000-0000-0000-0000000000ad"
+
+func TestAdminSearchUsers(t *testing.T) {
+	f := newFixture(t)
+	h := NewAdminHandler(f.st, f.notify, nil, audit.New(f.st.Audit))
+	admin := caller{id: adminID, role: "admin"}
+
+	cases := []struct {
+		q    string
+		want []string
+	}{
+		{"Ada@Example.com", []string{landlordID}},
+		{"0803 000 0003", []string{tenantID}},
+		{tenantID, []string{tenantID}},
+		{"landlord", []string{landlordID, otherLandlordID}},
+		{"nobody@example.com", nil},
+		{"99999999-0000-0000-0000-000000000000", nil},
+	}
+	for _, c := range cases {
+		var users []models.Profile
+		call(t, h.SearchUsers, admin, "GET", "/api/v1/admin/users?q="+url.QueryEscape(c.q), nil).expect(t, http.StatusOK).decode(t, &users)
+		got := map[string]bool{}
+		for _, u := range users {
+			got[u.ID] = true
+		}
+		if len(users) != len(c.want) {
+			t.Errorf("q=%q found %d users, want %v", c.q, len(users), c.want)
+			continue
+		}
+		for _, id := range c.want {
+			if !got[id] {
+				t.Errorf("q=%q is missing %s", c.q, id)
+			}
+		}
+	}
+	call(t, h.SearchUsers, admin, "GET", "/api/v1/admin/users?q=()", nil).expect(t, http.StatusBadRequest)
+
+	// Searches are audited too
+	entries, err := f.st.Audit.ListAuditEntries(store.AuditFilter{Equal: map[string]string{"action": "admin.search.users"}}, 0, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(cases) || *entries[0].ActorID != adminID {
+		t.Errorf("got %d search entries, want %d by the admin", len(entries), len(cases))
+	}
+	// Contact details are searched for but never written down
+	for _, e := range entries {
+		if meta := string(e.Metadata); strings.Contains(meta, "example.com") || strings.Contains(meta, "0803") {
+			t.Errorf("search entry holds a contact detail: %s", meta)
+		}
+	}
+}
+
+func TestAdminImpersonation(t *testing.T) {
+	f := newFixture(t)
+	f.mem.PutProfile(models.Profile{ID: adminID, Role: "admin", Roles: []string{"admin"}, FullName: "Efe Admin", Email: "efe@example.com"})
+	h := NewAdminHandler(f.st, f.notify, nil, audit.New(f.st.Audit))
+	admin := caller{id: adminID, role: "admin"}
+
+	call(t, h.StartImpersonation, admin, "POST", "/api/v1/admin/impersonate", models.StartImpersonationRequest{UserID: tenantID}).expect(t, http.StatusBadRequest)
+	call(t, h.StartImpersonation, admin, "POST", "/api/v1/admin/impersonate", models.StartImpersonationRequest{UserID: adminID, Reason: "curious"}).expect(t, http.StatusForbidden)
+	call(t, h.StartImpersonation, admin, "POST", "/api/v1/admin/impersonate", models.StartImpersonationRequest{UserID: "99999999-0000-0000-0000-000000000000", Reason: "ticket 12"}).expect(t, http.StatusNotFound)
+
+	var started struct {
+		SessionID string `json:"session_id"`
+		Token     string `json:"token"`
+	}
+	call(t, h.StartImpersonation, admin, "POST", "/api/v1/admin/impersonate", models.StartImpersonationRequest{UserID: tenantID, Reason: " ticket 12 "}).expect(t, http.StatusCreated).decode(t, &started)
+	sess, err := f.st.Admin.FindImpersonation(hashToken(started.Token), adminID)
+	if err != nil {
+		t.Fatalf("session not stored under the token's hash: %v", err)
+	}
+	if sess.ID != started.SessionID || sess.UserID != tenantID || sess.Reason != "ticket 12" {
+		t.Errorf("session = %+v", sess)
+	}
+	if _, err := f.st.Admin.FindImpersonation(hashToken(started.Token), staffID); err != store.ErrNotFound {
+		t.Errorf("another admin can use the session: %v", err)
+	}
+
+	endPath := "/api/v1/admin/impersonate/" + started.SessionID
+	call(t, h.EndImpersonation, caller{id: staffID, role: "admin"}, "DELETE", endPath, nil, "id", started.SessionID).expect(t, http.StatusNotFound)
+	call(t, h.EndImpersonation, admin, "DELETE", endPath, nil, "id", started.SessionID).expect(t, http.StatusOK)
+	call(t, h.EndImpersonation, admin, "DELETE", endPath, nil, "id", started.SessionID).expect(t, http.StatusNotFound)
+	if _, err := f.st.Admin.FindImpersonation(hashToken(started.Token), adminID); err != store.ErrNotFound {
+		t.Errorf("ended session still resolves: %v", err)
+	}
+
+	entries, err := f.st.Audit.ListAuditEntries(store.AuditFilter{Equal: map[string]string{"resource_id": tenantID}}, 0, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "admin.impersonation.end" || entries[1].Action != "admin.impersonation.start" {
+		t.Errorf("audit entries = %+v, want the start and end", entries)
+	}
+}
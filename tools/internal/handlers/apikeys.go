@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const (
+	apiKeyDefaultDays      = 90
+	apiKeyMaxDays          = 365
+	apiKeyDefaultPerMinute = 60
+	apiKeyMaxPerMinute     = 600
+)
+
+type APIKeysHandler struct {
+	store *store.Store
+	audit *audit.Logger
+}
+
+func NewAPIKeysHandler(st *store.Store, auditLog *audit.Logger) *APIKeysHandler {
+	return &APIKeysHandler{store: st, audit: auditLog}
+}
+
+// CreateAPIKey issues a scoped key. The full key is returned once and only
+// its hash is stored.
+func (h *APIKeysHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 {
+		respondError(w, http.StatusBadRequest, "Name and at least one scope are required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(middleware.APIKeyScopes, scope) {
+			respondError(w, http.StatusBadRequest, "Unknown scope '"+scope+"'. Valid scopes: "+strings.Join(middleware.APIKeyScopes, ", "))
+			return
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = apiKeyDefaultDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > apiKeyMaxDays {
+		respondError(w, http.StatusBadRequest, "expires_in_days must be between 1 and 365")
+		return
+	}
+	if req.RateLimitPerMinute == 0 {
+		req.RateLimitPerMinute = apiKeyDefaultPerMinute
+	}
+	if req.RateLimitPerMinute < 1 || req.RateLimitPerMinute > apiKeyMaxPerMinute {
+		respondError(w, http.StatusBadRequest, "rate_limit_per_minute must be between 1 and 600")
+		return
+	}
+
+	key := middleware.APIKeyPrefix + generateToken() + generateToken()
+	created, err := h.store.APIKeys.CreateAPIKey(models.APIKey{
+		UserID:             userID,
+		Name:               req.Name,
+		Prefix:             key[:len(middleware.APIKeyPrefix)+8],
+		Scopes:             req.Scopes,
+		RateLimitPerMinute: req.RateLimitPerMinute,
+		ExpiresAt:          time.Now().UTC().AddDate(0, 0, req.ExpiresInDays),
+	}, hashToken(key))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "api_key.create",
+		ResourceType: "api_key",
+		ResourceID:   created.ID,
+		After:        created,
+	})
+
+	respondJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    models.CreateAPIKeyResponse{APIKey: created, Key: key},
+		Message: "Copy this key now; it will not be shown again",
+	})
+}
+
+// ListAPIKeys returns the caller's keys without their secrets
+func (h *APIKeysHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.store.APIKeys.ListAPIKeys(middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch API keys")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    keys,
+	})
+}
+
+// RevokeAPIKey stops a key from working immediately
+func (h *APIKeysHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID := getPathParam(r, "id")
+
+	revoked, err := h.store.APIKeys.RevokeAPIKey(keyID, middleware.GetUserID(r))
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "api_key.revoke",
+		ResourceType: "api_key",
+		ResourceID:   keyID,
+		After:        map[string]interface{}{"revoked_at": revoked.RevokedAt},
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    revoked,
+		Message: "API key revoked",
+	})
+}
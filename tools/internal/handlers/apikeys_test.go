@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+)
+
+func TestAPIKeys(t *testing.T) {
+	f := newFixture(t)
+	h := NewAPIKeysHandler(f.st, nil)
+
+	call(t, h.CreateAPIKey, asLandlord, "POST", "/api/v1/api-keys", models.CreateAPIKeyRequest{Name: "Books", Scopes: []string{"everything"}}).expect(t, http.StatusBadRequest)
+	call(t, h.CreateAPIKey, asLandlord, "POST", "/api/v1/api-keys", models.CreateAPIKeyRequest{Name: "Books", Scopes: []string{middleware.ScopePaymentsRead}, ExpiresInDays: 400}).expect(t, http.StatusBadRequest)
+
+	var created models.CreateAPIKeyResponse
+	req := models.CreateAPIKeyRequest{Name: " Books ", Scopes: []string{middleware.ScopePaymentsRead, middleware.ScopeBuildingsRead, middleware.ScopePaymentsRead}}
+	call(t, h.CreateAPIKey, asLandlord, "POST", "/api/v1/api-keys", req).expect(t, http.StatusCreated).decode(t, &created)
+	if !strings.HasPrefix(created.Key, created.Prefix) || !strings.HasPrefix(created.Key, middleware.APIKeyPrefix) {
+		t.Errorf("key %q does not start with its prefix %q", created.Key, created.Prefix)
+	}
+	if created.Name != "Books" || len(created.Scopes) != 2 || created.RateLimitPerMinute != apiKeyDefaultPerMinute || created.ExpiresAt.IsZero() {
+		t.Errorf("created = %+v, want defaults and de-duplicated scopes", created.APIKey)
+	}
+
+	var keys []map[string]interface{}
+	call(t, h.ListAPIKeys, asLandlord, "GET", "/api/v1/api-keys", nil).expect(t, http.StatusOK).decode(t, &keys)
+	if len(keys) != 1 || keys[0]["id"] != created.ID {
+		t.Fatalf("keys = %v, want the new key", keys)
+	}
+	if _, ok := keys[0]["key"]; ok {
+		t.Error("listed key includes its secret")
+	}
+	call(t, h.ListAPIKeys, asOtherLandlord, "GET", "/api/v1/api-keys", nil).expect(t, http.StatusOK).decode(t, &keys)
+	if len(keys) != 0 {
+		t.Errorf("another landlord sees %d keys", len(keys))
+	}
+
+	call(t, h.RevokeAPIKey, asOtherLandlord, "DELETE", "/api/v1/api-keys/"+created.ID, nil, "id", created.ID).expect(t, http.StatusNotFound)
+	var revoked models.APIKey
+	call(t, h.RevokeAPIKey, asLandlord, "DELETE", "/api/v1/api-keys/"+created.ID, nil, "id", created.ID).expect(t, http.StatusOK).decode(t, &revoked)
+	if revoked.RevokedAt == nil {
+		t.Error("revoked key has no revoked_at")
+	}
+	call(t, h.RevokeAPIKey, asLandlord, "DELETE", "/api/v1/api-keys/"+created.ID, nil, "id", created.ID).expect(t, http.StatusNotFound)
+}
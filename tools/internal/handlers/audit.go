@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const (
+	auditDefaultLimit = 50
+	auditMaxLimit     = 200
+)
+
+type AuditHandler struct {
+	store *store.Store
+}
+
+func NewAuditHandler(st *store.Store) *AuditHandler {
+	return &AuditHandler{store: st}
+}
+
+// ListAuditLog returns audit entries, newest first. Platform admins see
+// everything; building owners see entries for their buildings. Filters:
+// ?building_id= ?actor_id= ?action= ?resource_type= ?resource_id=
+// ?from=&to= (YYYY-MM-DD, inclusive) and ?limit=&offset=.
+func (h *AuditHandler) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.AuditFilter{Equal: map[string]string{}}
+
+	if middleware.GetUserRole(r) != "admin" {
+		ids := middleware.BuildingsWith(r, access.ViewAuditLog)
+		if len(ids) == 0 {
+			respondError(w, http.StatusForbidden, "Only building owners and admins can read the audit log")
+			return
+		}
+		if b := q.Get("building_id"); b != "" && !slices.Contains(ids, b) {
+			respondError(w, http.StatusNotFound, "Building not found")
+			return
+		}
+		filter.BuildingIDs = ids
+	}
+
+	for _, col := range []string{"building_id", "actor_id", "action", "resource_type", "resource_id"} {
+		if v := q.Get(col); v != "" {
+			filter.Equal[col] = v
+		}
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "from must be a date (YYYY-MM-DD)")
+			return
+		}
+		filter.From = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "to must be a date (YYYY-MM-DD)")
+			return
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+
+	limit := auditDefaultLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > auditMaxLimit {
+			respondError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respondError(w, http.StatusBadRequest, "offset must be zero or more")
+			return
+		}
+		offset = n
+	}
+
+	entries, err := h.store.Audit.ListAuditEntries(filter, offset, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch audit log")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    entries,
+	})
+}
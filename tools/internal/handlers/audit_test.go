@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+)
+
+func TestListAuditLog(t *testing.T) {
+	f := newFixture(t)
+	h := NewAuditHandler(f.st)
+	ref := func(s string) *string { return &s }
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	f.mem.PutAuditEntry(models.AuditEntry{ActorID: ref(landlordID), Action: "building.update", ResourceType: "building", BuildingID: ref(buildingA), CreatedAt: day(1)})
+	f.mem.PutAuditEntry(models.AuditEntry{ActorID: ref(staffID), Action: "unit.update", ResourceType: "unit", BuildingID: ref(buildingA), CreatedAt: day(2)})
+	f.mem.PutAuditEntry(models.AuditEntry{ActorID: ref(otherLandlordID), Action: "building.update", ResourceType: "building", BuildingID: ref(buildingB), CreatedAt: day(3)})
+	f.mem.PutAuditEntry(models.AuditEntry{ActorID: ref(landlordID), Action: "auth.login", ResourceType: "user", CreatedAt: day(4)})
+
+	var entries []models.AuditEntry
+	call(t, h.ListAuditLog, asLandlord, "GET", "/api/v1/audit-log", nil).expect(t, http.StatusOK).decode(t, &entries)
+	if len(entries) != 2 || entries[0].Action != "unit.update" || entries[1].Action != "building.update" {
+		t.Errorf("owner sees %+v, want building A's entries, newest first", entries)
+	}
+
+	call(t, h.ListAuditLog, asLandlord, "GET", "/api/v1/audit-log?actor_id="+staffID, nil).expect(t, http.StatusOK).decode(t, &entries)
+	if len(entries) != 1 || *entries[0].ActorID != staffID {
+		t.Errorf("actor filter = %+v, want the staff entry", entries)
+	}
+	call(t, h.ListAuditLog, asLandlord, "GET", "/api/v1/audit-log?from=2026-03-01&to=2026-03-01", nil).expect(t, http.StatusOK).decode(t, &entries)
+	if len(entries) != 1 || entries[0].Action != "building.update" {
+		t.Errorf("date filter = %+v, want the 1 March entry", entries)
+	}
+
+	call(t, h.ListAuditLog, asLandlord, "GET", "/api/v1/audit-log?building_id="+buildingB, nil).expect(t, http.StatusNotFound)
+	call(t, h.ListAuditLog, asTenant, "GET", "/api/v1/audit-log", nil).expect(t, http.StatusForbidden)
+	call(t, h.ListAuditLog, asLandlord, "GET", "/api/v1/audit-log?limit=500", nil).expect(t, http.StatusBadRequest)
+
+	admin := caller{id: staffID, role: "admin"}
+	call(t, h.ListAuditLog, admin, "GET", "/api/v1/audit-log?limit=3", nil).expect(t, http.StatusOK).decode(t, &entries)
+	if len(entries) != 3 || entries[0].Action != "auth.login" {
+		t.Errorf("admin sees %+v, want the latest 3 entries", entries)
+	}
+	call(t, h.ListAuditLog, admin, "GET", "/api/v1/audit-log?offset=3", nil).expect(t, http.StatusOK).decode(t, &entries)
+	if len(entries) != 1 {
+		t.Errorf("offset 3 = %d entries, want 1", len(entries))
+	}
+}
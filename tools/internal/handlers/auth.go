@@ -2,19 +2,35 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/mfa"
+	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/phone"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
+	gotrue "github.com/supabase-community/gotrue-go"
 	gotrue_types "github.com/supabase-community/gotrue-go/types"
-	supabase "github.com/supabase-community/supabase-go"
 )
 
 type AuthHandler struct {
-	client *supabase.Client
+	auth      gotrue.Client
+	admin     gotrue.Client // service-role auth client; nil when not configured
+	store     *store.Store
+	notifier  *notify.Notifier
+	mfa       *mfa.Service
+	otpSecret []byte
+	audit     *audit.Logger
 }
 
-func NewAuthHandler(client *supabase.Client) *AuthHandler {
-	return &AuthHandler{client: client}
+func NewAuthHandler(auth, admin gotrue.Client, st *store.Store, notifier *notify.Notifier, mfaService *mfa.Service, otpSecret []byte, auditLog *audit.Logger) *AuthHandler {
+	return &AuthHandler{auth: auth, admin: admin, store: st, notifier: notifier, mfa: mfaService, otpSecret: otpSecret, audit: auditLog}
 }
 
 // Signup handles new user registration (landlord or tenant direct signup)
@@ -30,13 +46,22 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Role != "landlord" && req.Role != "tenant" {
-		respondError(w, http.StatusBadRequest, "Role must be 'landlord' or 'tenant'")
+	if !validSignupRole(req.Role) {
+		respondError(w, http.StatusBadRequest, "Role must be 'landlord', 'tenant' or 'staff'")
 		return
 	}
 
+	if req.Phone != "" {
+		normalized, err := phone.NormalizeNG(req.Phone)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Phone = normalized
+	}
+
 	// Sign up with Supabase Auth
-	session, err := h.client.Auth.Signup(gotrue_types.SignupRequest{
+	session, err := h.auth.Signup(gotrue_types.SignupRequest{
 		Email:    req.Email,
 		Password: req.Password,
 	})
@@ -46,20 +71,29 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create profile record
-	profile := map[string]interface{}{
-		"id":        session.User.ID.String(),
-		"role":      req.Role,
-		"full_name": req.FullName,
-		"email":     req.Email,
-		"phone":     req.Phone,
+	profile := models.Profile{
+		ID:       session.User.ID.String(),
+		Role:     req.Role,
+		Roles:    []string{req.Role},
+		FullName: req.FullName,
+		Email:    req.Email,
+	}
+	if req.Phone != "" {
+		profile.Phone = &req.Phone
 	}
 
-	_, _, err = h.client.From("profiles").Insert(profile, false, "", "", "").Execute()
-	if err != nil {
+	if _, err := h.store.Profiles.CreateProfile(profile); err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to create profile: "+err.Error())
 		return
 	}
 
+	userID := session.User.ID.String()
+	if err := h.sendVerificationEmail(userID, req.FullName, req.Email); err != nil {
+		log.Printf("signup: verification email for %s not sent: %v", userID, err)
+	}
+
+	h.recordAuthEvent(r, "auth.signup", userID, req.Role, map[string]interface{}{"method": "email"})
+
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
 		Data: models.AuthResponse{
@@ -68,11 +102,12 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 			User: models.Profile{
 				ID:       session.User.ID.String(),
 				Role:     req.Role,
+				Roles:    []string{req.Role},
 				FullName: req.FullName,
 				Email:    req.Email,
 			},
 		},
-		Message: "Account created successfully",
+		Message: "Account created successfully. Check your email to verify your address.",
 	})
 }
 
@@ -89,29 +124,59 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Sign in with Supabase Auth
-	session, err := h.client.SignInWithEmailPassword(req.Email, req.Password)
+	// Locked emails are refused before the password is even checked. Only
+	// the blind index of the email is stored or audited.
+	key := lockoutKey(h.otpSecret, req.Email)
+	wait, err := lockedFor(h.store.Lockouts, key)
+	if err != nil {
+		log.Printf("login lockout check failed: %v", err)
+	}
+	if wait > 0 {
+		h.recordAuthEvent(r, "auth.login.locked", "", "", map[string]interface{}{"email_index": key})
+		respondLockedOut(w, wait)
+		return
+	}
+
+	// Sign in with Supabase Auth. Use the Auth client directly so the shared
+	// client is not switched over to this user's session.
+	token, err := h.auth.SignInWithEmailPassword(req.Email, req.Password)
 	if err != nil {
+		wait, lockErr := recordLoginFailure(h.store.Lockouts, key)
+		if lockErr != nil {
+			log.Printf("failed to record login failure: %v", lockErr)
+		}
+		h.recordAuthEvent(r, "auth.login.failed", "", "", map[string]interface{}{"email_index": key})
+		if wait > 0 {
+			respondLockedOut(w, wait)
+			return
+		}
 		respondError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
+	clearLoginFailures(h.store.Lockouts, key)
+	session := token.Session
 
 	// Get profile for role info
 	userID := session.User.ID.String()
-	data, _, err := h.client.From("profiles").Select("*", "exact", false).Eq("id", userID).Execute()
-	if err != nil {
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err != nil && err != store.ErrNotFound {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
 		return
 	}
 
-	var profiles []models.Profile
-	json.Unmarshal(data, &profiles)
-
-	var profile models.Profile
-	if len(profiles) > 0 {
-		profile = profiles[0]
+	// Accounts with 2FA get a short-lived challenge instead of a session
+	mfaStatus, err := h.mfa.Status(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check two-factor settings")
+		return
+	}
+	if mfaStatus.Enabled {
+		h.startMFAChallenge(w, userID, &session)
+		return
 	}
 
+	h.recordAuthEvent(r, "auth.login", userID, profile.Role, map[string]interface{}{"method": "password"})
+
 	respondJSON(w, http.StatusOK, models.APIResponse{
 		Success: true,
 		Data: models.AuthResponse{
@@ -122,7 +187,9 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// AcceptInvite handles tenant invite acceptance — creates account and links to unit
+// AcceptInvite handles tenant invite acceptance — creates account and links to unit.
+// Email invites sign up with email+password; phone invites can instead prove
+// the invited number with an SMS code and get a passwordless account.
 func (h *AuthHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
 	var req models.AcceptInviteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -130,80 +197,571 @@ func (h *AuthHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Token == "" || req.Email == "" || req.Password == "" || req.FullName == "" {
-		respondError(w, http.StatusBadRequest, "Token, email, password, and full_name are required")
+	phoneOnly := req.Email == ""
+	if req.Token == "" || req.FullName == "" ||
+		(phoneOnly && (req.Phone == "" || req.Code == "")) ||
+		(!phoneOnly && req.Password == "") {
+		respondError(w, http.StatusBadRequest, "Token and full_name are required, plus either email and password or phone and code")
 		return
 	}
 
-	// Find the invitation by token
-	data, _, err := h.client.From("invitations").Select("*, units(building_id)", "exact", false).Eq("token", req.Token).Eq("status", "pending").Execute()
+	phoneNumber := req.Phone
+	if req.Phone != "" {
+		normalized, err := phone.NormalizeNG(req.Phone)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		phoneNumber = normalized
+	}
+
+	invite, err := h.store.Invitations.GetPendingInvitation(req.Token)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
+		return
+	}
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to lookup invitation")
 		return
 	}
 
-	var invitations []struct {
-		models.Invitation
-		Units struct {
-			BuildingID string `json:"building_id"`
-		} `json:"units"`
+	// Refuse before creating an account that could not be linked anyway;
+	// AcceptInvitation checks again under a lock
+	if unit, err := h.store.Units.GetUnit(invite.UnitID); err == nil && unit.TenantID != nil {
+		respondError(w, http.StatusConflict, "This unit already has a tenant")
+		return
 	}
-	json.Unmarshal(data, &invitations)
 
-	if len(invitations) == 0 {
-		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
-		return
+	var session gotrue_types.Session
+	if phoneOnly {
+		invitedPhone := ""
+		if invite.Phone != nil {
+			invitedPhone, _ = phone.NormalizeNG(*invite.Phone)
+		}
+		if invitedPhone == "" || invitedPhone != phoneNumber {
+			respondError(w, http.StatusForbidden, "Phone number does not match the invitation")
+			return
+		}
+		if h.admin == nil {
+			respondError(w, http.StatusServiceUnavailable, "Phone sign-in is not configured")
+			return
+		}
+		if err := verifyOTP(h.store.OTPs, h.otpSecret, phoneNumber, req.Code); err != nil {
+			respondOTPError(w, err)
+			return
+		}
+		session, err = h.createPhoneUser(phoneNumber)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Signup failed: "+err.Error())
+			return
+		}
+	} else {
+		// Sign up the tenant
+		signup, err := h.auth.Signup(gotrue_types.SignupRequest{
+			Email:    req.Email,
+			Password: req.Password,
+		})
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Signup failed: "+err.Error())
+			return
+		}
+		session = signup.Session
+		if session.User.ID == uuid.Nil {
+			session.User = signup.User
+		}
 	}
 
-	invite := invitations[0]
+	tenantID := session.User.ID.String()
 
-	// Sign up the tenant
-	session, err := h.client.Auth.Signup(gotrue_types.SignupRequest{
+	user := models.Profile{
+		ID:       tenantID,
+		Role:     "tenant",
+		Roles:    []string{"tenant"},
+		FullName: req.FullName,
 		Email:    req.Email,
-		Password: req.Password,
+	}
+	if phoneNumber != "" {
+		user.Phone = &phoneNumber
+	}
+	now := time.Now().UTC()
+	// The invite link was delivered to this address, which proves ownership
+	if invite.Email != nil && strings.EqualFold(*invite.Email, req.Email) {
+		user.EmailVerifiedAt = &now
+	}
+	if phoneOnly {
+		user.PhoneVerifiedAt = &now
+	}
+
+	// The auth account cannot join the database transaction, so it is
+	// removed again if the invitation cannot be accepted
+	accepted, err := h.store.Invitations.AcceptInvitation(store.Acceptance{Token: invite.Token, TenantID: tenantID, NewProfile: &user})
+	if err != nil {
+		h.discardSignup(session.User.ID)
+		respondAcceptError(w, err)
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.signup", tenantID, "tenant", map[string]interface{}{"method": "invite"})
+	h.audit.Record(r, audit.Entry{
+		ActorID:      tenantID,
+		ActorRole:    "tenant",
+		Action:       "invitation.accept",
+		ResourceType: "invitation",
+		ResourceID:   invite.ID,
+		BuildingID:   accepted.BuildingID,
+		Before:       map[string]interface{}{"status": invite.Status},
+		After:        map[string]interface{}{"status": "accepted", "tenant_id": tenantID, "tenancy_id": accepted.TenancyID},
+		Metadata:     map[string]interface{}{"unit_id": invite.UnitID},
 	})
+
+	respondJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: models.AuthResponse{
+			AccessToken:  session.AccessToken,
+			RefreshToken: session.RefreshToken,
+			User:         user,
+		},
+		Message: "Invitation accepted, account created",
+	})
+}
+
+// RequestOTP sends a one-time login code to a Nigerian mobile number
+func (h *AuthHandler) RequestOTP(w http.ResponseWriter, r *http.Request) {
+	var req models.RequestOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	phoneNumber, err := phone.NormalizeNG(req.Phone)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Signup failed: "+err.Error())
+		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	tenantID := session.User.ID.String()
+	code, err := issueOTP(h.store.OTPs, h.otpSecret, phoneNumber)
+	if err != nil {
+		respondOTPError(w, err)
+		return
+	}
+
+	if err := h.notifier.SMS("", phoneNumber, notify.LoginCodeSMS(code, "10 minutes")); err != nil {
+		respondError(w, http.StatusBadGateway, "Failed to send SMS, please try again")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"phone":      phone.Mask(phoneNumber),
+			"expires_in": int(otpTTL.Seconds()),
+		},
+		Message: "Code sent",
+	})
+}
+
+// VerifyOTP exchanges an SMS code for a session, or for a two-factor
+// challenge when the account has 2FA on (finish with LoginMFA). If no
+// account exists for the number yet, full_name and role create one (phone
+// signup).
+func (h *AuthHandler) VerifyOTP(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Phone == "" || req.Code == "" {
+		respondError(w, http.StatusBadRequest, "Phone and code are required")
+		return
+	}
 
-	// Create profile
-	profile := map[string]interface{}{
-		"id":        tenantID,
-		"role":      "tenant",
-		"full_name": req.FullName,
-		"email":     req.Email,
-		"phone":     req.Phone,
+	phoneNumber, err := phone.NormalizeNG(req.Phone)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if h.admin == nil {
+		respondError(w, http.StatusServiceUnavailable, "Phone sign-in is not configured")
+		return
+	}
+
+	profile, err := h.store.Profiles.FindProfileByPhone(phoneNumber)
+	exists := err == nil
+	if err != nil && err != store.ErrNotFound {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	// A number someone typed into their profile but never confirmed proves
+	// nothing about who owns the account, so it can't be used to sign in
+	if exists && profile.PhoneVerifiedAt == nil {
+		respondError(w, http.StatusForbidden, "This phone number has not been verified. Sign in with your email and confirm the number from your profile.")
+		return
 	}
-	h.client.From("profiles").Insert(profile, false, "", "", "").Execute()
 
-	// Link tenant to unit
-	update := map[string]interface{}{
-		"tenant_id": tenantID,
-		"status":    "occupied",
+	// Validate signup fields before burning the code
+	if !exists {
+		if req.FullName == "" || req.Role == "" {
+			respondError(w, http.StatusNotFound, "No account for this phone number. Provide full_name and role to sign up.")
+			return
+		}
+		if !validSignupRole(req.Role) {
+			respondError(w, http.StatusBadRequest, "Role must be 'landlord', 'tenant' or 'staff'")
+			return
+		}
 	}
-	h.client.From("units").Update(update, "", "").Eq("id", invite.UnitID).Execute()
 
-	// Mark invitation as accepted
-	invUpdate := map[string]interface{}{
-		"status": "accepted",
+	if err := verifyOTP(h.store.OTPs, h.otpSecret, phoneNumber, req.Code); err != nil {
+		respondOTPError(w, err)
+		return
 	}
-	h.client.From("invitations").Update(invUpdate, "", "").Eq("id", invite.ID).Execute()
+
+	now := time.Now().UTC()
+
+	if exists {
+		// The SMS code is only the first factor for accounts with 2FA
+		mfaStatus, err := h.mfa.Status(profile.ID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check two-factor settings")
+			return
+		}
+		if mfaStatus.Enabled {
+			h.startMFAChallenge(w, profile.ID, nil)
+			return
+		}
+
+		session, err := h.sessionForProfile(profile)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to sign in")
+			return
+		}
+		h.recordAuthEvent(r, "auth.login", profile.ID, profile.Role, map[string]interface{}{"method": "phone"})
+		respondJSON(w, http.StatusOK, models.APIResponse{
+			Success: true,
+			Data: models.AuthResponse{
+				AccessToken:  session.AccessToken,
+				RefreshToken: session.RefreshToken,
+				User:         profile,
+			},
+		})
+		return
+	}
+
+	session, err := h.createPhoneUser(phoneNumber)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Signup failed: "+err.Error())
+		return
+	}
+
+	userID := session.User.ID.String()
+	created := models.Profile{
+		ID:              userID,
+		Role:            req.Role,
+		Roles:           []string{req.Role},
+		FullName:        req.FullName,
+		Phone:           &phoneNumber,
+		PhoneVerifiedAt: &now,
+	}
+	if _, err := h.store.Profiles.CreateProfile(created); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create profile: "+err.Error())
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.signup", userID, req.Role, map[string]interface{}{"method": "phone"})
 
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
 		Data: models.AuthResponse{
 			AccessToken:  session.AccessToken,
 			RefreshToken: session.RefreshToken,
-			User: models.Profile{
-				ID:       tenantID,
-				Role:     "tenant",
-				FullName: req.FullName,
-				Email:    req.Email,
-			},
+			User:         created,
 		},
-		Message: "Invitation accepted, account created",
+		Message: "Account created successfully",
 	})
 }
+
+// validSignupRole reports whether a new account may choose role. Staff
+// accounts get building access only through accepted staff invites.
+func validSignupRole(role string) bool {
+	return role == "landlord" || role == "tenant" || role == "staff"
+}
+
+// createPhoneUser registers a passwordless Supabase user for a verified
+// phone number and signs them in. The random password is never returned;
+// the account is only reachable through SMS codes.
+func (h *AuthHandler) createPhoneUser(phoneNumber string) (gotrue_types.Session, error) {
+	password := generateToken() + generateToken()
+	if _, err := h.admin.AdminCreateUser(gotrue_types.AdminCreateUserRequest{
+		Phone:        phoneNumber,
+		PhoneConfirm: true,
+		Password:     &password,
+	}); err != nil {
+		return gotrue_types.Session{}, err
+	}
+
+	token, err := h.auth.SignInWithPhonePassword(phoneNumber, password)
+	if err != nil {
+		return gotrue_types.Session{}, err
+	}
+	return token.Session, nil
+}
+
+// discardSignup deletes an auth user created while accepting an invitation
+// that then could not be accepted, so the address can sign up again.
+// Signup can hand back an account that already existed; one with a profile
+// is never touched. Without the service-role client the account is left
+// behind and logged.
+func (h *AuthHandler) discardSignup(userID uuid.UUID) {
+	if _, err := h.store.Profiles.GetProfile(userID.String()); err != store.ErrNotFound {
+		return
+	}
+	if h.admin == nil {
+		log.Printf("invite: auth user %s left without a profile: service role not configured", userID)
+		return
+	}
+	if err := h.admin.AdminDeleteUser(gotrue_types.AdminDeleteUserRequest{UserID: userID}); err != nil {
+		log.Printf("invite: failed to remove auth user %s: %v", userID, err)
+	}
+}
+
+// sessionForProfile mints a session for a user whose identity has already
+// been proven out of band (SMS code). Accounts with an email use a
+// server-generated magic link; phone-only accounts get their random
+// password rotated and are signed in with it.
+func (h *AuthHandler) sessionForProfile(profile models.Profile) (gotrue_types.Session, error) {
+	if profile.Email != "" {
+		link, err := h.admin.AdminGenerateLink(gotrue_types.AdminGenerateLinkRequest{
+			Type:  gotrue_types.LinkTypeMagicLink,
+			Email: profile.Email,
+		})
+		if err != nil {
+			return gotrue_types.Session{}, err
+		}
+		verified, err := h.auth.VerifyForUser(gotrue_types.VerifyForUserRequest{
+			Type:  gotrue_types.VerificationTypeMagiclink,
+			Token: link.EmailOTP,
+			Email: profile.Email,
+		})
+		if err != nil {
+			return gotrue_types.Session{}, err
+		}
+		return verified.Session, nil
+	}
+
+	uid, err := uuid.Parse(profile.ID)
+	if err != nil {
+		return gotrue_types.Session{}, err
+	}
+	password := generateToken() + generateToken()
+	if _, err := h.admin.AdminUpdateUser(gotrue_types.AdminUpdateUserRequest{
+		UserID:   uid,
+		Password: password,
+	}); err != nil {
+		return gotrue_types.Session{}, err
+	}
+
+	token, err := h.auth.SignInWithPhonePassword(*profile.Phone, password)
+	if err != nil {
+		return gotrue_types.Session{}, err
+	}
+	return token.Session, nil
+}
+
+// ForgotPassword emails a single-use password reset link. It always responds
+// with the same message so it cannot be used to discover registered emails.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		respondError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	ok := models.APIResponse{
+		Success: true,
+		Message: "If an account exists for that email, a reset link has been sent",
+	}
+
+	profile, err := h.store.Profiles.FindProfileByEmail(email)
+	if err == store.ErrNotFound {
+		respondJSON(w, http.StatusOK, ok)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+
+	token, err := issueAuthToken(h.store.AuthTokens, profile.ID, tokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to process request")
+		return
+	}
+
+	link := h.notifier.AppURL() + "/reset-password?token=" + token
+	if err := h.notifier.Email(profile.ID, profile.Email, notify.PasswordResetEmail(profile.FullName, link, "1 hour")); err != nil {
+		log.Printf("forgot-password: reset email for %s not sent: %v", profile.ID, err)
+	}
+
+	respondJSON(w, http.StatusOK, ok)
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		respondError(w, http.StatusBadRequest, "Token and password are required")
+		return
+	}
+
+	if len(req.Password) < 8 {
+		respondError(w, http.StatusBadRequest, "Password must be at least 8 characters")
+		return
+	}
+
+	if h.admin == nil {
+		respondError(w, http.StatusServiceUnavailable, "Password reset is not configured")
+		return
+	}
+
+	userID, err := consumeAuthToken(h.store.AuthTokens, req.Token, tokenPurposePasswordReset)
+	if err == errTokenInvalid || err == errTokenUsed {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify token")
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if _, err := h.admin.AdminUpdateUser(gotrue_types.AdminUpdateUserRequest{
+		UserID:   uid,
+		Password: req.Password,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	// Following a link from the inbox also proves the address is theirs
+	h.markEmailVerified(userID)
+
+	h.recordAuthEvent(r, "auth.password.reset", userID, "", nil)
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password has been reset. You can now log in.",
+	})
+}
+
+// VerifyEmail confirms the user's email address using the emailed token
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" {
+		respondError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	userID, err := consumeAuthToken(h.store.AuthTokens, req.Token, tokenPurposeEmailVerify)
+	if err == errTokenInvalid || err == errTokenUsed {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify token")
+		return
+	}
+
+	if err := h.markEmailVerified(userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.email.verify", userID, "", nil)
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Email verified successfully",
+	})
+}
+
+// ResendVerification sends a fresh verification email to the logged-in user
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	if middleware.IsEmailVerified(r) {
+		respondJSON(w, http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "Email is already verified",
+		})
+		return
+	}
+
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err != nil && err != store.ErrNotFound {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+	if profile.Email == "" {
+		respondError(w, http.StatusBadRequest, "No email address on this account")
+		return
+	}
+
+	if err := h.sendVerificationEmail(userID, profile.FullName, profile.Email); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Verification email sent",
+	})
+}
+
+func (h *AuthHandler) sendVerificationEmail(userID, name, email string) error {
+	token, err := issueAuthToken(h.store.AuthTokens, userID, tokenPurposeEmailVerify, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+	link := h.notifier.AppURL() + "/verify-email?token=" + token
+	return h.notifier.Email(userID, email, notify.EmailVerificationEmail(name, link, "48 hours"))
+}
+
+// recordAuthEvent writes an account event to the audit log. userID is empty
+// for failed logins, which are filed under the attempted email instead.
+func (h *AuthHandler) recordAuthEvent(r *http.Request, action, userID, role string, metadata map[string]interface{}) {
+	h.audit.Record(r, audit.Entry{
+		ActorID:      userID,
+		ActorRole:    role,
+		Action:       action,
+		ResourceType: "user",
+		ResourceID:   userID,
+		Metadata:     metadata,
+	})
+}
+
+func (h *AuthHandler) markEmailVerified(userID string) error {
+	return h.store.Profiles.MarkEmailVerified(userID, time.Now().UTC())
+}
@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/lockout"
+	"github.com/aletheia/backend/internal/mfa"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const (
+	landlordPassword = "landlord-password"
+	tenantPhone      = "+2348030000003"
+)
+
+// newAuthHandler serves auth from the fixture store, with the landlord and
+// tenant registered in a fake Supabase Auth
+func newAuthHandler(f *fixture) (*AuthHandler, *fakeAuth) {
+	auth := &fakeAuth{}
+	auth.add(landlordID, "ada@example.com", "", landlordPassword)
+	auth.add(tenantID, "chidi@example.com", tenantPhone, "tenant-password")
+	return NewAuthHandler(auth, auth, f.st, f.notify, mfa.NewService(f.st.MFA, "Aletheia"), []byte("otp-secret"), nil), auth
+}
+
+// enableMFA turns two-factor on for userID and returns the TOTP secret
+func enableMFA(t *testing.T, f *fixture, userID string) string {
+	t.Helper()
+	secret := mfa.GenerateSecret()
+	enabled := time.Now().UTC()
+	if err := f.st.MFA.PutMFA(models.UserMFA{UserID: userID, Secret: secret, EnabledAt: &enabled}); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// totpCode is what an authenticator app would show for secret right now
+func totpCode(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestSignupAndVerifyEmail(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+
+	signup := map[string]string{"email": "eze@example.com", "password": "secret-pass", "full_name": "Eze New", "role": "landlord", "phone": "08031234567"}
+	var created models.AuthResponse
+	call(t, h.Signup, caller{}, "POST", "/api/v1/auth/signup", signup).expect(t, http.StatusCreated).decode(t, &created)
+	if created.AccessToken == "" || created.User.ID == "" {
+		t.Fatalf("signup response = %+v, want a session", created)
+	}
+
+	profile, err := f.st.Profiles.GetProfile(created.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Email != "eze@example.com" || profile.Phone == nil || *profile.Phone != "+2348031234567" || profile.EmailVerifiedAt != nil {
+		t.Errorf("profile = %+v, want the signup details, unverified", profile)
+	}
+
+	token := f.outbox.linkToken(t, "eze@example.com")
+	call(t, h.VerifyEmail, caller{}, "POST", "/api/v1/auth/verify-email", map[string]string{"token": token}).expect(t, http.StatusOK)
+	if profile, _ = f.st.Profiles.GetProfile(created.User.ID); profile.EmailVerifiedAt == nil {
+		t.Error("email not marked verified")
+	}
+	call(t, h.VerifyEmail, caller{}, "POST", "/api/v1/auth/verify-email", map[string]string{"token": token}).expect(t, http.StatusBadRequest)
+
+	call(t, h.Signup, caller{}, "POST", "/api/v1/auth/signup", signup).expect(t, http.StatusBadRequest)
+}
+
+func TestLoginLockout(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+	h.audit = audit.New(f.st.Audit)
+	key := lockoutKey(h.otpSecret, "ada@example.com")
+	login := func(password string) response {
+		return call(t, h.Login, caller{}, "POST", "/api/v1/auth/login", map[string]string{"email": "Ada@Example.com", "password": password})
+	}
+
+	var session models.AuthResponse
+	login(landlordPassword).expect(t, http.StatusOK).decode(t, &session)
+	if session.AccessToken != "access-"+landlordID || session.User.FullName != "Ada Landlord" {
+		t.Errorf("login = %+v, want the landlord's session and profile", session)
+	}
+
+	// A success clears earlier failures
+	login("wrong").expect(t, http.StatusUnauthorized)
+	login(landlordPassword).expect(t, http.StatusOK)
+	if _, err := f.st.Lockouts.GetLoginLockout(key); err != store.ErrNotFound {
+		t.Errorf("lockout after a successful login: %v, want none", err)
+	}
+
+	for i := 1; i < lockout.Threshold; i++ {
+		login("wrong").expect(t, http.StatusUnauthorized)
+	}
+	res := login("wrong").expect(t, http.StatusTooManyRequests)
+	if res.Error == "" {
+		t.Error("lockout response has no message")
+	}
+	// The right password is refused while locked
+	login(landlordPassword).expect(t, http.StatusTooManyRequests)
+
+	// Lockouts and the audit log only hold the email's blind index
+	if _, err := f.st.Lockouts.GetLoginLockout("ada@example.com"); err != store.ErrNotFound {
+		t.Errorf("lockout stored under the plain email: %v", err)
+	}
+	entries, err := f.st.Audit.ListAuditEntries(store.AuditFilter{}, 0, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := 0
+	for _, e := range entries {
+		if strings.Contains(strings.ToLower(string(e.Metadata)), "ada@example.com") {
+			t.Errorf("%s metadata holds the email: %s", e.Action, e.Metadata)
+		}
+		if e.Action == "auth.login.failed" && strings.Contains(string(e.Metadata), key) {
+			failed++
+		}
+	}
+	if failed != lockout.Threshold+1 {
+		t.Errorf("%d failed logins audited with the email index, want %d", failed, lockout.Threshold+1)
+	}
+}
+
+func TestLoginLockoutConcurrentFailures(t *testing.T) {
+	f := newFixture(t)
+	key := lockoutKey([]byte("otp-secret"), "ada@example.com")
+
+	// Every failure is counted, however many arrive at once
+	const failures = 4 * lockout.Threshold
+	var wg sync.WaitGroup
+	for i := 0; i < failures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := recordLoginFailure(f.st.Lockouts, key); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	l, err := f.st.Lockouts.GetLoginLockout(key)
+	if err != nil || l.FailedCount != failures || l.LockedUntil == nil {
+		t.Fatalf("lockout = %+v (%v), want %d failures and a lock", l, err, failures)
+	}
+	if wait, _ := lockedFor(f.st.Lockouts, key); wait <= lockout.Base || wait > lockout.Max {
+		t.Errorf("locked for %v, want more than %v and at most %v", wait, lockout.Base, lockout.Max)
+	}
+}
+
+func TestLoginMFA(t *testing.T) {
+	f := newFixture(t)
+	h, auth := newAuthHandler(f)
+	secret := enableMFA(t, f, landlordID)
+
+	var challenge models.MFAChallengeResponse
+	call(t, h.Login, caller{}, "POST", "/api/v1/auth/login", map[string]string{"email": "ada@example.com", "password": landlordPassword}).expect(t, http.StatusOK).decode(t, &challenge)
+	if !challenge.MFARequired || challenge.MFAToken == "" {
+		t.Fatalf("login with 2FA = %+v, want a challenge", challenge)
+	}
+	if auth.loggedOut != 1 {
+		t.Errorf("password session logged out %d times, want 1", auth.loggedOut)
+	}
+
+	// A mistyped code doesn't burn the challenge
+	code := totpCode(t, secret)
+	call(t, h.LoginMFA, caller{}, "POST", "/api/v1/auth/login/2fa", models.LoginMFARequest{MFAToken: challenge.MFAToken, Code: wrongCode(code)}).expect(t, http.StatusUnauthorized)
+
+	var session models.AuthResponse
+	call(t, h.LoginMFA, caller{}, "POST", "/api/v1/auth/login/2fa", models.LoginMFARequest{MFAToken: challenge.MFAToken, Code: code}).expect(t, http.StatusOK).decode(t, &session)
+	if session.AccessToken != "access-"+landlordID || session.User.ID != landlordID {
+		t.Errorf("2FA login = %+v, want the landlord's session", session)
+	}
+
+	// The challenge is single-use
+	call(t, h.LoginMFA, caller{}, "POST", "/api/v1/auth/login/2fa", models.LoginMFARequest{MFAToken: challenge.MFAToken, Code: totpCode(t, secret)}).expect(t, http.StatusUnauthorized)
+}
+
+func TestLoginMFAAttempts(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+	secret := enableMFA(t, f, landlordID)
+
+	var challenge models.MFAChallengeResponse
+	call(t, h.Login, caller{}, "POST", "/api/v1/auth/login", map[string]string{"email": "ada@example.com", "password": landlordPassword}).expect(t, http.StatusOK).decode(t, &challenge)
+
+	// Wrong codes also count towards the user's two-factor lockout, which
+	// the last of them sets off
+	code := totpCode(t, secret)
+	for i := 1; i < mfaLoginAttempts; i++ {
+		call(t, h.LoginMFA, caller{}, "POST", "/api/v1/auth/login/2fa", models.LoginMFARequest{MFAToken: challenge.MFAToken, Code: wrongCode(code)}).expect(t, http.StatusUnauthorized)
+	}
+	call(t, h.LoginMFA, caller{}, "POST", "/api/v1/auth/login/2fa", models.LoginMFARequest{MFAToken: challenge.MFAToken, Code: wrongCode(code)}).expect(t, http.StatusTooManyRequests)
+
+	// Out of attempts, even the right code needs a fresh login
+	res := call(t, h.LoginMFA, caller{}, "POST", "/api/v1/auth/login/2fa", models.LoginMFARequest{MFAToken: challenge.MFAToken, Code: code}).expect(t, http.StatusUnauthorized)
+	if res.Error != "Too many incorrect codes, please log in again" {
+		t.Errorf("error = %q, want the attempts message", res.Error)
+	}
+}
+
+func TestRequireMFALockout(t *testing.T) {
+	f := newFixture(t)
+	svc := mfa.NewService(f.st.MFA, "Aletheia")
+	secret := enableMFA(t, f, landlordID)
+	if err := f.st.MFA.UpdateMFA(landlordID, map[string]interface{}{"require_for_sensitive": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	guarded := middleware.RequireMFA(svc, f.st.Lockouts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, models.APIResponse{Success: true})
+	}))
+	withCode := func(code string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set(middleware.MFACodeHeader, code)
+			guarded.ServeHTTP(w, r)
+		}
+	}
+
+	code := totpCode(t, secret)
+	for i := 1; i < lockout.Threshold; i++ {
+		call(t, withCode(wrongCode(code)), asLandlord, "DELETE", "/api/v1/me", nil).expect(t, http.StatusForbidden)
+	}
+
+	// A right code clears the count
+	call(t, withCode(code), asLandlord, "DELETE", "/api/v1/me", nil).expect(t, http.StatusOK)
+
+	for i := 1; i < lockout.Threshold; i++ {
+		call(t, withCode(wrongCode(code)), asLandlord, "DELETE", "/api/v1/me", nil).expect(t, http.StatusForbidden)
+	}
+	call(t, withCode(wrongCode(code)), asLandlord, "DELETE", "/api/v1/me", nil).expect(t, http.StatusTooManyRequests)
+
+	// Locked: no code is checked until the lockout passes
+	call(t, withCode(code), asLandlord, "DELETE", "/api/v1/me", nil).expect(t, http.StatusTooManyRequests)
+
+	// A login email can't reach the two-factor lockout
+	if wait, _ := lockedFor(f.st.Lockouts, lockoutKey([]byte("otp-secret"), "MFA:"+landlordID)); wait != 0 {
+		t.Error("two-factor lockout reachable through a login email")
+	}
+}
+
+func TestMFASettingsLockout(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+	svc := mfa.NewService(f.st.MFA, "Aletheia")
+	secret := enableMFA(t, f, landlordID)
+	code := totpCode(t, secret)
+
+	// Turning 2FA off or changing its settings takes codes from the same
+	// per-user count as sensitive actions
+	for i := 1; i < lockout.Threshold; i++ {
+		call(t, h.DisableMFA, asLandlord, "POST", "/api/v1/me/2fa/disable", models.MFACodeRequest{Code: wrongCode(code)}).expect(t, http.StatusForbidden)
+	}
+	call(t, h.UpdateMFASettings, asLandlord, "PUT", "/api/v1/me/2fa/settings", models.MFASettingsRequest{Code: wrongCode(code), RequireForSensitive: true}).expect(t, http.StatusTooManyRequests)
+
+	// Locked: the right code is refused everywhere
+	call(t, h.DisableMFA, asLandlord, "POST", "/api/v1/me/2fa/disable", models.MFACodeRequest{Code: code}).expect(t, http.StatusTooManyRequests)
+	call(t, h.RegenerateRecoveryCodes, asLandlord, "POST", "/api/v1/me/2fa/recovery-codes", models.MFACodeRequest{Code: code}).expect(t, http.StatusTooManyRequests)
+	if wait, err := middleware.VerifyMFACode(svc, f.st.Lockouts, landlordID, code); wait == 0 || err != nil {
+		t.Errorf("VerifyMFACode while locked = %v, %v; want a wait", wait, err)
+	}
+	if status, _ := svc.Status(landlordID); !status.Enabled {
+		t.Error("2FA disabled while locked out")
+	}
+
+	// Once the lock passes a right code works and clears the count
+	if err := f.st.Lockouts.ClearLoginLockout("MFA:" + landlordID); err != nil {
+		t.Fatal(err)
+	}
+	call(t, h.DisableMFA, asLandlord, "POST", "/api/v1/me/2fa/disable", models.MFACodeRequest{Code: wrongCode(code)}).expect(t, http.StatusForbidden)
+	call(t, h.DisableMFA, asLandlord, "POST", "/api/v1/me/2fa/disable", models.MFACodeRequest{Code: code}).expect(t, http.StatusOK)
+	if _, err := f.st.Lockouts.GetLoginLockout("MFA:" + landlordID); err != store.ErrNotFound {
+		t.Errorf("lockout after a right code: %v, want none", err)
+	}
+}
+
+// wrongCode is a six-digit code that isn't code
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+func TestPhoneOTPLogin(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+
+	call(t, h.RequestOTP, caller{}, "POST", "/api/v1/auth/otp/request", map[string]string{"phone": "0803 000 0003"}).expect(t, http.StatusOK)
+	code := f.outbox.smsCode(t, tenantPhone)
+	call(t, h.RequestOTP, caller{}, "POST", "/api/v1/auth/otp/request", map[string]string{"phone": tenantPhone}).expect(t, http.StatusTooManyRequests)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: tenantPhone, Code: wrong}).expect(t, http.StatusUnauthorized)
+
+	var session models.AuthResponse
+	call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: tenantPhone, Code: code}).expect(t, http.StatusOK).decode(t, &session)
+	if session.AccessToken != "access-"+tenantID {
+		t.Errorf("OTP login = %+v, want the tenant's session", session)
+	}
+
+	// Codes are single-use
+	call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: tenantPhone, Code: code}).expect(t, http.StatusUnauthorized)
+}
+
+func TestPhoneOTPLoginUnverifiedPhone(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+
+	// The landlord typed someone else's number into their profile
+	const typedPhone = "+2348039999999"
+	if _, err := f.st.Profiles.UpdateProfile(landlordID, map[string]interface{}{"phone": typedPhone}); err != nil {
+		t.Fatal(err)
+	}
+
+	call(t, h.RequestOTP, caller{}, "POST", "/api/v1/auth/otp/request", map[string]string{"phone": typedPhone}).expect(t, http.StatusOK)
+	code := f.outbox.smsCode(t, typedPhone)
+	call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: typedPhone, Code: code}).expect(t, http.StatusForbidden)
+	if profile, _ := f.st.Profiles.GetProfile(landlordID); profile.PhoneVerifiedAt != nil {
+		t.Error("unverified phone marked verified by an SMS login")
+	}
+}
+
+func TestPhoneOTPLoginMFA(t *testing.T) {
+	f := newFixture(t)
+	h, auth := newAuthHandler(f)
+	secret := enableMFA(t, f, tenantID)
+
+	call(t, h.RequestOTP, caller{}, "POST", "/api/v1/auth/otp/request", map[string]string{"phone": tenantPhone}).expect(t, http.StatusOK)
+	code := f.outbox.smsCode(t, tenantPhone)
+
+	// The SMS code alone gets a challenge, not tokens
+	res := call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: tenantPhone, Code: code}).expect(t, http.StatusOK)
+	var challenge struct {
+		models.MFAChallengeResponse
+		AccessToken string `json:"access_token"`
+	}
+	res.decode(t, &challenge)
+	if !challenge.MFARequired || challenge.MFAToken == "" || challenge.AccessToken != "" {
+		t.Fatalf("OTP login with 2FA = %s, want a challenge and no session", res.Data)
+	}
+	if auth.loggedOut != 0 {
+		t.Errorf("%d sessions revoked, want none minted", auth.loggedOut)
+	}
+
+	var session models.AuthResponse
+	call(t, h.LoginMFA, caller{}, "POST", "/api/v1/auth/login/2fa", models.LoginMFARequest{MFAToken: challenge.MFAToken, Code: totpCode(t, secret)}).expect(t, http.StatusOK).decode(t, &session)
+	if session.AccessToken != "access-"+tenantID {
+		t.Errorf("2FA login = %+v, want the tenant's session", session)
+	}
+}
+
+func TestPhoneOTPAttempts(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+
+	call(t, h.RequestOTP, caller{}, "POST", "/api/v1/auth/otp/request", map[string]string{"phone": tenantPhone}).expect(t, http.StatusOK)
+	code := f.outbox.smsCode(t, tenantPhone)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < otpMaxAttempts; i++ {
+		call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: tenantPhone, Code: wrong}).expect(t, http.StatusUnauthorized)
+	}
+	// The code is burned after too many wrong guesses
+	res := call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: tenantPhone, Code: code}).expect(t, http.StatusUnauthorized)
+	if res.Error != errOTPAttempts.Error() {
+		t.Errorf("error = %q, want %q", res.Error, errOTPAttempts)
+	}
+}
+
+func TestPhoneOTPConcurrentGuesses(t *testing.T) {
+	f := newFixture(t)
+	secret := []byte("otp-secret")
+	if _, err := issueOTP(f.st.OTPs, secret, tenantPhone); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every guess is counted, however many arrive at once
+	results := make(chan error, 4*otpMaxAttempts)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- verifyOTP(f.st.OTPs, secret, tenantPhone, "not-a-code")
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	compared := 0
+	for err := range results {
+		switch err {
+		case errOTPInvalid:
+			compared++
+		case errOTPAttempts:
+		default:
+			t.Errorf("guess: %v", err)
+		}
+	}
+	if compared != otpMaxAttempts {
+		t.Errorf("%d guesses compared, want %d", compared, otpMaxAttempts)
+	}
+	if otp, err := f.st.OTPs.LatestOTP(tenantPhone); err != nil || otp.Attempts != otpMaxAttempts {
+		t.Errorf("attempts = %d (%v), want %d", otp.Attempts, err, otpMaxAttempts)
+	}
+}
+
+func TestPhoneOTPSignup(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+	const newPhone = "+2348031234567"
+
+	call(t, h.RequestOTP, caller{}, "POST", "/api/v1/auth/otp/request", map[string]string{"phone": newPhone}).expect(t, http.StatusOK)
+	code := f.outbox.smsCode(t, newPhone)
+
+	// Without signup details the code is not spent
+	call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: newPhone, Code: code}).expect(t, http.StatusNotFound)
+
+	var created models.AuthResponse
+	call(t, h.VerifyOTP, caller{}, "POST", "/api/v1/auth/otp/verify", models.VerifyOTPRequest{Phone: newPhone, Code: code, FullName: "Femi Phone", Role: "tenant"}).expect(t, http.StatusCreated).decode(t, &created)
+	if created.AccessToken == "" || created.User.Phone == nil || *created.User.Phone != newPhone {
+		t.Fatalf("phone signup = %+v, want a session for the new number", created)
+	}
+	profile, err := f.st.Profiles.FindProfileByPhone(newPhone)
+	if err != nil || profile.ID != created.User.ID || profile.PhoneVerifiedAt == nil {
+		t.Errorf("profile = %+v (%v), want the new verified account", profile, err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	f := newFixture(t)
+	h, _ := newAuthHandler(f)
+
+	// Unknown addresses get the same answer and no email
+	call(t, h.ForgotPassword, caller{}, "POST", "/api/v1/auth/forgot-password", map[string]string{"email": "nobody@example.com"}).expect(t, http.StatusOK)
+	if sent := f.outbox.sentTo("nobody@example.com"); len(sent) != 0 {
+		t.Errorf("reset email sent to an unknown address: %v", sent)
+	}
+
+	call(t, h.ForgotPassword, caller{}, "POST", "/api/v1/auth/forgot-password", map[string]string{"email": "ada@example.com"}).expect(t, http.StatusOK)
+	token := f.outbox.linkToken(t, "ada@example.com")
+
+	call(t, h.ResetPassword, caller{}, "POST", "/api/v1/auth/reset-password", map[string]string{"token": token, "password": "short"}).expect(t, http.StatusBadRequest)
+	call(t, h.ResetPassword, caller{}, "POST", "/api/v1/auth/reset-password", map[string]string{"token": token, "password": "a-new-password"}).expect(t, http.StatusOK)
+	call(t, h.ResetPassword, caller{}, "POST", "/api/v1/auth/reset-password", map[string]string{"token": token, "password": "another-password"}).expect(t, http.StatusBadRequest)
+
+	call(t, h.Login, caller{}, "POST", "/api/v1/auth/login", map[string]string{"email": "ada@example.com", "password": "a-new-password"}).expect(t, http.StatusOK)
+	if profile, _ := f.st.Profiles.GetProfile(landlordID); profile.EmailVerifiedAt == nil {
+		t.Error("following the reset link did not verify the email")
+	}
+}
@@ -2,67 +2,78 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
 	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
-	postgrest "github.com/supabase-community/postgrest-go"
-	supabase "github.com/supabase-community/supabase-go"
+	"github.com/aletheia/backend/internal/store"
 )
 
 type BuildingsHandler struct {
-	client *supabase.Client
+	store *store.Store
+	audit *audit.Logger
 }
 
-func NewBuildingsHandler(client *supabase.Client) *BuildingsHandler {
-	return &BuildingsHandler{client: client}
+func NewBuildingsHandler(st *store.Store, auditLog *audit.Logger) *BuildingsHandler {
+	return &BuildingsHandler{store: st, audit: auditLog}
 }
 
-// ListBuildings returns all buildings for the authenticated landlord
+var buildingList = listSpec{
+	sorts:   []string{"-created_at", "name"},
+	filters: []listFilter{idFilter("organisation_id"), archivedFilter()},
+}
+
+// ListBuildings returns a page of the buildings the user owns or is staff on
 func (h *BuildingsHandler) ListBuildings(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
+	lq, err := parseList(r, buildingList)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	data, _, err := h.client.From("buildings").Select("*", "exact", false).Eq("landlord_id", userID).Order("created_at", &postgrest.OrderOpts{Ascending: false}).Execute()
+	ids := middleware.BuildingsWith(r, access.ViewBuilding)
+	buildings, err := h.store.Buildings.ListBuildings(ids, lq.opts)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch buildings")
 		return
 	}
 
-	var buildings []models.Building
-	json.Unmarshal(data, &buildings)
-
-	respondJSON(w, http.StatusOK, models.APIResponse{
-		Success: true,
-		Data:    buildings,
-	})
+	respondPage(w, lq, buildings)
 }
 
 // GetBuilding returns a single building with unit stats
 func (h *BuildingsHandler) GetBuilding(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
 	buildingID := getPathParam(r, "id")
 
-	// Get building
-	data, _, err := h.client.From("buildings").Select("*", "exact", false).Eq("id", buildingID).Eq("landlord_id", userID).Execute()
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+	if !requireBuilding(w, r, buildingID, access.ViewBuilding) {
 		return
 	}
 
-	var buildings []models.Building
-	json.Unmarshal(data, &buildings)
-	if len(buildings) == 0 {
+	building, err := h.store.Buildings.GetBuilding(buildingID)
+	if err == store.ErrNotFound {
 		respondError(w, http.StatusNotFound, "Building not found")
 		return
 	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
 
 	respondJSON(w, http.StatusOK, models.APIResponse{
 		Success: true,
-		Data:    buildings[0],
+		Data:    building,
 	})
 }
 
-// CreateBuilding creates a new building
+// CreateBuilding creates a new building, with the units of generate_units
+// when given; the building and its units are created together or not at
+// all. Organisation admins may create it under their organisation on
+// behalf of one of its owner members.
 func (h *BuildingsHandler) CreateBuilding(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 
@@ -77,35 +88,100 @@ func (h *BuildingsHandler) CreateBuilding(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	building := map[string]interface{}{
-		"landlord_id": userID,
-		"name":        req.Name,
-		"address":     req.Address,
-		"total_units": req.TotalUnits,
-		"photo_url":   req.PhotoURL,
+	var numbers []string
+	if req.GenerateUnits != "" {
+		var err error
+		if numbers, err = unitNumbers(req.GenerateUnits); err != nil {
+			respondError(w, http.StatusBadRequest, "generate_units: "+err.Error())
+			return
+		}
+	}
+	if req.UnitRent < 0 {
+		respondError(w, http.StatusBadRequest, "unit_rent cannot be negative")
+		return
+	}
+
+	ownerID := userID
+	if req.OrganisationID != "" {
+		role, err := h.store.Organisations.OrganisationRole(req.OrganisationID, userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check membership")
+			return
+		}
+		if role != string(access.RoleAdmin) {
+			respondError(w, http.StatusForbidden, "Only organisation admins can add buildings to it")
+			return
+		}
+		if req.OwnerID != "" && req.OwnerID != userID {
+			ownerRole, err := h.store.Organisations.OrganisationRole(req.OrganisationID, req.OwnerID)
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "Failed to check owner")
+				return
+			}
+			if ownerRole != string(access.RoleOwner) {
+				respondError(w, http.StatusBadRequest, "owner_id must be an owner member of the organisation")
+				return
+			}
+			ownerID = req.OwnerID
+		}
+	} else if req.OwnerID != "" {
+		respondError(w, http.StatusBadRequest, "owner_id can only be set together with organisation_id")
+		return
+	}
+
+	building := models.Building{
+		LandlordID: ownerID,
+		Name:       req.Name,
+		Address:    req.Address,
+		PhotoURL:   &req.PhotoURL,
+	}
+	if req.OrganisationID != "" {
+		building.OrganisationID = &req.OrganisationID
 	}
 
-	data, _, err := h.client.From("buildings").Insert(building, false, "", "", "").Execute()
+	var created models.Building
+	var units []models.Unit
+	var err error
+	if numbers != nil {
+		created, units, err = h.store.Buildings.CreateBuildingWithUnits(building, newUnits("", numbers, req.UnitRent))
+	} else {
+		created, err = h.store.Buildings.CreateBuilding(building)
+	}
+	if err == store.ErrUnitNumberTaken {
+		respondError(w, http.StatusConflict, "Unit numbers must be unique within the building")
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to create building: "+err.Error())
+		respondError(w, http.StatusInternalServerError, "Failed to create building")
 		return
 	}
 
-	var created []models.Building
-	json.Unmarshal(data, &created)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "building.create",
+		ResourceType: "building",
+		ResourceID:   created.ID,
+		BuildingID:   created.ID,
+		After:        created,
+	})
+	if units != nil {
+		recordUnitsGenerated(h.audit, r, created.ID, units)
+	}
 
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
-		Data:    created[0],
+		Data:    created,
 		Message: "Building created successfully",
 	})
 }
 
 // UpdateBuilding updates a building's details
 func (h *BuildingsHandler) UpdateBuilding(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
 	buildingID := getPathParam(r, "id")
 
+	if !requireBuilding(w, r, buildingID, access.ManageBuilding) {
+		return
+	}
+
 	var req models.UpdateBuildingRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
@@ -119,9 +195,6 @@ func (h *BuildingsHandler) UpdateBuilding(w http.ResponseWriter, r *http.Request
 	if req.Address != nil {
 		update["address"] = *req.Address
 	}
-	if req.TotalUnits != nil {
-		update["total_units"] = *req.TotalUnits
-	}
 	if req.PhotoURL != nil {
 		update["photo_url"] = *req.PhotoURL
 	}
@@ -131,67 +204,172 @@ func (h *BuildingsHandler) UpdateBuilding(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	data, _, err := h.client.From("buildings").Update(update, "", "").Eq("id", buildingID).Eq("landlord_id", userID).Execute()
+	before, err := h.store.Buildings.GetBuilding(buildingID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Building not found")
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to update building")
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
 		return
 	}
 
-	var updated []models.Building
-	json.Unmarshal(data, &updated)
-
-	if len(updated) == 0 {
+	updated, err := h.store.Buildings.UpdateBuilding(buildingID, update)
+	if err == store.ErrNotFound {
 		respondError(w, http.StatusNotFound, "Building not found")
 		return
 	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update building")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "building.update",
+		ResourceType: "building",
+		ResourceID:   buildingID,
+		BuildingID:   buildingID,
+		Before:       before,
+		After:        updated,
+	})
 
 	respondJSON(w, http.StatusOK, models.APIResponse{
 		Success: true,
-		Data:    updated[0],
+		Data:    updated,
 		Message: "Building updated successfully",
 	})
 }
 
-// ListUnits returns all units for a building
-func (h *BuildingsHandler) ListUnits(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
+// ArchiveBuilding archives a building and its units. Their payments,
+// documents and maintenance history are kept; the building drops out of
+// lists and dashboards until restored. Refused while any unit has a tenant.
+func (h *BuildingsHandler) ArchiveBuilding(w http.ResponseWriter, r *http.Request) {
 	buildingID := getPathParam(r, "id")
 
-	// Verify building belongs to this landlord
-	bData, _, err := h.client.From("buildings").Select("id", "exact", false).Eq("id", buildingID).Eq("landlord_id", userID).Execute()
+	if !requireBuilding(w, r, buildingID, access.ManageOwnership) {
+		return
+	}
+
+	before, err := h.store.Buildings.GetBuilding(buildingID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Building not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
+	if before.ArchivedAt != nil {
+		respondError(w, http.StatusConflict, "Building is already archived")
+		return
+	}
+
+	archived, err := h.store.Buildings.ArchiveBuilding(buildingID)
+	if err == store.ErrUnitOccupied {
+		respondError(w, http.StatusConflict, "Building has units with an active tenancy")
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to verify building")
+		respondError(w, http.StatusInternalServerError, "Failed to archive building")
 		return
 	}
-	var bCheck []struct {
-		ID string `json:"id"`
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "building.archive",
+		ResourceType: "building",
+		ResourceID:   buildingID,
+		BuildingID:   buildingID,
+		Before:       before,
+		After:        archived,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    archived,
+		Message: "Building archived",
+	})
+}
+
+// RestoreBuilding brings back an archived building with the units that
+// were archived along with it
+func (h *BuildingsHandler) RestoreBuilding(w http.ResponseWriter, r *http.Request) {
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ManageOwnership) {
+		return
 	}
-	json.Unmarshal(bData, &bCheck)
-	if len(bCheck) == 0 {
+
+	before, err := h.store.Buildings.GetBuilding(buildingID)
+	if err == store.ErrNotFound {
 		respondError(w, http.StatusNotFound, "Building not found")
 		return
 	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
+	if before.ArchivedAt == nil {
+		respondError(w, http.StatusConflict, "Building is not archived")
+		return
+	}
 
-	// Get units with tenant profiles
-	data, _, err := h.client.From("units").Select("*, profiles!units_tenant_id_fkey(full_name, email, phone)", "exact", false).Eq("building_id", buildingID).Order("unit_number", &postgrest.OrderOpts{Ascending: true}).Execute()
+	restored, err := h.store.Buildings.RestoreBuilding(buildingID)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch units")
+		respondError(w, http.StatusInternalServerError, "Failed to restore building")
 		return
 	}
 
-	var units []json.RawMessage
-	json.Unmarshal(data, &units)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "building.restore",
+		ResourceType: "building",
+		ResourceID:   buildingID,
+		BuildingID:   buildingID,
+		Before:       before,
+		After:        restored,
+	})
 
 	respondJSON(w, http.StatusOK, models.APIResponse{
 		Success: true,
-		Data:    units,
+		Data:    restored,
+		Message: "Building restored",
 	})
 }
 
+var unitList = listSpec{
+	sorts: []string{"unit_number", "rent_amount", "created_at"},
+	filters: []listFilter{
+		setFilter("status", "vacant", "occupied"),
+		amountRange("rent_amount", "min_rent", "max_rent"),
+		archivedFilter(),
+	},
+}
+
+// ListUnits returns a page of a building's units
+func (h *BuildingsHandler) ListUnits(w http.ResponseWriter, r *http.Request) {
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ViewBuilding) {
+		return
+	}
+
+	lq, err := parseList(r, unitList)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Units with tenant profiles
+	units, err := h.store.Units.ListUnits(buildingID, lq.opts)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch units")
+		return
+	}
+
+	respondPage(w, lq, units)
+}
+
 // CreateUnit creates a new unit in a building
 func (h *BuildingsHandler) CreateUnit(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
-
 	var req models.CreateUnitRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Invalid request body")
@@ -203,37 +381,359 @@ func (h *BuildingsHandler) CreateUnit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify building belongs to this landlord
-	bData, _, _ := h.client.From("buildings").Select("id", "exact", false).Eq("id", req.BuildingID).Eq("landlord_id", userID).Execute()
-	var bCheck []struct {
-		ID string `json:"id"`
+	if !requireBuilding(w, r, req.BuildingID, access.ManageBuilding) {
+		return
+	}
+
+	building, err := h.store.Buildings.GetBuilding(req.BuildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
 	}
-	json.Unmarshal(bData, &bCheck)
-	if len(bCheck) == 0 {
-		respondError(w, http.StatusForbidden, "Building not found or not yours")
+	if building.ArchivedAt != nil {
+		respondError(w, http.StatusConflict, "Building is archived")
 		return
 	}
 
-	unit := map[string]interface{}{
-		"building_id": req.BuildingID,
-		"unit_number": req.UnitNumber,
-		"rent_amount": req.RentAmount,
-		"lease_start": req.LeaseStart,
-		"lease_end":   req.LeaseEnd,
+	unit := models.Unit{
+		BuildingID: req.BuildingID,
+		UnitNumber: req.UnitNumber,
+		RentAmount: req.RentAmount,
+		LeaseStart: &req.LeaseStart,
+		LeaseEnd:   &req.LeaseEnd,
 	}
 
-	data, _, err := h.client.From("units").Insert(unit, false, "", "", "").Execute()
+	created, err := h.store.Units.CreateUnit(unit)
+	if err == store.ErrUnitNumberTaken {
+		respondError(w, http.StatusConflict, "This unit number is already used in the building")
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to create unit: "+err.Error())
+		respondError(w, http.StatusInternalServerError, "Failed to create unit")
 		return
 	}
 
-	var created []models.Unit
-	json.Unmarshal(data, &created)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "unit.create",
+		ResourceType: "unit",
+		ResourceID:   created.ID,
+		BuildingID:   req.BuildingID,
+		After:        created,
+	})
 
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
-		Data:    created[0],
+		Data:    created,
 		Message: "Unit created successfully",
 	})
 }
+
+// GenerateUnits adds the units of a pattern such as "A1..A12" to a
+// building, all or nothing
+func (h *BuildingsHandler) GenerateUnits(w http.ResponseWriter, r *http.Request) {
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ManageBuilding) {
+		return
+	}
+
+	var req models.GenerateUnitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	numbers, err := unitNumbers(req.Pattern)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "pattern: "+err.Error())
+		return
+	}
+	if req.RentAmount < 0 {
+		respondError(w, http.StatusBadRequest, "rent_amount cannot be negative")
+		return
+	}
+
+	building, err := h.store.Buildings.GetBuilding(buildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
+	if building.ArchivedAt != nil {
+		respondError(w, http.StatusConflict, "Building is archived")
+		return
+	}
+
+	created, err := h.generateUnits(r, buildingID, numbers, req.RentAmount)
+	if err == store.ErrUnitNumberTaken {
+		respondError(w, http.StatusConflict, "Some of these unit numbers are already used in the building")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create units")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    created,
+		Message: fmt.Sprintf("%d units created", len(created)),
+	})
+}
+
+func (h *BuildingsHandler) generateUnits(r *http.Request, buildingID string, numbers []string, rent int64) ([]models.Unit, error) {
+	created, err := h.store.Units.CreateUnits(newUnits(buildingID, numbers, rent))
+	if err != nil {
+		return nil, err
+	}
+	recordUnitsGenerated(h.audit, r, buildingID, created)
+	return created, nil
+}
+
+// newUnits returns vacant units with the given numbers and rent
+func newUnits(buildingID string, numbers []string, rent int64) []models.Unit {
+	units := make([]models.Unit, len(numbers))
+	for i, n := range numbers {
+		units[i] = models.Unit{BuildingID: buildingID, UnitNumber: n, RentAmount: rent}
+	}
+	return units
+}
+
+func recordUnitsGenerated(logger *audit.Logger, r *http.Request, buildingID string, units []models.Unit) {
+	recordAudit(logger, r, audit.Entry{
+		Action:       "unit.generate",
+		ResourceType: "building",
+		ResourceID:   buildingID,
+		BuildingID:   buildingID,
+		After:        units,
+	})
+}
+
+// requireUnit loads the unit in the {id} path value and checks perm on its
+// building, responding 404 when the caller cannot see the building at all
+func (h *BuildingsHandler) requireUnit(w http.ResponseWriter, r *http.Request, perm access.Permission) (models.Unit, bool) {
+	unit, err := h.store.Units.GetUnit(getPathParam(r, "id"))
+	if err == store.ErrNotFound || err == nil && !middleware.Can(r, unit.BuildingID, access.ViewBuilding) {
+		respondError(w, http.StatusNotFound, "Unit not found")
+		return unit, false
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch unit")
+		return unit, false
+	}
+	if !middleware.Can(r, unit.BuildingID, perm) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions for this building")
+		return unit, false
+	}
+	return unit, true
+}
+
+// GetUnit returns a single unit with its current tenancy and every
+// tenancy it has had, newest first
+func (h *BuildingsHandler) GetUnit(w http.ResponseWriter, r *http.Request) {
+	unit, ok := h.requireUnit(w, r, access.ViewBuilding)
+	if !ok {
+		return
+	}
+
+	tenancies, err := h.store.Tenancies.ListTenancies(unit.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch tenancies")
+		return
+	}
+	detail := struct {
+		models.Unit
+		Tenancy   *store.TenancyListing  `json:"tenancy"`
+		Tenancies []store.TenancyListing `json:"tenancies"`
+	}{Unit: unit, Tenancies: tenancies}
+	for i := range tenancies {
+		if tenancies[i].Status == "active" {
+			detail.Tenancy = &tenancies[i]
+		}
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    detail,
+	})
+}
+
+// UpdateUnit changes a unit's number, rent or lease dates. An empty
+// lease_start or lease_end clears it.
+func (h *BuildingsHandler) UpdateUnit(w http.ResponseWriter, r *http.Request) {
+	unit, ok := h.requireUnit(w, r, access.ManageBuilding)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateUnitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	update := map[string]interface{}{}
+	if req.UnitNumber != nil {
+		number := strings.TrimSpace(*req.UnitNumber)
+		if number == "" {
+			respondError(w, http.StatusBadRequest, "Unit number cannot be empty")
+			return
+		}
+		update["unit_number"] = number
+	}
+	if req.RentAmount != nil {
+		if *req.RentAmount < 0 {
+			respondError(w, http.StatusBadRequest, "Rent amount cannot be negative")
+			return
+		}
+		update["rent_amount"] = *req.RentAmount
+	}
+	leaseStart, leaseEnd := unit.LeaseStart, unit.LeaseEnd
+	for _, d := range []struct {
+		column string
+		value  *string
+		keep   **string
+	}{{"lease_start", req.LeaseStart, &leaseStart}, {"lease_end", req.LeaseEnd, &leaseEnd}} {
+		switch {
+		case d.value == nil:
+			continue
+		case *d.value == "":
+			update[d.column], *d.keep = nil, nil
+		default:
+			if _, err := time.Parse("2006-01-02", *d.value); err != nil {
+				respondError(w, http.StatusBadRequest, d.column+" must be a date (YYYY-MM-DD)")
+				return
+			}
+			update[d.column], *d.keep = *d.value, d.value
+		}
+	}
+	if leaseStart != nil && leaseEnd != nil && *leaseEnd < *leaseStart {
+		respondError(w, http.StatusBadRequest, "Lease end must be after lease start")
+		return
+	}
+
+	if len(update) == 0 {
+		respondError(w, http.StatusBadRequest, "No fields to update")
+		return
+	}
+
+	if number, ok := update["unit_number"].(string); ok && number != unit.UnitNumber {
+		taken, err := h.store.Units.ListUnits(unit.BuildingID, store.ListOptions{
+			PerPage: 1,
+			Filters: []store.Filter{{Column: "unit_number", Op: store.OpEq, Value: number}},
+		})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check unit number")
+			return
+		}
+		if taken.Total > 0 {
+			respondError(w, http.StatusConflict, "Another unit in this building already has that number")
+			return
+		}
+	}
+
+	updated, err := h.store.Units.UpdateUnit(unit.ID, update)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Unit not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update unit")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "unit.update",
+		ResourceType: "unit",
+		ResourceID:   unit.ID,
+		BuildingID:   unit.BuildingID,
+		Before:       unit,
+		After:        updated,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    updated,
+		Message: "Unit updated successfully",
+	})
+}
+
+// ArchiveUnit archives a vacant unit, keeping its payment history. Its
+// pending invitations expire.
+func (h *BuildingsHandler) ArchiveUnit(w http.ResponseWriter, r *http.Request) {
+	unit, ok := h.requireUnit(w, r, access.ManageBuilding)
+	if !ok {
+		return
+	}
+	if unit.ArchivedAt != nil {
+		respondError(w, http.StatusConflict, "Unit is already archived")
+		return
+	}
+
+	archived, err := h.store.Units.ArchiveUnit(unit.ID)
+	if err == store.ErrUnitOccupied {
+		respondError(w, http.StatusConflict, "Unit has an active tenancy")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to archive unit")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "unit.archive",
+		ResourceType: "unit",
+		ResourceID:   unit.ID,
+		BuildingID:   unit.BuildingID,
+		Before:       unit,
+		After:        archived,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    archived,
+		Message: "Unit archived",
+	})
+}
+
+// RestoreUnit brings back an archived unit. Units of an archived building
+// come back with the building.
+func (h *BuildingsHandler) RestoreUnit(w http.ResponseWriter, r *http.Request) {
+	unit, ok := h.requireUnit(w, r, access.ManageBuilding)
+	if !ok {
+		return
+	}
+	if unit.ArchivedAt == nil {
+		respondError(w, http.StatusConflict, "Unit is not archived")
+		return
+	}
+
+	building, err := h.store.Buildings.GetBuilding(unit.BuildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
+	if building.ArchivedAt != nil {
+		respondError(w, http.StatusConflict, "Building is archived; restore the building first")
+		return
+	}
+
+	restored, err := h.store.Units.UpdateUnit(unit.ID, map[string]interface{}{"archived_at": nil})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to restore unit")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "unit.restore",
+		ResourceType: "unit",
+		ResourceID:   unit.ID,
+		BuildingID:   unit.BuildingID,
+		Before:       unit,
+		After:        restored,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    restored,
+		Message: "Unit restored",
+	})
+}
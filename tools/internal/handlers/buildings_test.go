@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestListBuildings(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+
+	var buildings []models.Building
+	call(t, h.ListBuildings, asLandlord, "GET", "/api/v1/buildings", nil).expect(t, http.StatusOK).decode(t, &buildings)
+	if len(buildings) != 1 || buildings[0].ID != buildingA {
+		t.Fatalf("landlord sees %+v, want only building A", buildings)
+	}
+
+	call(t, h.ListBuildings, asNewLandlord, "GET", "/api/v1/buildings", nil).expect(t, http.StatusOK).decode(t, &buildings)
+	if len(buildings) != 0 {
+		t.Fatalf("landlord without buildings sees %d", len(buildings))
+	}
+}
+
+func TestGetBuilding(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+
+	var b models.Building
+	call(t, h.GetBuilding, asLandlord, "GET", "/api/v1/buildings/"+buildingA, nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &b)
+	if b.Name != "Palm Court" {
+		t.Errorf("name = %q", b.Name)
+	}
+
+	// Someone else's building is indistinguishable from a missing one
+	call(t, h.GetBuilding, asLandlord, "GET", "/api/v1/buildings/"+buildingB, nil, "id", buildingB).expect(t, http.StatusNotFound)
+}
+
+func TestCreateBuilding(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "No Address"}).expect(t, http.StatusBadRequest)
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "X", Address: "Y", OwnerID: otherLandlordID}).expect(t, http.StatusBadRequest)
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", "{").expect(t, http.StatusBadRequest)
+
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "X", Address: "Y", GenerateUnits: "A12..A1"}).expect(t, http.StatusBadRequest)
+
+	var b models.Building
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "Harbour House", Address: "3 Quay St"}).expect(t, http.StatusCreated).decode(t, &b)
+	if b.ID == "" || b.LandlordID != landlordID || b.TotalUnits != 0 {
+		t.Fatalf("created %+v", b)
+	}
+	if b.PhotoURL != nil || b.OrganisationID != nil {
+		t.Errorf("empty optional fields stored: %+v", b)
+	}
+	if _, err := f.st.Buildings.GetBuilding(b.ID); err != nil {
+		t.Fatalf("building not stored: %v", err)
+	}
+
+	b = models.Building{}
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "Marina Flats", Address: "5 Quay St", GenerateUnits: "101..104, 201..204", UnitRent: 90_000_00}).expect(t, http.StatusCreated).decode(t, &b)
+	if b.TotalUnits != 8 {
+		t.Fatalf("total_units = %d, want the 8 generated", b.TotalUnits)
+	}
+	units, _ := f.st.Units.ListUnits(b.ID, store.ListOptions{PerPage: 10})
+	if units.Total != 8 || units.Items[4].UnitNumber != "201" || units.Items[4].RentAmount != 90_000_00 || units.Items[4].Status != "vacant" {
+		t.Errorf("units = %+v", units.Items)
+	}
+}
+
+// failingUnits is a building store whose units can never be created. It
+// records the buildings created without units.
+type failingUnits struct {
+	store.BuildingStore
+	created []models.Building
+}
+
+func (s *failingUnits) CreateBuilding(b models.Building) (models.Building, error) {
+	s.created = append(s.created, b)
+	return s.BuildingStore.CreateBuilding(b)
+}
+
+func (s *failingUnits) CreateBuildingWithUnits(models.Building, []models.Unit) (models.Building, []models.Unit, error) {
+	return models.Building{}, nil, errors.New("connection reset")
+}
+
+func TestCreateBuildingAtomic(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+
+	// A building whose units fail is not left behind half created
+	failing := &failingUnits{BuildingStore: f.st.Buildings}
+	f.st.Buildings = failing
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "Marina Flats", Address: "5 Quay St", GenerateUnits: "101..104"}).expect(t, http.StatusInternalServerError)
+	if len(failing.created) != 0 {
+		t.Errorf("buildings created without their units: %+v", failing.created)
+	}
+
+	// Nor is one whose units clash with each other
+	b := models.Building{ID: "b-marina", LandlordID: landlordID, Name: "Marina Flats", Address: "5 Quay St"}
+	if _, _, err := f.mem.CreateBuildingWithUnits(b, newUnits("", []string{"101", "102", "101"}, 0)); !errors.Is(err, store.ErrUnitNumberTaken) {
+		t.Fatalf("duplicate unit numbers: err = %v, want ErrUnitNumberTaken", err)
+	}
+	if _, err := f.mem.GetBuilding(b.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("building with clashing units was stored: err = %v", err)
+	}
+	if units, _ := f.mem.ListUnits(b.ID, store.ListOptions{PerPage: 10}); units.Total != 0 {
+		t.Errorf("units stored for a building that was not: %+v", units.Items)
+	}
+}
+
+func TestGenerateUnits(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+	path := "/api/v1/buildings/" + buildingA + "/units/generate"
+
+	call(t, h.GenerateUnits, asStaff(access.RoleCaretaker), "POST", path, models.GenerateUnitsRequest{Pattern: "A3..A5"}, "id", buildingA).expect(t, http.StatusForbidden)
+	call(t, h.GenerateUnits, asLandlord, "POST", path, models.GenerateUnitsRequest{Pattern: "A3..B5"}, "id", buildingA).expect(t, http.StatusBadRequest)
+	// A2 exists, so none of the range is created
+	call(t, h.GenerateUnits, asLandlord, "POST", path, models.GenerateUnitsRequest{Pattern: "A2..A5"}, "id", buildingA).expect(t, http.StatusConflict)
+
+	var units []models.Unit
+	call(t, h.GenerateUnits, asStaff(access.RoleManager), "POST", path, models.GenerateUnitsRequest{Pattern: "A3..A5", RentAmount: 50_000_00}, "id", buildingA).expect(t, http.StatusCreated).decode(t, &units)
+	if len(units) != 3 || units[0].UnitNumber != "A3" || units[2].UnitNumber != "A5" {
+		t.Fatalf("units = %+v", units)
+	}
+	if b, _ := f.st.Buildings.GetBuilding(buildingA); b.TotalUnits != 5 {
+		t.Errorf("total_units = %d, want 5", b.TotalUnits)
+	}
+
+	call(t, h.ArchiveUnit, asLandlord, "POST", "/api/v1/units/"+units[0].ID+"/archive", nil, "id", units[0].ID).expect(t, http.StatusOK)
+	if b, _ := f.st.Buildings.GetBuilding(buildingA); b.TotalUnits != 4 {
+		t.Errorf("total_units after archiving a unit = %d, want 4", b.TotalUnits)
+	}
+}
+
+func TestUnitNumbers(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    string // numbers joined by spaces, or "error"
+	}{
+		{"A1..A3", "A1 A2 A3"},
+		{"101..103, 201..202", "101 102 103 201 202"},
+		{"A08..A11", "A08 A09 A10 A11"},
+		{"Shop, Flat 1..Flat 2", "Shop Flat 1 Flat 2"},
+		{"7", "7"},
+		{"A3..A1", "error"},
+		{"A1..B3", "error"},
+		{"A..B", "error"},
+		{"1..3, 2", "error"},
+		{"1..501", "error"},
+		{" , ", "error"},
+	}
+	for _, c := range cases {
+		numbers, err := unitNumbers(c.pattern)
+		got := strings.Join(numbers, " ")
+		if err != nil {
+			got = "error"
+		}
+		if got != c.want {
+			t.Errorf("unitNumbers(%q) = %q, want %q", c.pattern, got, c.want)
+		}
+	}
+}
+
+func TestCreateBuildingForOrganisation(t *testing.T) {
+	const orgID = "40000000-0000-0000-0000-000000000001"
+	f := newFixture(t)
+	f.mem.AddOrganisationMember(orgID, staffID, string(access.RoleAdmin))
+	f.mem.AddOrganisationMember(orgID, otherLandlordID, string(access.RoleOwner))
+	f.mem.AddOrganisationMember(orgID, tenantID, string(access.RoleManager))
+	h := NewBuildingsHandler(f.st, nil)
+	admin := caller{id: staffID, role: "staff", grants: access.Grants{}}
+
+	// Only admins may add buildings, and only for owner members
+	call(t, h.CreateBuilding, asLandlord, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "X", Address: "Y", OrganisationID: orgID}).expect(t, http.StatusForbidden)
+	call(t, h.CreateBuilding, admin, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "X", Address: "Y", OrganisationID: orgID, OwnerID: tenantID}).expect(t, http.StatusBadRequest)
+
+	var b models.Building
+	call(t, h.CreateBuilding, admin, "POST", "/api/v1/buildings", models.CreateBuildingRequest{Name: "X", Address: "Y", OrganisationID: orgID, OwnerID: otherLandlordID}).expect(t, http.StatusCreated).decode(t, &b)
+	if b.LandlordID != otherLandlordID || b.OrganisationID == nil || *b.OrganisationID != orgID {
+		t.Fatalf("created %+v", b)
+	}
+}
+
+func TestUpdateBuilding(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+	name := "Palm Court II"
+	path := "/api/v1/buildings/" + buildingA
+
+	call(t, h.UpdateBuilding, asStaff(access.RoleCaretaker), "PUT", path, models.UpdateBuildingRequest{Name: &name}, "id", buildingA).expect(t, http.StatusForbidden)
+	call(t, h.UpdateBuilding, asLandlord, "PUT", path, models.UpdateBuildingRequest{}, "id", buildingA).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateBuilding, asOtherLandlord, "PUT", path, models.UpdateBuildingRequest{Name: &name}, "id", buildingA).expect(t, http.StatusNotFound)
+
+	var b models.Building
+	call(t, h.UpdateBuilding, asStaff(access.RoleManager), "PUT", path, models.UpdateBuildingRequest{Name: &name}, "id", buildingA).expect(t, http.StatusOK).decode(t, &b)
+	if b.Name != name || b.Address != "1 Palm Rd" {
+		t.Fatalf("updated %+v", b)
+	}
+}
+
+func TestListUnits(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+
+	var units []store.UnitListing
+	call(t, h.ListUnits, asStaff(access.RoleCaretaker), "GET", "/api/v1/buildings/"+buildingA+"/units", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &units)
+	if len(units) != 2 || units[0].UnitNumber != "A1" || units[1].UnitNumber != "A2" {
+		t.Fatalf("units = %+v, want A1, A2", units)
+	}
+	if units[0].Tenant == nil || units[0].Tenant.FullName != "Chidi Tenant" {
+		t.Errorf("occupied unit tenant = %+v", units[0].Tenant)
+	}
+	if units[1].Tenant != nil {
+		t.Errorf("vacant unit has tenant %+v", units[1].Tenant)
+	}
+
+	call(t, h.ListUnits, asLandlord, "GET", "/api/v1/buildings/"+buildingB+"/units", nil, "id", buildingB).expect(t, http.StatusNotFound)
+}
+
+func TestCreateUnit(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+
+	call(t, h.CreateUnit, asLandlord, "POST", "/api/v1/units", models.CreateUnitRequest{BuildingID: buildingA}).expect(t, http.StatusBadRequest)
+	call(t, h.CreateUnit, asLandlord, "POST", "/api/v1/units", models.CreateUnitRequest{BuildingID: buildingB, UnitNumber: "B2"}).expect(t, http.StatusNotFound)
+	call(t, h.CreateUnit, asStaff(access.RoleAccountant), "POST", "/api/v1/units", models.CreateUnitRequest{BuildingID: buildingA, UnitNumber: "A3"}).expect(t, http.StatusForbidden)
+
+	var u models.Unit
+	call(t, h.CreateUnit, asLandlord, "POST", "/api/v1/units", models.CreateUnitRequest{BuildingID: buildingA, UnitNumber: "A3", RentAmount: 70_000_00, LeaseStart: "2026-01-01"}).expect(t, http.StatusCreated).decode(t, &u)
+	if u.Status != "vacant" || u.TenantID != nil || u.LeaseStart == nil || *u.LeaseStart != "2026-01-01" || u.LeaseEnd != nil {
+		t.Fatalf("created %+v", u)
+	}
+
+	call(t, h.CreateUnit, asLandlord, "POST", "/api/v1/units", models.CreateUnitRequest{BuildingID: buildingA, UnitNumber: "A3"}).expect(t, http.StatusConflict)
+}
+
+func TestUpdateUnit(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+	path := "/api/v1/units/" + vacantUnit
+	str := func(s string) *string { return &s }
+	rent := int64(55_000_00)
+
+	call(t, h.UpdateUnit, asLandlord, "PUT", path, models.UpdateUnitRequest{}, "id", vacantUnit).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateUnit, asLandlord, "PUT", path, models.UpdateUnitRequest{UnitNumber: str("  ")}, "id", vacantUnit).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateUnit, asLandlord, "PUT", path, models.UpdateUnitRequest{LeaseStart: str("1/1/2026")}, "id", vacantUnit).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateUnit, asLandlord, "PUT", path, models.UpdateUnitRequest{LeaseStart: str("2026-06-01"), LeaseEnd: str("2026-01-01")}, "id", vacantUnit).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateUnit, asLandlord, "PUT", path, models.UpdateUnitRequest{UnitNumber: str("A1")}, "id", vacantUnit).expect(t, http.StatusConflict)
+	call(t, h.UpdateUnit, asStaff(access.RoleAccountant), "PUT", path, models.UpdateUnitRequest{RentAmount: &rent}, "id", vacantUnit).expect(t, http.StatusForbidden)
+	call(t, h.UpdateUnit, asOtherLandlord, "PUT", path, models.UpdateUnitRequest{RentAmount: &rent}, "id", vacantUnit).expect(t, http.StatusNotFound)
+
+	var u models.Unit
+	call(t, h.UpdateUnit, asLandlord, "PUT", path, models.UpdateUnitRequest{UnitNumber: str("A2b"), RentAmount: &rent, LeaseStart: str("2026-01-01")}, "id", vacantUnit).expect(t, http.StatusOK).decode(t, &u)
+	if u.UnitNumber != "A2b" || u.RentAmount != rent || u.LeaseStart == nil || *u.LeaseStart != "2026-01-01" {
+		t.Fatalf("updated %+v", u)
+	}
+
+	// An empty date clears it
+	u = models.Unit{}
+	call(t, h.UpdateUnit, asLandlord, "PUT", path, models.UpdateUnitRequest{LeaseStart: str("")}, "id", vacantUnit).expect(t, http.StatusOK).decode(t, &u)
+	if u.LeaseStart != nil || u.UnitNumber != "A2b" {
+		t.Fatalf("after clearing lease_start %+v", u)
+	}
+
+	call(t, h.GetUnit, asStaff(access.RoleCaretaker), "GET", path, nil, "id", vacantUnit).expect(t, http.StatusOK).decode(t, &u)
+	if u.RentAmount != rent {
+		t.Errorf("fetched %+v", u)
+	}
+}
+
+func TestArchiveUnit(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+	unitsPath := "/api/v1/buildings/" + buildingA + "/units"
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: vacantUnit, BuildingID: buildingA, Amount: 100, Status: "successful", Period: "Jan 2026"})
+
+	call(t, h.ArchiveUnit, asLandlord, "POST", "/api/v1/units/"+occupiedUnit+"/archive", nil, "id", occupiedUnit).expect(t, http.StatusConflict)
+	call(t, h.ArchiveUnit, asStaff(access.RoleCaretaker), "POST", "/api/v1/units/"+vacantUnit+"/archive", nil, "id", vacantUnit).expect(t, http.StatusForbidden)
+	call(t, h.RestoreUnit, asLandlord, "POST", "/api/v1/units/"+vacantUnit+"/restore", nil, "id", vacantUnit).expect(t, http.StatusConflict)
+
+	var u models.Unit
+	call(t, h.ArchiveUnit, asLandlord, "POST", "/api/v1/units/"+vacantUnit+"/archive", nil, "id", vacantUnit).expect(t, http.StatusOK).decode(t, &u)
+	if u.ArchivedAt == nil {
+		t.Fatalf("archived %+v", u)
+	}
+	call(t, h.ArchiveUnit, asLandlord, "POST", "/api/v1/units/"+vacantUnit+"/archive", nil, "id", vacantUnit).expect(t, http.StatusConflict)
+
+	var units []store.UnitListing
+	call(t, h.ListUnits, asLandlord, "GET", unitsPath, nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &units)
+	if len(units) != 1 || units[0].ID != occupiedUnit {
+		t.Fatalf("default list = %+v, want only A1", units)
+	}
+	call(t, h.ListUnits, asLandlord, "GET", unitsPath+"?archived=true", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &units)
+	if len(units) != 1 || units[0].ID != vacantUnit {
+		t.Fatalf("archived list = %+v, want only A2", units)
+	}
+	call(t, h.ListUnits, asLandlord, "GET", unitsPath+"?archived=maybe", nil, "id", buildingA).expect(t, http.StatusBadRequest)
+
+	// Its payment history stays
+	payments, err := f.st.Payments.ListPayments(store.PaymentFilter{BuildingIDs: []string{buildingA}}, store.ListOptions{PerPage: 10})
+	if err != nil || payments.Total != 1 {
+		t.Fatalf("payments after archiving = %+v, %v", payments, err)
+	}
+
+	u = models.Unit{}
+	call(t, h.RestoreUnit, asLandlord, "POST", "/api/v1/units/"+vacantUnit+"/restore", nil, "id", vacantUnit).expect(t, http.StatusOK).decode(t, &u)
+	if u.ArchivedAt != nil {
+		t.Fatalf("restored %+v", u)
+	}
+}
+
+func TestArchiveBuilding(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+	path := "/api/v1/buildings/" + buildingA
+
+	call(t, h.ArchiveBuilding, asLandlord, "POST", path+"/archive", nil, "id", buildingA).expect(t, http.StatusConflict)
+	call(t, h.ArchiveBuilding, asStaff(access.RoleManager), "POST", path+"/archive", nil, "id", buildingA).expect(t, http.StatusForbidden)
+	call(t, h.ArchiveBuilding, asOtherLandlord, "POST", path+"/archive", nil, "id", buildingA).expect(t, http.StatusNotFound)
+
+	if _, err := f.st.Units.UpdateUnit(occupiedUnit, map[string]interface{}{"status": "vacant", "tenant_id": nil}); err != nil {
+		t.Fatal(err)
+	}
+	var b models.Building
+	call(t, h.ArchiveBuilding, asLandlord, "POST", path+"/archive", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &b)
+	if b.ArchivedAt == nil {
+		t.Fatalf("archived %+v", b)
+	}
+
+	var buildings []models.Building
+	call(t, h.ListBuildings, asLandlord, "GET", "/api/v1/buildings", nil).expect(t, http.StatusOK).decode(t, &buildings)
+	if len(buildings) != 0 {
+		t.Fatalf("archived building listed: %+v", buildings)
+	}
+	call(t, h.CreateUnit, asLandlord, "POST", "/api/v1/units", models.CreateUnitRequest{BuildingID: buildingA, UnitNumber: "A3"}).expect(t, http.StatusConflict)
+	call(t, h.RestoreUnit, asLandlord, "POST", "/api/v1/units/"+vacantUnit+"/restore", nil, "id", vacantUnit).expect(t, http.StatusConflict)
+
+	b = models.Building{}
+	call(t, h.RestoreBuilding, asLandlord, "POST", path+"/restore", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &b)
+	if b.ArchivedAt != nil {
+		t.Fatalf("restored %+v", b)
+	}
+	var u models.Unit
+	call(t, h.GetUnit, asLandlord, "GET", "/api/v1/units/"+vacantUnit, nil, "id", vacantUnit).expect(t, http.StatusOK).decode(t, &u)
+	if u.ArchivedAt != nil {
+		t.Errorf("unit archived with the building is still archived: %+v", u)
+	}
+}
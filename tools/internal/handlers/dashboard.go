@@ -1,80 +1,65 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
+	"slices"
 
+	"github.com/aletheia/backend/internal/access"
 	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
-	postgrest "github.com/supabase-community/postgrest-go"
-	supabase "github.com/supabase-community/supabase-go"
+	"github.com/aletheia/backend/internal/store"
 )
 
 type DashboardHandler struct {
-	client *supabase.Client
+	store *store.Store
 }
 
-func NewDashboardHandler(client *supabase.Client) *DashboardHandler {
-	return &DashboardHandler{client: client}
+func NewDashboardHandler(st *store.Store) *DashboardHandler {
+	return &DashboardHandler{store: st}
 }
 
-// LandlordDashboard returns aggregated stats for the landlord
+// LandlordDashboard returns aggregated stats across the buildings the user
+// owns or is staff on. Revenue figures only cover buildings where they may
+// view financials. Archived buildings are left out unless
+// ?include_archived=true.
 func (h *DashboardHandler) LandlordDashboard(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
-
-	// Get buildings
-	bData, _, _ := h.client.From("buildings").Select("*", "exact", false).Eq("landlord_id", userID).Execute()
-	var buildings []models.Building
-	json.Unmarshal(bData, &buildings)
+	ids := middleware.BuildingsWith(r, access.ViewBuilding)
+	financialIDs := middleware.BuildingsWith(r, access.ViewFinancials)
 
-	// Get all units across buildings
-	totalUnits := 0
-	occupiedUnits := 0
-	for _, b := range buildings {
-		uData, _, _ := h.client.From("units").Select("status", "exact", false).Eq("building_id", b.ID).Execute()
-		var units []struct {
-			Status string `json:"status"`
-		}
-		json.Unmarshal(uData, &units)
-		totalUnits += len(units)
-		for _, u := range units {
-			if u.Status == "occupied" {
-				occupiedUnits++
-			}
-		}
+	buildings, err := h.store.Buildings.BuildingsByID(ids, r.URL.Query().Get("include_archived") == "true")
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
 	}
-
-	// Get payment stats
-	var totalCollected int64
-	var totalPending int64
+	listed := make(map[string]bool, len(buildings))
 	for _, b := range buildings {
-		pData, _, _ := h.client.From("payments").Select("amount, status", "exact", false).Eq("building_id", b.ID).Execute()
-		var payments []struct {
-			Amount int64  `json:"amount"`
-			Status string `json:"status"`
-		}
-		json.Unmarshal(pData, &payments)
-		for _, p := range payments {
-			if p.Status == "successful" {
-				totalCollected += p.Amount
-			} else if p.Status == "pending" {
-				totalPending += p.Amount
-			}
-		}
+		listed[b.ID] = true
 	}
+	unlisted := func(id string) bool { return !listed[id] }
+	ids = slices.DeleteFunc(slices.Clone(ids), unlisted)
+	financialIDs = slices.DeleteFunc(slices.Clone(financialIDs), unlisted)
 
-	// Recent payments
-	rpData, _, _ := h.client.From("payments").Select("*, profiles!payments_tenant_id_fkey(full_name), buildings(name), units(unit_number)", "exact", false).Eq("status", "successful").Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(5, "").Execute()
-	var recentPayments []json.RawMessage
-	json.Unmarshal(rpData, &recentPayments)
+	totals, err := h.store.Stats.Totals(ids, financialIDs)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
+	}
+	recentPayments, err := h.store.Payments.ListPayments(store.PaymentFilter{BuildingIDs: financialIDs}, store.ListOptions{
+		PerPage: 5,
+		Filters: []store.Filter{{Column: "status", Op: store.OpEq, Value: "successful"}},
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
+	}
 
 	dashboard := map[string]interface{}{
 		"total_buildings":  len(buildings),
-		"total_units":      totalUnits,
-		"occupied_units":   occupiedUnits,
-		"total_collected":  totalCollected,
-		"total_pending":    totalPending,
-		"recent_payments":  recentPayments,
+		"total_units":      totals.Units,
+		"occupied_units":   totals.OccupiedUnits,
+		"total_collected":  totals.Collected,
+		"total_pending":    totals.Pending,
+		"recent_payments":  recentPayments.Items,
 		"active_buildings": buildings,
 	}
 
@@ -88,30 +73,29 @@ func (h *DashboardHandler) LandlordDashboard(w http.ResponseWriter, r *http.Requ
 func (h *DashboardHandler) TenantDashboard(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 
-	// Get profile
-	profData, _, _ := h.client.From("profiles").Select("*", "exact", false).Eq("id", userID).Execute()
-	var profiles []models.Profile
-	json.Unmarshal(profData, &profiles)
-
-	if len(profiles) == 0 {
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
 		respondError(w, http.StatusNotFound, "Profile not found")
 		return
 	}
-
-	// Get assigned unit
-	uData, _, _ := h.client.From("units").Select("*, buildings(*)", "exact", false).Eq("tenant_id", userID).Execute()
-	var units []struct {
-		models.Unit
-		Buildings models.Building `json:"buildings"`
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
 	}
-	json.Unmarshal(uData, &units)
 
-	if len(units) == 0 {
+	// The unit comes from the tenant's active tenancy
+	tenancies, err := h.store.Tenancies.TenanciesForTenant(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
+	}
+	i := slices.IndexFunc(tenancies, func(t models.Tenancy) bool { return t.Status == "active" })
+	if i < 0 {
 		// Tenant has no unit yet
 		respondJSON(w, http.StatusOK, models.APIResponse{
 			Success: true,
 			Data: map[string]interface{}{
-				"profile":    profiles[0],
+				"profile":    profile,
 				"unit":       nil,
 				"building":   nil,
 				"total_paid": 0,
@@ -121,12 +105,28 @@ func (h *DashboardHandler) TenantDashboard(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	unit := units[0]
+	unit, err := h.store.Units.GetUnit(tenancies[i].UnitID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
+	}
+	building, err := h.store.Buildings.GetBuilding(unit.BuildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
+	}
 
 	// Get payment history for this unit
-	pData, _, _ := h.client.From("payments").Select("*", "exact", false).Eq("tenant_id", userID).Eq("unit_id", unit.ID).Order("created_at", &postgrest.OrderOpts{Ascending: false}).Execute()
-	var payments []models.Payment
-	json.Unmarshal(pData, &payments)
+	payments, err := allPages(func(opts store.ListOptions) (store.Page[store.PaymentListing], error) {
+		return h.store.Payments.ListPayments(store.PaymentFilter{TenantID: userID}, opts)
+	}, store.ListOptions{
+		Sort:    store.Sort{Column: "created_at", Desc: true},
+		Filters: []store.Filter{{Column: "unit_id", Op: store.OpEq, Value: unit.ID}},
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to load dashboard")
+		return
+	}
 
 	var totalPaid int64
 	var lastPayment *models.Payment
@@ -136,13 +136,13 @@ func (h *DashboardHandler) TenantDashboard(w http.ResponseWriter, r *http.Reques
 		}
 	}
 	if len(payments) > 0 {
-		lastPayment = &payments[0]
+		lastPayment = &payments[0].Payment
 	}
 
 	dashboard := map[string]interface{}{
-		"profile":      profiles[0],
-		"unit":         unit.Unit,
-		"building":     unit.Buildings,
+		"profile":      profile,
+		"unit":         unit,
+		"building":     building,
 		"total_paid":   totalPaid,
 		"last_payment": lastPayment,
 		"next_amount":  unit.RentAmount,
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestLandlordDashboard(t *testing.T) {
+	f := newFixture(t)
+	h := NewDashboardHandler(f.st)
+	for i := 0; i < 6; i++ {
+		mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 100, Status: "successful", Period: "Jan 2026"})
+	}
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 40, Status: "pending", Period: "Feb 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 7, Status: "failed", Period: "Feb 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: otherLandlordID, UnitID: otherUnit, BuildingID: buildingB, Amount: 999, Status: "successful", Period: "Jan 2026"})
+
+	type dashboard struct {
+		TotalBuildings int                    `json:"total_buildings"`
+		TotalUnits     int                    `json:"total_units"`
+		OccupiedUnits  int                    `json:"occupied_units"`
+		TotalCollected int64                  `json:"total_collected"`
+		TotalPending   int64                  `json:"total_pending"`
+		RecentPayments []store.PaymentListing `json:"recent_payments"`
+	}
+	var got dashboard
+	call(t, h.LandlordDashboard, asLandlord, "GET", "/api/v1/dashboard/landlord", nil).expect(t, http.StatusOK).decode(t, &got)
+	if got.TotalBuildings != 1 || got.TotalUnits != 2 || got.OccupiedUnits != 1 {
+		t.Errorf("counts = %+v, want 1 building with 2 units, 1 occupied", got)
+	}
+	if got.TotalCollected != 600 || got.TotalPending != 40 {
+		t.Errorf("collected %d pending %d, want 600 and 40", got.TotalCollected, got.TotalPending)
+	}
+	if len(got.RecentPayments) != 5 || got.RecentPayments[0].Status != "successful" || got.RecentPayments[0].Unit == nil {
+		t.Errorf("recent payments = %+v, want the latest 5 successful ones", got.RecentPayments)
+	}
+
+	// Caretakers see the building but not its money
+	got = dashboard{}
+	call(t, h.LandlordDashboard, asStaff(access.RoleCaretaker), "GET", "/api/v1/dashboard/landlord", nil).expect(t, http.StatusOK).decode(t, &got)
+	if got.TotalUnits != 2 || got.TotalCollected != 0 || got.TotalPending != 0 || len(got.RecentPayments) != 0 {
+		t.Errorf("caretaker dashboard = %+v, want units without financials", got)
+	}
+}
+
+func TestLandlordDashboardArchived(t *testing.T) {
+	f := newFixture(t)
+	h := NewDashboardHandler(f.st)
+	if _, err := f.st.Units.UpdateUnit(occupiedUnit, map[string]interface{}{"status": "vacant", "tenant_id": nil}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.st.Buildings.ArchiveBuilding(buildingA); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		TotalBuildings int `json:"total_buildings"`
+	}
+	call(t, h.LandlordDashboard, asLandlord, "GET", "/api/v1/dashboard/landlord", nil).expect(t, http.StatusOK).decode(t, &got)
+	if got.TotalBuildings != 0 {
+		t.Errorf("archived building counted: %+v", got)
+	}
+	call(t, h.LandlordDashboard, asLandlord, "GET", "/api/v1/dashboard/landlord?include_archived=true", nil).expect(t, http.StatusOK).decode(t, &got)
+	if got.TotalBuildings != 1 {
+		t.Errorf("include_archived = %+v, want the archived building", got)
+	}
+}
+
+func TestLandlordDashboardManyBuildings(t *testing.T) {
+	f := newFixture(t)
+	h := NewDashboardHandler(f.st)
+
+	// More buildings than a list page holds, the oldest of them archived
+	landlord := caller{id: landlordID, role: "landlord", grants: access.Grants{}}
+	const count = store.MaxPerPage + 10
+	for i := 0; i < count; i++ {
+		b := mustCreate(t, f.mem.CreateBuilding, models.Building{LandlordID: landlordID, Name: fmt.Sprintf("Block %d", i), Address: "Estate Rd"})
+		mustCreate(t, f.mem.CreateUnit, models.Unit{BuildingID: b.ID, UnitNumber: "1", RentAmount: 100})
+		mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, BuildingID: b.ID, Amount: 1, Status: "successful", Period: "Jan 2026"})
+		if i == 0 {
+			if _, err := f.st.Buildings.ArchiveBuilding(b.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		maps.Copy(landlord.grants, grantsOn(b.ID, access.RoleOwner))
+	}
+
+	var got struct {
+		TotalBuildings  int               `json:"total_buildings"`
+		TotalUnits      int               `json:"total_units"`
+		TotalCollected  int64             `json:"total_collected"`
+		ActiveBuildings []models.Building `json:"active_buildings"`
+	}
+	call(t, h.LandlordDashboard, landlord, "GET", "/api/v1/dashboard/landlord", nil).expect(t, http.StatusOK).decode(t, &got)
+	if got.TotalBuildings != count-1 || len(got.ActiveBuildings) != count-1 || got.TotalUnits != count-1 || got.TotalCollected != count-1 {
+		t.Errorf("dashboard = %d buildings (%d listed), %d units, %d collected; want %d of each",
+			got.TotalBuildings, len(got.ActiveBuildings), got.TotalUnits, got.TotalCollected, count-1)
+	}
+}
+
+func TestTenantDashboard(t *testing.T) {
+	f := newFixture(t)
+	h := NewDashboardHandler(f.st)
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 300, Status: "successful", Period: "Jan 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 200, Status: "successful", Period: "Feb 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 50, Status: "pending", Period: "Mar 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: otherUnit, BuildingID: buildingB, Amount: 999, Status: "successful", Period: "Jan 2026"})
+
+	var got struct {
+		Profile     models.Profile  `json:"profile"`
+		Unit        *models.Unit    `json:"unit"`
+		Building    models.Building `json:"building"`
+		TotalPaid   int64           `json:"total_paid"`
+		LastPayment *models.Payment `json:"last_payment"`
+		NextAmount  int64           `json:"next_amount"`
+	}
+	call(t, h.TenantDashboard, asTenant, "GET", "/api/v1/dashboard/tenant", nil).expect(t, http.StatusOK).decode(t, &got)
+	if got.Profile.ID != tenantID || got.Unit == nil || got.Unit.ID != occupiedUnit || got.Building.ID != buildingA {
+		t.Fatalf("dashboard = %+v, want the tenant's unit in building A", got)
+	}
+	if got.TotalPaid != 500 || got.NextAmount != 60_000_00 {
+		t.Errorf("total paid %d next %d, want 500 and the unit's rent", got.TotalPaid, got.NextAmount)
+	}
+	if got.LastPayment == nil || got.LastPayment.Period != "Mar 2026" {
+		t.Errorf("last payment = %+v, want the latest one", got.LastPayment)
+	}
+
+	// A tenant without a tenancy is pointed at their invitation
+	got.Unit = nil
+	call(t, h.TenantDashboard, caller{id: staffID, role: "tenant"}, "GET", "/api/v1/dashboard/tenant", nil).expect(t, http.StatusOK).decode(t, &got)
+	if got.Unit != nil {
+		t.Errorf("unit = %+v, want none", got.Unit)
+	}
+}
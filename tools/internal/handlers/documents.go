@@ -4,18 +4,20 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
 	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
-	postgrest "github.com/supabase-community/postgrest-go"
-	supabase "github.com/supabase-community/supabase-go"
+	"github.com/aletheia/backend/internal/store"
 )
 
 type DocumentsHandler struct {
-	client *supabase.Client
+	store *store.Store
+	audit *audit.Logger
 }
 
-func NewDocumentsHandler(client *supabase.Client) *DocumentsHandler {
-	return &DocumentsHandler{client: client}
+func NewDocumentsHandler(st *store.Store, auditLog *audit.Logger) *DocumentsHandler {
+	return &DocumentsHandler{store: st, audit: auditLog}
 }
 
 // UploadDocument records a document upload (file uploaded to Supabase Storage separately)
@@ -33,6 +35,11 @@ func (h *DocumentsHandler) UploadDocument(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if req.BuildingID != "" && middleware.GetUserRole(r) != "tenant" && !middleware.Can(r, req.BuildingID, access.ManageDocuments) {
+		respondError(w, http.StatusForbidden, "You cannot add documents to this building")
+		return
+	}
+
 	// File URL will be set after uploading to Supabase Storage
 	fileURL := r.URL.Query().Get("file_url")
 	if fileURL == "" {
@@ -40,67 +47,131 @@ func (h *DocumentsHandler) UploadDocument(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	doc := map[string]interface{}{
-		"uploaded_by": userID,
-		"building_id": req.BuildingID,
-		"unit_id":     req.UnitID,
-		"name":        req.Name,
-		"type":        req.Type,
-		"file_url":    fileURL,
+	doc := models.Document{
+		UploadedBy: userID,
+		BuildingID: &req.BuildingID,
+		UnitID:     &req.UnitID,
+		Name:       req.Name,
+		Type:       req.Type,
+		FileURL:    fileURL,
+	}
+	tenancy, ok := h.documentTenancy(w, r, req)
+	if !ok {
+		return
+	}
+	if tenancy != nil {
+		doc.UnitID, doc.TenancyID = &tenancy.UnitID, &tenancy.ID
+		// A tenant's own uploads stay out of the building's documents
+		if middleware.GetUserRole(r) != "tenant" {
+			doc.BuildingID = &tenancy.BuildingID
+		}
 	}
 
-	data, _, err := h.client.From("documents").Insert(doc, false, "", "", "").Execute()
+	created, err := h.store.Documents.CreateDocument(doc)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to save document: "+err.Error())
 		return
 	}
 
-	var created []models.Document
-	json.Unmarshal(data, &created)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "document.create",
+		ResourceType: "document",
+		ResourceID:   created.ID,
+		BuildingID:   req.BuildingID,
+		After:        created,
+	})
 
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
-		Data:    created[0],
+		Data:    created,
 		Message: "Document uploaded successfully",
 	})
 }
 
-// ListDocuments returns documents scoped by role
+// documentTenancy picks the tenancy a new document belongs to: the one
+// asked for, a tenant's current tenancy, or the current tenancy of the unit
+// staff file it against. It returns nil, true when there is none, and nil,
+// false after responding with an error.
+func (h *DocumentsHandler) documentTenancy(w http.ResponseWriter, r *http.Request, req models.UploadDocumentRequest) (*models.Tenancy, bool) {
+	userID := middleware.GetUserID(r)
+	isTenant := middleware.GetUserRole(r) == "tenant"
+
+	var tenancy models.Tenancy
+	var err error
+	switch {
+	case req.TenancyID != "":
+		tenancy, err = h.store.Tenancies.GetTenancy(req.TenancyID)
+		if err == nil && (isTenant && tenancy.TenantID != userID || !isTenant && !middleware.Can(r, tenancy.BuildingID, access.ManageDocuments)) {
+			err = store.ErrNotFound
+		}
+		if err == store.ErrNotFound {
+			respondError(w, http.StatusNotFound, "Tenancy not found")
+			return nil, false
+		}
+	case isTenant:
+		var found bool
+		tenancy, found, err = currentTenancy(h.store, userID)
+		if err == nil && !found {
+			return nil, true
+		}
+	case req.UnitID != "" && req.BuildingID != "":
+		tenancy, err = h.store.Tenancies.ActiveTenancy(req.UnitID)
+		if err == store.ErrNotFound || err == nil && tenancy.BuildingID != req.BuildingID {
+			return nil, true
+		}
+	default:
+		return nil, true
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to find the tenancy")
+		return nil, false
+	}
+	return &tenancy, true
+}
+
+var documentList = listSpec{
+	sorts: []string{"-created_at", "name"},
+	filters: []listFilter{
+		idFilter("building_id"),
+		idFilter("unit_id"),
+		idFilter("tenancy_id"),
+		setFilter("type", "lease_agreement", "receipt", "other"),
+		dateRange("created_at", "from", "to"),
+	},
+}
+
+// ListDocuments returns a page of documents scoped by role
 func (h *DocumentsHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 	userRole := middleware.GetUserRole(r)
 
-	query := h.client.From("documents").Select("*", "exact", false).Order("created_at", &postgrest.OrderOpts{Ascending: false})
+	lq, err := parseList(r, documentList)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	// Own uploads, plus the documents of a tenant's tenancies, past and
+	// current, or any document for buildings staff manage documents for
+	filter := store.DocumentFilter{UploadedBy: userID}
 	if userRole == "tenant" {
-		// Get tenant's unit
-		uData, _, _ := h.client.From("units").Select("id", "exact", false).Eq("tenant_id", userID).Execute()
-		var units []struct {
-			ID string `json:"id"`
-		}
-		json.Unmarshal(uData, &units)
-
-		if len(units) > 0 {
-			query = query.Eq("unit_id", units[0].ID)
-		} else {
-			respondJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: []interface{}{}})
+		tenancies, err := h.store.Tenancies.TenanciesForTenant(userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to find your tenancies")
 			return
 		}
+		for _, t := range tenancies {
+			filter.TenancyIDs = append(filter.TenancyIDs, t.ID)
+		}
 	} else {
-		query = query.Eq("uploaded_by", userID)
+		filter.BuildingIDs = middleware.BuildingsWith(r, access.ManageDocuments)
 	}
 
-	data, _, err := query.Execute()
+	docs, err := h.store.Documents.ListDocuments(filter, lq.opts)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch documents")
 		return
 	}
 
-	var docs []models.Document
-	json.Unmarshal(data, &docs)
-
-	respondJSON(w, http.StatusOK, models.APIResponse{
-		Success: true,
-		Data:    docs,
-	})
+	respondPage(w, lq, docs)
 }
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+)
+
+func TestUploadDocument(t *testing.T) {
+	f := newFixture(t)
+	h := NewDocumentsHandler(f.st, nil)
+	lease := models.UploadDocumentRequest{BuildingID: buildingA, UnitID: occupiedUnit, Name: "Lease 2026", Type: "lease_agreement"}
+	path := "/api/v1/documents?file_url=https://files.example.com/lease.pdf"
+
+	call(t, h.UploadDocument, asLandlord, "POST", "/api/v1/documents", lease).expect(t, http.StatusBadRequest)
+	call(t, h.UploadDocument, asLandlord, "POST", path, models.UploadDocumentRequest{Name: "No type"}).expect(t, http.StatusBadRequest)
+	call(t, h.UploadDocument, asOtherLandlord, "POST", path, lease).expect(t, http.StatusForbidden)
+	call(t, h.UploadDocument, asStaff(access.RoleCaretaker), "POST", path, lease).expect(t, http.StatusForbidden)
+
+	var d models.Document
+	call(t, h.UploadDocument, asStaff(access.RoleManager), "POST", path, lease).expect(t, http.StatusCreated).decode(t, &d)
+	if d.UploadedBy != staffID || d.BuildingID == nil || *d.BuildingID != buildingA || d.FileURL != "https://files.example.com/lease.pdf" {
+		t.Fatalf("document = %+v", d)
+	}
+
+	// Tenants may upload without building grants
+	var receipt models.Document
+	call(t, h.UploadDocument, asTenant, "POST", path, models.UploadDocumentRequest{UnitID: occupiedUnit, Name: "Receipt", Type: "receipt"}).expect(t, http.StatusCreated).decode(t, &receipt)
+	if receipt.BuildingID != nil {
+		t.Errorf("building_id = %v, want none", *receipt.BuildingID)
+	}
+	if receipt.TenancyID == nil || *receipt.TenancyID != activeTenancy {
+		t.Errorf("tenancy_id = %v, want the tenant's current tenancy", receipt.TenancyID)
+	}
+
+	// Staff filing against an occupied unit file against its tenancy
+	if d.TenancyID == nil || *d.TenancyID != activeTenancy {
+		t.Errorf("lease tenancy_id = %v, want %s", d.TenancyID, activeTenancy)
+	}
+	call(t, h.UploadDocument, asTenant, "POST", path, models.UploadDocumentRequest{TenancyID: "50000000-0000-0000-0000-00000000ffff", Name: "Receipt", Type: "receipt"}).expect(t, http.StatusNotFound)
+}
+
+func TestListDocuments(t *testing.T) {
+	f := newFixture(t)
+	h := NewDocumentsHandler(f.st, nil)
+	a, unitA, b, tenancy := buildingA, occupiedUnit, buildingB, activeTenancy
+	mustCreate(t, f.mem.CreateDocument, models.Document{UploadedBy: landlordID, BuildingID: &a, UnitID: &unitA, TenancyID: &tenancy, Name: "Lease", Type: "lease_agreement"})
+	// A previous tenant's lease for the same unit
+	mustCreate(t, f.mem.CreateDocument, models.Document{UploadedBy: landlordID, BuildingID: &a, UnitID: &unitA, Name: "Old lease", Type: "lease_agreement"})
+	mustCreate(t, f.mem.CreateDocument, models.Document{UploadedBy: landlordID, BuildingID: &a, Name: "Fire certificate", Type: "other"})
+	mustCreate(t, f.mem.CreateDocument, models.Document{UploadedBy: otherLandlordID, BuildingID: &b, Name: "Other lease", Type: "lease_agreement"})
+	mustCreate(t, f.mem.CreateDocument, models.Document{UploadedBy: staffID, Name: "Staff notes", Type: "other"})
+
+	list := func(c caller) []models.Document {
+		t.Helper()
+		var docs []models.Document
+		call(t, h.ListDocuments, c, "GET", "/api/v1/documents", nil).expect(t, http.StatusOK).decode(t, &docs)
+		return docs
+	}
+	names := func(docs []models.Document) []string {
+		var out []string
+		for _, d := range docs {
+			out = append(out, d.Name)
+		}
+		return out
+	}
+
+	if got := list(asTenant); len(got) != 1 || got[0].Name != "Lease" {
+		t.Errorf("tenant sees %v, want only their tenancy's lease", names(got))
+	}
+	if got := list(asLandlord); len(got) != 3 {
+		t.Errorf("landlord sees %v, want building A's three documents", names(got))
+	}
+	// Staff without documents:manage see only what they uploaded themselves
+	if got := list(asStaff(access.RoleCaretaker)); len(got) != 1 || got[0].Name != "Staff notes" {
+		t.Errorf("caretaker sees %v", names(got))
+	}
+	if got := list(asStaff(access.RoleManager)); len(got) != 4 {
+		t.Errorf("manager sees %v, want building A's documents and their own", names(got))
+	}
+}
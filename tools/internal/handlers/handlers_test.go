@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
+	gotrue "github.com/supabase-community/gotrue-go"
+	gotrue_types "github.com/supabase-community/gotrue-go/types"
+)
+
+// Fixture IDs. The landlord owns building A with a vacant and an occupied
+// unit, whose tenant moved in under activeTenancy; the other landlord owns
+// building B with one vacant unit.
+const (
+	landlordID      = "10000000-0000-0000-0000-000000000001"
+	otherLandlordID = "10000000-0000-0000-0000-000000000002"
+	tenantID        = "10000000-0000-0000-0000-000000000003"
+	staffID         = "10000000-0000-0000-0000-000000000004"
+	buildingA       = "20000000-0000-0000-0000-00000000000a"
+	buildingB       = "20000000-0000-0000-0000-00000000000b"
+	vacantUnit      = "30000000-0000-0000-0000-000000000001"
+	occupiedUnit    = "30000000-0000-0000-0000-000000000002"
+	otherUnit       = "30000000-0000-0000-0000-000000000003"
+	activeTenancy   = "50000000-0000-0000-0000-000000000001"
+)
+
+type fixture struct {
+	mem    *store.Memory
+	st     *store.Store
+	outbox *outbox
+	notify *notify.Notifier
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	mem := store.NewMemory()
+	tenantPhone := "+2348030000003"
+	phoneVerified := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	mem.PutProfile(models.Profile{ID: landlordID, Role: "landlord", Roles: []string{"landlord"}, FullName: "Ada Landlord", Email: "ada@example.com"})
+	mem.PutProfile(models.Profile{ID: otherLandlordID, Role: "landlord", Roles: []string{"landlord"}, FullName: "Bola Landlord", Email: "bola@example.com"})
+	mem.PutProfile(models.Profile{ID: tenantID, Role: "tenant", Roles: []string{"tenant"}, FullName: "Chidi Tenant", Email: "chidi@example.com", Phone: &tenantPhone, PhoneVerifiedAt: &phoneVerified})
+	mem.PutProfile(models.Profile{ID: staffID, Role: "staff", Roles: []string{"staff"}, FullName: "Dayo Staff", Email: "dayo@example.com"})
+
+	mustCreate(t, mem.CreateBuilding, models.Building{ID: buildingA, LandlordID: landlordID, Name: "Palm Court", Address: "1 Palm Rd", TotalUnits: 2})
+	mustCreate(t, mem.CreateBuilding, models.Building{ID: buildingB, LandlordID: otherLandlordID, Name: "Lagoon View", Address: "9 Lagoon Rd", TotalUnits: 1})
+	mustCreate(t, mem.CreateUnit, models.Unit{ID: vacantUnit, BuildingID: buildingA, UnitNumber: "A2", RentAmount: 50_000_00, Status: "vacant"})
+	mustCreate(t, mem.CreateUnit, models.Unit{ID: occupiedUnit, BuildingID: buildingA, UnitNumber: "A1", RentAmount: 60_000_00, Status: "vacant"})
+	mem.PutTenancy(models.Tenancy{ID: activeTenancy, UnitID: occupiedUnit, BuildingID: buildingA, TenantID: tenantID, RentAmount: 60_000_00, StartDate: "2025-06-01", Status: "active"})
+	mustCreate(t, mem.CreateUnit, models.Unit{ID: otherUnit, BuildingID: buildingB, UnitNumber: "B1", RentAmount: 40_000_00, Status: "vacant"})
+
+	box := &outbox{}
+	return &fixture{
+		mem:    mem,
+		st:     mem.Store(),
+		outbox: box,
+		notify: notify.New(mem, box, box, "https://app.example.com"),
+	}
+}
+
+func mustCreate[T any](t *testing.T, create func(T) (T, error), v T) T {
+	t.Helper()
+	created, err := create(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+// caller is who a test request is made as, with the grants the permission
+// middleware would have loaded for them
+type caller struct {
+	id     string
+	role   string
+	grants access.Grants
+}
+
+func grantsOn(buildingID string, role access.Role) access.Grants {
+	perms := map[access.Permission]bool{}
+	for _, p := range access.PermissionsFor(role) {
+		perms[p] = true
+	}
+	return access.Grants{buildingID: perms}
+}
+
+var (
+	asLandlord      = caller{id: landlordID, role: "landlord", grants: grantsOn(buildingA, access.RoleOwner)}
+	asOtherLandlord = caller{id: otherLandlordID, role: "landlord", grants: grantsOn(buildingB, access.RoleOwner)}
+	asTenant        = caller{id: tenantID, role: "tenant", grants: access.Grants{}}
+	asNewLandlord   = caller{id: otherLandlordID, role: "landlord", grants: access.Grants{}}
+)
+
+func asStaff(role access.Role) caller {
+	return caller{id: staffID, role: "staff", grants: grantsOn(buildingA, role)}
+}
+
+// response is an APIResponse (or PaginatedResponse) with Data left raw for the test to decode
+type response struct {
+	Code    int
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+	Message string          `json:"message"`
+
+	// Set on list responses
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	TotalPages int    `json:"total_pages"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// call runs handler for a request made as c. pathValues are name, value
+// pairs for the route's wildcards.
+func call(t *testing.T, handler http.HandlerFunc, c caller, method, target string, body interface{}, pathValues ...string) response {
+	t.Helper()
+	var reader *bytes.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	case upload:
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for field, content := range b {
+			part, err := mw.CreateFormFile(field, field+".csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte(content))
+		}
+		mw.Close()
+		reader, contentType = bytes.NewReader(buf.Bytes()), mw.FormDataContentType()
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	r.Header.Set("Content-Type", contentType)
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		r.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	if c.id != "" {
+		ctx := context.WithValue(r.Context(), middleware.UserIDKey, c.id)
+		ctx = context.WithValue(ctx, middleware.UserRoleKey, c.role)
+		ctx = context.WithValue(ctx, middleware.UserRolesKey, []string{c.role})
+		ctx = context.WithValue(ctx, middleware.GrantsKey, c.grants)
+		r = r.WithContext(ctx)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	res := response{Code: w.Code}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s %s: response is not JSON: %s", method, target, w.Body.String())
+		}
+	}
+	return res
+}
+
+// upload is a multipart request body of files, by form field
+type upload map[string]string
+
+// expect fails the test unless the response has the given status
+func (res response) expect(t *testing.T, code int) response {
+	t.Helper()
+	if res.Code != code {
+		t.Fatalf("status = %d, want %d (error %q)", res.Code, code, res.Error)
+	}
+	return res
+}
+
+// decode unmarshals the response data into v
+func (res response) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(res.Data, v); err != nil {
+		t.Fatalf("decoding %s: %v", res.Data, err)
+	}
+}
+
+// outbox collects messages instead of sending them
+type outbox struct {
+	mu     sync.Mutex
+	emails []sentMessage
+	texts  []sentMessage
+}
+
+type sentMessage struct {
+	to, body string
+}
+
+func (o *outbox) SendEmail(to, subject, html string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.emails = append(o.emails, sentMessage{to: to, body: html})
+	return nil
+}
+
+func (o *outbox) SendSMS(to, body string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.texts = append(o.texts, sentMessage{to: to, body: body})
+	return nil
+}
+
+// sentTo returns the messages sent to an address or number
+func (o *outbox) sentTo(to string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var bodies []string
+	for _, m := range append(append([]sentMessage{}, o.emails...), o.texts...) {
+		if strings.EqualFold(m.to, to) {
+			bodies = append(bodies, m.body)
+		}
+	}
+	return bodies
+}
+
+// linkToken pulls the ?token= out of the last link emailed to an address
+func (o *outbox) linkToken(t *testing.T, to string) string {
+	t.Helper()
+	sent := o.sentTo(to)
+	if len(sent) == 0 {
+		t.Fatalf("nothing sent to %s", to)
+	}
+	m := regexp.MustCompile(`token=([0-9a-f]+)`).FindStringSubmatch(sent[len(sent)-1])
+	if m == nil {
+		t.Fatalf("no token link in %q", sent[len(sent)-1])
+	}
+	return m[1]
+}
+
+// smsCode pulls the code out of the last SMS sent to a number
+func (o *outbox) smsCode(t *testing.T, to string) string {
+	t.Helper()
+	sent := o.sentTo(to)
+	if len(sent) == 0 {
+		t.Fatalf("nothing sent to %s", to)
+	}
+	code := regexp.MustCompile(`\d{6}`).FindString(sent[len(sent)-1])
+	if code == "" {
+		t.Fatalf("no code in %q", sent[len(sent)-1])
+	}
+	return code
+}
+
+// fakeAuth stands in for Supabase Auth. Sessions are "access-<user id>";
+// calls it does not implement panic on the embedded nil Client.
+type fakeAuth struct {
+	gotrue.Client
+	mu        sync.Mutex
+	users     []*fakeUser
+	loggedOut int
+}
+
+type fakeUser struct {
+	id           uuid.UUID
+	email, phone string
+	password     string
+}
+
+var errBadCredentials = errors.New("invalid login credentials")
+
+// add registers an existing account
+func (a *fakeAuth) add(id, email, phone, password string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.users = append(a.users, &fakeUser{id: uuid.MustParse(id), email: email, phone: phone, password: password})
+}
+
+func (a *fakeAuth) find(match func(u *fakeUser) bool) *fakeUser {
+	for _, u := range a.users {
+		if match(u) {
+			return u
+		}
+	}
+	return nil
+}
+
+func (a *fakeAuth) session(u *fakeUser) gotrue_types.Session {
+	return gotrue_types.Session{
+		AccessToken:  "access-" + u.id.String(),
+		RefreshToken: "refresh-" + u.id.String(),
+		User:         gotrue_types.User{ID: u.id, Email: u.email, Phone: u.phone},
+	}
+}
+
+func (a *fakeAuth) Signup(req gotrue_types.SignupRequest) (*gotrue_types.SignupResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.find(func(u *fakeUser) bool { return strings.EqualFold(u.email, req.Email) }) != nil {
+		return nil, errors.New("user already registered")
+	}
+	u := &fakeUser{id: uuid.New(), email: req.Email, password: req.Password}
+	a.users = append(a.users, u)
+	session := a.session(u)
+	return &gotrue_types.SignupResponse{User: session.User, Session: session}, nil
+}
+
+func (a *fakeAuth) SignInWithEmailPassword(email, password string) (*gotrue_types.TokenResponse, error) {
+	return a.signIn(func(u *fakeUser) bool { return strings.EqualFold(u.email, email) }, password)
+}
+
+func (a *fakeAuth) SignInWithPhonePassword(phone, password string) (*gotrue_types.TokenResponse, error) {
+	return a.signIn(func(u *fakeUser) bool { return u.phone == phone }, password)
+}
+
+func (a *fakeAuth) signIn(match func(u *fakeUser) bool, password string) (*gotrue_types.TokenResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u := a.find(match)
+	if u == nil || u.password != password {
+		return nil, errBadCredentials
+	}
+	return &gotrue_types.TokenResponse{Session: a.session(u)}, nil
+}
+
+func (a *fakeAuth) WithToken(token string) gotrue.Client {
+	return a
+}
+
+func (a *fakeAuth) Logout() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.loggedOut++
+	return nil
+}
+
+func (a *fakeAuth) AdminCreateUser(req gotrue_types.AdminCreateUserRequest) (*gotrue_types.AdminCreateUserResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u := &fakeUser{id: uuid.New(), email: req.Email, phone: req.Phone}
+	if req.Password != nil {
+		u.password = *req.Password
+	}
+	a.users = append(a.users, u)
+	return &gotrue_types.AdminCreateUserResponse{User: a.session(u).User}, nil
+}
+
+func (a *fakeAuth) AdminDeleteUser(req gotrue_types.AdminDeleteUserRequest) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, u := range a.users {
+		if u.id == req.UserID {
+			a.users = append(a.users[:i], a.users[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("user not found")
+}
+
+func (a *fakeAuth) AdminUpdateUser(req gotrue_types.AdminUpdateUserRequest) (*gotrue_types.AdminUpdateUserResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u := a.find(func(u *fakeUser) bool { return u.id == req.UserID })
+	if u == nil {
+		return nil, errors.New("user not found")
+	}
+	if req.Password != "" {
+		u.password = req.Password
+	}
+	if req.Email != "" {
+		u.email = req.Email
+	}
+	if req.Phone != "" {
+		u.phone = req.Phone
+	}
+	return &gotrue_types.AdminUpdateUserResponse{User: a.session(u).User}, nil
+}
+
+// AdminGenerateLink's email OTP is the address itself, which VerifyForUser checks
+func (a *fakeAuth) AdminGenerateLink(req gotrue_types.AdminGenerateLinkRequest) (*gotrue_types.AdminGenerateLinkResponse, error) {
+	return &gotrue_types.AdminGenerateLinkResponse{EmailOTP: "otp:" + req.Email}, nil
+}
+
+func (a *fakeAuth) VerifyForUser(req gotrue_types.VerifyForUserRequest) (*gotrue_types.VerifyForUserResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	u := a.find(func(u *fakeUser) bool { return strings.EqualFold(u.email, req.Email) })
+	if u == nil || req.Token != "otp:"+req.Email {
+		return nil, errBadCredentials
+	}
+	return &gotrue_types.VerifyForUserResponse{Session: a.session(u)}, nil
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
 )
 
@@ -27,3 +30,37 @@ func respondError(w http.ResponseWriter, status int, message string) {
 func getPathParam(r *http.Request, name string) string {
 	return r.PathValue(name)
 }
+
+// requireBuilding checks the caller's grants for a building. It responds 404
+// when the building is not visible to them at all (so IDs can't be probed)
+// and 403 when they can see it but lack perm.
+func requireBuilding(w http.ResponseWriter, r *http.Request, buildingID string, perm access.Permission) bool {
+	if !middleware.Can(r, buildingID, access.ViewBuilding) {
+		respondError(w, http.StatusNotFound, "Building not found")
+		return false
+	}
+	if !middleware.Can(r, buildingID, perm) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions for this building")
+		return false
+	}
+	return true
+}
+
+// recordAudit writes e to the audit log, filling in the signed-in user as
+// the actor. Requests made with an API key note the key, so integrations
+// can be told apart from people.
+func recordAudit(auditLog *audit.Logger, r *http.Request, e audit.Entry) {
+	if e.ActorID == "" {
+		e.ActorID = middleware.GetUserID(r)
+	}
+	if e.ActorRole == "" {
+		e.ActorRole = middleware.GetUserRole(r)
+	}
+	if keyID := middleware.GetAPIKeyID(r); keyID != "" {
+		if e.Metadata == nil {
+			e.Metadata = map[string]interface{}{}
+		}
+		e.Metadata["api_key_id"] = keyID
+	}
+	auditLog.Record(r, e)
+}
@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/phone"
+	"github.com/aletheia/backend/internal/sheet"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	importMaxBytes = 5 << 20 // per file
+	importMaxRows  = 2000    // per file
+)
+
+// The columns an import file may have. Amounts are in naira, as landlords
+// keep them; dates are YYYY-MM-DD, DD/MM/YYYY or Excel dates.
+var (
+	importUnitColumns    = []string{"unit_number", "rent", "lease_start", "lease_end", "tenant_email", "tenant_phone"}
+	importPaymentColumns = []string{"unit_number", "amount", "paid_at", "period", "method"}
+	importPaymentMethods = []string{"bank_transfer", "cash", "card", "ussd"}
+)
+
+// ImportsHandler brings a landlord's existing units, tenants and payment
+// history in from spreadsheets
+type ImportsHandler struct {
+	store    *store.Store
+	notifier *notify.Notifier
+	audit    *audit.Logger
+}
+
+func NewImportsHandler(st *store.Store, notifier *notify.Notifier, auditLog *audit.Logger) *ImportsHandler {
+	return &ImportsHandler{store: st, notifier: notifier, audit: auditLog}
+}
+
+// importPlan is what a validated import would write
+type importPlan struct {
+	units    []models.Unit
+	tenants  map[string]importTenant // by unit number
+	payments []models.Payment
+	errors   []models.ImportRowError
+}
+
+type importTenant struct {
+	row          int
+	email, phone string
+}
+
+func (p *importPlan) fail(file string, row int, column, format string, args ...any) {
+	p.errors = append(p.errors, models.ImportRowError{File: file, Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// ImportBuilding takes a multipart "units" file (units and their current
+// tenants) and/or "payments" file (past payments), each CSV or XLSX. With
+// ?dry_run=true it only validates, listing every problem by file, row and
+// column. Otherwise, if there are none, the units and payments are created
+// in one transaction — imported payments are marked with the import — and
+// then each imported tenant is sent an invitation.
+func (h *ImportsHandler) ImportBuilding(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	buildingID := getPathParam(r, "id")
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	if !requireBuilding(w, r, buildingID, access.ManageBuilding) {
+		return
+	}
+
+	building, err := h.store.Buildings.GetBuilding(buildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
+	if building.ArchivedAt != nil {
+		respondError(w, http.StatusConflict, "Building is archived")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 2*importMaxBytes+1<<20)
+	if err := r.ParseMultipartForm(2 * importMaxBytes); err != nil {
+		respondError(w, http.StatusRequestEntityTooLarge, "Import files must be 5MB or smaller")
+		return
+	}
+	var plan importPlan
+	unitsFile, hasUnits := readImportFile(r, "units", &plan)
+	paymentsFile, hasPayments := readImportFile(r, "payments", &plan)
+	if !hasUnits && !hasPayments {
+		respondError(w, http.StatusBadRequest, "Upload a \"units\" and/or \"payments\" file")
+		return
+	}
+
+	existing, err := h.buildingUnits(buildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch units")
+		return
+	}
+	if unitsFile != nil {
+		plan.readUnits(*unitsFile, buildingID, existing)
+	}
+	if paymentsFile != nil {
+		plan.readPayments(*paymentsFile, existing)
+	}
+
+	if len(plan.tenants) > 0 && !middleware.Can(r, buildingID, access.InviteTenants) {
+		respondError(w, http.StatusForbidden, "You cannot invite tenants to this building")
+		return
+	}
+	if len(plan.payments) > 0 && !middleware.Can(r, buildingID, access.RecordPayments) {
+		respondError(w, http.StatusForbidden, "You cannot record payments for this building")
+		return
+	}
+
+	report := models.ImportReport{
+		DryRun:   dryRun,
+		Units:    len(plan.units),
+		Tenants:  len(plan.tenants),
+		Payments: len(plan.payments),
+		Errors:   plan.errors,
+	}
+	if report.Errors == nil {
+		report.Errors = []models.ImportRowError{}
+	}
+	if dryRun {
+		respondJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: report})
+		return
+	}
+	if len(plan.errors) > 0 {
+		respondJSON(w, http.StatusUnprocessableEntity, models.APIResponse{
+			Success: false,
+			Data:    report,
+			Error:   "The import has errors; nothing was imported",
+		})
+		return
+	}
+
+	if err := h.creditTenancies(plan.payments); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch tenancies")
+		return
+	}
+	imp, err := h.store.Imports.CommitImport(models.Import{BuildingID: buildingID, CreatedBy: &userID}, plan.units, plan.payments)
+	if err == store.ErrUnitNumberTaken {
+		respondError(w, http.StatusConflict, "A unit number was taken while importing; run the import again")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to import")
+		return
+	}
+	report.Import = &imp
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "import.create",
+		ResourceType: "import",
+		ResourceID:   imp.ID,
+		BuildingID:   buildingID,
+		After:        imp,
+	})
+
+	// Invitations go out once the batch is in. One that fails leaves its
+	// unit vacant; it can be invited again as usual.
+	for _, unit := range plan.units {
+		tenant, ok := plan.tenants[unit.UnitNumber]
+		if !ok {
+			continue
+		}
+		created, err := inviteTenant(h.store, h.notifier, building, unit, userID, tenant.email, tenant.phone)
+		if err != nil {
+			report.InviteFailures = append(report.InviteFailures, models.ImportRowError{
+				File: "units", Row: tenant.row, Message: "Invitation not created: " + err.Error(),
+			})
+			continue
+		}
+		report.InvitationsSent++
+		recordAudit(h.audit, r, audit.Entry{
+			Action:       "invitation.create",
+			ResourceType: "invitation",
+			ResourceID:   created.ID,
+			BuildingID:   buildingID,
+			After:        created,
+		})
+	}
+
+	respondJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    report,
+		Message: fmt.Sprintf("Imported %d units and %d payments", imp.UnitsCreated, imp.PaymentsCreated),
+	})
+}
+
+// readImportFile reads the named multipart file, if uploaded. Problems with
+// the file as a whole are recorded in plan.
+func readImportFile(r *http.Request, field string, plan *importPlan) (*sheet.Table, bool) {
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	if header.Size > importMaxBytes {
+		plan.fail(field, 0, "", "File must be 5MB or smaller")
+		return nil, true
+	}
+	raw, err := io.ReadAll(io.LimitReader(file, importMaxBytes+1))
+	if err != nil || len(raw) > importMaxBytes {
+		plan.fail(field, 0, "", "File must be 5MB or smaller")
+		return nil, true
+	}
+	table, err := sheet.Read(raw)
+	if err != nil {
+		plan.fail(field, 0, "", "%s", err.Error())
+		return nil, true
+	}
+	if len(table.Rows) > importMaxRows {
+		plan.fail(field, 0, "", "File has %d rows; import at most %d at a time", len(table.Rows), importMaxRows)
+		return nil, true
+	}
+
+	known := importUnitColumns
+	if field == "payments" {
+		known = importPaymentColumns
+	}
+	ok := true
+	for _, column := range table.Header {
+		if column != "" && !slices.Contains(known, column) {
+			plan.fail(field, 0, column, "Unknown column; expected %s", strings.Join(known, ", "))
+			ok = false
+		}
+	}
+	if !table.Has("unit_number") {
+		plan.fail(field, 0, "unit_number", "Missing column")
+		ok = false
+	}
+	if !ok {
+		return nil, true
+	}
+	return &table, true
+}
+
+// buildingUnits returns every unit of the building, archived ones included,
+// by unit number
+func (h *ImportsHandler) buildingUnits(buildingID string) (map[string]models.Unit, error) {
+	units := map[string]models.Unit{}
+	for page := 1; ; page++ {
+		p, err := h.store.Units.ListUnits(buildingID, store.ListOptions{Page: page, PerPage: store.MaxPerPage})
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range p.Items {
+			units[u.UnitNumber] = u.Unit
+		}
+		if len(p.Items) < store.MaxPerPage {
+			return units, nil
+		}
+	}
+}
+
+// creditTenancies gives payments for occupied units to the units' current
+// tenancies. Payments for new units get theirs when the tenant accepts.
+func (h *ImportsHandler) creditTenancies(payments []models.Payment) error {
+	tenancies := map[string]models.Tenancy{} // by unit
+	for i, p := range payments {
+		if p.TenantID == "" {
+			continue
+		}
+		t, ok := tenancies[p.UnitID]
+		if !ok {
+			var err error
+			if t, err = h.store.Tenancies.ActiveTenancy(p.UnitID); err != nil && err != store.ErrNotFound {
+				return err
+			}
+			tenancies[p.UnitID] = t
+		}
+		if t.ID != "" && t.TenantID == p.TenantID {
+			payments[i].TenancyID = &t.ID
+		}
+	}
+	return nil
+}
+
+func (p *importPlan) readUnits(t sheet.Table, buildingID string, existing map[string]models.Unit) {
+	p.tenants = map[string]importTenant{}
+	seen := map[string]int{}
+	for _, row := range t.Rows {
+		before := len(p.errors)
+		unit := models.Unit{ID: uuid.NewString(), BuildingID: buildingID, Status: "vacant"}
+
+		unit.UnitNumber = t.Get(row, "unit_number")
+		switch first, dup := seen[unit.UnitNumber]; {
+		case unit.UnitNumber == "":
+			p.fail("units", row.Line, "unit_number", "Unit number is required")
+		case dup:
+			p.fail("units", row.Line, "unit_number", "Unit %s is also on row %d", unit.UnitNumber, first)
+		case existing[unit.UnitNumber].ID != "":
+			p.fail("units", row.Line, "unit_number", "Unit %s already exists in this building", unit.UnitNumber)
+		default:
+			seen[unit.UnitNumber] = row.Line
+		}
+
+		if v := t.Get(row, "rent"); v != "" {
+			rent, err := parseNaira(v)
+			if err != nil {
+				p.fail("units", row.Line, "rent", "%s", err.Error())
+			}
+			unit.RentAmount = rent
+		}
+
+		for _, column := range []string{"lease_start", "lease_end"} {
+			v := t.Get(row, column)
+			if v == "" {
+				continue
+			}
+			d, err := sheet.Date(v)
+			if err != nil {
+				p.fail("units", row.Line, column, "%s", err.Error())
+				continue
+			}
+			date := d.Format("2006-01-02")
+			if column == "lease_start" {
+				unit.LeaseStart = &date
+			} else {
+				unit.LeaseEnd = &date
+			}
+		}
+		if unit.LeaseStart != nil && unit.LeaseEnd != nil && *unit.LeaseEnd < *unit.LeaseStart {
+			p.fail("units", row.Line, "lease_end", "Lease end is before lease start")
+		}
+
+		tenant := importTenant{row: row.Line, email: strings.ToLower(t.Get(row, "tenant_email")), phone: t.Get(row, "tenant_phone")}
+		if tenant.email != "" && !strings.Contains(tenant.email, "@") {
+			p.fail("units", row.Line, "tenant_email", "%q is not an email address", tenant.email)
+		}
+		if tenant.phone != "" {
+			normalized, err := phone.NormalizeNG(tenant.phone)
+			if err != nil {
+				p.fail("units", row.Line, "tenant_phone", "%s", err.Error())
+			}
+			tenant.phone = normalized
+		}
+
+		if len(p.errors) == before {
+			p.units = append(p.units, unit)
+			if tenant.email != "" || tenant.phone != "" {
+				p.tenants[unit.UnitNumber] = tenant
+			}
+		}
+	}
+}
+
+func (p *importPlan) readPayments(t sheet.Table, existing map[string]models.Unit) {
+	today := time.Now().UTC()
+	for _, row := range t.Rows {
+		before := len(p.errors)
+		payment := models.Payment{Currency: "NGN", Status: "successful"}
+
+		number := t.Get(row, "unit_number")
+		if unit, ok := existing[number]; ok {
+			switch {
+			case unit.ArchivedAt != nil:
+				p.fail("payments", row.Line, "unit_number", "Unit %s is archived", number)
+			case unit.TenantID == nil:
+				p.fail("payments", row.Line, "unit_number", "Unit %s has no tenant to credit the payment to", number)
+			default:
+				payment.UnitID, payment.TenantID = unit.ID, *unit.TenantID
+			}
+		} else if i := slices.IndexFunc(p.units, func(u models.Unit) bool { return u.UnitNumber == number }); i >= 0 {
+			// A unit from the units file: the payment waits for its tenant
+			if _, ok := p.tenants[number]; !ok {
+				p.fail("payments", row.Line, "unit_number", "Unit %s has no tenant in the units file", number)
+			}
+			payment.UnitID = p.units[i].ID
+		} else if number == "" {
+			p.fail("payments", row.Line, "unit_number", "Unit number is required")
+		} else {
+			p.fail("payments", row.Line, "unit_number", "Unit %s is not in this building or the units file", number)
+		}
+
+		amount, err := parseNaira(t.Get(row, "amount"))
+		switch {
+		case t.Get(row, "amount") == "":
+			p.fail("payments", row.Line, "amount", "Amount is required")
+		case err != nil:
+			p.fail("payments", row.Line, "amount", "%s", err.Error())
+		case amount == 0:
+			p.fail("payments", row.Line, "amount", "Amount must be more than zero")
+		}
+		payment.Amount = amount
+
+		paidAt, err := sheet.Date(t.Get(row, "paid_at"))
+		switch {
+		case t.Get(row, "paid_at") == "":
+			p.fail("payments", row.Line, "paid_at", "Payment date is required")
+		case err != nil:
+			p.fail("payments", row.Line, "paid_at", "%s", err.Error())
+		case paidAt.After(today):
+			p.fail("payments", row.Line, "paid_at", "Payment date is in the future")
+		}
+		payment.PaidAt = &paidAt
+
+		payment.Period = t.Get(row, "period")
+		if payment.Period == "" {
+			payment.Period = paidAt.Format("Jan 2006")
+		}
+
+		method := strings.ToLower(strings.ReplaceAll(t.Get(row, "method"), " ", "_"))
+		if method == "" {
+			method = "bank_transfer"
+		}
+		if !slices.Contains(importPaymentMethods, method) {
+			p.fail("payments", row.Line, "method", "Method must be one of %s", strings.Join(importPaymentMethods, ", "))
+		}
+		payment.PaymentMethod = &method
+
+		if len(p.errors) == before {
+			p.payments = append(p.payments, payment)
+		}
+	}
+}
+
+// parseNaira reads an amount in naira, such as "₦150,000" or "2500.50",
+// as kobo
+func parseNaira(v string) (int64, error) {
+	s := strings.NewReplacer("₦", "", "NGN", "", ",", "", " ", "").Replace(strings.ToUpper(v))
+	whole, frac, _ := strings.Cut(s, ".")
+	valid := whole != "" && len(whole) <= 12 && len(frac) <= 2
+	for _, c := range whole + frac {
+		valid = valid && c >= '0' && c <= '9'
+	}
+	if !valid {
+		return 0, fmt.Errorf("%q is not an amount in naira", v)
+	}
+	naira, _ := strconv.ParseInt(whole, 10, 64)
+	kobo, _ := strconv.ParseInt((frac + "00")[:2], 10, 64)
+	return naira*100 + kobo, nil
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestImportDryRun(t *testing.T) {
+	f := newFixture(t)
+	h := NewImportsHandler(f.st, f.notify, nil)
+	path := "/api/v1/buildings/" + buildingA + "/import"
+	files := upload{
+		"units": "Unit Number,Rent,Lease Start,Tenant Phone\n" +
+			"A3,\"₦150,000\",2026-01-01,08030000009\n" +
+			"A3,100000,,\n" +
+			"A1,100000,,\n" +
+			"A4,lots,31/02/2026,12345\n",
+		"payments": "unit_number,amount,paid_at,method\n" +
+			"A3,150000,2026-01-05,\n" +
+			"Z9,100,2026-01-05,cheque\n",
+	}
+
+	var report models.ImportReport
+	call(t, h.ImportBuilding, asLandlord, "POST", path+"?dry_run=true", files, "id", buildingA).expect(t, http.StatusOK).decode(t, &report)
+	if !report.DryRun || report.Units != 1 || report.Tenants != 1 || report.Payments != 1 {
+		t.Errorf("report = %+v, want 1 good unit with a tenant and 1 good payment", report)
+	}
+	want := []string{
+		"units 3 unit_number", // A3 twice
+		"units 4 unit_number", // A1 exists
+		"units 5 rent", "units 5 lease_start", "units 5 tenant_phone",
+		"payments 3 unit_number", "payments 3 method",
+	}
+	var got []string
+	for _, e := range report.Errors {
+		got = append(got, fmt.Sprintf("%s %d %s", e.File, e.Row, e.Column))
+	}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+
+	// Nothing is written, with or without dry_run, while there are errors
+	call(t, h.ImportBuilding, asLandlord, "POST", path, files, "id", buildingA).expect(t, http.StatusUnprocessableEntity)
+	units, _ := f.st.Units.ListUnits(buildingA, store.ListOptions{PerPage: 10})
+	if units.Total != 2 {
+		t.Errorf("building has %d units after a failed import", units.Total)
+	}
+
+	call(t, h.ImportBuilding, asLandlord, "POST", path, upload{"units": "rent\n100\n"}, "id", buildingA).expect(t, http.StatusUnprocessableEntity)
+	call(t, h.ImportBuilding, asLandlord, "POST", path, upload{"other": "unit_number\nA3\n"}, "id", buildingA).expect(t, http.StatusBadRequest)
+	call(t, h.ImportBuilding, asStaff(access.RoleCaretaker), "POST", path, files, "id", buildingA).expect(t, http.StatusForbidden)
+	call(t, h.ImportBuilding, asLandlord, "POST", "/api/v1/buildings/"+buildingB+"/import", files, "id", buildingB).expect(t, http.StatusNotFound)
+}
+
+func TestImport(t *testing.T) {
+	f := newFixture(t)
+	h := NewImportsHandler(f.st, f.notify, nil)
+	files := upload{
+		"units": "unit_number,rent,lease_start,lease_end,tenant_email\n" +
+			"A3,\"150,000.50\",01/01/2026,31/12/2026,Dayo@Example.com\n" +
+			"A4,90000,,,\n",
+		"payments": "unit_number,amount,paid_at,period,method\n" +
+			"A3,150000,2026-01-05,,Cash\n" +
+			"A3,150000,2026-02-05,Feb 2026,\n" +
+			"A1,60000,2026-01-03,Jan 2026,bank transfer\n",
+	}
+
+	var report models.ImportReport
+	call(t, h.ImportBuilding, asStaff(access.RoleManager), "POST", "/api/v1/buildings/"+buildingA+"/import", files, "id", buildingA).expect(t, http.StatusCreated).decode(t, &report)
+	if report.Import == nil || report.Import.UnitsCreated != 2 || report.Import.PaymentsCreated != 3 || report.InvitationsSent != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(f.outbox.sentTo("dayo@example.com")) != 1 {
+		t.Errorf("imported tenant was not invited")
+	}
+
+	units, _ := f.st.Units.ListUnits(buildingA, store.ListOptions{PerPage: 10})
+	if units.Total != 4 || units.Items[2].UnitNumber != "A3" || units.Items[2].RentAmount != 150_000_50 || *units.Items[2].LeaseEnd != "2026-12-31" {
+		t.Fatalf("units = %+v", units.Items)
+	}
+
+	payments, _ := f.st.Payments.ListPayments(store.PaymentFilter{BuildingIDs: []string{buildingA}}, store.ListOptions{PerPage: 10})
+	byPeriod := map[string]store.PaymentListing{}
+	for _, p := range payments.Items {
+		if p.ImportID == nil || *p.ImportID != report.Import.ID || p.Status != "successful" {
+			t.Errorf("imported payment not labelled: %+v", p.Payment)
+		}
+		byPeriod[p.Period+" "+p.UnitID] = p
+	}
+	if p := byPeriod["Jan 2026 "+occupiedUnit]; p.TenantID != tenantID || *p.PaymentMethod != "bank_transfer" {
+		t.Errorf("payment for the occupied unit = %+v, want it credited to its tenant", p.Payment)
+	}
+	a3 := units.Items[2].ID
+	if p := byPeriod["Jan 2026 "+a3]; p.TenantID != "" || *p.PaymentMethod != "cash" || p.PaidAt.Format("2006-01-02") != "2026-01-05" {
+		t.Errorf("payment for the imported unit = %+v", p.Payment)
+	}
+
+	// The imported tenant gets their payment history on accepting
+	invitations, _ := f.st.Invitations.ListInvitations([]string{buildingA}, store.ListOptions{PerPage: 10})
+	if invitations.Total != 1 {
+		t.Fatalf("invitations = %+v", invitations.Items)
+	}
+	const dayo = "10000000-0000-0000-0000-000000000009"
+	if _, err := f.st.Invitations.AcceptInvitation(store.Acceptance{Token: invitations.Items[0].Token, TenantID: dayo, NewProfile: &models.Profile{FullName: "Dayo"}}); err != nil {
+		t.Fatal(err)
+	}
+	mine, _ := f.st.Payments.ListPayments(store.PaymentFilter{TenantID: dayo}, store.ListOptions{PerPage: 10})
+	if mine.Total != 2 {
+		t.Errorf("tenant has %d payments after accepting, want the 2 imported", mine.Total)
+	}
+
+	// Importing the same units again is refused row by row
+	call(t, h.ImportBuilding, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/import", upload{"units": files["units"]}, "id", buildingA).expect(t, http.StatusUnprocessableEntity)
+}
+
+func TestImportXLSX(t *testing.T) {
+	f := newFixture(t)
+	h := NewImportsHandler(f.st, f.notify, nil)
+
+	var report models.ImportReport
+	book := xlsx(t, [][]string{{"Unit Number", "Rent", "Lease Start"}, {"B-101", "120000", "46023"}, {}, {"B-102", "80000", ""}})
+	call(t, h.ImportBuilding, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/import", upload{"units": book}, "id", buildingA).expect(t, http.StatusCreated).decode(t, &report)
+	if report.Import == nil || report.Import.UnitsCreated != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	units, _ := f.st.Units.ListUnits(buildingA, store.ListOptions{PerPage: 10, Filters: []store.Filter{{Column: "unit_number", Op: store.OpEq, Value: "B-101"}}})
+	if units.Total != 1 || units.Items[0].RentAmount != 120_000_00 || units.Items[0].LeaseStart == nil || *units.Items[0].LeaseStart != "2026-01-01" {
+		t.Errorf("units = %+v", units.Items)
+	}
+
+	call(t, h.ImportBuilding, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/import?dry_run=true", upload{"units": "PK\x03\x04not a zip"}, "id", buildingA).expect(t, http.StatusOK).decode(t, &report)
+	if len(report.Errors) != 1 || report.Errors[0].Row != 0 {
+		t.Errorf("damaged workbook errors = %+v", report.Errors)
+	}
+}
+
+// xlsx builds a minimal workbook: the header row as shared strings, the
+// rest as inline strings and numbers, as spreadsheet programs write them
+func xlsx(t *testing.T, rows [][]string) string {
+	t.Helper()
+	var sheet, shared strings.Builder
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, v := range row {
+			ref := fmt.Sprintf("%c%d", 'A'+j, i+1)
+			switch {
+			case i == 0:
+				fmt.Fprintf(&sheet, `<c r="%s" t="s"><v>%d</v></c>`, ref, j)
+				fmt.Fprintf(&shared, `<si><t>%s</t></si>`, v)
+			case v == "":
+			case strings.Trim(v, "0123456789") == "":
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, v)
+			default:
+				fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, v)
+			}
+		}
+		sheet.WriteString(`</row>`)
+	}
+
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Units" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":     `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + shared.String() + `</sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheet.String() + `</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
@@ -4,20 +4,27 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
 
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
 	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
-	postgrest "github.com/supabase-community/postgrest-go"
-	supabase "github.com/supabase-community/supabase-go"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/phone"
+	"github.com/aletheia/backend/internal/store"
 )
 
 type InvitationsHandler struct {
-	client *supabase.Client
+	store    *store.Store
+	notifier *notify.Notifier
+	audit    *audit.Logger
 }
 
-func NewInvitationsHandler(client *supabase.Client) *InvitationsHandler {
-	return &InvitationsHandler{client: client}
+func NewInvitationsHandler(st *store.Store, notifier *notify.Notifier, auditLog *audit.Logger) *InvitationsHandler {
+	return &InvitationsHandler{store: st, notifier: notifier, audit: auditLog}
 }
 
 // SendInvite sends an invitation to a tenant for a specific unit
@@ -35,62 +42,87 @@ func (h *InvitationsHandler) SendInvite(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Verify the unit belongs to a building owned by this landlord
-	uData, _, err := h.client.From("units").Select("*, buildings!inner(landlord_id)", "exact", false).Eq("id", req.UnitID).Execute()
-	if err != nil {
+	if req.Phone != "" {
+		normalized, err := phone.NormalizeNG(req.Phone)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Phone = normalized
+	}
+
+	// Verify the unit is in a building the user may invite tenants to
+	unit, err := h.store.Units.GetUnit(req.UnitID)
+	if err != nil && err != store.ErrNotFound {
 		respondError(w, http.StatusInternalServerError, "Failed to verify unit")
 		return
 	}
 
-	var units []struct {
-		models.Unit
-		Buildings struct {
-			LandlordID string `json:"landlord_id"`
-		} `json:"buildings"`
+	if err == store.ErrNotFound || !middleware.Can(r, unit.BuildingID, access.InviteTenants) {
+		respondError(w, http.StatusForbidden, "Unit not found or not in your building")
+		return
 	}
-	json.Unmarshal(uData, &units)
 
-	if len(units) == 0 || units[0].Buildings.LandlordID != userID {
-		respondError(w, http.StatusForbidden, "Unit not found or not in your building")
+	building, err := h.store.Buildings.GetBuilding(unit.BuildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify unit")
 		return
 	}
 
-	if units[0].Status == "occupied" {
+	if unit.Status == "occupied" {
 		respondError(w, http.StatusBadRequest, "Unit is already occupied")
 		return
 	}
 
-	// Generate unique invite token
-	token := generateToken()
-
-	invite := map[string]interface{}{
-		"unit_id":     req.UnitID,
-		"landlord_id": userID,
-		"email":       req.Email,
-		"phone":       req.Phone,
-		"token":       token,
-		"status":      "pending",
+	if unit.ArchivedAt != nil {
+		respondError(w, http.StatusConflict, "Unit is archived")
+		return
 	}
 
-	data, _, err := h.client.From("invitations").Insert(invite, false, "", "", "").Execute()
+	created, err := inviteTenant(h.store, h.notifier, building, unit, userID, req.Email, req.Phone)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to create invitation: "+err.Error())
 		return
 	}
 
-	var created []models.Invitation
-	json.Unmarshal(data, &created)
-
-	// TODO: Send email via Resend and/or SMS via Termii with the invite link
-	// The invite link would be: APP_URL/invite?token=<token>
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "invitation.create",
+		ResourceType: "invitation",
+		ResourceID:   created.ID,
+		BuildingID:   unit.BuildingID,
+		After:        created,
+	})
 
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
-		Data:    created[0],
+		Data:    created,
 		Message: "Invitation sent successfully",
 	})
 }
 
+// inviteTenant creates a pending invitation to unit and sends it by email
+// and/or SMS. Failing to send is logged, not returned: the invitation
+// stands and can be sent again.
+func inviteTenant(st *store.Store, notifier *notify.Notifier, building models.Building, unit models.Unit, invitedBy, email, phone string) (models.Invitation, error) {
+	created, err := st.Invitations.CreateInvitation(models.Invitation{
+		UnitID:     unit.ID,
+		LandlordID: building.LandlordID,
+		InvitedBy:  &invitedBy,
+		Email:      &email,
+		Phone:      &phone,
+		Token:      generateToken(),
+		Status:     "pending",
+	})
+	if err != nil {
+		return created, err
+	}
+
+	if err := sendTenantInvite(notifier, created, building.Name, unit.UnitNumber); err != nil {
+		log.Printf("invitation %s: notification not sent: %v", created.ID, err)
+	}
+	return created, nil
+}
+
 // GetInviteByToken retrieves invitation details for the acceptance page (public)
 func (h *InvitationsHandler) GetInviteByToken(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
@@ -99,45 +131,150 @@ func (h *InvitationsHandler) GetInviteByToken(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	data, _, err := h.client.From("invitations").Select("*, units(unit_number, rent_amount), buildings:units(buildings(name, address, photo_url))", "exact", false).Eq("token", token).Eq("status", "pending").Execute()
-	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch invitation")
+	invitation, err := h.store.Invitations.GetInvitationDetails(token)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
 		return
 	}
-
-	var invitations []json.RawMessage
-	json.Unmarshal(data, &invitations)
-
-	if len(invitations) == 0 {
-		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch invitation")
 		return
 	}
 
 	respondJSON(w, http.StatusOK, models.APIResponse{
 		Success: true,
-		Data:    invitations[0],
+		Data:    invitation,
 	})
 }
 
-// ListInvitations returns all invitations for a landlord
+var invitationList = listSpec{
+	sorts: []string{"-created_at", "expires_at"},
+	filters: []listFilter{
+		idFilter("unit_id"),
+		setFilter("status", "pending", "accepted", "expired"),
+		dateRange("created_at", "from", "to"),
+	},
+}
+
+// ListInvitations returns a page of the invitations for buildings the user
+// can invite tenants to
 func (h *InvitationsHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
+	lq, err := parseList(r, invitationList)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	data, _, err := h.client.From("invitations").Select("*, units(unit_number, building_id)", "exact", false).Eq("landlord_id", userID).Order("created_at", &postgrest.OrderOpts{Ascending: false}).Execute()
+	ids := middleware.BuildingsWith(r, access.InviteTenants)
+	invitations, err := h.store.Invitations.ListInvitations(ids, lq.opts)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch invitations")
 		return
 	}
 
-	var invitations []json.RawMessage
-	json.Unmarshal(data, &invitations)
+	respondPage(w, lq, invitations)
+}
+
+// ClaimInvite accepts a tenant invitation for the logged-in account instead
+// of creating a new one, adding the tenant role if the account lacks it. The
+// account's email or phone must match the one the invite was sent to.
+func (h *InvitationsHandler) ClaimInvite(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.ClaimInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" {
+		respondError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	invite, err := h.store.Invitations.GetPendingInvitation(req.Token)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to lookup invitation")
+		return
+	}
+
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	emailMatch := invite.Email != nil && *invite.Email != "" && strings.EqualFold(*invite.Email, profile.Email)
+	phoneMatch := false
+	if invite.Phone != nil && profile.Phone != nil {
+		invitedPhone, _ := phone.NormalizeNG(*invite.Phone)
+		phoneMatch = invitedPhone != "" && invitedPhone == *profile.Phone
+	}
+	if !emailMatch && !phoneMatch {
+		respondError(w, http.StatusForbidden, "This invitation was sent to a different email or phone number")
+		return
+	}
+
+	accepted, err := h.store.Invitations.AcceptInvitation(store.Acceptance{Token: invite.Token, TenantID: userID})
+	if err != nil {
+		respondAcceptError(w, err)
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "invitation.accept",
+		ResourceType: "invitation",
+		ResourceID:   invite.ID,
+		BuildingID:   accepted.BuildingID,
+		Before:       map[string]interface{}{"status": invite.Status},
+		After:        map[string]interface{}{"status": "accepted", "tenant_id": userID, "tenancy_id": accepted.TenancyID},
+		Metadata:     map[string]interface{}{"unit_id": invite.UnitID},
+	})
 
 	respondJSON(w, http.StatusOK, models.APIResponse{
 		Success: true,
-		Data:    invitations,
+		Data:    map[string]interface{}{"unit_id": accepted.UnitID, "tenancy_id": accepted.TenancyID, "roles": accepted.Roles},
+		Message: "Invitation accepted. Act as tenant to see your tenancy.",
 	})
 }
 
+// respondAcceptError maps AcceptInvitation errors to responses
+func respondAcceptError(w http.ResponseWriter, err error) {
+	switch err {
+	case store.ErrInvitationUnavailable:
+		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
+	case store.ErrUnitOccupied:
+		respondError(w, http.StatusConflict, "This unit already has a tenant")
+	default:
+		respondError(w, http.StatusInternalServerError, "Failed to accept invitation")
+	}
+}
+
+// sendTenantInvite delivers the invite link by email and/or SMS, whichever
+// the invitation has. It returns the first delivery error.
+func sendTenantInvite(n *notify.Notifier, invite models.Invitation, buildingName, unitNumber string) error {
+	link := n.AppURL() + "/invite?token=" + invite.Token
+
+	var firstErr error
+	if invite.Email != nil && *invite.Email != "" {
+		firstErr = n.Email("", *invite.Email, notify.TenantInviteEmail(buildingName, unitNumber, link))
+	}
+	if invite.Phone != nil && *invite.Phone != "" {
+		if err := n.SMS("", *invite.Phone, notify.TenantInviteSMS(buildingName, unitNumber, link)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 func generateToken() string {
 	bytes := make([]byte, 16)
 	rand.Read(bytes)
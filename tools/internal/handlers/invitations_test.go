@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestSendInvite(t *testing.T) {
+	f := newFixture(t)
+	h := NewInvitationsHandler(f.st, f.notify, nil)
+	manager := asStaff(access.RoleManager)
+
+	call(t, h.SendInvite, manager, "POST", "/api/v1/invitations", models.SendInviteRequest{UnitID: vacantUnit}).expect(t, http.StatusBadRequest)
+	call(t, h.SendInvite, manager, "POST", "/api/v1/invitations", models.SendInviteRequest{UnitID: vacantUnit, Phone: "12345"}).expect(t, http.StatusBadRequest)
+	call(t, h.SendInvite, manager, "POST", "/api/v1/invitations", models.SendInviteRequest{UnitID: occupiedUnit, Email: "new@example.com"}).expect(t, http.StatusBadRequest)
+	call(t, h.SendInvite, manager, "POST", "/api/v1/invitations", models.SendInviteRequest{UnitID: otherUnit, Email: "new@example.com"}).expect(t, http.StatusForbidden)
+	call(t, h.SendInvite, asStaff(access.RoleCaretaker), "POST", "/api/v1/invitations", models.SendInviteRequest{UnitID: vacantUnit, Email: "new@example.com"}).expect(t, http.StatusForbidden)
+
+	var inv models.Invitation
+	call(t, h.SendInvite, manager, "POST", "/api/v1/invitations", models.SendInviteRequest{UnitID: vacantUnit, Email: "new@example.com", Phone: "08031234567"}).expect(t, http.StatusCreated).decode(t, &inv)
+	if inv.Status != "pending" || inv.Token == "" || inv.LandlordID != landlordID || inv.InvitedBy == nil || *inv.InvitedBy != staffID {
+		t.Fatalf("invitation = %+v", inv)
+	}
+	if inv.Phone == nil || *inv.Phone != "+2348031234567" {
+		t.Errorf("phone = %v, want it normalised", inv.Phone)
+	}
+
+	link := "https://app.example.com/invite?token=" + inv.Token
+	for _, to := range []string{"new@example.com", "+2348031234567"} {
+		sent := f.outbox.sentTo(to)
+		if len(sent) != 1 || !strings.Contains(sent[0], link) {
+			t.Errorf("sent to %s: %q, want the invite link", to, sent)
+		}
+	}
+}
+
+func TestGetInviteByToken(t *testing.T) {
+	f := newFixture(t)
+	h := NewInvitationsHandler(f.st, f.notify, nil)
+	email := "new@example.com"
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Email: &email, Token: "tok-pending", Status: "pending"})
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Email: &email, Token: "tok-used", Status: "accepted"})
+
+	call(t, h.GetInviteByToken, caller{}, "GET", "/api/v1/invitations/verify", nil).expect(t, http.StatusBadRequest)
+	call(t, h.GetInviteByToken, caller{}, "GET", "/api/v1/invitations/verify?token=tok-used", nil).expect(t, http.StatusNotFound)
+	call(t, h.GetInviteByToken, caller{}, "GET", "/api/v1/invitations/verify?token=nope", nil).expect(t, http.StatusNotFound)
+
+	var details store.InvitationDetails
+	call(t, h.GetInviteByToken, caller{}, "GET", "/api/v1/invitations/verify?token=tok-pending", nil).expect(t, http.StatusOK).decode(t, &details)
+	if details.Unit == nil || details.Unit.UnitNumber != "A2" || details.Unit.RentAmount != 50_000_00 {
+		t.Errorf("unit = %+v", details.Unit)
+	}
+	if details.Buildings == nil || details.Buildings.Building == nil || details.Buildings.Building.Name != "Palm Court" {
+		t.Errorf("building = %+v", details.Buildings)
+	}
+}
+
+func TestListInvitations(t *testing.T) {
+	f := newFixture(t)
+	h := NewInvitationsHandler(f.st, f.notify, nil)
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Token: "a", Status: "pending"})
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: otherUnit, LandlordID: otherLandlordID, Token: "b", Status: "pending"})
+
+	var invitations []store.InvitationListing
+	call(t, h.ListInvitations, asLandlord, "GET", "/api/v1/invitations", nil).expect(t, http.StatusOK).decode(t, &invitations)
+	if len(invitations) != 1 || invitations[0].Token != "a" || invitations[0].Unit == nil || invitations[0].Unit.BuildingID != buildingA {
+		t.Fatalf("invitations = %+v", invitations)
+	}
+
+	call(t, h.ListInvitations, asNewLandlord, "GET", "/api/v1/invitations", nil).expect(t, http.StatusOK).decode(t, &invitations)
+	if len(invitations) != 0 {
+		t.Errorf("landlord without buildings sees %d invitations", len(invitations))
+	}
+}
+
+func TestClaimInvite(t *testing.T) {
+	f := newFixture(t)
+	h := NewInvitationsHandler(f.st, f.notify, nil)
+	invitedEmail := "BOLA@example.com"
+	otherEmail := "someone@example.com"
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Email: &invitedEmail, Token: "for-bola", Status: "pending"})
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Email: &otherEmail, Token: "for-someone", Status: "pending"})
+	bola := caller{id: otherLandlordID, role: "landlord", grants: access.Grants{}}
+
+	call(t, h.ClaimInvite, bola, "POST", "/api/v1/invitations/accept", models.ClaimInviteRequest{}).expect(t, http.StatusBadRequest)
+	call(t, h.ClaimInvite, bola, "POST", "/api/v1/invitations/accept", models.ClaimInviteRequest{Token: "for-someone"}).expect(t, http.StatusForbidden)
+
+	var out struct {
+		UnitID    string   `json:"unit_id"`
+		TenancyID string   `json:"tenancy_id"`
+		Roles     []string `json:"roles"`
+	}
+	call(t, h.ClaimInvite, bola, "POST", "/api/v1/invitations/accept", models.ClaimInviteRequest{Token: "for-bola"}).expect(t, http.StatusOK).decode(t, &out)
+	if out.UnitID != vacantUnit || !slices.Equal(out.Roles, []string{"landlord", "tenant"}) {
+		t.Fatalf("claim = %+v", out)
+	}
+	tenancy, err := f.st.Tenancies.ActiveTenancy(vacantUnit)
+	if err != nil || tenancy.ID != out.TenancyID || tenancy.TenantID != otherLandlordID || tenancy.RentAmount != 50_000_00 {
+		t.Errorf("tenancy = %+v, %v; want one started on the unit's rent", tenancy, err)
+	}
+
+	unit, _ := f.st.Units.GetUnit(vacantUnit)
+	if unit.Status != "occupied" || unit.TenantID == nil || *unit.TenantID != otherLandlordID {
+		t.Errorf("unit = %+v", unit)
+	}
+	profile, _ := f.st.Profiles.GetProfile(otherLandlordID)
+	if profile.Role != "landlord" || !slices.Contains(profile.Roles, "tenant") {
+		t.Errorf("profile roles = %q %v, want tenant added and landlord kept as default", profile.Role, profile.Roles)
+	}
+
+	// An invitation is only ever accepted once
+	call(t, h.ClaimInvite, bola, "POST", "/api/v1/invitations/accept", models.ClaimInviteRequest{Token: "for-bola"}).expect(t, http.StatusNotFound)
+}
+
+func TestClaimInviteForOccupiedUnit(t *testing.T) {
+	f := newFixture(t)
+	h := NewInvitationsHandler(f.st, f.notify, nil)
+	email := "bola@example.com"
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: occupiedUnit, LandlordID: landlordID, Email: &email, Token: "stale", Status: "pending"})
+	bola := caller{id: otherLandlordID, role: "landlord", grants: access.Grants{}}
+
+	call(t, h.ClaimInvite, bola, "POST", "/api/v1/invitations/accept", models.ClaimInviteRequest{Token: "stale"}).expect(t, http.StatusConflict)
+
+	unit, _ := f.st.Units.GetUnit(occupiedUnit)
+	if unit.TenantID == nil || *unit.TenantID != tenantID {
+		t.Errorf("unit tenant = %v, want the existing tenant kept", unit.TenantID)
+	}
+	profile, _ := f.st.Profiles.GetProfile(otherLandlordID)
+	if slices.Contains(profile.Roles, "tenant") {
+		t.Errorf("roles = %v, want nothing granted", profile.Roles)
+	}
+	if _, err := f.st.Invitations.GetPendingInvitation("stale"); err != nil {
+		t.Errorf("invitation no longer pending: %v", err)
+	}
+}
+
+func TestAcceptInvitationOnce(t *testing.T) {
+	f := newFixture(t)
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Token: "first", Status: "pending"})
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Token: "second", Status: "pending"})
+	mustCreate(t, f.mem.CreateInvitation, models.Invitation{UnitID: vacantUnit, LandlordID: landlordID, Token: "expired", Status: "pending", ExpiresAt: time.Now().Add(-time.Hour)})
+
+	if _, err := f.st.Invitations.AcceptInvitation(store.Acceptance{Token: "expired", TenantID: landlordID}); err != store.ErrInvitationUnavailable {
+		t.Errorf("expired invitation: %v", err)
+	}
+
+	// Racing acceptances of the same invitation and of another one for the
+	// same unit: exactly one wins
+	tokens := []string{"first", "first", "second", "second"}
+	errs := make([]error, len(tokens))
+	var wg sync.WaitGroup
+	for i, token := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			newProfile := &models.Profile{FullName: "Racer"}
+			_, errs[i] = f.st.Invitations.AcceptInvitation(store.Acceptance{Token: token, TenantID: fmt.Sprintf("10000000-0000-0000-0000-0000000001%02d", i), NewProfile: newProfile})
+		}()
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch err {
+		case nil:
+			won++
+		case store.ErrInvitationUnavailable, store.ErrUnitOccupied:
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d acceptances succeeded, want 1", won)
+	}
+	for _, token := range []string{"first", "second"} {
+		if _, err := f.st.Invitations.GetPendingInvitation(token); err != store.ErrNotFound {
+			t.Errorf("%s still pending", token)
+		}
+	}
+}
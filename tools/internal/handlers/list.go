@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
+)
+
+// listSpec is what a list endpoint accepts on top of paging: the columns
+// ?sort= may name (the first is the default) and its typed filters.
+//
+// Every list takes ?page=&per_page= (default 1 and 50, at most 200) or
+// ?after=<next_cursor from the previous page>, and ?sort=column or
+// ?sort=-column for descending.
+type listSpec struct {
+	sorts   []string
+	filters []listFilter
+}
+
+type filterKind int
+
+const (
+	filterID       filterKind = iota // ?param=<uuid>
+	filterSet                        // ?param=a,b — any of the allowed values
+	filterDates                      // ?from=&to= — YYYY-MM-DD, both inclusive
+	filterAmount                     // ?min=&max= — whole kobo, both inclusive
+	filterArchived                   // ?archived=false (the default), true or all
+)
+
+type listFilter struct {
+	column  string
+	kind    filterKind
+	params  []string // the query parameter, or the lower and upper bound for ranges
+	allowed []string
+}
+
+func idFilter(column string) listFilter {
+	return listFilter{column: column, kind: filterID, params: []string{column}}
+}
+
+func setFilter(column string, allowed ...string) listFilter {
+	return listFilter{column: column, kind: filterSet, params: []string{column}, allowed: allowed}
+}
+
+func dateRange(column, from, to string) listFilter {
+	return listFilter{column: column, kind: filterDates, params: []string{from, to}}
+}
+
+func amountRange(column, min, max string) listFilter {
+	return listFilter{column: column, kind: filterAmount, params: []string{min, max}}
+}
+
+// archivedFilter hides archived rows unless ?archived=true (only archived
+// ones) or ?archived=all
+func archivedFilter() listFilter {
+	return listFilter{column: "archived_at", kind: filterArchived, params: []string{"archived"}}
+}
+
+// listQuery is a parsed list request
+type listQuery struct {
+	opts store.ListOptions
+	sort string // as given in ?sort=, carried in cursors
+}
+
+// cursor is the opaque ?after= value: a store.Cursor plus the sort it was
+// made for, since a cursor means nothing in another order
+type cursor struct {
+	Sort string `json:"s"`
+	store.Cursor
+}
+
+// parseList reads paging, sorting and filters from the query string. Its
+// errors are meant for the client.
+func parseList(r *http.Request, spec listSpec) (listQuery, error) {
+	q := r.URL.Query()
+	lq := listQuery{sort: q.Get("sort")}
+
+	if lq.sort == "" {
+		lq.sort = spec.sorts[0]
+	}
+	column, desc := strings.CutPrefix(lq.sort, "-")
+	if !slices.Contains(spec.sorts, column) && !slices.Contains(spec.sorts, "-"+column) {
+		return lq, fmt.Errorf("sort must be one of %s (prefix - for descending)", strings.Join(sortColumns(spec.sorts), ", "))
+	}
+	lq.opts.Sort = store.Sort{Column: column, Desc: desc}
+
+	lq.opts.PerPage = store.DefaultPerPage
+	if v := q.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > store.MaxPerPage {
+			return lq, fmt.Errorf("per_page must be between 1 and %d", store.MaxPerPage)
+		}
+		lq.opts.PerPage = n
+	}
+
+	if v := q.Get("after"); v != "" {
+		if q.Get("page") != "" {
+			return lq, fmt.Errorf("use either page or after, not both")
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		var c cursor
+		if err != nil || json.Unmarshal(raw, &c) != nil || c.ID == "" {
+			return lq, fmt.Errorf("after is not a valid cursor")
+		}
+		if c.Sort != lq.sort {
+			return lq, fmt.Errorf("after was made for sort=%s", c.Sort)
+		}
+		lq.opts.After = &c.Cursor
+	} else {
+		lq.opts.Page = 1
+		if v := q.Get("page"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return lq, fmt.Errorf("page must be 1 or more")
+			}
+			lq.opts.Page = n
+		}
+	}
+
+	for _, f := range spec.filters {
+		filters, err := f.parse(q.Get)
+		if err != nil {
+			return lq, err
+		}
+		lq.opts.Filters = append(lq.opts.Filters, filters...)
+	}
+	return lq, nil
+}
+
+func (f listFilter) parse(get func(string) string) ([]store.Filter, error) {
+	switch f.kind {
+	case filterID:
+		v := get(f.params[0])
+		if v == "" {
+			return nil, nil
+		}
+		if _, err := uuid.Parse(v); err != nil {
+			return nil, fmt.Errorf("%s must be an ID", f.params[0])
+		}
+		return []store.Filter{{Column: f.column, Op: store.OpEq, Value: v}}, nil
+
+	case filterSet:
+		v := get(f.params[0])
+		if v == "" {
+			return nil, nil
+		}
+		values := strings.Split(v, ",")
+		for _, s := range values {
+			if !slices.Contains(f.allowed, s) {
+				return nil, fmt.Errorf("%s must be any of %s, comma-separated", f.params[0], strings.Join(f.allowed, ", "))
+			}
+		}
+		return []store.Filter{{Column: f.column, Op: store.OpIn, Value: values}}, nil
+
+	case filterDates:
+		var out []store.Filter
+		for i, op := range []string{store.OpGte, store.OpLt} {
+			v := get(f.params[i])
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD)", f.params[i])
+			}
+			if op == store.OpLt {
+				// to is inclusive: everything before the next day
+				t = t.AddDate(0, 0, 1)
+			}
+			out = append(out, store.Filter{Column: f.column, Op: op, Value: t.Format(time.RFC3339)})
+		}
+		return out, nil
+
+	case filterAmount:
+		var out []store.Filter
+		for i, op := range []string{store.OpGte, store.OpLte} {
+			v := get(f.params[i])
+			if v == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s must be a whole amount in kobo", f.params[i])
+			}
+			out = append(out, store.Filter{Column: f.column, Op: op, Value: n})
+		}
+		return out, nil
+
+	case filterArchived:
+		switch get(f.params[0]) {
+		case "", "false":
+			return []store.Filter{{Column: f.column, Op: store.OpNull, Value: true}}, nil
+		case "true":
+			return []store.Filter{{Column: f.column, Op: store.OpNull, Value: false}}, nil
+		case "all":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s must be true, false or all", f.params[0])
+	}
+	return nil, nil
+}
+
+func sortColumns(sorts []string) []string {
+	out := make([]string, len(sorts))
+	for i, s := range sorts {
+		out[i] = strings.TrimPrefix(s, "-")
+	}
+	return out
+}
+
+// respondPage writes a page of a list as a PaginatedResponse. page is 0
+// when paging by cursor; next_cursor is set while more rows follow.
+func respondPage[T any](w http.ResponseWriter, lq listQuery, page store.Page[T]) {
+	perPage := lq.opts.PerPage
+	res := models.PaginatedResponse{
+		Success:    true,
+		Data:       page.Items,
+		Total:      page.Total,
+		Page:       lq.opts.Page,
+		PerPage:    perPage,
+		TotalPages: (page.Total + perPage - 1) / perPage,
+	}
+	if page.Next != nil {
+		raw, _ := json.Marshal(cursor{Sort: lq.sort, Cursor: *page.Next})
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	respondJSON(w, http.StatusOK, res)
+}
+
+// allPages walks a store list to the end. It is for the few callers that
+// need every row (totals, statements), not for serving a list endpoint.
+func allPages[T any](list func(store.ListOptions) (store.Page[T], error), opts store.ListOptions) ([]T, error) {
+	all := []T{}
+	opts.PerPage = store.MaxPerPage
+	for {
+		p, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, p.Items...)
+		if p.Next == nil {
+			return all, nil
+		}
+		opts.After = p.Next
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestListPaging(t *testing.T) {
+	f := newFixture(t)
+	h := NewPaymentsHandler(f.st, nil, nil)
+	for i, status := range []string{"successful", "pending", "successful", "failed", "successful"} {
+		mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: int64(i+1) * 100, Status: status, Period: fmt.Sprintf("M%d", i+1)})
+	}
+
+	list := func(query string) ([]store.PaymentListing, response) {
+		t.Helper()
+		var payments []store.PaymentListing
+		res := call(t, h.ListPayments, asLandlord, "GET", "/api/v1/payments"+query, nil).expect(t, http.StatusOK)
+		res.decode(t, &payments)
+		return payments, res
+	}
+	amounts := func(payments []store.PaymentListing) []int64 {
+		out := make([]int64, len(payments))
+		for i, p := range payments {
+			out[i] = p.Amount
+		}
+		return out
+	}
+
+	got, res := list("?sort=amount&per_page=2&page=2")
+	if fmt.Sprint(amounts(got)) != "[300 400]" || res.Total != 5 || res.Page != 2 || res.TotalPages != 3 {
+		t.Errorf("page 2 = %v, total %d, page %d of %d", amounts(got), res.Total, res.Page, res.TotalPages)
+	}
+
+	// Following cursors visits every row once, in order
+	var seen []int64
+	query := "?sort=-amount&per_page=2"
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("cursor never ran out")
+		}
+		got, res := list(query)
+		if res.Total != 5 {
+			t.Errorf("total = %d on every page, want 5", res.Total)
+		}
+		seen = append(seen, amounts(got)...)
+		if res.NextCursor == "" {
+			break
+		}
+		query = "?sort=-amount&per_page=2&after=" + res.NextCursor
+	}
+	if fmt.Sprint(seen) != "[500 400 300 200 100]" {
+		t.Errorf("cursor pages = %v", seen)
+	}
+
+	if got, res := list("?status=successful,failed&min_amount=200&max_amount=400&sort=amount"); fmt.Sprint(amounts(got)) != "[300 400]" || res.Total != 2 {
+		t.Errorf("filters = %v (total %d)", amounts(got), res.Total)
+	}
+	if got, _ := list("?from=2000-01-01&to=2000-12-31"); len(got) != 0 {
+		t.Errorf("date range kept %d payments", len(got))
+	}
+
+	_, first := list("?per_page=2")
+	for _, query := range []string{
+		"?sort=tenant_id",
+		"?per_page=0",
+		"?per_page=201",
+		"?page=0",
+		"?status=refunded",
+		"?min_amount=ten",
+		"?from=01/02/2026",
+		"?building_id=palm-court",
+		"?after=not-a-cursor",
+		"?page=2&after=" + first.NextCursor,
+		"?sort=amount&after=" + first.NextCursor,
+	} {
+		call(t, h.ListPayments, asLandlord, "GET", "/api/v1/payments"+query, nil).expect(t, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/lockout"
+	"github.com/aletheia/backend/internal/ratelimit"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// Progressive login lockout: failed passwords are counted per email by the
+// lockout package, which locks the email after lockout.Threshold in a row.
+// Callers pass the key from lockoutKey, never the email itself.
+
+// lockedFor returns how long the email behind key is still locked out, or 0
+func lockedFor(lockouts store.LockoutStore, key string) (time.Duration, error) {
+	return lockout.LockedFor(lockouts, key)
+}
+
+// recordLoginFailure counts a failed password and returns the lockout it
+// triggers, or 0 while the email is still under the threshold
+func recordLoginFailure(lockouts store.LockoutStore, key string) (time.Duration, error) {
+	return lockout.RecordFailure(lockouts, key)
+}
+
+// clearLoginFailures resets the count after a successful password
+func clearLoginFailures(lockouts store.LockoutStore, key string) {
+	lockout.Clear(lockouts, key)
+}
+
+// respondLockedOut answers a login for a locked email
+func respondLockedOut(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+	respondError(w, http.StatusTooManyRequests, "Too many failed login attempts, please try again later")
+}
+
+// lockoutKey is the login_lockouts key for an email: a blind index (HMAC
+// under the OTP secret) of the lower-cased address, so the table and the
+// audit entries that quote the key never hold the email in plain text
+func lockoutKey(secret []byte, email string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("lockout:" + strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -4,18 +4,20 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
 	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
-	postgrest "github.com/supabase-community/postgrest-go"
-	supabase "github.com/supabase-community/supabase-go"
+	"github.com/aletheia/backend/internal/store"
 )
 
 type MaintenanceHandler struct {
-	client *supabase.Client
+	store *store.Store
+	audit *audit.Logger
 }
 
-func NewMaintenanceHandler(client *supabase.Client) *MaintenanceHandler {
-	return &MaintenanceHandler{client: client}
+func NewMaintenanceHandler(st *store.Store, auditLog *audit.Logger) *MaintenanceHandler {
+	return &MaintenanceHandler{store: st, audit: auditLog}
 }
 
 // CreateRequest allows a tenant to submit a maintenance request
@@ -33,20 +35,14 @@ func (h *MaintenanceHandler) CreateRequest(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Get tenant's unit
-	uData, _, err := h.client.From("units").Select("id, building_id", "exact", false).Eq("tenant_id", userID).Execute()
+	// The request is for the unit of the tenant's current tenancy
+	tenancy, ok, err := currentTenancy(h.store, userID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to find your unit")
 		return
 	}
 
-	var units []struct {
-		ID         string `json:"id"`
-		BuildingID string `json:"building_id"`
-	}
-	json.Unmarshal(uData, &units)
-
-	if len(units) == 0 {
+	if !ok {
 		respondError(w, http.StatusNotFound, "No unit assigned to your account")
 		return
 	}
@@ -56,76 +52,80 @@ func (h *MaintenanceHandler) CreateRequest(w http.ResponseWriter, r *http.Reques
 		priority = "medium"
 	}
 
-	mReq := map[string]interface{}{
-		"tenant_id":   userID,
-		"unit_id":     units[0].ID,
-		"building_id": units[0].BuildingID,
-		"title":       req.Title,
-		"description": req.Description,
-		"priority":    priority,
-		"status":      "open",
+	mReq := models.MaintenanceRequest{
+		TenantID:    userID,
+		TenancyID:   &tenancy.ID,
+		UnitID:      tenancy.UnitID,
+		BuildingID:  tenancy.BuildingID,
+		Title:       req.Title,
+		Description: req.Description,
+		Priority:    priority,
+		Status:      "open",
 	}
 
-	data, _, err := h.client.From("maintenance_requests").Insert(mReq, false, "", "", "").Execute()
+	created, err := h.store.Maintenance.CreateMaintenanceRequest(mReq)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to create request: "+err.Error())
 		return
 	}
 
-	var created []models.MaintenanceRequest
-	json.Unmarshal(data, &created)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "maintenance.create",
+		ResourceType: "maintenance_request",
+		ResourceID:   created.ID,
+		BuildingID:   tenancy.BuildingID,
+		After:        created,
+	})
 
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
-		Data:    created[0],
+		Data:    created,
 		Message: "Maintenance request submitted",
 	})
 }
 
-// ListRequests returns maintenance requests (scoped by role)
+var maintenanceList = listSpec{
+	sorts: []string{"-created_at", "updated_at"},
+	filters: []listFilter{
+		idFilter("building_id"),
+		idFilter("unit_id"),
+		idFilter("tenancy_id"),
+		setFilter("status", "open", "in_progress", "resolved", "closed"),
+		setFilter("priority", "low", "medium", "high", "urgent"),
+		dateRange("created_at", "from", "to"),
+	},
+}
+
+// ListRequests returns a page of maintenance requests (scoped by role)
 func (h *MaintenanceHandler) ListRequests(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 	userRole := middleware.GetUserRole(r)
 
-	query := h.client.From("maintenance_requests").Select("*, profiles!maintenance_requests_tenant_id_fkey(full_name), units(unit_number), buildings(name)", "exact", false).Order("created_at", &postgrest.OrderOpts{Ascending: false})
+	lq, err := parseList(r, maintenanceList)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	var filter store.MaintenanceFilter
 	if userRole == "tenant" {
-		query = query.Eq("tenant_id", userID)
+		filter.TenantID = userID
 	} else {
-		// Landlord: get requests for their buildings
-		bData, _, _ := h.client.From("buildings").Select("id", "exact", false).Eq("landlord_id", userID).Execute()
-		var bIDs []struct {
-			ID string `json:"id"`
-		}
-		json.Unmarshal(bData, &bIDs)
-
-		if len(bIDs) > 0 {
-			ids := make([]string, len(bIDs))
-			for i, b := range bIDs {
-				ids[i] = b.ID
-			}
-			query = query.In("building_id", ids)
-		}
-	}
-
-	data, _, err := query.Execute()
+		// Landlord / staff: requests for buildings they maintain
+		filter.BuildingIDs = middleware.BuildingsWith(r, access.ManageMaintenance)
+	}
+
+	requests, err := h.store.Maintenance.ListMaintenanceRequests(filter, lq.opts)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch requests")
 		return
 	}
 
-	var requests []json.RawMessage
-	json.Unmarshal(data, &requests)
-
-	respondJSON(w, http.StatusOK, models.APIResponse{
-		Success: true,
-		Data:    requests,
-	})
+	respondPage(w, lq, requests)
 }
 
-// UpdateRequestStatus allows a landlord to update a request status
+// UpdateRequestStatus allows a landlord or building staff to update a request status
 func (h *MaintenanceHandler) UpdateRequestStatus(w http.ResponseWriter, r *http.Request) {
-	userID := middleware.GetUserID(r)
 	reqID := getPathParam(r, "id")
 
 	var req models.UpdateMaintenanceStatusRequest
@@ -134,25 +134,18 @@ func (h *MaintenanceHandler) UpdateRequestStatus(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Verify the request is for a building the landlord owns
-	mData, _, _ := h.client.From("maintenance_requests").Select("building_id", "exact", false).Eq("id", reqID).Execute()
-	var mReqs []struct {
-		BuildingID string `json:"building_id"`
-	}
-	json.Unmarshal(mData, &mReqs)
-
-	if len(mReqs) == 0 {
+	// Verify the request is for a building the user maintains
+	before, err := h.store.Maintenance.GetMaintenanceRequest(reqID)
+	if err == store.ErrNotFound {
 		respondError(w, http.StatusNotFound, "Request not found")
 		return
 	}
-
-	bData, _, _ := h.client.From("buildings").Select("id", "exact", false).Eq("id", mReqs[0].BuildingID).Eq("landlord_id", userID).Execute()
-	var bCheck []struct {
-		ID string `json:"id"`
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch request")
+		return
 	}
-	json.Unmarshal(bData, &bCheck)
 
-	if len(bCheck) == 0 {
+	if !middleware.Can(r, before.BuildingID, access.ManageMaintenance) {
 		respondError(w, http.StatusForbidden, "Not your building")
 		return
 	}
@@ -161,18 +154,24 @@ func (h *MaintenanceHandler) UpdateRequestStatus(w http.ResponseWriter, r *http.
 		"status": req.Status,
 	}
 
-	data, _, err := h.client.From("maintenance_requests").Update(update, "", "").Eq("id", reqID).Execute()
+	updated, err := h.store.Maintenance.UpdateMaintenanceRequest(reqID, update)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to update request")
 		return
 	}
 
-	var updated []models.MaintenanceRequest
-	json.Unmarshal(data, &updated)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "maintenance.status.update",
+		ResourceType: "maintenance_request",
+		ResourceID:   reqID,
+		BuildingID:   before.BuildingID,
+		Before:       map[string]interface{}{"status": before.Status},
+		After:        map[string]interface{}{"status": req.Status},
+	})
 
 	respondJSON(w, http.StatusOK, models.APIResponse{
 		Success: true,
-		Data:    updated[0],
+		Data:    updated,
 		Message: "Request status updated",
 	})
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestCreateMaintenanceRequest(t *testing.T) {
+	f := newFixture(t)
+	h := NewMaintenanceHandler(f.st, nil)
+	leak := models.CreateMaintenanceRequest{Title: "Leaking tap", Description: "Kitchen tap drips all night"}
+
+	call(t, h.CreateRequest, asTenant, "POST", "/api/v1/maintenance", models.CreateMaintenanceRequest{Title: "No description"}).expect(t, http.StatusBadRequest)
+	unhoused := caller{id: otherLandlordID, role: "tenant", grants: access.Grants{}}
+	call(t, h.CreateRequest, unhoused, "POST", "/api/v1/maintenance", leak).expect(t, http.StatusNotFound)
+
+	var m models.MaintenanceRequest
+	call(t, h.CreateRequest, asTenant, "POST", "/api/v1/maintenance", leak).expect(t, http.StatusCreated).decode(t, &m)
+	if m.UnitID != occupiedUnit || m.BuildingID != buildingA || m.TenantID != tenantID || m.Priority != "medium" || m.Status != "open" {
+		t.Fatalf("request = %+v", m)
+	}
+}
+
+func TestListMaintenanceRequests(t *testing.T) {
+	f := newFixture(t)
+	h := NewMaintenanceHandler(f.st, nil)
+	mustCreate(t, f.mem.CreateMaintenanceRequest, models.MaintenanceRequest{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Title: "Tap", Status: "open"})
+	mustCreate(t, f.mem.CreateMaintenanceRequest, models.MaintenanceRequest{TenantID: otherLandlordID, UnitID: otherUnit, BuildingID: buildingB, Title: "Roof", Status: "open"})
+
+	list := func(c caller) []store.MaintenanceListing {
+		t.Helper()
+		var requests []store.MaintenanceListing
+		call(t, h.ListRequests, c, "GET", "/api/v1/maintenance", nil).expect(t, http.StatusOK).decode(t, &requests)
+		return requests
+	}
+
+	if got := list(asTenant); len(got) != 1 || got[0].Title != "Tap" {
+		t.Errorf("tenant sees %+v", got)
+	}
+	got := list(asStaff(access.RoleCaretaker))
+	if len(got) != 1 || got[0].Title != "Tap" {
+		t.Fatalf("caretaker sees %+v", got)
+	}
+	if got[0].Tenant == nil || got[0].Tenant.FullName != "Chidi Tenant" || got[0].Unit == nil || got[0].Unit.UnitNumber != "A1" || got[0].Building == nil || got[0].Building.Name != "Palm Court" {
+		t.Errorf("embedded rows = %+v %+v %+v", got[0].Tenant, got[0].Unit, got[0].Building)
+	}
+	if got := list(asStaff(access.RoleAccountant)); len(got) != 0 {
+		t.Errorf("accountant sees %d requests", len(got))
+	}
+}
+
+func TestUpdateMaintenanceStatus(t *testing.T) {
+	f := newFixture(t)
+	h := NewMaintenanceHandler(f.st, nil)
+	m := mustCreate(t, f.mem.CreateMaintenanceRequest, models.MaintenanceRequest{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Title: "Tap", Status: "open"})
+	path := "/api/v1/maintenance/" + m.ID + "/status"
+	resolved := models.UpdateMaintenanceStatusRequest{Status: "resolved"}
+
+	call(t, h.UpdateRequestStatus, asLandlord, "PUT", "/api/v1/maintenance/missing/status", resolved, "id", "missing").expect(t, http.StatusNotFound)
+	call(t, h.UpdateRequestStatus, asStaff(access.RoleAccountant), "PUT", path, resolved, "id", m.ID).expect(t, http.StatusForbidden)
+	call(t, h.UpdateRequestStatus, asOtherLandlord, "PUT", path, resolved, "id", m.ID).expect(t, http.StatusForbidden)
+
+	var updated models.MaintenanceRequest
+	call(t, h.UpdateRequestStatus, asStaff(access.RoleCaretaker), "PUT", path, resolved, "id", m.ID).expect(t, http.StatusOK).decode(t, &updated)
+	if updated.Status != "resolved" || updated.Title != "Tap" {
+		t.Fatalf("updated = %+v", updated)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestOrganisations(t *testing.T) {
+	f := newFixture(t)
+	resolver := access.NewResolver(f.st)
+	h := NewOrganisationsHandler(f.st, f.notify, resolver, nil)
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 100, Status: "successful", Period: "Jan 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 40, Status: "pending", Period: "Feb 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: otherLandlordID, UnitID: otherUnit, BuildingID: buildingB, Amount: 999, Status: "successful", Period: "Jan 2026"})
+
+	call(t, h.CreateOrganisation, asLandlord, "POST", "/api/v1/organisations", models.CreateOrganisationRequest{Name: "  "}).expect(t, http.StatusBadRequest)
+	var org models.Organisation
+	call(t, h.CreateOrganisation, asLandlord, "POST", "/api/v1/organisations", models.CreateOrganisationRequest{Name: " Acme Estates "}).expect(t, http.StatusCreated).decode(t, &org)
+	if org.Name != "Acme Estates" || org.CreatedBy != landlordID {
+		t.Fatalf("org = %+v", org)
+	}
+	base := "/api/v1/organisations/" + org.ID
+
+	var mine []struct {
+		Organisation models.Organisation `json:"organisation"`
+		Role         string              `json:"role"`
+	}
+	call(t, h.ListOrganisations, asLandlord, "GET", "/api/v1/organisations", nil).expect(t, http.StatusOK).decode(t, &mine)
+	if len(mine) != 1 || mine[0].Organisation.ID != org.ID || mine[0].Role != "admin" {
+		t.Errorf("organisations = %+v, want the creator as admin", mine)
+	}
+	call(t, h.ListOrganisations, asOtherLandlord, "GET", "/api/v1/organisations", nil).expect(t, http.StatusOK).decode(t, &mine)
+	if len(mine) != 0 {
+		t.Errorf("non-member sees %+v", mine)
+	}
+
+	// Non-members can't tell the organisation exists
+	call(t, h.ListMembers, asOtherLandlord, "GET", base+"/members", nil, "id", org.ID).expect(t, http.StatusNotFound)
+	call(t, h.InviteMember, asOtherLandlord, "POST", base+"/members", models.InviteOrgMemberRequest{Email: "x@example.com", Role: "manager"}, "id", org.ID).expect(t, http.StatusNotFound)
+	call(t, h.InviteMember, asLandlord, "POST", base+"/members", models.InviteOrgMemberRequest{Email: "x@example.com", Role: "co_owner"}, "id", org.ID).expect(t, http.StatusBadRequest)
+
+	var owner, manager models.OrganisationMember
+	call(t, h.InviteMember, asLandlord, "POST", base+"/members", models.InviteOrgMemberRequest{Email: "Bola@Example.com", Role: "owner"}, "id", org.ID).expect(t, http.StatusCreated).decode(t, &owner)
+	call(t, h.InviteMember, asLandlord, "POST", base+"/members", models.InviteOrgMemberRequest{Email: "bola@example.com", Role: "manager"}, "id", org.ID).expect(t, http.StatusConflict)
+	call(t, h.InviteMember, asLandlord, "POST", base+"/members", models.InviteOrgMemberRequest{Email: "dayo@example.com", Role: "manager"}, "id", org.ID).expect(t, http.StatusCreated).decode(t, &manager)
+
+	ownerToken := f.outbox.linkToken(t, "bola@example.com")
+	call(t, h.AcceptInvite, asStaff(""), "POST", "/api/v1/organisations/accept", models.AcceptOrgInviteRequest{Token: ownerToken}).expect(t, http.StatusForbidden)
+	call(t, h.AcceptInvite, asOtherLandlord, "POST", "/api/v1/organisations/accept", models.AcceptOrgInviteRequest{Token: ownerToken}).expect(t, http.StatusOK)
+	call(t, h.AcceptInvite, asStaff(""), "POST", "/api/v1/organisations/accept", models.AcceptOrgInviteRequest{Token: f.outbox.linkToken(t, "dayo@example.com")}).expect(t, http.StatusOK)
+
+	var members []store.OrganisationMemberListing
+	call(t, h.ListMembers, asLandlord, "GET", base+"/members", nil, "id", org.ID).expect(t, http.StatusOK).decode(t, &members)
+	if len(members) != 3 {
+		t.Errorf("got %d members, want the admin, owner and manager", len(members))
+	}
+	call(t, h.ListMembers, asOtherLandlord, "GET", base+"/members", nil, "id", org.ID).expect(t, http.StatusForbidden)
+
+	// Only a building's own landlord, as an owner or admin member, can hand it over
+	assign := models.AssignOrganisationRequest{OrganisationID: &org.ID}
+	call(t, h.AssignBuilding, asTenant, "PUT", "/api/v1/buildings/"+buildingB+"/organisation", assign, "id", buildingB).expect(t, http.StatusForbidden)
+	call(t, h.AssignBuilding, asOtherLandlord, "PUT", "/api/v1/buildings/"+buildingA+"/organisation", assign, "id", buildingA).expect(t, http.StatusNotFound)
+	call(t, h.AssignBuilding, asLandlord, "PUT", "/api/v1/buildings/"+buildingA+"/organisation", assign, "id", buildingA).expect(t, http.StatusOK)
+	call(t, h.AssignBuilding, asOtherLandlord, "PUT", "/api/v1/buildings/"+buildingB+"/organisation", assign, "id", buildingB).expect(t, http.StatusOK)
+
+	grants, err := resolver.Grants(staffID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !grants.Can(buildingA, access.InviteTenants) || !grants.Can(buildingB, access.InviteTenants) {
+		t.Errorf("manager grants = %v, want both managed buildings", grants)
+	}
+	// Owner members keep to the buildings they own
+	if grants, _ = resolver.Grants(otherLandlordID); grants.Can(buildingA, access.ViewBuilding) {
+		t.Errorf("owner member can see another owner's building: %v", grants)
+	}
+
+	type portfolio struct {
+		Buildings      []models.BuildingWithStats `json:"buildings"`
+		TotalBuildings int                        `json:"total_buildings"`
+		TotalCollected int64                      `json:"total_collected"`
+		TotalPending   int64                      `json:"total_pending"`
+	}
+	var all, own portfolio
+	call(t, h.Portfolio, asLandlord, "GET", base+"/portfolio", nil, "id", org.ID).expect(t, http.StatusOK).decode(t, &all)
+	if all.TotalBuildings != 2 || all.TotalCollected != 1099 || all.TotalPending != 40 {
+		t.Errorf("portfolio = %+v, want both buildings' figures", all)
+	}
+	call(t, h.Portfolio, asOtherLandlord, "GET", base+"/portfolio", nil, "id", org.ID).expect(t, http.StatusOK).decode(t, &own)
+	if own.TotalBuildings != 1 || own.Buildings[0].ID != buildingB || own.TotalCollected != 999 {
+		t.Errorf("owner portfolio = %+v, want only their own building", own)
+	}
+	call(t, h.Portfolio, asOtherLandlord, "GET", base+"/portfolio?owner_id="+landlordID, nil, "id", org.ID).expect(t, http.StatusForbidden)
+
+	// Admins can't revoke themselves, and revoking ends the member's grants
+	var self models.OrganisationMember
+	for _, m := range members {
+		if m.UserID != nil && *m.UserID == landlordID {
+			self = m.OrganisationMember
+		}
+	}
+	call(t, h.RevokeMember, asLandlord, "DELETE", base+"/members/"+self.ID, nil, "id", org.ID, "memberId", self.ID).expect(t, http.StatusNotFound)
+	call(t, h.RevokeMember, asLandlord, "DELETE", base+"/members/"+manager.ID, nil, "id", org.ID, "memberId", manager.ID).expect(t, http.StatusOK)
+	if grants, _ = resolver.Grants(staffID); len(grants) != 0 {
+		t.Errorf("revoked manager still has grants %v", grants)
+	}
+}
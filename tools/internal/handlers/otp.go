@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const (
+	otpLength      = 6
+	otpTTL         = 10 * time.Minute
+	otpMaxAttempts = 5
+	otpResendAfter = time.Minute
+	otpHourlyLimit = 5
+)
+
+var (
+	errOTPInvalid  = errors.New("invalid or expired code")
+	errOTPAttempts = errors.New("too many incorrect attempts, request a new code")
+)
+
+// otpThrottledError is returned when a new code is requested too soon
+type otpThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *otpThrottledError) Error() string {
+	return fmt.Sprintf("please wait %d seconds before requesting another code", int(e.RetryAfter.Seconds())+1)
+}
+
+// hashOTP keys the hash with a server secret and the phone number, so the
+// small 6-digit code space cannot be brute-forced from a copy of the table
+func hashOTP(secret []byte, phone, code string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(phone + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateOTP() string {
+	max := big.NewInt(1)
+	for i := 0; i < otpLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, _ := rand.Int(rand.Reader, max)
+	return fmt.Sprintf("%0*d", otpLength, n.Int64())
+}
+
+// issueOTP creates a new code for an E.164 phone number, enforcing the
+// resend cooldown and hourly cap. Earlier unconsumed codes are invalidated.
+func issueOTP(otps store.OTPStore, secret []byte, phone string) (string, error) {
+	now := time.Now().UTC()
+
+	recent, err := otps.RecentOTPs(phone, now.Add(-time.Hour))
+	if err != nil {
+		return "", err
+	}
+
+	if len(recent) > 0 {
+		if wait := recent[0].Add(otpResendAfter).Sub(now); wait > 0 {
+			return "", &otpThrottledError{RetryAfter: wait}
+		}
+	}
+	if len(recent) >= otpHourlyLimit {
+		oldest := recent[len(recent)-1]
+		return "", &otpThrottledError{RetryAfter: oldest.Add(time.Hour).Sub(now)}
+	}
+
+	code := generateOTP()
+	otp := models.PhoneOTP{
+		Phone:     phone,
+		CodeHash:  hashOTP(secret, phone, code),
+		ExpiresAt: now.Add(otpTTL),
+	}
+	if err := otps.CreateOTP(otp); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// verifyOTP checks a code against the latest outstanding OTP for the phone.
+// Every guess is counted before the comparison, atomically, so concurrent
+// guesses cannot get past otpMaxAttempts; after that the code is burned.
+func verifyOTP(otps store.OTPStore, secret []byte, phone, code string) error {
+	otp, err := otps.LatestOTP(phone)
+	if errors.Is(err, store.ErrNotFound) {
+		return errOTPInvalid
+	}
+	if err != nil {
+		return err
+	}
+	if time.Now().After(otp.ExpiresAt) {
+		return errOTPInvalid
+	}
+
+	if otp.Attempts >= otpMaxAttempts {
+		return errOTPAttempts
+	}
+
+	taken, err := otps.TakeOTPAttempt(otp.ID, otpMaxAttempts)
+	if err != nil {
+		return err
+	}
+	if !taken {
+		return errOTPAttempts
+	}
+
+	if !hmac.Equal([]byte(otp.CodeHash), []byte(hashOTP(secret, phone, code))) {
+		return errOTPInvalid
+	}
+
+	consumed, err := otps.ConsumeOTP(otp.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return errOTPInvalid
+	}
+	return nil
+}
+
+// respondOTPError maps issueOTP/verifyOTP errors to responses
+func respondOTPError(w http.ResponseWriter, err error) {
+	var throttled *otpThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		respondError(w, http.StatusTooManyRequests, throttled.Error())
+	case err == errOTPInvalid || err == errOTPAttempts:
+		respondError(w, http.StatusUnauthorized, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "Failed to process code")
+	}
+}
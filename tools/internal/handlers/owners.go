@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// fullShareBps is 100% in basis points
+const fullShareBps = 10000
+
+// ListOwners returns a building's owners and their shares
+func (h *BuildingsHandler) ListOwners(w http.ResponseWriter, r *http.Request) {
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ViewBuilding) {
+		return
+	}
+
+	owners, err := h.buildingShares(buildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch owners")
+		return
+	}
+
+	ids := make([]string, len(owners))
+	for i, o := range owners {
+		ids[i] = o.UserID
+	}
+	profiles, err := h.store.Profiles.ListProfiles(ids)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch owners")
+		return
+	}
+	names := map[string]models.Profile{}
+	for _, p := range profiles {
+		names[p.ID] = p
+	}
+
+	result := make([]map[string]interface{}, len(owners))
+	for i, o := range owners {
+		result[i] = map[string]interface{}{
+			"user_id":   o.UserID,
+			"full_name": names[o.UserID].FullName,
+			"email":     names[o.UserID].Email,
+			"share_bps": o.ShareBps,
+			"managing":  o.Managing,
+		}
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// SetOwners replaces a building's ownership list. Shares must add up to
+// 100%, at least one owner must be managing, and the landlord who created
+// the building must stay on the list.
+func (h *BuildingsHandler) SetOwners(w http.ResponseWriter, r *http.Request) {
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ManageOwnership) {
+		return
+	}
+
+	var req models.SetBuildingOwnersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Owners) == 0 {
+		respondError(w, http.StatusBadRequest, "At least one owner is required")
+		return
+	}
+
+	building, err := h.store.Buildings.GetBuilding(buildingID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Building not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
+
+	total := 0
+	hasManaging := false
+	seen := map[string]bool{}
+	owners := make([]models.BuildingOwner, 0, len(req.Owners))
+	for _, o := range req.Owners {
+		userID := o.UserID
+		if userID == "" && o.Email != "" {
+			profile, err := h.store.Profiles.FindProfileByEmail(strings.ToLower(strings.TrimSpace(o.Email)))
+			if err == store.ErrNotFound {
+				respondError(w, http.StatusBadRequest, "No account found for "+o.Email+"; ask them to sign up first")
+				return
+			}
+			if err != nil {
+				respondError(w, http.StatusInternalServerError, "Failed to look up owner")
+				return
+			}
+			userID = profile.ID
+		}
+		if userID == "" {
+			respondError(w, http.StatusBadRequest, "Each owner needs a user_id or email")
+			return
+		}
+		if seen[userID] {
+			respondError(w, http.StatusBadRequest, "An owner is listed more than once")
+			return
+		}
+		if o.ShareBps <= 0 {
+			respondError(w, http.StatusBadRequest, "Each share must be greater than zero")
+			return
+		}
+		seen[userID] = true
+		total += o.ShareBps
+		hasManaging = hasManaging || o.Managing
+
+		owners = append(owners, models.BuildingOwner{
+			BuildingID: buildingID,
+			UserID:     userID,
+			ShareBps:   o.ShareBps,
+			Managing:   o.Managing,
+		})
+	}
+
+	if total != fullShareBps {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Shares must add up to 10000 basis points (100%%), got %d", total))
+		return
+	}
+	if !hasManaging {
+		respondError(w, http.StatusBadRequest, "At least one owner must be managing")
+		return
+	}
+	if !seen[building.LandlordID] {
+		respondError(w, http.StatusBadRequest, "The building's original landlord must remain an owner")
+		return
+	}
+
+	existing, err := h.store.Owners.ListOwners(buildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch owners")
+		return
+	}
+
+	saved, err := h.store.Owners.ReplaceOwners(buildingID, owners)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to save owners: "+err.Error())
+		return
+	}
+
+	previous, current := ownerShares(existing), ownerShares(saved)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "building.owners.update",
+		ResourceType: "building",
+		ResourceID:   buildingID,
+		BuildingID:   buildingID,
+		Before:       map[string]interface{}{"owners": previous},
+		After:        map[string]interface{}{"owners": current},
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    saved,
+		Message: "Ownership updated",
+	})
+}
+
+// OwnerStatement allocates a building's collections for a period between
+// its owners by share and returns one owner's part (?owner_id=, default the
+// caller; ?from=&to= as YYYY-MM-DD, default this month). Co-owners can only
+// see their own statement. ?format=csv downloads it.
+func (h *BuildingsHandler) OwnerStatement(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ViewBuilding) {
+		return
+	}
+
+	ownerID := r.URL.Query().Get("owner_id")
+	if ownerID == "" {
+		ownerID = userID
+	}
+	if ownerID == userID {
+		if !middleware.Can(r, buildingID, access.ViewOwnStatement) && !middleware.Can(r, buildingID, access.ViewFinancials) {
+			respondError(w, http.StatusForbidden, "Insufficient permissions for this building")
+			return
+		}
+	} else if !middleware.Can(r, buildingID, access.ViewFinancials) {
+		respondError(w, http.StatusForbidden, "You can only view your own statement")
+		return
+	}
+
+	from, to, msg := statementPeriod(r)
+	if msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	owners, err := h.buildingShares(buildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch owners")
+		return
+	}
+	shares := make([]int, len(owners))
+	ownerIdx := -1
+	for i, o := range owners {
+		shares[i] = o.ShareBps
+		if o.UserID == ownerID {
+			ownerIdx = i
+		}
+	}
+	if ownerIdx < 0 {
+		respondError(w, http.StatusNotFound, "That user does not own this building")
+		return
+	}
+
+	payments, err := allPages(func(opts store.ListOptions) (store.Page[store.PaymentListing], error) {
+		return h.store.Payments.ListPayments(store.PaymentFilter{BuildingIDs: []string{buildingID}}, opts)
+	}, store.ListOptions{
+		Sort: store.Sort{Column: "paid_at"},
+		Filters: []store.Filter{
+			{Column: "status", Op: store.OpEq, Value: "successful"},
+			{Column: "paid_at", Op: store.OpGte, Value: from.Format(time.RFC3339)},
+			{Column: "paid_at", Op: store.OpLt, Value: to.AddDate(0, 0, 1).Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+		return
+	}
+
+	type statementLine struct {
+		PaymentID  string     `json:"payment_id"`
+		PaidAt     *time.Time `json:"paid_at"`
+		UnitNumber string     `json:"unit_number"`
+		TenantName string     `json:"tenant_name"`
+		Period     string     `json:"period"`
+		Amount     int64      `json:"amount"`    // full payment, in kobo
+		Allocated  int64      `json:"allocated"` // this owner's part, in kobo
+	}
+
+	lines := []statementLine{}
+	var collected, allocated int64
+	for _, p := range payments {
+		part := allocateShares(p.Amount, shares)[ownerIdx]
+		collected += p.Amount
+		allocated += part
+		line := statementLine{
+			PaymentID: p.ID,
+			PaidAt:    p.PaidAt,
+			Period:    p.Period,
+			Amount:    p.Amount,
+			Allocated: part,
+		}
+		if p.Unit != nil {
+			line.UnitNumber = p.Unit.UnitNumber
+		}
+		if p.Tenant != nil {
+			line.TenantName = p.Tenant.FullName
+		}
+		lines = append(lines, line)
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s-%s-%s.csv"`, buildingID, from.Format("20060102"), to.Format("20060102")))
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"paid_at", "unit", "tenant", "period", "amount_naira", "share_percent", "allocated_naira"})
+		share := strconv.FormatFloat(float64(owners[ownerIdx].ShareBps)/100, 'f', 2, 64)
+		for _, l := range lines {
+			paidAt := ""
+			if l.PaidAt != nil {
+				paidAt = l.PaidAt.Format("2006-01-02")
+			}
+			cw.Write([]string{paidAt, l.UnitNumber, l.TenantName, l.Period, koboToNaira(l.Amount), share, koboToNaira(l.Allocated)})
+		}
+		cw.Write([]string{"", "", "", "TOTAL", koboToNaira(collected), share, koboToNaira(allocated)})
+		cw.Flush()
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"building_id":     buildingID,
+			"owner_id":        ownerID,
+			"share_bps":       owners[ownerIdx].ShareBps,
+			"from":            from.Format("2006-01-02"),
+			"to":              to.Format("2006-01-02"),
+			"total_collected": collected,
+			"total_allocated": allocated,
+			"lines":           lines,
+		},
+	})
+}
+
+// buildingShares returns a building's ownership list, falling back to the
+// landlord holding 100% when none has been set
+func (h *BuildingsHandler) buildingShares(buildingID string) ([]models.BuildingOwner, error) {
+	owners, err := h.store.Owners.ListOwners(buildingID)
+	if err != nil || len(owners) > 0 {
+		return owners, err
+	}
+
+	building, err := h.store.Buildings.GetBuilding(buildingID)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []models.BuildingOwner{{
+		BuildingID: buildingID,
+		UserID:     building.LandlordID,
+		ShareBps:   fullShareBps,
+		Managing:   true,
+		CreatedAt:  building.CreatedAt,
+	}}, nil
+}
+
+// ownerShares is the audit view of an ownership list
+func ownerShares(owners []models.BuildingOwner) []map[string]interface{} {
+	shares := make([]map[string]interface{}, len(owners))
+	for i, o := range owners {
+		shares[i] = map[string]interface{}{"user_id": o.UserID, "share_bps": o.ShareBps, "managing": o.Managing}
+	}
+	return shares
+}
+
+// allocateShares splits amount by basis-point shares using the largest
+// remainder method, so the parts always add up to exactly amount. Ties go to
+// the earlier owner.
+func allocateShares(amount int64, shares []int) []int64 {
+	parts := make([]int64, len(shares))
+	remainders := make([]int64, len(shares))
+	var given int64
+	for i, s := range shares {
+		parts[i] = amount * int64(s) / fullShareBps
+		remainders[i] = amount * int64(s) % fullShareBps
+		given += parts[i]
+	}
+	for left := amount - given; left > 0; left-- {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		parts[best]++
+		remainders[best] = -1
+	}
+	return parts
+}
+
+// statementPeriod reads ?from= and ?to= (YYYY-MM-DD, inclusive), defaulting
+// to the current calendar month. It returns a message on bad input.
+func statementPeriod(r *http.Request) (time.Time, time.Time, string) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, -1)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, "from must be a date (YYYY-MM-DD)"
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return from, to, "to must be a date (YYYY-MM-DD)"
+		}
+		to = t
+	}
+	if to.Before(from) {
+		return from, to, "to must not be before from"
+	}
+	return from, to, ""
+}
+
+// koboToNaira formats a kobo amount as naira with two decimals
+func koboToNaira(kobo int64) string {
+	sign := ""
+	if kobo < 0 {
+		sign, kobo = "-", -kobo
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, kobo/100, kobo%100)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+)
+
+func TestSetOwners(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+
+	var owners []map[string]interface{}
+	call(t, h.ListOwners, asLandlord, "GET", "/api/v1/buildings/"+buildingA+"/owners", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &owners)
+	if len(owners) != 1 || owners[0]["user_id"] != landlordID || owners[0]["share_bps"] != float64(fullShareBps) {
+		t.Fatalf("default owners = %v, want the landlord at 100%%", owners)
+	}
+
+	set := func(owners ...models.BuildingOwnerInput) response {
+		return call(t, h.SetOwners, asLandlord, "PUT", "/api/v1/buildings/"+buildingA+"/owners", models.SetBuildingOwnersRequest{Owners: owners}, "id", buildingA)
+	}
+	set(models.BuildingOwnerInput{UserID: landlordID, ShareBps: 6000, Managing: true}, models.BuildingOwnerInput{Email: "bola@example.com", ShareBps: 3000}).expect(t, http.StatusBadRequest)
+	set(models.BuildingOwnerInput{UserID: otherLandlordID, ShareBps: 10000, Managing: true}).expect(t, http.StatusBadRequest)
+	set(models.BuildingOwnerInput{UserID: landlordID, ShareBps: 6000}, models.BuildingOwnerInput{Email: "bola@example.com", ShareBps: 4000}).expect(t, http.StatusBadRequest)
+	set(models.BuildingOwnerInput{UserID: landlordID, ShareBps: 6000, Managing: true}, models.BuildingOwnerInput{Email: "nobody@example.com", ShareBps: 4000}).expect(t, http.StatusBadRequest)
+
+	var saved []models.BuildingOwner
+	set(models.BuildingOwnerInput{UserID: landlordID, ShareBps: 6000, Managing: true}, models.BuildingOwnerInput{Email: "Bola@Example.com", ShareBps: 4000}).expect(t, http.StatusOK).decode(t, &saved)
+	if len(saved) != 2 || saved[1].UserID != otherLandlordID || saved[1].BuildingID != buildingA {
+		t.Fatalf("saved = %+v, want the co-owner found by email", saved)
+	}
+
+	call(t, h.ListOwners, asLandlord, "GET", "/api/v1/buildings/"+buildingA+"/owners", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &owners)
+	if len(owners) != 2 || owners[1]["full_name"] != "Bola Landlord" || owners[1]["share_bps"] != float64(4000) {
+		t.Errorf("owners = %v, want both owners with names", owners)
+	}
+
+	// Setting the list again replaces it
+	set(models.BuildingOwnerInput{UserID: landlordID, ShareBps: 10000, Managing: true}).expect(t, http.StatusOK)
+	call(t, h.ListOwners, asLandlord, "GET", "/api/v1/buildings/"+buildingA+"/owners", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &owners)
+	if len(owners) != 1 {
+		t.Errorf("owners after reset = %v, want only the landlord", owners)
+	}
+}
+
+func TestOwnerStatement(t *testing.T) {
+	f := newFixture(t)
+	h := NewBuildingsHandler(f.st, nil)
+	if _, err := f.st.Owners.ReplaceOwners(buildingA, []models.BuildingOwner{
+		{UserID: landlordID, ShareBps: 6667, Managing: true},
+		{UserID: otherLandlordID, ShareBps: 3333},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	paid := func(d int) *time.Time { t := time.Date(2026, 3, d, 9, 0, 0, 0, time.UTC); return &t }
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 100, Status: "successful", Period: "Mar 2026", PaidAt: paid(31)})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 200, Status: "successful", Period: "Feb 2026", PaidAt: paid(1)})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 999, Status: "pending", Period: "Apr 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 999, Status: "successful", Period: "Apr 2026", PaidAt: paid(32)})
+
+	var statement struct {
+		ShareBps       int   `json:"share_bps"`
+		TotalCollected int64 `json:"total_collected"`
+		TotalAllocated int64 `json:"total_allocated"`
+		Lines          []struct {
+			UnitNumber string `json:"unit_number"`
+			TenantName string `json:"tenant_name"`
+			Amount     int64  `json:"amount"`
+			Allocated  int64  `json:"allocated"`
+		} `json:"lines"`
+	}
+	target := "/api/v1/buildings/" + buildingA + "/owner-statement?from=2026-03-01&to=2026-03-31"
+	call(t, h.OwnerStatement, asLandlord, "GET", target, nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &statement)
+	if statement.TotalCollected != 300 || statement.TotalAllocated != 200 || len(statement.Lines) != 2 {
+		t.Fatalf("statement = %+v, want March's 300 kobo with 200 allocated", statement)
+	}
+	if l := statement.Lines[0]; l.Amount != 200 || l.Allocated != 133 || l.UnitNumber != "A1" || l.TenantName != "Chidi Tenant" {
+		t.Errorf("first line = %+v, want the 1 March payment", l)
+	}
+
+	// A co-owner may see their own statement but not the other owner's
+	coOwner := caller{id: otherLandlordID, role: "landlord", grants: grantsOn(buildingA, access.RoleCoOwner)}
+	call(t, h.OwnerStatement, coOwner, "GET", target+"&owner_id="+landlordID, nil, "id", buildingA).expect(t, http.StatusForbidden)
+	call(t, h.OwnerStatement, coOwner, "GET", target, nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &statement)
+	if statement.ShareBps != 3333 || statement.TotalAllocated != 100 {
+		t.Errorf("co-owner statement = %+v, want 100 of 300", statement)
+	}
+}
@@ -2,20 +2,31 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
 	"github.com/aletheia/backend/internal/middleware"
 	"github.com/aletheia/backend/internal/models"
-	postgrest "github.com/supabase-community/postgrest-go"
-	supabase "github.com/supabase-community/supabase-go"
+	"github.com/aletheia/backend/internal/paystack"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
 )
 
 type PaymentsHandler struct {
-	client *supabase.Client
+	store    *store.Store
+	paystack *paystack.Client // nil when PAYSTACK_SECRET_KEY is not set
+	audit    *audit.Logger
 }
 
-func NewPaymentsHandler(client *supabase.Client) *PaymentsHandler {
-	return &PaymentsHandler{client: client}
+func NewPaymentsHandler(st *store.Store, ps *paystack.Client, auditLog *audit.Logger) *PaymentsHandler {
+	return &PaymentsHandler{store: st, paystack: ps, audit: auditLog}
 }
 
 // InitializePayment starts a Paystack payment for a tenant
@@ -33,127 +44,350 @@ func (h *PaymentsHandler) InitializePayment(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Get unit details (verify tenant owns this unit)
-	data, _, err := h.client.From("units").Select("*, buildings(id, name)", "exact", false).Eq("id", req.UnitID).Eq("tenant_id", userID).Execute()
-	if err != nil {
+	// The tenant pays the rent of their current tenancy of the unit
+	tenancy, err := h.store.Tenancies.ActiveTenancy(req.UnitID)
+	if err != nil && err != store.ErrNotFound {
 		respondError(w, http.StatusInternalServerError, "Failed to fetch unit")
 		return
 	}
-
-	var units []struct {
-		models.Unit
-		Buildings struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-		} `json:"buildings"`
-	}
-	json.Unmarshal(data, &units)
-
-	if len(units) == 0 {
+	if err == store.ErrNotFound || tenancy.TenantID != userID {
 		respondError(w, http.StatusNotFound, "Unit not found or not assigned to you")
 		return
 	}
 
-	unit := units[0]
-
 	// Create a pending payment record
-	payment := map[string]interface{}{
-		"tenant_id":   userID,
-		"unit_id":     req.UnitID,
-		"building_id": unit.Buildings.ID,
-		"amount":      unit.RentAmount,
-		"currency":    "NGN",
-		"status":      "pending",
-		"period":      req.Period,
-	}
-
-	payData, _, err := h.client.From("payments").Insert(payment, false, "", "", "").Execute()
+	payment, err := h.store.Payments.CreatePayment(models.Payment{
+		TenantID:   userID,
+		TenancyID:  &tenancy.ID,
+		UnitID:     req.UnitID,
+		BuildingID: tenancy.BuildingID,
+		Amount:     tenancy.RentAmount,
+		Currency:   "NGN",
+		Status:     "pending",
+		Period:     req.Period,
+	})
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "Failed to create payment record")
 		return
 	}
 
-	var payments []models.Payment
-	json.Unmarshal(payData, &payments)
-
 	// TODO: Initialize Paystack transaction using the payment reference
 	// For now, return the payment record — Paystack integration will be added
 	// when the API keys are available
 
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "payment.initialize",
+		ResourceType: "payment",
+		ResourceID:   payment.ID,
+		BuildingID:   tenancy.BuildingID,
+		After:        payment,
+	})
+
 	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
 		Data: map[string]interface{}{
-			"payment":           payments[0],
-			"amount_naira":      float64(unit.RentAmount) / 100,
+			"payment":           payment,
+			"amount_naira":      float64(tenancy.RentAmount) / 100,
 			"authorization_url": "", // Will be filled by Paystack
-			"reference":         payments[0].ID,
+			"reference":         payment.ID,
 		},
 		Message: "Payment initiated (Paystack integration pending)",
 	})
 }
 
-// PaystackWebhook handles Paystack payment callbacks
+// PaystackWebhook handles Paystack payment callbacks. Signed deliveries are
+// stored in webhook_events for support; the rest are refused without being
+// stored, so forged traffic can't fill the table. Signed charge.success events
+// are then reconciled against the Paystack API rather than trusted as-is.
 func (h *PaymentsHandler) PaystackWebhook(w http.ResponseWriter, r *http.Request) {
-	// TODO: Verify Paystack webhook signature
-	// TODO: Parse the event and update the payment status
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
-	var event map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+	if h.paystack == nil || !h.paystack.VerifySignature(body, r.Header.Get("X-Paystack-Signature")) {
+		log.Printf("paystack webhook: rejected %d byte delivery from %s with a bad signature", len(body), audit.ClientIP(r))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var event struct {
+		Event string `json:"event"`
+		Data  struct {
+			Reference string `json:"reference"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	// Acknowledge receipt immediately (Paystack expects 200)
+	stored, err := h.store.Payments.RecordWebhookEvent(models.WebhookEvent{
+		Provider:       "paystack",
+		Event:          event.Event,
+		Reference:      event.Data.Reference,
+		Payload:        json.RawMessage(body),
+		SignatureValid: true,
+	})
+	if err != nil {
+		log.Printf("paystack webhook: failed to store event: %v", err)
+	}
+
+	if event.Event == "charge.success" && event.Data.Reference != "" {
+		result := map[string]interface{}{"processed_at": time.Now().UTC()}
+		payment, err := findPaymentByReference(h.store.Payments, event.Data.Reference)
+		if err == nil {
+			var updated models.Payment
+			updated, err = reconcilePayment(h.store.Payments, h.paystack, payment)
+			if err == nil && updated.Status != payment.Status {
+				h.audit.Record(r, audit.Entry{
+					ActorRole:    "system",
+					Action:       "payment.webhook.reconcile",
+					ResourceType: "payment",
+					ResourceID:   payment.ID,
+					BuildingID:   payment.BuildingID,
+					Before:       payment,
+					After:        updated,
+					Metadata:     map[string]interface{}{"event": event.Event},
+				})
+			}
+		}
+		if err != nil {
+			log.Printf("paystack webhook %s: %v", event.Data.Reference, err)
+			result["error"] = err.Error()
+		}
+		if stored.ID != "" {
+			h.store.Payments.UpdateWebhookEvent(stored.ID, result)
+		}
+	}
+
+	// Acknowledge receipt (Paystack retries anything but 200)
 	w.WriteHeader(http.StatusOK)
 }
 
-// ListPayments returns payment history (scoped by role)
+var (
+	errPaystackNotConfigured = errors.New("paystack is not configured")
+	errPaymentNotFound       = errors.New("payment not found")
+)
+
+// findPaymentByReference looks a payment up by its Paystack reference,
+// falling back to the payment ID, which is the reference we initialise with
+func findPaymentByReference(payments store.PaymentStore, reference string) (models.Payment, error) {
+	payment, err := payments.FindPaymentByReference(reference)
+	if err == store.ErrNotFound {
+		if _, perr := uuid.Parse(reference); perr != nil {
+			return models.Payment{}, errPaymentNotFound
+		}
+		payment, err = payments.GetPayment(reference)
+	}
+	if err == store.ErrNotFound {
+		return models.Payment{}, errPaymentNotFound
+	}
+	return payment, err
+}
+
+// reconcilePayment brings a payment in line with what Paystack reports for
+// it. Successful payments are never downgraded, and a success whose amount
+// does not match the payment is reported instead of applied.
+func reconcilePayment(payments store.PaymentStore, ps *paystack.Client, payment models.Payment) (models.Payment, error) {
+	if ps == nil {
+		return payment, errPaystackNotConfigured
+	}
+	if payment.Status == "successful" {
+		return payment, nil
+	}
+
+	reference := payment.ID
+	if payment.PaystackReference != nil && *payment.PaystackReference != "" {
+		reference = *payment.PaystackReference
+	}
+
+	tx, err := ps.Verify(reference)
+	if err != nil {
+		return payment, err
+	}
+
+	update := map[string]interface{}{}
+	switch tx.Status {
+	case "success":
+		if tx.Amount != payment.Amount {
+			return payment, fmt.Errorf("amount mismatch: paystack %d, expected %d kobo", tx.Amount, payment.Amount)
+		}
+		paidAt := time.Now().UTC()
+		if tx.PaidAt != nil {
+			paidAt = *tx.PaidAt
+		}
+		update["status"] = "successful"
+		update["paid_at"] = paidAt
+		update["paystack_reference"] = tx.Reference
+		update["paystack_transaction_id"] = strconv.FormatInt(tx.ID, 10)
+		if method := paymentMethodForChannel(tx.Channel); method != "" {
+			update["payment_method"] = method
+		}
+	case "failed", "abandoned", "reversed":
+		update["status"] = "failed"
+	default:
+		// Still in progress on Paystack's side
+		return payment, nil
+	}
+
+	updated, ok, err := payments.UpdateUnsettledPayment(payment.ID, update)
+	if err != nil || !ok {
+		return payment, err
+	}
+	return updated, nil
+}
+
+// paymentMethodForChannel maps a Paystack channel onto payments.payment_method
+func paymentMethodForChannel(channel string) string {
+	switch channel {
+	case "card":
+		return "card"
+	case "bank", "bank_transfer", "dedicated_nuban":
+		return "bank_transfer"
+	case "ussd":
+		return "ussd"
+	}
+	return ""
+}
+
+var paymentList = listSpec{
+	sorts: []string{"-created_at", "amount"},
+	filters: []listFilter{
+		idFilter("building_id"),
+		idFilter("unit_id"),
+		idFilter("tenancy_id"),
+		setFilter("status", "pending", "successful", "failed"),
+		dateRange("created_at", "from", "to"),
+		dateRange("paid_at", "paid_from", "paid_to"),
+		amountRange("amount", "min_amount", "max_amount"),
+	},
+}
+
+// ListPayments returns a page of payment history (scoped by role)
 func (h *PaymentsHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 	userRole := middleware.GetUserRole(r)
 
-	query := h.client.From("payments").Select("*, profiles!payments_tenant_id_fkey(full_name), buildings(name), units(unit_number)", "exact", false).Order("created_at", &postgrest.OrderOpts{Ascending: false})
+	lq, err := parseList(r, paymentList)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	var filter store.PaymentFilter
 	if userRole == "tenant" {
-		query = query.Eq("tenant_id", userID)
+		filter.TenantID = userID
 	} else {
-		// Landlord: filter by buildings they own
-		// First get their building IDs
-		bData, _, _ := h.client.From("buildings").Select("id", "exact", false).Eq("landlord_id", userID).Execute()
-		var bIDs []struct {
-			ID string `json:"id"`
-		}
-		json.Unmarshal(bData, &bIDs)
+		// Landlord / staff: only buildings where they may see financials
+		filter.BuildingIDs = middleware.BuildingsWith(r, access.ViewFinancials)
+	}
 
-		if len(bIDs) > 0 {
-			ids := make([]string, len(bIDs))
-			for i, b := range bIDs {
-				ids[i] = b.ID
-			}
-			// Use In filter for building_id
-			query = query.In("building_id", ids)
+	payments, err := h.store.Payments.ListPayments(filter, lq.opts)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+		return
+	}
+
+	respondPage(w, lq, payments)
+}
+
+// RecordOfflinePayment records a rent payment collected outside Paystack
+// (cash or direct bank transfer) by the landlord or building staff
+func (h *PaymentsHandler) RecordOfflinePayment(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.RecordOfflinePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.UnitID == "" || req.Period == "" || req.Amount <= 0 {
+		respondError(w, http.StatusBadRequest, "Unit ID, period, and a positive amount are required")
+		return
+	}
+
+	if req.PaymentMethod != "cash" && req.PaymentMethod != "bank_transfer" {
+		respondError(w, http.StatusBadRequest, "Payment method must be 'cash' or 'bank_transfer'")
+		return
+	}
+
+	paidAt := time.Now().UTC()
+	if req.PaidAt != "" {
+		t, err := time.Parse("2006-01-02", req.PaidAt)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "paid_at must be a date (YYYY-MM-DD)")
+			return
 		}
+		paidAt = t
 	}
 
-	// Apply optional filters
-	if status := r.URL.Query().Get("status"); status != "" {
-		query = query.Eq("status", status)
+	unit, err := h.store.Units.GetUnit(req.UnitID)
+	if err != nil && err != store.ErrNotFound {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch unit")
+		return
 	}
-	if buildingID := r.URL.Query().Get("building_id"); buildingID != "" {
-		query = query.Eq("building_id", buildingID)
+
+	if err == store.ErrNotFound || !middleware.Can(r, unit.BuildingID, access.RecordPayments) {
+		respondError(w, http.StatusForbidden, "Unit not found or you cannot record payments for it")
+		return
 	}
 
-	data, _, err := query.Execute()
+	// The payment goes to the unit's current tenancy unless an earlier one
+	// is named, e.g. for arrears a former tenant settles
+	var tenancy models.Tenancy
+	if req.TenancyID != "" {
+		tenancy, err = h.store.Tenancies.GetTenancy(req.TenancyID)
+		if err == nil && tenancy.UnitID != unit.ID {
+			err = store.ErrNotFound
+		}
+	} else {
+		tenancy, err = h.store.Tenancies.ActiveTenancy(unit.ID)
+	}
+	if err == store.ErrNotFound {
+		if req.TenancyID != "" {
+			respondError(w, http.StatusBadRequest, "Tenancy is not one of this unit's")
+		} else {
+			respondError(w, http.StatusBadRequest, "Unit has no tenant")
+		}
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "Failed to fetch payments")
+		respondError(w, http.StatusInternalServerError, "Failed to fetch tenancy")
+		return
+	}
+
+	payment, err := h.store.Payments.CreatePayment(models.Payment{
+		TenantID:      tenancy.TenantID,
+		TenancyID:     &tenancy.ID,
+		UnitID:        unit.ID,
+		BuildingID:    unit.BuildingID,
+		Amount:        req.Amount,
+		Currency:      "NGN",
+		Status:        "successful",
+		PaymentMethod: &req.PaymentMethod,
+		Period:        req.Period,
+		PaidAt:        &paidAt,
+		RecordedBy:    &userID,
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to record payment")
 		return
 	}
 
-	var payments []json.RawMessage
-	json.Unmarshal(data, &payments)
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "payment.record_offline",
+		ResourceType: "payment",
+		ResourceID:   payment.ID,
+		BuildingID:   unit.BuildingID,
+		After:        payment,
+	})
 
-	respondJSON(w, http.StatusOK, models.APIResponse{
+	respondJSON(w, http.StatusCreated, models.APIResponse{
 		Success: true,
-		Data:    payments,
+		Data:    payment,
+		Message: "Payment recorded",
 	})
 }
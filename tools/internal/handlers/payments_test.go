@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/paystack"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestInitializePayment(t *testing.T) {
+	f := newFixture(t)
+	h := NewPaymentsHandler(f.st, nil, nil)
+
+	call(t, h.InitializePayment, asTenant, "POST", "/api/v1/payments/initialize", models.InitializePaymentRequest{UnitID: occupiedUnit}).expect(t, http.StatusBadRequest)
+	call(t, h.InitializePayment, asTenant, "POST", "/api/v1/payments/initialize", models.InitializePaymentRequest{UnitID: vacantUnit, Period: "Feb 2026"}).expect(t, http.StatusNotFound)
+
+	var out struct {
+		Payment   models.Payment `json:"payment"`
+		Reference string         `json:"reference"`
+	}
+	call(t, h.InitializePayment, asTenant, "POST", "/api/v1/payments/initialize", models.InitializePaymentRequest{UnitID: occupiedUnit, Period: "Feb 2026"}).expect(t, http.StatusCreated).decode(t, &out)
+	p := out.Payment
+	if p.Status != "pending" || p.Amount != 60_000_00 || p.BuildingID != buildingA || p.TenantID != tenantID || p.Currency != "NGN" {
+		t.Fatalf("payment = %+v", p)
+	}
+	if out.Reference != p.ID {
+		t.Errorf("reference = %q, want the payment ID", out.Reference)
+	}
+}
+
+func TestRecordOfflinePayment(t *testing.T) {
+	f := newFixture(t)
+	h := NewPaymentsHandler(f.st, nil, nil)
+	caretaker := asStaff(access.RoleCaretaker)
+	req := func(unitID, method, paidAt string) models.RecordOfflinePaymentRequest {
+		return models.RecordOfflinePaymentRequest{UnitID: unitID, Amount: 60_000_00, Period: "Mar 2026", PaymentMethod: method, PaidAt: paidAt}
+	}
+
+	call(t, h.RecordOfflinePayment, caretaker, "POST", "/api/v1/payments/offline", req(occupiedUnit, "card", "")).expect(t, http.StatusBadRequest)
+	call(t, h.RecordOfflinePayment, caretaker, "POST", "/api/v1/payments/offline", req(occupiedUnit, "cash", "03/01/2026")).expect(t, http.StatusBadRequest)
+	call(t, h.RecordOfflinePayment, caretaker, "POST", "/api/v1/payments/offline", req(vacantUnit, "cash", "")).expect(t, http.StatusBadRequest)
+	call(t, h.RecordOfflinePayment, caretaker, "POST", "/api/v1/payments/offline", req(otherUnit, "cash", "")).expect(t, http.StatusForbidden)
+	call(t, h.RecordOfflinePayment, asTenant, "POST", "/api/v1/payments/offline", req(occupiedUnit, "cash", "")).expect(t, http.StatusForbidden)
+
+	var p models.Payment
+	call(t, h.RecordOfflinePayment, caretaker, "POST", "/api/v1/payments/offline", req(occupiedUnit, "cash", "2026-03-01")).expect(t, http.StatusCreated).decode(t, &p)
+	if p.Status != "successful" || p.TenantID != tenantID || p.RecordedBy == nil || *p.RecordedBy != staffID {
+		t.Fatalf("payment = %+v", p)
+	}
+	if p.PaymentMethod == nil || *p.PaymentMethod != "cash" || p.PaidAt == nil || p.PaidAt.Format("2006-01-02") != "2026-03-01" {
+		t.Errorf("method/paid_at = %v/%v", p.PaymentMethod, p.PaidAt)
+	}
+}
+
+func TestListPayments(t *testing.T) {
+	f := newFixture(t)
+	h := NewPaymentsHandler(f.st, nil, nil)
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 1, Status: "successful", Period: "Jan 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 2, Status: "pending", Period: "Feb 2026"})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: otherLandlordID, UnitID: otherUnit, BuildingID: buildingB, Amount: 3, Status: "successful", Period: "Jan 2026"})
+
+	list := func(c caller, query string) []store.PaymentListing {
+		t.Helper()
+		var payments []store.PaymentListing
+		call(t, h.ListPayments, c, "GET", "/api/v1/payments"+query, nil).expect(t, http.StatusOK).decode(t, &payments)
+		return payments
+	}
+
+	if got := list(asTenant, ""); len(got) != 2 {
+		t.Errorf("tenant sees %d payments, want 2", len(got))
+	}
+	got := list(asLandlord, "")
+	if len(got) != 2 || got[0].Period != "Feb 2026" {
+		t.Fatalf("landlord sees %+v, want both building A payments newest first", got)
+	}
+	if got[0].Tenant == nil || got[0].Tenant.FullName != "Chidi Tenant" || got[0].Unit == nil || got[0].Unit.UnitNumber != "A1" || got[0].Building == nil || got[0].Building.Name != "Palm Court" {
+		t.Errorf("embedded rows = %+v %+v %+v", got[0].Tenant, got[0].Unit, got[0].Building)
+	}
+	if got := list(asLandlord, "?status=successful"); len(got) != 1 || got[0].Amount != 1 {
+		t.Errorf("status filter = %+v", got)
+	}
+	if got := list(asLandlord, "?building_id="+buildingB); len(got) != 0 {
+		t.Errorf("building_id filter leaked %d payments from another landlord", len(got))
+	}
+	// Caretakers record payments but may not see financials
+	if got := list(asStaff(access.RoleCaretaker), ""); len(got) != 0 {
+		t.Errorf("caretaker sees %d payments", len(got))
+	}
+}
+
+func TestPaystackWebhook(t *testing.T) {
+	const secret = "sk_test_webhook"
+	body := `{"event":"transfer.success","data":{"reference":"ref-1"}}`
+	sign := func(b string) string {
+		mac := hmac.New(sha512.New, []byte(secret))
+		mac.Write([]byte(b))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	deliver := func(h *PaymentsHandler, signature string) int {
+		r := httptest.NewRequest("POST", "/api/v1/webhooks/paystack", strings.NewReader(body))
+		r.Header.Set("X-Paystack-Signature", signature)
+		w := httptest.NewRecorder()
+		h.PaystackWebhook(w, r)
+		return w.Code
+	}
+
+	f := newFixture(t)
+	h := NewPaymentsHandler(f.st, paystack.New(secret), nil)
+	if code := deliver(h, "bad"); code != http.StatusUnauthorized {
+		t.Errorf("bad signature: status %d", code)
+	}
+	if code := deliver(h, sign(body)); code != http.StatusOK {
+		t.Errorf("good signature: status %d", code)
+	}
+	// Without Paystack configured nothing can be verified
+	if code := deliver(NewPaymentsHandler(f.st, nil, nil), sign(body)); code != http.StatusUnauthorized {
+		t.Errorf("unconfigured: status %d", code)
+	}
+
+	// Only the signed delivery is kept
+	events := f.mem.WebhookEvents()
+	if len(events) != 1 {
+		t.Fatalf("stored %d events, want only the signed one", len(events))
+	}
+	if !events[0].SignatureValid || events[0].Event != "transfer.success" || events[0].Reference != "ref-1" {
+		t.Errorf("event = %+v", events[0])
+	}
+}
+
+func TestFindPaymentByReference(t *testing.T) {
+	f := newFixture(t)
+	ref := "psk_ref_123"
+	withRef := mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Status: "pending", PaystackReference: &ref})
+	byID := mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Status: "pending"})
+
+	if p, err := findPaymentByReference(f.st.Payments, ref); err != nil || p.ID != withRef.ID {
+		t.Errorf("by reference: %v, %v", p.ID, err)
+	}
+	if p, err := findPaymentByReference(f.st.Payments, byID.ID); err != nil || p.ID != byID.ID {
+		t.Errorf("by ID: %v, %v", p.ID, err)
+	}
+	if _, err := findPaymentByReference(f.st.Payments, "unknown"); err != errPaymentNotFound {
+		t.Errorf("unknown reference: %v", err)
+	}
+	if _, err := findPaymentByReference(f.st.Payments, "30000000-0000-0000-0000-0000000000ff"); err != errPaymentNotFound {
+		t.Errorf("unknown ID: %v", err)
+	}
+}
+
+func TestReconcilePaymentWithoutPaystack(t *testing.T) {
+	f := newFixture(t)
+	p := mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Status: "pending"})
+	if _, err := reconcilePayment(f.st.Payments, nil, p); err != errPaystackNotConfigured {
+		t.Errorf("err = %v", err)
+	}
+}
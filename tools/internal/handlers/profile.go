@@ -0,0 +1,585 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/imaging"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/phone"
+	"github.com/aletheia/backend/internal/store"
+	"github.com/google/uuid"
+	gotrue_types "github.com/supabase-community/gotrue-go/types"
+	storage_go "github.com/supabase-community/storage-go"
+)
+
+const (
+	avatarBucket    = "avatars" // public bucket
+	avatarMaxBytes  = 5 << 20
+	avatarMaxSide   = 1024
+	avatarThumbSide = 256
+	fullNameMaxLen  = 100
+)
+
+// avatarPaths are the storage objects for a user's avatar. They are
+// overwritten on each upload and removed when the account is erased.
+func avatarPaths(userID string) (full, thumb string) {
+	return userID + "/avatar.jpg", userID + "/avatar-thumb.jpg"
+}
+
+// AvatarStorage holds avatar images where browsers can load them
+type AvatarStorage interface {
+	// Upload stores (or replaces) a JPEG and returns its public URL
+	Upload(path string, data []byte) (string, error)
+	Remove(paths ...string) error
+}
+
+// SupabaseAvatars keeps avatars in the public avatars bucket of Supabase
+// Storage, which stays in use whichever store holds the data
+type SupabaseAvatars struct {
+	Storage *storage_go.Client
+}
+
+func (s SupabaseAvatars) Upload(path string, data []byte) (string, error) {
+	contentType := "image/jpeg"
+	cacheControl := "3600"
+	upsert := true
+	if _, err := s.Storage.UploadFile(avatarBucket, path, bytes.NewReader(data), storage_go.FileOptions{
+		ContentType:  &contentType,
+		CacheControl: &cacheControl,
+		Upsert:       &upsert,
+	}); err != nil {
+		return "", err
+	}
+	return s.Storage.GetPublicUrl(avatarBucket, path).SignedURL, nil
+}
+
+func (s SupabaseAvatars) Remove(paths ...string) error {
+	_, err := s.Storage.RemoveFile(avatarBucket, paths)
+	return err
+}
+
+// GetProfile returns the caller's profile
+func (h *AccountHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := h.loadProfile(middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+	if profile == nil {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    profile,
+	})
+}
+
+// UpdateProfile changes the caller's name, email or phone. A name change
+// applies at once; a new email or phone is held as pending until the user
+// proves they control it, and the current one keeps working meanwhile.
+func (h *AccountHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	profile, err := h.loadProfile(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+	if profile == nil {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+
+	update := map[string]interface{}{}
+
+	if req.FullName != nil {
+		name := strings.TrimSpace(*req.FullName)
+		if name == "" || len(name) > fullNameMaxLen {
+			respondError(w, http.StatusBadRequest, "Full name must be between 1 and 100 characters")
+			return
+		}
+		if name != profile.FullName {
+			update["full_name"] = name
+		}
+	}
+
+	var newEmail, newPhone string
+	if req.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*req.Email))
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			respondError(w, http.StatusBadRequest, "Invalid email address")
+			return
+		}
+		if email != strings.ToLower(profile.Email) {
+			newEmail = email
+		}
+	}
+	if req.Phone != nil {
+		number, err := phone.NormalizeNG(*req.Phone)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if profile.Phone == nil || number != *profile.Phone {
+			newPhone = number
+		}
+	}
+
+	// Support staff can fix a name, but contact details decide who can
+	// sign in, so only the account holder may change them
+	if (newEmail != "" || newPhone != "") && middleware.GetImpersonator(r) != "" {
+		respondError(w, http.StatusForbidden, "Email and phone cannot be changed while impersonating")
+		return
+	}
+
+	if newEmail != "" {
+		taken, err := h.contactTaken("email", newEmail, userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check email")
+			return
+		}
+		if taken {
+			respondError(w, http.StatusConflict, "That email is already used by another account")
+			return
+		}
+		update["pending_email"] = newEmail
+	}
+	if newPhone != "" {
+		taken, err := h.contactTaken("phone", newPhone, userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Failed to check phone")
+			return
+		}
+		if taken {
+			respondError(w, http.StatusConflict, "That phone number is already used by another account")
+			return
+		}
+		update["pending_phone"] = newPhone
+	}
+
+	if len(update) == 0 {
+		respondJSON(w, http.StatusOK, models.APIResponse{
+			Success: true,
+			Data:    profile,
+			Message: "Nothing to update",
+		})
+		return
+	}
+
+	// Send the SMS code first: if the number is throttled nothing is saved
+	if newPhone != "" {
+		code, err := issueOTP(h.store.OTPs, h.otpSecret, newPhone)
+		if err != nil {
+			respondOTPError(w, err)
+			return
+		}
+		if err := h.notifier.SMS(userID, newPhone, notify.PhoneChangeSMS(code, "10 minutes")); err != nil {
+			respondError(w, http.StatusBadGateway, "Failed to send SMS, please try again")
+			return
+		}
+	}
+
+	update["updated_at"] = time.Now().UTC()
+	updated, err := h.store.Profiles.UpdateProfile(userID, update)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	var pending []string
+	if newEmail != "" {
+		pending = append(pending, "check "+newEmail+" for a confirmation link")
+		h.sendEmailChange(*profile, newEmail)
+	}
+	if newPhone != "" {
+		pending = append(pending, "enter the code sent to "+phone.Mask(newPhone))
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "account.profile.update",
+		ResourceType: "user",
+		ResourceID:   userID,
+		Before:       profile,
+		After:        updated,
+	})
+
+	message := "Profile updated"
+	if len(pending) > 0 {
+		message += "; to finish changing your contact details, " + strings.Join(pending, " and ")
+	}
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    updated,
+		Message: message,
+	})
+}
+
+// sendEmailChange mails a confirmation link to the new address and a
+// heads-up to the current one. Failures are logged; the user can repeat
+// the PATCH to get a fresh link.
+func (h *AccountHandler) sendEmailChange(profile models.Profile, newEmail string) {
+	token, err := issueAuthToken(h.store.AuthTokens, profile.ID, tokenPurposeEmailChange, emailChangeTTL)
+	if err != nil {
+		log.Printf("profile: email change token for %s not issued: %v", profile.ID, err)
+		return
+	}
+	link := h.notifier.AppURL() + "/confirm-email-change?token=" + token
+	if err := h.notifier.Email(profile.ID, newEmail, notify.EmailChangeEmail(profile.FullName, link, "24 hours")); err != nil {
+		log.Printf("profile: email change link for %s not sent: %v", profile.ID, err)
+	}
+	if profile.Email != "" {
+		if err := h.notifier.Email(profile.ID, profile.Email, notify.EmailChangeNoticeEmail(profile.FullName, newEmail)); err != nil {
+			log.Printf("profile: email change notice for %s not sent: %v", profile.ID, err)
+		}
+	}
+}
+
+// ConfirmEmailChange applies a pending email change from the link sent to
+// the new address. It is public so the link works in any browser; the
+// single-use token identifies the account.
+func (h *AccountHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		respondError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	if h.admin == nil {
+		respondError(w, http.StatusServiceUnavailable, "Email changes are not configured")
+		return
+	}
+
+	userID, err := consumeAuthToken(h.store.AuthTokens, req.Token, tokenPurposeEmailChange)
+	if err == errTokenInvalid || err == errTokenUsed {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify token")
+		return
+	}
+
+	profile, err := h.loadProfile(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+	if profile == nil || profile.PendingEmail == nil {
+		respondError(w, http.StatusBadRequest, "No email change is pending")
+		return
+	}
+	newEmail := *profile.PendingEmail
+
+	// Someone may have claimed the address since the change was requested
+	taken, err := h.contactTaken("email", newEmail, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check email")
+		return
+	}
+	if taken {
+		respondError(w, http.StatusConflict, "That email is already used by another account")
+		return
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+	if _, err := h.admin.AdminUpdateUser(gotrue_types.AdminUpdateUserRequest{
+		UserID:       uid,
+		Email:        newEmail,
+		EmailConfirm: true,
+	}); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+
+	now := time.Now().UTC()
+	update := map[string]interface{}{
+		"email":             newEmail,
+		"email_verified_at": now,
+		"pending_email":     nil,
+		"updated_at":        now,
+	}
+	if _, err := h.store.Profiles.UpdateProfile(userID, update); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		ActorID:      userID,
+		Action:       "account.email.change",
+		ResourceType: "user",
+		ResourceID:   userID,
+		Before:       map[string]interface{}{"email": profile.Email},
+		After:        map[string]interface{}{"email": newEmail},
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Email address changed; use it to sign in from now on",
+	})
+}
+
+// ConfirmPhoneChange applies a pending phone change with the SMS code sent
+// to the new number
+func (h *AccountHandler) ConfirmPhoneChange(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.ConfirmPhoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		respondError(w, http.StatusBadRequest, "Code is required")
+		return
+	}
+
+	profile, err := h.loadProfile(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+	if profile == nil || profile.PendingPhone == nil {
+		respondError(w, http.StatusBadRequest, "No phone change is pending")
+		return
+	}
+	newPhone := *profile.PendingPhone
+
+	if err := verifyOTP(h.store.OTPs, h.otpSecret, newPhone, req.Code); err != nil {
+		respondOTPError(w, err)
+		return
+	}
+
+	taken, err := h.contactTaken("phone", newPhone, userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check phone")
+		return
+	}
+	if taken {
+		respondError(w, http.StatusConflict, "That phone number is already used by another account")
+		return
+	}
+
+	// Phone sign-in goes through Supabase Auth, so it must learn the new number
+	if h.admin != nil {
+		if uid, err := uuid.Parse(userID); err == nil {
+			if _, err := h.admin.AdminUpdateUser(gotrue_types.AdminUpdateUserRequest{
+				UserID:       uid,
+				Phone:        newPhone,
+				PhoneConfirm: true,
+			}); err != nil {
+				respondError(w, http.StatusInternalServerError, "Failed to change phone")
+				return
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	update := map[string]interface{}{
+		"phone":             newPhone,
+		"phone_verified_at": now,
+		"pending_phone":     nil,
+		"updated_at":        now,
+	}
+	if _, err := h.store.Profiles.UpdateProfile(userID, update); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to change phone")
+		return
+	}
+
+	var before interface{}
+	if profile.Phone != nil {
+		before = *profile.Phone
+	}
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "account.phone.change",
+		ResourceType: "user",
+		ResourceID:   userID,
+		Before:       map[string]interface{}{"phone": before},
+		After:        map[string]interface{}{"phone": newPhone},
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Phone number changed",
+	})
+}
+
+// UploadAvatar accepts a multipart "avatar" file (JPEG, PNG or GIF, up to
+// 5MB) and stores a re-encoded copy no larger than 1024px plus a 256px
+// square thumbnail. Re-encoding strips EXIF data such as photo location.
+func (h *AccountHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	r.Body = http.MaxBytesReader(w, r.Body, avatarMaxBytes+1<<20)
+	if err := r.ParseMultipartForm(avatarMaxBytes); err != nil {
+		respondError(w, http.StatusRequestEntityTooLarge, "Avatar must be 5MB or smaller")
+		return
+	}
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Upload the image in an \"avatar\" form field")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > avatarMaxBytes {
+		respondError(w, http.StatusRequestEntityTooLarge, "Avatar must be 5MB or smaller")
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(file, avatarMaxBytes+1))
+	if err != nil || len(raw) > avatarMaxBytes {
+		respondError(w, http.StatusRequestEntityTooLarge, "Avatar must be 5MB or smaller")
+		return
+	}
+
+	// Trust the bytes, not the file name or the client's Content-Type
+	switch http.DetectContentType(raw) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		respondError(w, http.StatusUnsupportedMediaType, imaging.ErrUnsupported.Error())
+		return
+	}
+	img, err := imaging.Decode(raw)
+	if err != nil {
+		respondError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	fullJPEG, err := imaging.EncodeJPEG(imaging.Fit(img, avatarMaxSide))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to process image")
+		return
+	}
+	thumbJPEG, err := imaging.EncodeJPEG(imaging.Square(img, avatarThumbSide))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to process image")
+		return
+	}
+
+	fullPath, thumbPath := avatarPaths(userID)
+	fullURL, err := h.avatars.Upload(fullPath, fullJPEG)
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Failed to store avatar")
+		return
+	}
+	thumbURL, err := h.avatars.Upload(thumbPath, thumbJPEG)
+	if err != nil {
+		respondError(w, http.StatusBadGateway, "Failed to store avatar")
+		return
+	}
+
+	// The paths never change, so a version parameter busts browser caches
+	version := fmt.Sprintf("?v=%d", time.Now().Unix())
+	update := map[string]interface{}{
+		"avatar_url":       fullURL + version,
+		"avatar_thumb_url": thumbURL + version,
+		"updated_at":       time.Now().UTC(),
+	}
+	updated, err := h.store.Profiles.UpdateProfile(userID, update)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "account.avatar.update",
+		ResourceType: "user",
+		ResourceID:   userID,
+		Metadata:     map[string]interface{}{"original_size": len(raw)},
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    updated,
+		Message: "Avatar updated",
+	})
+}
+
+// DeleteAvatar removes the caller's avatar
+func (h *AccountHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	fullPath, thumbPath := avatarPaths(userID)
+	if err := h.avatars.Remove(fullPath, thumbPath); err != nil {
+		log.Printf("profile: avatar files for %s not removed: %v", userID, err)
+	}
+
+	update := map[string]interface{}{
+		"avatar_url":       nil,
+		"avatar_thumb_url": nil,
+		"updated_at":       time.Now().UTC(),
+	}
+	if _, err := h.store.Profiles.UpdateProfile(userID, update); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "account.avatar.delete",
+		ResourceType: "user",
+		ResourceID:   userID,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Avatar removed",
+	})
+}
+
+func (h *AccountHandler) loadProfile(userID string) (*models.Profile, error) {
+	p, err := h.store.Profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// contactTaken reports whether another account already uses value as its
+// email or phone (column)
+func (h *AccountHandler) contactTaken(column, value, userID string) (bool, error) {
+	find := h.store.Profiles.FindProfileByPhone
+	if column == "email" {
+		find = h.store.Profiles.FindProfileByEmail
+	}
+	p, err := find(value)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return p.ID != userID, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+var errProfileNotFound = errors.New("profile not found")
+
+// grantRole adds role to the account's roles and returns the updated list.
+// Granting a role the account already holds is a no-op. When makeDefault is
+// set the role also becomes the one used when no X-Active-Role is sent.
+func grantRole(profiles store.ProfileStore, userID, role string, makeDefault bool) ([]string, error) {
+	profile, err := profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
+		return nil, errProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roles := profile.Roles
+	if len(roles) == 0 {
+		roles = []string{profile.Role}
+	}
+	if slices.Contains(roles, role) && (!makeDefault || profile.Role == role) {
+		return roles, nil
+	}
+	if !slices.Contains(roles, role) {
+		roles = append(roles, role)
+	}
+
+	defaultRole := ""
+	if makeDefault {
+		defaultRole = role
+	}
+	if err := profiles.UpdateRoles(userID, roles, defaultRole); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// AddRole lets an existing account take on another role, e.g. a landlord
+// who also rents a flat elsewhere adding "tenant"
+func (h *AuthHandler) AddRole(w http.ResponseWriter, r *http.Request) {
+	var req models.AddRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !validSignupRole(req.Role) {
+		respondError(w, http.StatusBadRequest, "Role must be 'landlord', 'tenant' or 'staff'")
+		return
+	}
+
+	roles, err := grantRole(h.store.Profiles, middleware.GetUserID(r), req.Role, req.MakeDefault)
+	if err == errProfileNotFound {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update roles")
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.role.add", middleware.GetUserID(r), middleware.GetUserRole(r), map[string]interface{}{"role": req.Role, "make_default": req.MakeDefault, "roles": roles})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"roles": roles},
+		Message: "Role added. Send the X-Active-Role header to act as it.",
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/phone"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const (
+	searchDefaultLimit = 10
+	searchMaxLimit     = 50
+)
+
+// SearchHandler serves portfolio search for landlords and building staff
+type SearchHandler struct {
+	store *store.Store
+}
+
+func NewSearchHandler(st *store.Store) *SearchHandler {
+	return &SearchHandler{store: st}
+}
+
+// Search finds tenants, units, buildings and payments matching ?q= in the
+// caller's buildings, grouped by type, best match first. Payments only come
+// from buildings where they may view financials. An email or phone number
+// finds tenants by that exact value: through the blind indexes when personal
+// data is encrypted, otherwise by the email ignoring case and the phone's
+// last ten digits, however either number was formatted. Anything else is
+// matched against names, unit numbers, addresses and payment references.
+// ?limit= caps each group (default 10, at most 50).
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := searchTerm(r.URL.Query().Get("q"))
+	if utf8.RuneCountInString(q) < 2 {
+		respondError(w, http.StatusBadRequest, "q must be at least 2 characters")
+		return
+	}
+
+	limit := searchDefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > searchMaxLimit {
+			respondError(w, http.StatusBadRequest, "limit must be between 1 and 50")
+			return
+		}
+		limit = n
+	}
+
+	query := store.SearchQuery{
+		BuildingIDs:  middleware.BuildingsWith(r, access.ViewBuilding),
+		FinancialIDs: middleware.BuildingsWith(r, access.ViewFinancials),
+		Limit:        limit,
+	}
+	if strings.Contains(q, "@") {
+		query.Email = strings.ToLower(q)
+	} else {
+		query.Text = q
+		if number, err := phone.NormalizeNG(q); err == nil {
+			query.Phone = number
+		}
+	}
+
+	results, err := h.store.Search.Search(query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to search")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    results,
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestSearch(t *testing.T) {
+	f := newFixture(t)
+	h := NewSearchHandler(f.st)
+	ref, otherRef := "psk_palm_0001", "psk_lagoon_0002"
+	paid := mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 60_000_00, Status: "successful", PaystackReference: &ref})
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: otherLandlordID, UnitID: otherUnit, BuildingID: buildingB, Amount: 1, Status: "successful", PaystackReference: &otherRef})
+
+	search := func(c caller, query string) store.SearchResults {
+		t.Helper()
+		var res store.SearchResults
+		call(t, h.Search, c, "GET", "/api/v1/search"+query, nil).expect(t, http.StatusOK).decode(t, &res)
+		return res
+	}
+
+	res := search(asLandlord, "?q=chidi")
+	if len(res.Tenants) != 1 || res.Tenants[0].ID != tenantID || res.Tenants[0].UnitNumber != "A1" || res.Tenants[0].BuildingName != "Palm Court" {
+		t.Errorf("tenants = %+v", res.Tenants)
+	}
+	if res.Units == nil || res.Buildings == nil || res.Payments == nil {
+		t.Error("empty groups should be [] not null")
+	}
+	if res := search(asLandlord, "?q=CHIDI@example.com"); len(res.Tenants) != 1 {
+		t.Errorf("by email = %+v", res.Tenants)
+	}
+	if res := search(asLandlord, "?q=chidi@exam"); len(res.Tenants) != 0 {
+		t.Errorf("partial email matched %+v", res.Tenants)
+	}
+	if res := search(asLandlord, "?q=0803+000+0003"); len(res.Tenants) != 1 {
+		t.Errorf("by phone = %+v", res.Tenants)
+	}
+
+	// An exact unit number outranks one that merely contains it
+	mustCreate(t, f.mem.CreateUnit, models.Unit{BuildingID: buildingA, UnitNumber: "A10", Status: "vacant"})
+	res = search(asLandlord, "?q=a1")
+	if len(res.Units) != 2 || res.Units[0].UnitNumber != "A1" || res.Units[0].Rank != 1 || res.Units[1].Rank >= 1 {
+		t.Errorf("units = %+v", res.Units)
+	}
+	if res.Units[0].TenantName == nil || *res.Units[0].TenantName != "Chidi Tenant" {
+		t.Errorf("unit tenant = %v", res.Units[0].TenantName)
+	}
+	if res := search(asLandlord, "?q=a1&limit=1"); len(res.Units) != 1 {
+		t.Errorf("limit kept %d units", len(res.Units))
+	}
+
+	if res := search(asLandlord, "?q=palm+rd"); len(res.Buildings) != 1 || res.Buildings[0].ID != buildingA {
+		t.Errorf("buildings = %+v", res.Buildings)
+	}
+	res = search(asLandlord, "?q=psk_")
+	if len(res.Payments) != 1 || res.Payments[0].ID != paid.ID || res.Payments[0].UnitNumber != "A1" {
+		t.Errorf("payments = %+v", res.Payments)
+	}
+	if res := search(asLandlord, "?q="+paid.ID); len(res.Payments) != 1 || res.Payments[0].Rank != 1 {
+		t.Errorf("by payment ID = %+v", res.Payments)
+	}
+
+	// Nothing from another landlord's building
+	if res := search(asLandlord, "?q=lagoon"); len(res.Buildings)+len(res.Payments) != 0 {
+		t.Errorf("leaked %+v %+v", res.Buildings, res.Payments)
+	}
+	if res := search(asLandlord, "?q=b1"); len(res.Units) != 0 {
+		t.Errorf("leaked units %+v", res.Units)
+	}
+	// Caretakers find units but not payments
+	if res := search(asStaff(access.RoleCaretaker), "?q=psk_"); len(res.Payments) != 0 {
+		t.Errorf("caretaker sees payments %+v", res.Payments)
+	}
+	if res := search(asStaff(access.RoleCaretaker), "?q=A2"); len(res.Units) != 1 {
+		t.Errorf("caretaker units = %+v", res.Units)
+	}
+
+	for _, query := range []string{"", "?q=a", "?q=a1&limit=0", "?q=a1&limit=51"} {
+		call(t, h.Search, asLandlord, "GET", "/api/v1/search"+query, nil).expect(t, http.StatusBadRequest)
+	}
+}
+
+func TestSearchPlainContactDetails(t *testing.T) {
+	f := newFixture(t)
+	h := NewSearchHandler(f.st)
+
+	// Without encryption there are no blind indexes: contact details are
+	// matched as stored, whatever their case or formatting
+	if _, err := f.st.Profiles.UpdateProfile(tenantID, map[string]interface{}{"email": "Chidi@Example.COM", "phone": "0803-000-0003"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{"chidi@example.com", "%2B2348030000003", "0803+000+0003"} {
+		var res store.SearchResults
+		call(t, h.Search, asLandlord, "GET", "/api/v1/search?q="+q, nil).expect(t, http.StatusOK).decode(t, &res)
+		if len(res.Tenants) != 1 || res.Tenants[0].ID != tenantID || res.Tenants[0].Rank != 1 {
+			t.Errorf("q=%s: tenants = %+v", q, res.Tenants)
+		}
+	}
+}
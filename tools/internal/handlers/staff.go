@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/notify"
+	"github.com/aletheia/backend/internal/phone"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const staffInviteTTL = 7 * 24 * time.Hour
+
+type StaffHandler struct {
+	store    *store.Store
+	notifier *notify.Notifier
+	audit    *audit.Logger
+}
+
+func NewStaffHandler(st *store.Store, notifier *notify.Notifier, auditLog *audit.Logger) *StaffHandler {
+	return &StaffHandler{store: st, notifier: notifier, audit: auditLog}
+}
+
+// ListStaff returns the active and pending staff of a building
+func (h *StaffHandler) ListStaff(w http.ResponseWriter, r *http.Request) {
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ManageStaff) {
+		return
+	}
+
+	staff, err := h.store.Staff.ListStaff(buildingID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch staff")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    staff,
+	})
+}
+
+// InviteStaff invites a manager, caretaker or accountant to a building by
+// email or phone. Access starts only once the invitee accepts.
+func (h *StaffHandler) InviteStaff(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	buildingID := getPathParam(r, "id")
+
+	if !requireBuilding(w, r, buildingID, access.ManageStaff) {
+		return
+	}
+
+	var req models.InviteStaffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" && req.Phone == "" {
+		respondError(w, http.StatusBadRequest, "Email or phone is required")
+		return
+	}
+
+	if !access.ValidStaffRole(access.Role(req.Role)) {
+		respondError(w, http.StatusBadRequest, "Role must be 'manager', 'caretaker' or 'accountant'")
+		return
+	}
+
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Phone != "" {
+		normalized, err := phone.NormalizeNG(req.Phone)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Phone = normalized
+	}
+
+	// One live membership per person per building
+	exists, err := h.store.Staff.StaffMemberExists(buildingID, req.Email, req.Phone)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to check existing staff")
+		return
+	}
+	if exists {
+		respondError(w, http.StatusConflict, "This person is already invited to or working on this building")
+		return
+	}
+
+	building, err := h.store.Buildings.GetBuilding(buildingID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Building not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch building")
+		return
+	}
+
+	token := generateToken() + generateToken()
+	member := models.BuildingMember{
+		BuildingID: buildingID,
+		Role:       req.Role,
+		InvitedBy:  userID,
+		ExpiresAt:  time.Now().UTC().Add(staffInviteTTL),
+	}
+	if req.Email != "" {
+		member.Email = &req.Email
+	}
+	if req.Phone != "" {
+		member.Phone = &req.Phone
+	}
+
+	created, err := h.store.Staff.CreateStaffInvite(member, hashToken(token))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to create staff invitation")
+		return
+	}
+
+	link := h.notifier.AppURL() + "/staff-invite?token=" + token
+	if req.Email != "" {
+		err = h.notifier.Email("", req.Email, notify.StaffInviteEmail(building.Name, req.Role, link))
+	} else {
+		err = h.notifier.SMS("", req.Phone, notify.StaffInviteSMS(building.Name, req.Role, link))
+	}
+	if err != nil {
+		log.Printf("staff invite %s: notification not sent: %v", created.ID, err)
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "staff.invite",
+		ResourceType: "building_member",
+		ResourceID:   created.ID,
+		BuildingID:   buildingID,
+		After:        created,
+	})
+
+	respondJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    created,
+		Message: "Staff invitation sent",
+	})
+}
+
+// RevokeStaff removes a staff member's (or pending invitee's) access
+func (h *StaffHandler) RevokeStaff(w http.ResponseWriter, r *http.Request) {
+	buildingID := getPathParam(r, "id")
+	memberID := getPathParam(r, "memberId")
+
+	if !requireBuilding(w, r, buildingID, access.ManageStaff) {
+		return
+	}
+
+	revoked, err := h.store.Staff.RevokeStaff(buildingID, memberID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Staff member not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to revoke access")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "staff.revoke",
+		ResourceType: "building_member",
+		ResourceID:   memberID,
+		BuildingID:   buildingID,
+		After:        map[string]interface{}{"status": "revoked"},
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    revoked,
+		Message: "Staff access revoked",
+	})
+}
+
+// AcceptStaffInvite links the logged-in user to a pending staff invitation.
+// The account's email or phone must match the one the invite was sent to.
+func (h *StaffHandler) AcceptStaffInvite(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.AcceptStaffInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" {
+		respondError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	member, err := h.store.Staff.FindStaffInvite(hashToken(req.Token))
+	if err != nil && err != store.ErrNotFound {
+		respondError(w, http.StatusInternalServerError, "Failed to lookup invitation")
+		return
+	}
+	if err == store.ErrNotFound || time.Now().After(member.ExpiresAt) {
+		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
+		return
+	}
+
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	emailMatch := member.Email != nil && strings.EqualFold(*member.Email, profile.Email)
+	phoneMatch := member.Phone != nil && profile.Phone != nil && *member.Phone == *profile.Phone
+	if !emailMatch && !phoneMatch {
+		respondError(w, http.StatusForbidden, "This invitation was sent to a different email or phone number")
+		return
+	}
+
+	accepted, err := h.store.Staff.AcceptStaffInvite(member.ID, userID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Invalid or expired invitation")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to accept invitation")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "staff.accept",
+		ResourceType: "building_member",
+		ResourceID:   member.ID,
+		BuildingID:   member.BuildingID,
+		Before:       member,
+		After:        accepted,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    accepted,
+		Message: "You now have access to this building",
+	})
+}
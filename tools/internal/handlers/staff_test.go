@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestStaffInvites(t *testing.T) {
+	f := newFixture(t)
+	h := NewStaffHandler(f.st, f.notify, nil)
+	resolver := access.NewResolver(f.st)
+	invite := models.InviteStaffRequest{Email: " Dayo@Example.com ", Role: "caretaker"}
+
+	call(t, h.InviteStaff, asOtherLandlord, "POST", "/api/v1/buildings/"+buildingA+"/staff", invite, "id", buildingA).expect(t, http.StatusNotFound)
+	call(t, h.InviteStaff, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/staff", models.InviteStaffRequest{Email: "dayo@example.com", Role: "owner"}, "id", buildingA).expect(t, http.StatusBadRequest)
+
+	var invited models.BuildingMember
+	call(t, h.InviteStaff, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/staff", invite, "id", buildingA).expect(t, http.StatusCreated).decode(t, &invited)
+	if invited.Status != "invited" || invited.Email == nil || *invited.Email != "dayo@example.com" || invited.UserID != nil {
+		t.Errorf("invited = %+v, want a pending invite to the normalised email", invited)
+	}
+	call(t, h.InviteStaff, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/staff", invite, "id", buildingA).expect(t, http.StatusConflict)
+	token := f.outbox.linkToken(t, "dayo@example.com")
+
+	var staff []store.StaffListing
+	call(t, h.ListStaff, asLandlord, "GET", "/api/v1/buildings/"+buildingA+"/staff", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &staff)
+	if len(staff) != 1 || staff[0].ID != invited.ID || staff[0].Profile != nil {
+		t.Fatalf("staff = %+v, want the pending invite", staff)
+	}
+	call(t, h.ListStaff, asStaff(access.RoleManager), "GET", "/api/v1/buildings/"+buildingA+"/staff", nil, "id", buildingA).expect(t, http.StatusForbidden)
+
+	// Only the person it was sent to can take it up
+	call(t, h.AcceptStaffInvite, asTenant, "POST", "/api/v1/staff/accept", models.AcceptStaffInviteRequest{Token: token}).expect(t, http.StatusForbidden)
+	call(t, h.AcceptStaffInvite, asStaff(""), "POST", "/api/v1/staff/accept", models.AcceptStaffInviteRequest{Token: "nope"}).expect(t, http.StatusNotFound)
+	var accepted models.BuildingMember
+	call(t, h.AcceptStaffInvite, asStaff(""), "POST", "/api/v1/staff/accept", models.AcceptStaffInviteRequest{Token: token}).expect(t, http.StatusOK).decode(t, &accepted)
+	if accepted.Status != "active" || accepted.UserID == nil || *accepted.UserID != staffID || accepted.AcceptedAt == nil {
+		t.Errorf("accepted = %+v, want an active membership for the staff user", accepted)
+	}
+	call(t, h.AcceptStaffInvite, asStaff(""), "POST", "/api/v1/staff/accept", models.AcceptStaffInviteRequest{Token: token}).expect(t, http.StatusNotFound)
+
+	call(t, h.ListStaff, asLandlord, "GET", "/api/v1/buildings/"+buildingA+"/staff", nil, "id", buildingA).expect(t, http.StatusOK).decode(t, &staff)
+	if len(staff) != 1 || staff[0].Profile == nil || staff[0].Profile.FullName != "Dayo Staff" {
+		t.Errorf("staff = %+v, want the member with their profile", staff)
+	}
+
+	grants, err := resolver.Grants(staffID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !grants.Can(buildingA, access.ManageMaintenance) || grants.Can(buildingA, access.ViewFinancials) || grants.Can(buildingB, access.ViewBuilding) {
+		t.Errorf("caretaker grants = %v, want caretaker permissions on building A only", grants)
+	}
+
+	revokePath := "/api/v1/buildings/" + buildingA + "/staff/" + invited.ID
+	call(t, h.RevokeStaff, asOtherLandlord, "DELETE", revokePath, nil, "id", buildingA, "memberId", invited.ID).expect(t, http.StatusNotFound)
+	call(t, h.RevokeStaff, asLandlord, "DELETE", revokePath, nil, "id", buildingA, "memberId", invited.ID).expect(t, http.StatusOK)
+	call(t, h.RevokeStaff, asLandlord, "DELETE", revokePath, nil, "id", buildingA, "memberId", invited.ID).expect(t, http.StatusNotFound)
+
+	if grants, _ = resolver.Grants(staffID); len(grants) != 0 {
+		t.Errorf("revoked staff still has grants %v", grants)
+	}
+	// A revoked invite frees the address for a new one
+	call(t, h.InviteStaff, asLandlord, "POST", "/api/v1/buildings/"+buildingA+"/staff", invite, "id", buildingA).expect(t, http.StatusCreated)
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// TenanciesHandler serves tenancies: who lived in a unit, when, and on
+// what rent. Tenancies start when a tenant accepts an invitation.
+type TenanciesHandler struct {
+	store *store.Store
+	audit *audit.Logger
+}
+
+func NewTenanciesHandler(st *store.Store, auditLog *audit.Logger) *TenanciesHandler {
+	return &TenanciesHandler{store: st, audit: auditLog}
+}
+
+// requireTenancy loads the tenancy in the {id} path value. Its tenant may
+// view it; anyone else needs perm on its building, and gets 404 when they
+// cannot see the building at all.
+func (h *TenanciesHandler) requireTenancy(w http.ResponseWriter, r *http.Request, perm access.Permission) (models.Tenancy, bool) {
+	tenancy, err := h.store.Tenancies.GetTenancy(getPathParam(r, "id"))
+	if err != nil && err != store.ErrNotFound {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch tenancy")
+		return tenancy, false
+	}
+	isTenant := err == nil && tenancy.TenantID == middleware.GetUserID(r)
+	if err == store.ErrNotFound || !isTenant && !middleware.Can(r, tenancy.BuildingID, access.ViewBuilding) {
+		respondError(w, http.StatusNotFound, "Tenancy not found")
+		return tenancy, false
+	}
+	if !(isTenant && perm == access.ViewBuilding) && !middleware.Can(r, tenancy.BuildingID, perm) {
+		respondError(w, http.StatusForbidden, "Insufficient permissions for this building")
+		return tenancy, false
+	}
+	return tenancy, true
+}
+
+// GetTenancy returns a single tenancy, to its tenant or building staff
+func (h *TenanciesHandler) GetTenancy(w http.ResponseWriter, r *http.Request) {
+	tenancy, ok := h.requireTenancy(w, r, access.ViewBuilding)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    tenancy,
+	})
+}
+
+// UpdateTenancy corrects a tenancy's rent or dates. An empty end_date
+// clears it while the tenancy is active.
+func (h *TenanciesHandler) UpdateTenancy(w http.ResponseWriter, r *http.Request) {
+	tenancy, ok := h.requireTenancy(w, r, access.ManageBuilding)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateTenancyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	update := map[string]interface{}{}
+	if req.RentAmount != nil {
+		if *req.RentAmount < 0 {
+			respondError(w, http.StatusBadRequest, "Rent amount cannot be negative")
+			return
+		}
+		update["rent_amount"] = *req.RentAmount
+	}
+	start, end := tenancy.StartDate, tenancy.EndDate
+	if req.StartDate != nil {
+		if _, err := time.Parse("2006-01-02", *req.StartDate); err != nil {
+			respondError(w, http.StatusBadRequest, "start_date must be a date (YYYY-MM-DD)")
+			return
+		}
+		update["start_date"], start = *req.StartDate, *req.StartDate
+	}
+	switch {
+	case req.EndDate == nil:
+	case *req.EndDate == "":
+		if tenancy.Status != "active" {
+			respondError(w, http.StatusBadRequest, "An ended tenancy keeps its end date")
+			return
+		}
+		update["end_date"], end = nil, nil
+	default:
+		if _, err := time.Parse("2006-01-02", *req.EndDate); err != nil {
+			respondError(w, http.StatusBadRequest, "end_date must be a date (YYYY-MM-DD)")
+			return
+		}
+		update["end_date"], end = *req.EndDate, req.EndDate
+	}
+	if end != nil && *end < start {
+		respondError(w, http.StatusBadRequest, "End date must be after start date")
+		return
+	}
+
+	if len(update) == 0 {
+		respondError(w, http.StatusBadRequest, "No fields to update")
+		return
+	}
+
+	updated, err := h.store.Tenancies.UpdateTenancy(tenancy.ID, update)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Tenancy not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update tenancy")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "tenancy.update",
+		ResourceType: "tenancy",
+		ResourceID:   tenancy.ID,
+		BuildingID:   tenancy.BuildingID,
+		Before:       tenancy,
+		After:        updated,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    updated,
+		Message: "Tenancy updated successfully",
+	})
+}
+
+// EndTenancy records the tenant moving out, on end_date or today, and
+// leaves the unit vacant for its next tenancy. The tenancy, with its
+// payments, documents and maintenance requests, stays in the unit's
+// history.
+func (h *TenanciesHandler) EndTenancy(w http.ResponseWriter, r *http.Request) {
+	tenancy, ok := h.requireTenancy(w, r, access.ManageBuilding)
+	if !ok {
+		return
+	}
+	if tenancy.Status != "active" {
+		respondError(w, http.StatusConflict, "Tenancy has already ended")
+		return
+	}
+
+	// The body is optional
+	var req models.EndTenancyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	endDate := time.Now().UTC().Format("2006-01-02")
+	if req.EndDate != "" {
+		if _, err := time.Parse("2006-01-02", req.EndDate); err != nil {
+			respondError(w, http.StatusBadRequest, "end_date must be a date (YYYY-MM-DD)")
+			return
+		}
+		endDate = req.EndDate
+	}
+	if endDate < tenancy.StartDate {
+		respondError(w, http.StatusBadRequest, "End date must be after start date")
+		return
+	}
+
+	ended, err := h.store.Tenancies.EndTenancy(tenancy.ID, endDate)
+	if err == store.ErrTenancyEnded {
+		respondError(w, http.StatusConflict, "Tenancy has already ended")
+		return
+	}
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Tenancy not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to end tenancy")
+		return
+	}
+
+	recordAudit(h.audit, r, audit.Entry{
+		Action:       "tenancy.end",
+		ResourceType: "tenancy",
+		ResourceID:   tenancy.ID,
+		BuildingID:   tenancy.BuildingID,
+		Before:       tenancy,
+		After:        ended,
+	})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    ended,
+		Message: "Tenancy ended; the unit is vacant",
+	})
+}
+
+// currentTenancy returns the tenant's active tenancy, if they have one
+func currentTenancy(st *store.Store, tenantID string) (models.Tenancy, bool, error) {
+	tenancies, err := st.Tenancies.TenanciesForTenant(tenantID)
+	if err != nil {
+		return models.Tenancy{}, false, err
+	}
+	for _, t := range tenancies {
+		if t.Status == "active" {
+			return t, true, nil
+		}
+	}
+	return models.Tenancy{}, false, nil
+}
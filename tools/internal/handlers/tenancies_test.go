@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aletheia/backend/internal/access"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+func TestGetTenancy(t *testing.T) {
+	f := newFixture(t)
+	h := NewTenanciesHandler(f.st, nil)
+	path := "/api/v1/tenancies/" + activeTenancy
+
+	for _, c := range []caller{asTenant, asLandlord, asStaff(access.RoleCaretaker)} {
+		var tenancy models.Tenancy
+		call(t, h.GetTenancy, c, "GET", path, nil, "id", activeTenancy).expect(t, http.StatusOK).decode(t, &tenancy)
+		if tenancy.UnitID != occupiedUnit || tenancy.TenantID != tenantID || tenancy.Status != "active" {
+			t.Errorf("%s: tenancy = %+v", c.id, tenancy)
+		}
+	}
+	call(t, h.GetTenancy, asOtherLandlord, "GET", path, nil, "id", activeTenancy).expect(t, http.StatusNotFound)
+	call(t, h.GetTenancy, asLandlord, "GET", "/api/v1/tenancies/nope", nil, "id", "nope").expect(t, http.StatusNotFound)
+}
+
+func TestUpdateTenancy(t *testing.T) {
+	f := newFixture(t)
+	h := NewTenanciesHandler(f.st, nil)
+	path := "/api/v1/tenancies/" + activeTenancy
+	rent := int64(65_000_00)
+	negative := int64(-1)
+	date := func(s string) *string { return &s }
+
+	call(t, h.UpdateTenancy, asTenant, "PUT", path, models.UpdateTenancyRequest{RentAmount: &rent}, "id", activeTenancy).expect(t, http.StatusForbidden)
+	call(t, h.UpdateTenancy, asStaff(access.RoleCaretaker), "PUT", path, models.UpdateTenancyRequest{RentAmount: &rent}, "id", activeTenancy).expect(t, http.StatusForbidden)
+	call(t, h.UpdateTenancy, asLandlord, "PUT", path, models.UpdateTenancyRequest{}, "id", activeTenancy).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateTenancy, asLandlord, "PUT", path, models.UpdateTenancyRequest{RentAmount: &negative}, "id", activeTenancy).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateTenancy, asLandlord, "PUT", path, models.UpdateTenancyRequest{StartDate: date("01/06/2025")}, "id", activeTenancy).expect(t, http.StatusBadRequest)
+	call(t, h.UpdateTenancy, asLandlord, "PUT", path, models.UpdateTenancyRequest{EndDate: date("2025-05-31")}, "id", activeTenancy).expect(t, http.StatusBadRequest)
+
+	var tenancy models.Tenancy
+	call(t, h.UpdateTenancy, asLandlord, "PUT", path, models.UpdateTenancyRequest{RentAmount: &rent, EndDate: date("2026-05-31")}, "id", activeTenancy).expect(t, http.StatusOK).decode(t, &tenancy)
+	if tenancy.RentAmount != rent || tenancy.EndDate == nil || *tenancy.EndDate != "2026-05-31" {
+		t.Fatalf("updated = %+v", tenancy)
+	}
+
+	// The unit keeps its own rent for the next tenancy
+	unit, _ := f.st.Units.GetUnit(occupiedUnit)
+	if unit.RentAmount != 60_000_00 {
+		t.Errorf("unit rent = %d, want the unit's own rent left alone", unit.RentAmount)
+	}
+
+	var cleared models.Tenancy
+	call(t, h.UpdateTenancy, asLandlord, "PUT", path, models.UpdateTenancyRequest{EndDate: date("")}, "id", activeTenancy).expect(t, http.StatusOK).decode(t, &cleared)
+	if cleared.EndDate != nil {
+		t.Errorf("end_date = %v, want cleared", *cleared.EndDate)
+	}
+}
+
+func TestEndTenancy(t *testing.T) {
+	f := newFixture(t)
+	h := NewTenanciesHandler(f.st, nil)
+	units := NewBuildingsHandler(f.st, nil)
+	payments := NewPaymentsHandler(f.st, nil, nil)
+	path := "/api/v1/tenancies/" + activeTenancy + "/end"
+	tenancy := activeTenancy
+	mustCreate(t, f.mem.CreatePayment, models.Payment{TenantID: tenantID, TenancyID: &tenancy, UnitID: occupiedUnit, BuildingID: buildingA, Amount: 60_000_00, Status: "successful", Period: "Jan 2026"})
+
+	call(t, h.EndTenancy, asTenant, "POST", path, nil, "id", activeTenancy).expect(t, http.StatusForbidden)
+	call(t, h.EndTenancy, asOtherLandlord, "POST", path, nil, "id", activeTenancy).expect(t, http.StatusNotFound)
+	call(t, h.EndTenancy, asLandlord, "POST", path, models.EndTenancyRequest{EndDate: "2025-01-01"}, "id", activeTenancy).expect(t, http.StatusBadRequest)
+
+	var ended models.Tenancy
+	call(t, h.EndTenancy, asLandlord, "POST", path, models.EndTenancyRequest{EndDate: "2026-09-30"}, "id", activeTenancy).expect(t, http.StatusOK).decode(t, &ended)
+	if ended.Status != "ended" || ended.EndDate == nil || *ended.EndDate != "2026-09-30" || ended.EndedAt == nil {
+		t.Fatalf("ended = %+v", ended)
+	}
+	call(t, h.EndTenancy, asLandlord, "POST", path, nil, "id", activeTenancy).expect(t, http.StatusConflict)
+
+	// The unit is vacant, with the tenancy in its history
+	var unit struct {
+		models.Unit
+		Tenancy   *store.TenancyListing  `json:"tenancy"`
+		Tenancies []store.TenancyListing `json:"tenancies"`
+	}
+	call(t, units.GetUnit, asLandlord, "GET", "/api/v1/units/"+occupiedUnit, nil, "id", occupiedUnit).expect(t, http.StatusOK).decode(t, &unit)
+	if unit.Status != "vacant" || unit.TenantID != nil || unit.Tenancy != nil {
+		t.Fatalf("unit = %+v", unit)
+	}
+	if len(unit.Tenancies) != 1 || unit.Tenancies[0].ID != activeTenancy || unit.Tenancies[0].Tenant == nil || unit.Tenancies[0].Tenant.FullName != "Chidi Tenant" {
+		t.Fatalf("history = %+v", unit.Tenancies)
+	}
+
+	// The former tenant still sees their tenancy and payments
+	call(t, h.GetTenancy, asTenant, "GET", "/api/v1/tenancies/"+activeTenancy, nil, "id", activeTenancy).expect(t, http.StatusOK)
+	if page, err := f.st.Payments.ListPayments(store.PaymentFilter{TenantID: tenantID}, store.ListOptions{PerPage: 10}); err != nil || page.Total != 1 {
+		t.Errorf("tenant payments = %+v, %v", page, err)
+	}
+
+	// Late rent for the ended tenancy is recorded against it
+	offline := models.RecordOfflinePaymentRequest{UnitID: occupiedUnit, Amount: 60_000_00, Period: "Sep 2026", PaymentMethod: "cash"}
+	call(t, payments.RecordOfflinePayment, asLandlord, "POST", "/api/v1/payments/offline", offline).expect(t, http.StatusBadRequest)
+	offline.TenancyID = activeTenancy
+	var p models.Payment
+	call(t, payments.RecordOfflinePayment, asLandlord, "POST", "/api/v1/payments/offline", offline).expect(t, http.StatusCreated).decode(t, &p)
+	if p.TenantID != tenantID || p.TenancyID == nil || *p.TenancyID != activeTenancy {
+		t.Errorf("payment = %+v", p)
+	}
+}
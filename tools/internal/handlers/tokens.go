@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// Purposes for single-use tokens stored in auth_tokens
+const (
+	tokenPurposePasswordReset = "password_reset"
+	tokenPurposeEmailVerify   = "email_verification"
+	tokenPurposeMFALogin      = "mfa_login"
+	tokenPurposeEmailChange   = "email_change"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	emailVerifyTTL   = 48 * time.Hour
+	mfaLoginTTL      = 5 * time.Minute
+	emailChangeTTL   = 24 * time.Hour
+)
+
+// mfaLoginAttempts is how many codes one two-factor login challenge accepts
+const mfaLoginAttempts = 5
+
+var (
+	errTokenInvalid = errors.New("invalid or expired token")
+	errTokenUsed    = errors.New("token has already been used")
+)
+
+// hashToken returns the hex SHA-256 of a raw token. Only hashes are stored,
+// so a leaked auth_tokens table cannot be replayed.
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueAuthToken creates a new single-use token for userID and returns the
+// raw value to send to the user. Any outstanding tokens for the same purpose
+// are invalidated so only the latest link works.
+func issueAuthToken(tokens store.AuthTokenStore, userID, purpose string, ttl time.Duration) (string, error) {
+	raw := generateToken() + generateToken()
+	token := models.AuthToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+	if err := tokens.CreateAuthToken(token); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// findAuthToken looks up a raw token that is still unused and unexpired,
+// without using it up
+func findAuthToken(tokens store.AuthTokenStore, raw, purpose string) (models.AuthToken, error) {
+	if raw == "" {
+		return models.AuthToken{}, errTokenInvalid
+	}
+
+	token, err := tokens.FindAuthToken(hashToken(raw), purpose)
+	if errors.Is(err, store.ErrNotFound) {
+		return models.AuthToken{}, errTokenInvalid
+	}
+	if err != nil {
+		return models.AuthToken{}, err
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return models.AuthToken{}, errTokenInvalid
+	}
+	if token.UsedAt != nil {
+		return models.AuthToken{}, errTokenUsed
+	}
+	return token, nil
+}
+
+// consumeAuthToken validates a raw token and marks it used, returning the
+// owning user ID. UseAuthToken only matches an unused token, so two
+// concurrent requests with the same token cannot both succeed.
+func consumeAuthToken(tokens store.AuthTokenStore, raw, purpose string) (string, error) {
+	token, err := findAuthToken(tokens, raw, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	used, err := tokens.UseAuthToken(token.ID)
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "", errTokenUsed
+	}
+
+	return token.UserID, nil
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aletheia/backend/internal/mfa"
+	"github.com/aletheia/backend/internal/middleware"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/ratelimit"
+	"github.com/aletheia/backend/internal/store"
+	gotrue_types "github.com/supabase-community/gotrue-go/types"
+)
+
+// startMFAChallenge answers a proven first factor with a challenge token
+// instead of a session. A password session already minted is revoked so it
+// cannot be used to skip the second step; SMS logins pass nil.
+func (h *AuthHandler) startMFAChallenge(w http.ResponseWriter, userID string, session *gotrue_types.Session) {
+	if session != nil {
+		h.auth.WithToken(session.AccessToken).Logout()
+	}
+
+	if h.admin == nil {
+		respondError(w, http.StatusServiceUnavailable, "Two-factor login is not configured")
+		return
+	}
+
+	token, err := issueAuthToken(h.store.AuthTokens, userID, tokenPurposeMFALogin, mfaLoginTTL)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to start two-factor login")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    token,
+			ExpiresIn:   int(mfaLoginTTL.Seconds()),
+		},
+		Message: "Two-factor code required",
+	})
+}
+
+// LoginMFA completes the second login step. A challenge accepts up to
+// mfaLoginAttempts codes, each counted before it is checked, and is used up
+// by the right one; after that the user starts again from the password.
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		respondError(w, http.StatusBadRequest, "mfa_token and code are required")
+		return
+	}
+
+	if h.admin == nil {
+		respondError(w, http.StatusServiceUnavailable, "Two-factor login is not configured")
+		return
+	}
+
+	challenge, err := findAuthToken(h.store.AuthTokens, req.MFAToken, tokenPurposeMFALogin)
+	if err == errTokenInvalid || err == errTokenUsed {
+		respondError(w, http.StatusUnauthorized, "Login session expired, please log in again")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify login")
+		return
+	}
+	userID := challenge.UserID
+
+	taken, err := h.store.AuthTokens.TakeAuthTokenAttempt(challenge.ID, mfaLoginAttempts)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify login")
+		return
+	}
+	if !taken {
+		respondError(w, http.StatusUnauthorized, "Too many incorrect codes, please log in again")
+		return
+	}
+
+	wait, err := middleware.VerifyMFACode(h.mfa, h.store.Lockouts, userID, req.Code)
+	if wait > 0 {
+		respondMFALockedOut(w, wait)
+		return
+	}
+	if err != nil {
+		h.recordAuthEvent(r, "auth.login.mfa_failed", userID, "", nil)
+		respondError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	used, err := h.store.AuthTokens.UseAuthToken(challenge.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to verify login")
+		return
+	}
+	if !used {
+		respondError(w, http.StatusUnauthorized, "Login session expired, please log in again")
+		return
+	}
+
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusUnauthorized, "User profile not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	session, err := h.sessionForProfile(profile)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.login", userID, profile.Role, map[string]interface{}{"method": "password+2fa"})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.AuthResponse{
+			AccessToken:  session.AccessToken,
+			RefreshToken: session.RefreshToken,
+			User:         profile,
+		},
+	})
+}
+
+// MFAStatus returns the caller's two-factor configuration
+func (h *AuthHandler) MFAStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.mfa.Status(middleware.GetUserID(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch two-factor status")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// EnrollMFA creates a pending TOTP secret and returns its provisioning URI
+func (h *AuthHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	profile, err := h.store.Profiles.GetProfile(userID)
+	if err == store.ErrNotFound {
+		respondError(w, http.StatusNotFound, "Profile not found")
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	account := profile.Email
+	if account == "" && profile.Phone != nil {
+		account = *profile.Phone
+	}
+
+	secret, uri, err := h.mfa.Enroll(userID, account)
+	if err == mfa.ErrAlreadyEnabled {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to start enrolment")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.MFAEnrollResponse{
+			Secret:          secret,
+			ProvisioningURI: uri,
+		},
+		Message: "Scan the code in your authenticator app, then confirm with a code",
+	})
+}
+
+// ConfirmMFA activates 2FA with a first code and returns the recovery codes
+func (h *AuthHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	codes, err := h.mfa.Confirm(middleware.GetUserID(r), req.Code)
+	switch err {
+	case nil:
+	case mfa.ErrInvalidCode, mfa.ErrNotEnrolled:
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	case mfa.ErrAlreadyEnabled:
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	default:
+		respondError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.mfa.enable", middleware.GetUserID(r), middleware.GetUserRole(r), nil)
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"recovery_codes": codes},
+		Message: "Two-factor authentication enabled. Store these recovery codes somewhere safe; they will not be shown again.",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a current code
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	if !h.checkMFACode(w, r, userID) {
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.mfa.recovery_codes.regenerate", userID, middleware.GetUserRole(r), nil)
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    map[string]interface{}{"recovery_codes": codes},
+		Message: "New recovery codes generated; the old ones no longer work",
+	})
+}
+
+// UpdateMFASettings turns "require 2FA for sensitive actions" on or off
+func (h *AuthHandler) UpdateMFASettings(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req models.MFASettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if !h.verifyMFACode(w, userID, req.Code) {
+		return
+	}
+
+	if err := h.mfa.SetRequireForSensitive(userID, req.RequireForSensitive); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to update settings")
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.mfa.settings.update", userID, middleware.GetUserRole(r), map[string]interface{}{"require_for_sensitive": req.RequireForSensitive})
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Two-factor settings updated",
+	})
+}
+
+// DisableMFA turns off 2FA after checking a current code
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	if !h.checkMFACode(w, r, userID) {
+		return
+	}
+
+	if err := h.mfa.Disable(userID); err != nil {
+		respondError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	h.recordAuthEvent(r, "auth.mfa.disable", userID, middleware.GetUserRole(r), nil)
+
+	respondJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// checkMFACode decodes a {"code": ...} body and verifies it, writing the
+// error response itself when verification fails
+func (h *AuthHandler) checkMFACode(w http.ResponseWriter, r *http.Request, userID string) bool {
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+
+	return h.verifyMFACode(w, userID, req.Code)
+}
+
+// verifyMFACode checks a code under the user's two-factor lockout, writing
+// the error response itself when it fails
+func (h *AuthHandler) verifyMFACode(w http.ResponseWriter, userID, code string) bool {
+	wait, err := middleware.VerifyMFACode(h.mfa, h.store.Lockouts, userID, code)
+	if wait > 0 {
+		respondMFALockedOut(w, wait)
+		return false
+	}
+	if err != nil {
+		respondMFAError(w, err)
+		return false
+	}
+	return true
+}
+
+// respondMFALockedOut answers a code check for a user locked out by wrong codes
+func respondMFALockedOut(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+	respondError(w, http.StatusTooManyRequests, "Too many invalid two-factor codes, please try again later")
+}
+
+func respondMFAError(w http.ResponseWriter, err error) {
+	switch err {
+	case mfa.ErrInvalidCode:
+		respondError(w, http.StatusForbidden, err.Error())
+	case mfa.ErrNotEnabled:
+		respondError(w, http.StatusBadRequest, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, "Failed to verify two-factor code")
+	}
+}
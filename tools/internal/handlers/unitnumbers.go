@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxGeneratedUnits caps how many units one pattern may generate
+const maxGeneratedUnits = 500
+
+// unitNumbers expands a unit pattern into unit numbers. A pattern is a
+// comma-separated list of unit numbers and ranges: "A1..A12" or
+// "101..110, 201..210, Shop". Both ends of a range share a prefix and end
+// in digits; a zero-padded start ("A01..A12") keeps the padding.
+func unitNumbers(pattern string) ([]string, error) {
+	var numbers []string
+	seen := map[string]bool{}
+	for _, part := range strings.Split(pattern, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		expanded := []string{part}
+		if from, to, ok := strings.Cut(part, ".."); ok {
+			var err error
+			if expanded, err = unitRange(strings.TrimSpace(from), strings.TrimSpace(to)); err != nil {
+				return nil, err
+			}
+		}
+		for _, n := range expanded {
+			if seen[n] {
+				return nil, fmt.Errorf("unit %s appears twice in the pattern", n)
+			}
+			seen[n] = true
+			if numbers = append(numbers, n); len(numbers) > maxGeneratedUnits {
+				return nil, fmt.Errorf("a pattern can generate at most %d units", maxGeneratedUnits)
+			}
+		}
+	}
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("pattern has no units")
+	}
+	return numbers, nil
+}
+
+func unitRange(from, to string) ([]string, error) {
+	fromPrefix, fromDigits := splitDigits(from)
+	toPrefix, toDigits := splitDigits(to)
+	if fromDigits == "" || toDigits == "" || fromPrefix != toPrefix {
+		return nil, fmt.Errorf("range %s..%s must run between numbers with the same prefix, like A1..A12", from, to)
+	}
+	start, err1 := strconv.Atoi(fromDigits)
+	end, err2 := strconv.Atoi(toDigits)
+	if err1 != nil || err2 != nil || start > end {
+		return nil, fmt.Errorf("range %s..%s must count upwards", from, to)
+	}
+	if end-start >= maxGeneratedUnits {
+		return nil, fmt.Errorf("a pattern can generate at most %d units", maxGeneratedUnits)
+	}
+	width := 0
+	if len(fromDigits) > 1 && fromDigits[0] == '0' {
+		width = len(fromDigits)
+	}
+	numbers := make([]string, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		numbers = append(numbers, fmt.Sprintf("%s%0*d", fromPrefix, width, n))
+	}
+	return numbers, nil
+}
+
+// splitDigits splits "A12" into "A" and "12"
+func splitDigits(s string) (prefix, digits string) {
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+	return s[:i], s[i:]
+}
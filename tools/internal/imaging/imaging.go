@@ -0,0 +1,129 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	// Registered decoders: the formats accepted for uploads
+	_ "image/gif"
+	_ "image/png"
+)
+
+// maxPixels caps the decoded size so a small, highly compressed file
+// cannot expand into gigabytes of memory
+const maxPixels = 16_000_000
+
+// jpegQuality is used for every re-encoded image
+const jpegQuality = 85
+
+var (
+	ErrUnsupported = errors.New("image must be a JPEG, PNG or GIF")
+	ErrTooLarge    = errors.New("image dimensions are too large")
+)
+
+// Decode reads a JPEG, PNG or GIF, checking its dimensions before the
+// pixels are decoded. Anything else returns ErrUnsupported.
+func Decode(data []byte) (image.Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if format != "jpeg" && format != "png" && format != "gif" {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	return img, nil
+}
+
+// Fit scales img down so neither side exceeds limit, keeping its aspect
+// ratio. Smaller images are returned at their own size.
+func Fit(img image.Image, limit int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > limit || h > limit {
+		if w >= h {
+			w, h = limit, max(h*limit/w, 1)
+		} else {
+			w, h = max(w*limit/h, 1), limit
+		}
+	}
+	return scale(flatten(img), b, w, h)
+}
+
+// Square centre-crops img to a square and scales it to size×size
+func Square(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+	return scale(flatten(img), crop, size, size)
+}
+
+// EncodeJPEG encodes img as a JPEG. Re-encoding also drops any metadata
+// (EXIF location, camera details) carried by the original file.
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// flatten draws img onto an opaque white RGBA canvas, so transparent
+// PNGs and GIFs don't turn black when saved as JPEG
+func flatten(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// scale resamples the src region of img to w×h by averaging the source
+// pixels that fall into each destination pixel (a box filter). Enlarging
+// repeats pixels, which is only hit for images smaller than the target.
+func scale(img *image.RGBA, src image.Rectangle, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Dx(), src.Dy()
+
+	for y := 0; y < h; y++ {
+		sy0 := src.Min.Y + y*sh/h
+		sy1 := max(src.Min.Y+(y+1)*sh/h, sy0+1)
+		for x := 0; x < w; x++ {
+			sx0 := src.Min.X + x*sw/w
+			sx1 := max(src.Min.X+(x+1)*sw/w, sx0+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				i := img.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint64(img.Pix[i])
+					g += uint64(img.Pix[i+1])
+					b += uint64(img.Pix[i+2])
+					a += uint64(img.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
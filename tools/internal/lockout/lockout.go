@@ -0,0 +1,66 @@
+// Package lockout counts consecutive failures per key in the login_lockouts
+// table and locks the key out progressively. Handlers key password logins by
+// email; the two-factor middleware keys sensitive-action codes by user.
+package lockout
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// After Threshold consecutive failures a key is locked for Base, doubling
+// with each further failure up to Max. Failures older than Window are
+// forgotten.
+const (
+	Threshold = 5
+	Base      = time.Minute
+	Max       = time.Hour
+	Window    = 24 * time.Hour
+)
+
+// get loads the lockout for key, or nil if there is none
+func get(lockouts store.LockoutStore, key string) (*models.LoginLockout, error) {
+	lockout, err := lockouts.GetLoginLockout(key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lockout, nil
+}
+
+// LockedFor returns how long key is still locked out, or 0
+func LockedFor(lockouts store.LockoutStore, key string) (time.Duration, error) {
+	lockout, err := get(lockouts, key)
+	if err != nil || lockout == nil || lockout.LockedUntil == nil {
+		return 0, err
+	}
+	if wait := time.Until(*lockout.LockedUntil); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// RecordFailure counts a failure and returns the lockout it triggers, or 0
+// while key is still under the threshold
+func RecordFailure(lockouts store.LockoutStore, key string) (time.Duration, error) {
+	lockout, err := lockouts.RecordLoginFailure(key, store.LockoutPolicy{
+		Threshold: Threshold,
+		Base:      Base,
+		Max:       Max,
+		Window:    Window,
+	})
+	if err != nil || lockout.FailedCount < Threshold || lockout.LockedUntil == nil {
+		return 0, err
+	}
+	return max(time.Until(*lockout.LockedUntil), 0), nil
+}
+
+// Clear resets the count after a success
+func Clear(lockouts store.LockoutStore, key string) {
+	lockouts.ClearLoginLockout(key)
+}
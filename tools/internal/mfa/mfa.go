@@ -0,0 +1,196 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrNotEnrolled    = errors.New("start two-factor enrolment first")
+	ErrNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidCode    = errors.New("invalid two-factor code")
+)
+
+// Status describes a user's two-factor configuration
+type Status struct {
+	Enabled             bool       `json:"enabled"`
+	EnabledAt           *time.Time `json:"enabled_at,omitempty"`
+	RequireForSensitive bool       `json:"require_for_sensitive"`
+	RecoveryCodesLeft   int        `json:"recovery_codes_left"`
+}
+
+// Service manages TOTP enrolment, verification and recovery codes
+type Service struct {
+	store  store.MFAStore
+	issuer string
+}
+
+func NewService(mfaStore store.MFAStore, issuer string) *Service {
+	return &Service{store: mfaStore, issuer: issuer}
+}
+
+// load returns the user's record, or nil if they never enrolled
+func (s *Service) load(userID string) (*models.UserMFA, error) {
+	rec, err := s.store.GetMFA(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Status returns the user's current configuration
+func (s *Service) Status(userID string) (Status, error) {
+	rec, err := s.load(userID)
+	if err != nil || rec == nil || rec.EnabledAt == nil {
+		return Status{}, err
+	}
+
+	left, err := s.store.CountRecoveryCodes(userID)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return Status{
+		Enabled:             true,
+		EnabledAt:           rec.EnabledAt,
+		RequireForSensitive: rec.RequireForSensitive,
+		RecoveryCodesLeft:   left,
+	}, nil
+}
+
+// Enroll starts (or restarts) enrolment with a fresh secret. The secret is
+// not active until Confirm succeeds with a code from the user's app.
+func (s *Service) Enroll(userID, account string) (secret, uri string, err error) {
+	rec, err := s.load(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if rec != nil && rec.EnabledAt != nil {
+		return "", "", ErrAlreadyEnabled
+	}
+
+	secret = GenerateSecret()
+	if err := s.store.PutMFA(models.UserMFA{UserID: userID, Secret: secret}); err != nil {
+		return "", "", err
+	}
+	return secret, ProvisioningURI(secret, s.issuer, account), nil
+}
+
+// Confirm activates a pending enrolment and returns the initial recovery codes
+func (s *Service) Confirm(userID, code string) ([]string, error) {
+	rec, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrNotEnrolled
+	}
+	if rec.EnabledAt != nil {
+		return nil, ErrAlreadyEnabled
+	}
+
+	step, ok := matchStep(rec.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	update := map[string]interface{}{
+		"enabled_at":     time.Now().UTC(),
+		"last_used_step": step,
+	}
+	if err := s.store.UpdateMFA(userID, update); err != nil {
+		return nil, err
+	}
+
+	return s.RegenerateRecoveryCodes(userID)
+}
+
+// Verify accepts either a current TOTP code or an unused recovery code.
+// TOTP codes are single-use: a step at or before the last accepted one is
+// rejected, and AdvanceMFAStep makes that check race-free.
+func (s *Service) Verify(userID, code string) error {
+	rec, err := s.load(userID)
+	if err != nil {
+		return err
+	}
+	if rec == nil || rec.EnabledAt == nil {
+		return ErrNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := matchStep(rec.Secret, code, time.Now()); ok {
+		advanced, err := s.store.AdvanceMFAStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	return s.useRecoveryCode(userID, code)
+}
+
+func (s *Service) useRecoveryCode(userID, code string) error {
+	used, err := s.store.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. The plaintext codes
+// are returned once and only their hashes are stored.
+func (s *Service) RegenerateRecoveryCodes(userID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i] = generateRecoveryCode()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := s.store.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// SetRequireForSensitive toggles whether sensitive actions need a fresh code
+func (s *Service) SetRequireForSensitive(userID string, required bool) error {
+	return s.store.UpdateMFA(userID, map[string]interface{}{"require_for_sensitive": required})
+}
+
+// Disable removes the user's secret and recovery codes
+func (s *Service) Disable(userID string) error {
+	return s.store.DeleteMFA(userID)
+}
+
+// generateRecoveryCode returns a code like "k7q2m-x9fa3" (50 bits of entropy)
+func generateRecoveryCode() string {
+	buf := make([]byte, 7)
+	rand.Read(buf)
+	s := strings.ToLower(b32.EncodeToString(buf))[:10]
+	return s[:5] + "-" + s[5:]
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,23 +2,34 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/ratelimit"
+	"github.com/aletheia/backend/internal/store"
 	supabase "github.com/supabase-community/supabase-go"
 )
 
 type contextKey string
 
 const (
-	UserIDKey   contextKey = "user_id"
-	UserRoleKey contextKey = "user_role"
+	UserIDKey        contextKey = "user_id"
+	UserRoleKey      contextKey = "user_role"
+	UserRolesKey     contextKey = "user_roles"
+	EmailVerifiedKey contextKey = "email_verified"
 )
 
-// AuthMiddleware validates the JWT token via Supabase Auth
-func AuthMiddleware(supabaseURL, serviceKey string) func(http.Handler) http.Handler {
+// ActiveRoleHeader selects which of the account's roles a request acts as.
+// Without it the profile's default role is used.
+const ActiveRoleHeader = "X-Active-Role"
+
+// AuthMiddleware validates the JWT token via Supabase Auth and loads the
+// user's profile from the store. Bearer tokens starting with APIKeyPrefix
+// are treated as API keys instead; see AllowAPIKey.
+func AuthMiddleware(supabaseURL string, st *store.Store, keyLimiter *ratelimit.Limiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -33,6 +44,13 @@ func AuthMiddleware(supabaseURL, serviceKey string) func(http.Handler) http.Hand
 				return
 			}
 
+			if strings.HasPrefix(token, APIKeyPrefix) {
+				if r, ok := authenticateAPIKey(st, keyLimiter, w, r, token); ok {
+					next.ServeHTTP(w, r)
+				}
+				return
+			}
+
 			// Create an authenticated client using the user's token
 			userClient, err := supabase.NewClient(supabaseURL, token, &supabase.ClientOptions{})
 			if err != nil {
@@ -50,29 +68,48 @@ func AuthMiddleware(supabaseURL, serviceKey string) func(http.Handler) http.Hand
 			userID := user.ID.String()
 
 			// Get user profile to determine role
-			data, _, err := userClient.From("profiles").Select("role", "exact", false).Eq("id", userID).Execute()
+			profile, err := st.Profiles.GetProfile(userID)
 			if err != nil {
 				writeError(w, http.StatusUnauthorized, "User profile not found")
 				return
 			}
-
-			var profiles []struct {
-				Role string `json:"role"`
-			}
-			if err := json.Unmarshal(data, &profiles); err != nil || len(profiles) == 0 {
-				writeError(w, http.StatusUnauthorized, "User profile not found")
+			// Erased accounts are banned from signing in, but tokens issued
+			// before the erasure would otherwise work until they expire
+			if profile.DeletedAt != nil {
+				writeError(w, http.StatusUnauthorized, "This account has been deleted")
 				return
 			}
+			roles := heldRoles(profile)
+
+			activeRole := profile.Role
+			if requested := r.Header.Get(ActiveRoleHeader); requested != "" {
+				if !slices.Contains(roles, requested) {
+					writeError(w, http.StatusForbidden, "Your account does not have the requested role")
+					return
+				}
+				activeRole = requested
+			}
 
 			// Add user info to context
 			ctx := context.WithValue(r.Context(), UserIDKey, userID)
-			ctx = context.WithValue(ctx, UserRoleKey, profiles[0].Role)
+			ctx = context.WithValue(ctx, UserRoleKey, activeRole)
+			ctx = context.WithValue(ctx, UserRolesKey, roles)
+			ctx = context.WithValue(ctx, EmailVerifiedKey, profile.EmailVerifiedAt != nil)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// RequireRole checks that the user has the required role
+// heldRoles returns every role on the profile. Profiles created before
+// multi-role accounts only have role set.
+func heldRoles(p models.Profile) []string {
+	if len(p.Roles) == 0 {
+		return []string{p.Role}
+	}
+	return p.Roles
+}
+
+// RequireRole checks that the request is acting in the required role
 func RequireRole(role string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -86,6 +123,17 @@ func RequireRole(role string) func(http.Handler) http.Handler {
 	}
 }
 
+// RequireVerifiedEmail blocks users who have not confirmed their email address
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsEmailVerified(r) {
+			writeError(w, http.StatusForbidden, "Please verify your email address first")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // GetUserID extracts the user ID from context
 func GetUserID(r *http.Request) string {
 	if id, ok := r.Context().Value(UserIDKey).(string); ok {
@@ -94,7 +142,7 @@ func GetUserID(r *http.Request) string {
 	return ""
 }
 
-// GetUserRole extracts the user role from context
+// GetUserRole extracts the active role for this request from context
 func GetUserRole(r *http.Request) string {
 	if role, ok := r.Context().Value(UserRoleKey).(string); ok {
 		return role
@@ -102,6 +150,20 @@ func GetUserRole(r *http.Request) string {
 	return ""
 }
 
+// GetUserRoles returns every role the account holds
+func GetUserRoles(r *http.Request) []string {
+	if roles, ok := r.Context().Value(UserRolesKey).([]string); ok {
+		return roles
+	}
+	return nil
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func IsEmailVerified(r *http.Request) bool {
+	verified, _ := r.Context().Value(EmailVerifiedKey).(bool)
+	return verified
+}
+
 func writeError(w http.ResponseWriter, status int, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
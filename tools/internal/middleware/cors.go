@@ -1,21 +1,116 @@
 package middleware
 
-import "net/http"
-
-// CORSMiddleware handles Cross-Origin Resource Sharing headers
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "86400")
-
-		// Handle preflight requests
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig lists what cross-origin callers may do. An origin may be exact
+// ("https://app.aletheia.ng"), a single-label wildcard subdomain
+// ("https://*.vercel.app" matches "https://aletheia-git-x.vercel.app" but
+// not "https://vercel.app" or "https://a.b.vercel.app"), or "*" for any
+// origin — in which case credentials are never allowed.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int // seconds
+}
+
+// DefaultCORSConfig is the API's methods and headers with no origins allowed
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-2FA-Code", "X-Active-Role", "X-Impersonate", "X-Request-ID"},
+		ExposedHeaders:   []string{"Retry-After", "X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           86400,
+	}
+}
+
+// CORS handles Cross-Origin Resource Sharing headers. Requests from origins
+// that are not allowed get no CORS headers, so browsers block the response;
+// their preflights are refused outright.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	anyOrigin := false
+	for _, o := range cfg.AllowedOrigins {
+		anyOrigin = anyOrigin || o == "*"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			switch {
+			case anyOrigin:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case originAllowed(cfg.AllowedOrigins, origin):
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if cfg.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			default:
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", exposed)
+			}
+
+			// Handle preflight requests
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// originAllowed matches origin against exact and wildcard entries
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSuffix(a, "/"))
+		if a == origin {
+			return true
 		}
 
-		next.ServeHTTP(w, r)
-	})
+		scheme, host, ok := strings.Cut(a, "://*.")
+		if !ok {
+			continue
+		}
+		prefix := scheme + "://"
+		suffix := "." + host
+		if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+		label := strings.TrimSuffix(strings.TrimPrefix(origin, prefix), suffix)
+		if label != "" && !strings.ContainsAny(label, "./:@") {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// ImpersonateHeader carries the token from POST /admin/impersonate
+const ImpersonateHeader = "X-Impersonate"
+
+const ImpersonatorKey contextKey = "impersonator_id"
+
+// Impersonation lets a platform admin see the API as another user for
+// support. It must run after AuthMiddleware. With a valid X-Impersonate
+// token the request's identity is swapped for the target user's; only GET
+// and HEAD are allowed, and every request is written to the audit log.
+func Impersonation(st *store.Store, auditLog *audit.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(ImpersonateHeader)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			adminID := GetUserID(r)
+			if GetUserRole(r) != "admin" {
+				writeError(w, http.StatusForbidden, "Only platform admins can impersonate")
+				return
+			}
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				writeError(w, http.StatusForbidden, "Impersonation is read-only")
+				return
+			}
+
+			sum := sha256.Sum256([]byte(token))
+			sess, err := st.Admin.FindImpersonation(hex.EncodeToString(sum[:]), adminID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				writeError(w, http.StatusInternalServerError, "Failed to check impersonation session")
+				return
+			}
+			if err != nil || time.Now().After(sess.ExpiresAt) {
+				writeError(w, http.StatusUnauthorized, "Impersonation session expired")
+				return
+			}
+			targetID := sess.UserID
+
+			target, err := st.Profiles.GetProfile(targetID)
+			if errors.Is(err, store.ErrNotFound) {
+				writeError(w, http.StatusNotFound, "Impersonated user not found")
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to load impersonated user")
+				return
+			}
+
+			auditLog.Record(r, audit.Entry{
+				ActorID:      adminID,
+				ActorRole:    "admin",
+				Action:       "admin.impersonation.request",
+				ResourceType: "user",
+				ResourceID:   targetID,
+				Metadata:     map[string]interface{}{"method": r.Method, "path": r.URL.Path},
+			})
+
+			ctx := context.WithValue(r.Context(), UserIDKey, targetID)
+			ctx = context.WithValue(ctx, UserRoleKey, target.Role)
+			ctx = context.WithValue(ctx, UserRolesKey, heldRoles(target))
+			ctx = context.WithValue(ctx, EmailVerifiedKey, target.EmailVerifiedAt != nil)
+			ctx = context.WithValue(ctx, ImpersonatorKey, adminID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetImpersonator returns the admin behind an impersonated request, or ""
+func GetImpersonator(r *http.Request) string {
+	id, _ := r.Context().Value(ImpersonatorKey).(string)
+	return id
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aletheia/backend/internal/lockout"
+	"github.com/aletheia/backend/internal/mfa"
+	"github.com/aletheia/backend/internal/ratelimit"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// MFACodeHeader carries a TOTP or recovery code for sensitive actions
+const MFACodeHeader = "X-2FA-Code"
+
+// RequireMFA guards sensitive actions. Users who have turned on
+// "require 2FA for sensitive actions" must send a valid code in the
+// X-2FA-Code header; everyone else passes straight through. Wrong codes are
+// counted per user like failed passwords, and lock the user's sensitive
+// actions after lockout.Threshold in a row.
+func RequireMFA(svc *mfa.Service, lockouts store.LockoutStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := GetUserID(r)
+
+			status, err := svc.Status(userID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to check two-factor settings")
+				return
+			}
+			if !status.Enabled || !status.RequireForSensitive {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			code := r.Header.Get(MFACodeHeader)
+			if code == "" {
+				if wait, _ := lockout.LockedFor(lockouts, mfaLockoutKey(userID)); wait > 0 {
+					mfaLockedOut(w, wait)
+					return
+				}
+				writeError(w, http.StatusForbidden, "Two-factor code required")
+				return
+			}
+			wait, err := VerifyMFACode(svc, lockouts, userID, code)
+			if wait > 0 {
+				mfaLockedOut(w, wait)
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusForbidden, "Invalid two-factor code")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// VerifyMFACode checks a user's TOTP or recovery code under their two-factor
+// lockout, which every place that takes a code shares. While the user is
+// locked out, including by this code, it returns how long for without
+// checking anything more; otherwise it returns svc.Verify's result. Wrong
+// codes are counted and a right one clears the count.
+func VerifyMFACode(svc *mfa.Service, lockouts store.LockoutStore, userID, code string) (time.Duration, error) {
+	key := mfaLockoutKey(userID)
+	wait, err := lockout.LockedFor(lockouts, key)
+	if err != nil {
+		log.Printf("two-factor lockout check failed: %v", err)
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	err = svc.Verify(userID, code)
+	if err == mfa.ErrInvalidCode {
+		wait, lockErr := lockout.RecordFailure(lockouts, key)
+		if lockErr != nil {
+			log.Printf("failed to record two-factor failure: %v", lockErr)
+		}
+		return wait, err
+	}
+	if err == nil {
+		lockout.Clear(lockouts, key)
+	}
+	return 0, err
+}
+
+// mfaLockoutKey is the login_lockouts key for a user's two-factor codes. It is
+// upper case, so a login can never reach it: email keys are lower-case hex.
+func mfaLockoutKey(userID string) string {
+	return "MFA:" + userID
+}
+
+func mfaLockedOut(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+	writeError(w, http.StatusTooManyRequests, "Too many invalid two-factor codes, please try again later")
+}
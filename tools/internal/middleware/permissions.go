@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aletheia/backend/internal/access"
+)
+
+const GrantsKey contextKey = "grants"
+
+// WithGrants loads the user's per-building permissions into the context
+// without requiring any particular one. Use it on routes shared by tenants
+// and building staff, where the handler decides what to show. A request
+// acting as a tenant gets no grants, so a landlord who also rents sees only
+// their tenant data while in that role.
+func WithGrants(res *access.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			grants := access.Grants{}
+			if GetUserRole(r) != "tenant" {
+				var err error
+				grants, err = res.Grants(GetUserID(r))
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, "Failed to load permissions")
+					return
+				}
+			}
+			ctx := context.WithValue(r.Context(), GrantsKey, grants)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequirePermission loads the user's grants and rejects users who hold perm
+// on no building at all. Landlords always pass so that a new landlord with
+// no buildings yet sees empty lists rather than errors. Handlers must still
+// check the specific building with Can or scope queries with BuildingsWith.
+func RequirePermission(res *access.Resolver, perm access.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return WithGrants(res)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if GetUserRole(r) != "landlord" && !GetGrants(r).Any(perm) {
+				writeError(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// GetGrants returns the grants loaded by WithGrants or RequirePermission
+func GetGrants(r *http.Request) access.Grants {
+	if g, ok := r.Context().Value(GrantsKey).(access.Grants); ok {
+		return g
+	}
+	return access.Grants{}
+}
+
+// Can reports whether the user holds perm on the given building
+func Can(r *http.Request, buildingID string, perm access.Permission) bool {
+	return GetGrants(r).Can(buildingID, perm)
+}
+
+// BuildingsWith returns the IDs of buildings where the user holds perm
+func BuildingsWith(r *http.Request, perm access.Permission) []string {
+	return GetGrants(r).BuildingIDs(perm)
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/aletheia/backend/internal/audit"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds a caller-supplied X-Request-ID
+const maxRequestIDLength = 128
+
+// RequestID gives every request an X-Request-ID, keeping one sent by the
+// caller or proxy, and echoes it in the response so support can match a
+// client error to its audit log entries
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(audit.RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+			r.Header.Set(audit.RequestIDHeader, id)
+		}
+		w.Header().Set(audit.RequestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
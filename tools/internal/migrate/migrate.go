@@ -0,0 +1,232 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Migrations are pairs of files in migrations/ named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. They are embedded
+// in the binary, so a deployed server can always bring its database up to
+// the schema it was built against.
+//
+//go:embed migrations/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// lockKey serialises migration runs across processes (pg_advisory_lock)
+const lockKey = 4_207_043
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied; AppliedAt is nil while
+// it is pending
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations in version order. Every version
+// needs both an up and a down file.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migrate: unexpected file %s", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(files, "migrations/"+e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migrate: version %d has two names, %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migrate: %04d_%s needs both an up and a down file", mig.Version, mig.Name)
+		}
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Migrator applies migrations to one database. Applied versions are
+// recorded in schema_migrations; each migration runs in its own
+// transaction, so a failing one leaves the database at the previous
+// version.
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+}
+
+// Open connects to databaseURL (a postgres:// URL, e.g. the Supabase
+// direct connection string)
+func Open(ctx context.Context, databaseURL string) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+func (m *Migrator) Close(ctx context.Context) error {
+	return m.conn.Close(ctx)
+}
+
+// Up applies every pending migration in order and returns those applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(applied map[int]time.Time) error {
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			err := m.apply(ctx, mig.Up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `insert into schema_migrations (version, name) values ($1, $2)`, mig.Version, mig.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migrate: %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations (all of them when
+// steps < 0) and returns those reverted, latest first
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(applied map[int]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps != 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			err := m.apply(ctx, mig.Down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `delete from schema_migrations where version = $1`, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migrate: reverting %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+			steps--
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every known migration with when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var out []Status
+	err := m.locked(ctx, func(applied map[int]time.Time) error {
+		for _, mig := range m.migrations {
+			s := Status{Migration: mig}
+			if at, ok := applied[mig.Version]; ok {
+				s.AppliedAt = &at
+			}
+			out = append(out, s)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// locked runs fn while holding the migration lock, with the applied
+// versions read after taking it
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int]time.Time) error) error {
+	if _, err := m.conn.Exec(ctx, `select pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer m.conn.Exec(context.WithoutCancel(ctx), `select pg_advisory_unlock($1)`, lockKey)
+
+	if _, err := m.conn.Exec(ctx, `create table if not exists schema_migrations (
+		version integer primary key,
+		name text not null,
+		applied_at timestamptz not null default now()
+	)`); err != nil {
+		return err
+	}
+
+	rows, err := m.conn.Query(ctx, `select version, applied_at from schema_migrations`)
+	if err != nil {
+		return err
+	}
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return fn(applied)
+}
+
+// apply runs a migration script and its bookkeeping in one transaction.
+// Without arguments pgx uses the simple protocol, so a script may hold
+// several statements.
+func (m *Migrator) apply(ctx context.Context, script string, record func(pgx.Tx) error) error {
+	tx, err := m.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
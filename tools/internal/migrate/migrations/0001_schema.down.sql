@@ -0,0 +1,29 @@
+-- Drops every table and all their data. The auth schema and API roles are
+-- left alone: on Supabase they are not ours to remove.
+
+drop table if exists rate_limit_buckets;
+drop table if exists login_lockouts;
+drop table if exists mfa_recovery_codes;
+drop table if exists user_mfa;
+drop table if exists phone_otps;
+drop table if exists auth_tokens;
+drop table if exists audit_log;
+drop table if exists impersonation_sessions;
+drop table if exists webhook_events;
+drop table if exists api_keys;
+drop table if exists notifications;
+drop table if exists documents;
+drop table if exists maintenance_requests;
+drop table if exists payments;
+drop table if exists invitations;
+drop table if exists units;
+drop table if exists building_members;
+drop table if exists building_owners;
+drop table if exists buildings;
+drop table if exists organisation_members;
+drop table if exists organisations;
+drop table if exists profiles;
+
+drop function if exists public.protect_audit_log();
+drop function if exists public.protect_payments();
+drop function if exists public.set_updated_at();
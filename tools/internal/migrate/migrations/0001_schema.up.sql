@@ -0,0 +1,398 @@
+-- Tables, constraints and indexes. Column names follow the Go models in
+-- internal/models; foreign keys use Postgres' default <table>_<column>_fkey
+-- names, which the PostgREST embeds in the handlers refer to
+-- (e.g. profiles!units_tenant_id_fkey).
+--
+-- Tables are created "if not exists" so a project whose schema was set up
+-- by hand can adopt the migrations with `server migrate up`.
+
+-- Supabase provides the auth schema and the API roles. A bare Postgres
+-- (CI, local development) gets minimal stand-ins; existing ones are never
+-- replaced.
+do $$
+begin
+  if not exists (select 1 from pg_roles where rolname = 'anon') then
+    create role anon nologin noinherit;
+  end if;
+  if not exists (select 1 from pg_roles where rolname = 'authenticated') then
+    create role authenticated nologin noinherit;
+  end if;
+  if not exists (select 1 from pg_roles where rolname = 'service_role') then
+    create role service_role nologin noinherit bypassrls;
+  end if;
+end $$;
+
+create schema if not exists auth;
+create table if not exists auth.users (id uuid primary key);
+
+do $$
+begin
+  if to_regprocedure('auth.uid()') is null then
+    create function auth.uid() returns uuid language sql stable as $f$
+      select nullif(current_setting('request.jwt.claim.sub', true), '')::uuid
+    $f$;
+  end if;
+end $$;
+
+create or replace function public.set_updated_at() returns trigger
+language plpgsql as $$
+begin
+  new.updated_at := now();
+  return new;
+end $$;
+
+-- Users
+
+create table if not exists profiles (
+  id uuid primary key constraint profiles_id_fkey references auth.users (id) on delete cascade,
+  role text not null default 'tenant' check (role in ('landlord', 'tenant', 'staff', 'admin')),
+  roles text[] not null default '{}',
+  full_name text not null default '',
+  email text,
+  email_bidx text constraint profiles_email_bidx_key unique,
+  phone text,
+  phone_bidx text constraint profiles_phone_bidx_key unique,
+  avatar_url text,
+  avatar_thumb_url text,
+  pending_email text,
+  pending_phone text,
+  email_verified_at timestamptz,
+  phone_verified_at timestamptz,
+  deleted_at timestamptz,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+-- Organisations and buildings
+
+create table if not exists organisations (
+  id uuid primary key default gen_random_uuid(),
+  name text not null,
+  created_by uuid not null constraint organisations_created_by_fkey references profiles (id),
+  created_at timestamptz not null default now()
+);
+
+create table if not exists organisation_members (
+  id uuid primary key default gen_random_uuid(),
+  organisation_id uuid not null constraint organisation_members_organisation_id_fkey references organisations (id) on delete cascade,
+  user_id uuid constraint organisation_members_user_id_fkey references profiles (id),
+  role text not null check (role in ('admin', 'manager', 'caretaker', 'accountant', 'owner')),
+  status text not null default 'invited' check (status in ('invited', 'active', 'revoked')),
+  email text,
+  email_bidx text,
+  phone text,
+  phone_bidx text,
+  token_hash text constraint organisation_members_token_hash_key unique,
+  invited_by uuid not null constraint organisation_members_invited_by_fkey references profiles (id),
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null default now() + interval '7 days',
+  accepted_at timestamptz,
+  revoked_at timestamptz
+);
+create index if not exists organisation_members_organisation_id_idx on organisation_members (organisation_id);
+create index if not exists organisation_members_user_id_idx on organisation_members (user_id);
+
+create table if not exists buildings (
+  id uuid primary key default gen_random_uuid(),
+  landlord_id uuid not null constraint buildings_landlord_id_fkey references profiles (id),
+  organisation_id uuid constraint buildings_organisation_id_fkey references organisations (id),
+  name text not null,
+  address text not null,
+  total_units integer not null default 0 check (total_units >= 0),
+  photo_url text,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+create index if not exists buildings_landlord_id_idx on buildings (landlord_id);
+create index if not exists buildings_organisation_id_idx on buildings (organisation_id);
+
+create table if not exists building_owners (
+  id uuid primary key default gen_random_uuid(),
+  building_id uuid not null constraint building_owners_building_id_fkey references buildings (id) on delete cascade,
+  user_id uuid not null constraint building_owners_user_id_fkey references profiles (id),
+  share_bps integer not null check (share_bps > 0 and share_bps <= 10000),
+  managing boolean not null default false,
+  created_at timestamptz not null default now(),
+  constraint building_owners_building_id_user_id_key unique (building_id, user_id)
+);
+create index if not exists building_owners_user_id_idx on building_owners (user_id);
+
+create table if not exists building_members (
+  id uuid primary key default gen_random_uuid(),
+  building_id uuid not null constraint building_members_building_id_fkey references buildings (id) on delete cascade,
+  user_id uuid constraint building_members_user_id_fkey references profiles (id),
+  role text not null check (role in ('manager', 'caretaker', 'accountant')),
+  status text not null default 'invited' check (status in ('invited', 'active', 'revoked')),
+  email text,
+  email_bidx text,
+  phone text,
+  phone_bidx text,
+  token_hash text constraint building_members_token_hash_key unique,
+  invited_by uuid not null constraint building_members_invited_by_fkey references profiles (id),
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null default now() + interval '7 days',
+  accepted_at timestamptz,
+  revoked_at timestamptz
+);
+create index if not exists building_members_building_id_idx on building_members (building_id);
+create index if not exists building_members_user_id_idx on building_members (user_id);
+
+create table if not exists units (
+  id uuid primary key default gen_random_uuid(),
+  building_id uuid not null constraint units_building_id_fkey references buildings (id) on delete cascade,
+  tenant_id uuid constraint units_tenant_id_fkey references profiles (id),
+  unit_number text not null,
+  rent_amount bigint not null default 0 check (rent_amount >= 0),
+  lease_start date,
+  lease_end date,
+  status text not null default 'vacant' check (status in ('vacant', 'occupied')),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  constraint units_building_id_unit_number_key unique (building_id, unit_number)
+);
+create index if not exists units_tenant_id_idx on units (tenant_id);
+
+-- Tenancy
+
+create table if not exists invitations (
+  id uuid primary key default gen_random_uuid(),
+  unit_id uuid not null constraint invitations_unit_id_fkey references units (id) on delete cascade,
+  landlord_id uuid not null constraint invitations_landlord_id_fkey references profiles (id),
+  invited_by uuid constraint invitations_invited_by_fkey references profiles (id),
+  email text,
+  email_bidx text,
+  phone text,
+  phone_bidx text,
+  token text not null constraint invitations_token_key unique,
+  status text not null default 'pending' check (status in ('pending', 'accepted', 'expired')),
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null default now() + interval '7 days'
+);
+create index if not exists invitations_unit_id_idx on invitations (unit_id);
+create index if not exists invitations_email_bidx_idx on invitations (email_bidx);
+create index if not exists invitations_phone_bidx_idx on invitations (phone_bidx);
+
+-- Amounts are in kobo. status was documented as "success" and period as
+-- "period_label"; the API has always written "successful" and "period".
+create table if not exists payments (
+  id uuid primary key default gen_random_uuid(),
+  tenant_id uuid not null constraint payments_tenant_id_fkey references profiles (id),
+  unit_id uuid not null constraint payments_unit_id_fkey references units (id),
+  building_id uuid not null constraint payments_building_id_fkey references buildings (id),
+  amount bigint not null check (amount >= 0),
+  currency text not null default 'NGN' check (currency = 'NGN'),
+  status text not null default 'pending' check (status in ('pending', 'successful', 'failed')),
+  payment_method text,
+  paystack_reference text constraint payments_paystack_reference_key unique,
+  paystack_transaction_id text,
+  period text not null default '',
+  recorded_by uuid constraint payments_recorded_by_fkey references profiles (id),
+  paid_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists payments_tenant_id_idx on payments (tenant_id, created_at desc);
+create index if not exists payments_building_id_idx on payments (building_id, created_at desc);
+create index if not exists payments_unit_id_idx on payments (unit_id);
+
+create table if not exists maintenance_requests (
+  id uuid primary key default gen_random_uuid(),
+  tenant_id uuid not null constraint maintenance_requests_tenant_id_fkey references profiles (id),
+  unit_id uuid not null constraint maintenance_requests_unit_id_fkey references units (id),
+  building_id uuid not null constraint maintenance_requests_building_id_fkey references buildings (id),
+  title text not null,
+  description text not null default '',
+  priority text not null default 'medium' check (priority in ('low', 'medium', 'high', 'urgent')),
+  status text not null default 'open' check (status in ('open', 'in_progress', 'resolved', 'closed')),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+create index if not exists maintenance_requests_tenant_id_idx on maintenance_requests (tenant_id);
+create index if not exists maintenance_requests_building_id_idx on maintenance_requests (building_id, created_at desc);
+
+create table if not exists documents (
+  id uuid primary key default gen_random_uuid(),
+  uploaded_by uuid not null constraint documents_uploaded_by_fkey references profiles (id),
+  building_id uuid constraint documents_building_id_fkey references buildings (id),
+  unit_id uuid constraint documents_unit_id_fkey references units (id),
+  name text not null,
+  type text not null check (type in ('lease_agreement', 'receipt', 'other')),
+  generated boolean not null default false,
+  file_url text not null,
+  og_cid text,
+  og_tx_hash text,
+  file_size bigint not null default 0,
+  created_at timestamptz not null default now()
+);
+create index if not exists documents_building_id_idx on documents (building_id);
+create index if not exists documents_unit_id_idx on documents (unit_id);
+create index if not exists documents_uploaded_by_idx on documents (uploaded_by);
+
+-- Messaging, integrations and auditing
+
+create table if not exists notifications (
+  id uuid primary key default gen_random_uuid(),
+  user_id uuid constraint notifications_user_id_fkey references profiles (id) on delete set null,
+  channel text not null check (channel in ('email', 'sms')),
+  type text not null,
+  payload jsonb,
+  sent_at timestamptz not null default now(),
+  status text not null check (status in ('sent', 'failed'))
+);
+create index if not exists notifications_user_id_idx on notifications (user_id);
+
+create table if not exists api_keys (
+  id uuid primary key default gen_random_uuid(),
+  user_id uuid not null constraint api_keys_user_id_fkey references profiles (id),
+  name text not null,
+  prefix text not null,
+  key_hash text not null constraint api_keys_key_hash_key unique,
+  scopes text[] not null default '{}',
+  rate_limit_per_minute integer not null default 60 check (rate_limit_per_minute between 1 and 600),
+  expires_at timestamptz not null,
+  last_used_at timestamptz,
+  revoked_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists api_keys_user_id_idx on api_keys (user_id);
+
+create table if not exists webhook_events (
+  id uuid primary key default gen_random_uuid(),
+  provider text not null default 'paystack',
+  event text not null default '',
+  reference text not null default '',
+  payload jsonb,
+  signature_valid boolean not null default false,
+  processed_at timestamptz,
+  error text,
+  received_at timestamptz not null default now()
+);
+create index if not exists webhook_events_reference_idx on webhook_events (reference, received_at desc);
+
+create table if not exists impersonation_sessions (
+  id uuid primary key default gen_random_uuid(),
+  admin_id uuid not null constraint impersonation_sessions_admin_id_fkey references profiles (id),
+  user_id uuid not null constraint impersonation_sessions_user_id_fkey references profiles (id),
+  reason text not null,
+  token_hash text not null constraint impersonation_sessions_token_hash_key unique,
+  expires_at timestamptz not null,
+  ended_at timestamptz,
+  created_at timestamptz not null default now()
+);
+
+create table if not exists audit_log (
+  id uuid primary key default gen_random_uuid(),
+  actor_id uuid constraint audit_log_actor_id_fkey references profiles (id),
+  actor_role text not null default '',
+  action text not null,
+  resource_type text not null,
+  resource_id text,
+  building_id uuid constraint audit_log_building_id_fkey references buildings (id),
+  changes jsonb,
+  metadata jsonb,
+  ip text not null default '',
+  user_agent text not null default '',
+  request_id text,
+  created_at timestamptz not null default now()
+);
+create index if not exists audit_log_building_id_idx on audit_log (building_id, created_at desc);
+create index if not exists audit_log_actor_id_idx on audit_log (actor_id, created_at desc);
+
+-- Sign-in security
+
+create table if not exists auth_tokens (
+  id uuid primary key default gen_random_uuid(),
+  user_id uuid not null constraint auth_tokens_user_id_fkey references profiles (id) on delete cascade,
+  purpose text not null check (purpose in ('password_reset', 'email_verification', 'email_change')),
+  token_hash text not null constraint auth_tokens_token_hash_key unique,
+  expires_at timestamptz not null,
+  used_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists auth_tokens_user_id_idx on auth_tokens (user_id, purpose);
+
+create table if not exists phone_otps (
+  id uuid primary key default gen_random_uuid(),
+  phone text not null,
+  phone_bidx text,
+  code_hash text not null,
+  attempts integer not null default 0,
+  expires_at timestamptz not null,
+  consumed_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists phone_otps_phone_bidx_idx on phone_otps (phone_bidx, created_at desc);
+
+create table if not exists user_mfa (
+  user_id uuid primary key constraint user_mfa_user_id_fkey references profiles (id) on delete cascade,
+  secret text not null,
+  enabled_at timestamptz,
+  last_used_step bigint not null default 0,
+  require_for_sensitive boolean not null default false,
+  created_at timestamptz not null default now()
+);
+
+create table if not exists mfa_recovery_codes (
+  id uuid primary key default gen_random_uuid(),
+  user_id uuid not null constraint mfa_recovery_codes_user_id_fkey references profiles (id) on delete cascade,
+  code_hash text not null,
+  used_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists mfa_recovery_codes_user_id_idx on mfa_recovery_codes (user_id);
+
+create table if not exists login_lockouts (
+  email text primary key,
+  failed_count integer not null default 0,
+  locked_until timestamptz,
+  last_failed_at timestamptz not null default now()
+);
+
+create table if not exists rate_limit_buckets (
+  key text primary key,
+  tokens double precision not null,
+  updated_at timestamptz not null default now()
+);
+
+-- Triggers
+
+drop trigger if exists profiles_updated_at on profiles;
+create trigger profiles_updated_at before update on profiles
+  for each row execute function set_updated_at();
+drop trigger if exists buildings_updated_at on buildings;
+create trigger buildings_updated_at before update on buildings
+  for each row execute function set_updated_at();
+drop trigger if exists units_updated_at on units;
+create trigger units_updated_at before update on units
+  for each row execute function set_updated_at();
+drop trigger if exists maintenance_requests_updated_at on maintenance_requests;
+create trigger maintenance_requests_updated_at before update on maintenance_requests
+  for each row execute function set_updated_at();
+
+-- Payments are append-only: a settled payment never changes and no
+-- payment is ever deleted (rule #2)
+create or replace function public.protect_payments() returns trigger
+language plpgsql as $$
+begin
+  if tg_op = 'DELETE' then
+    raise exception 'payments cannot be deleted';
+  end if;
+  if old.status = 'successful' then
+    raise exception 'payment % is settled and cannot be changed', old.id;
+  end if;
+  return new;
+end $$;
+
+drop trigger if exists payments_append_only on payments;
+create trigger payments_append_only before update or delete on payments
+  for each row execute function protect_payments();
+
+-- The audit log is append-only (rule #23)
+create or replace function public.protect_audit_log() returns trigger
+language plpgsql as $$
+begin
+  raise exception 'audit_log is append-only';
+end $$;
+
+drop trigger if exists audit_log_append_only on audit_log;
+create trigger audit_log_append_only before update or delete on audit_log
+  for each row execute function protect_audit_log();
@@ -0,0 +1,2 @@
+drop function if exists public.accept_invitation(text, uuid, text, text, text, text, text, timestamptz, timestamptz);
+drop function if exists public.rate_limit_take(text, integer, integer);
@@ -0,0 +1,114 @@
+-- Database functions the API calls through PostgREST (POST /rest/v1/rpc/<name>).
+-- Only the service role may execute them.
+
+-- rate_limit_take refills the key's token bucket for the time since it was
+-- last used, then spends one token under a row lock. Mirrors
+-- ratelimit.MemoryStore so both stores limit alike.
+create or replace function public.rate_limit_take(p_key text, p_per_minute integer, p_burst integer)
+returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_now timestamptz := clock_timestamp();
+  v_rate double precision := p_per_minute / 60.0; -- tokens per second
+  v_tokens double precision;
+begin
+  insert into rate_limit_buckets (key, tokens, updated_at)
+  values (p_key, p_burst, v_now)
+  on conflict (key) do nothing;
+
+  select least(p_burst, tokens + greatest(extract(epoch from v_now - updated_at), 0) * v_rate)
+    into v_tokens
+    from rate_limit_buckets
+   where key = p_key
+     for update;
+
+  if v_tokens >= 1 then
+    update rate_limit_buckets set tokens = v_tokens - 1, updated_at = v_now where key = p_key;
+    return jsonb_build_object('allowed', true, 'retry_after_ms', 0);
+  end if;
+
+  update rate_limit_buckets set tokens = v_tokens, updated_at = v_now where key = p_key;
+  return jsonb_build_object('allowed', false, 'retry_after_ms', ceil((1 - v_tokens) / v_rate * 1000)::bigint);
+end;
+$$;
+
+revoke execute on function public.rate_limit_take(text, integer, integer) from public;
+grant execute on function public.rate_limit_take(text, integer, integer) to service_role;
+
+-- accept_invitation links a tenant to the invited unit in one transaction.
+-- The invite token is the credential: without it nothing can be accepted.
+--
+-- The invitation and unit rows are locked, so two concurrent acceptances of
+-- the same invitation, or of two invitations for the same unit, cannot both
+-- succeed. A new profile is created when p_full_name is given (signup through
+-- the invite); otherwise 'tenant' is added to the caller's existing roles.
+-- Email and phone arrive encrypted, with their blind indexes, from the API's
+-- encryption transport.
+--
+-- Errors (as the PostgREST "message"):
+--   invitation_unavailable  not found, no longer pending, or expired
+--   unit_occupied           the unit already has a different tenant
+--   profile_not_found       claiming with an account that has no profile
+create or replace function public.accept_invitation(
+  p_token text,
+  p_tenant_id uuid,
+  p_full_name text default null,
+  p_email text default null,
+  p_email_bidx text default null,
+  p_phone text default null,
+  p_phone_bidx text default null,
+  p_email_verified_at timestamptz default null,
+  p_phone_verified_at timestamptz default null
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_invitation invitations%rowtype;
+  v_unit units%rowtype;
+  v_roles text[];
+begin
+  select * into v_invitation from invitations where token = p_token for update;
+  if not found or v_invitation.status <> 'pending'
+     or (v_invitation.expires_at is not null and v_invitation.expires_at < now()) then
+    raise exception 'invitation_unavailable';
+  end if;
+
+  select * into v_unit from units where id = v_invitation.unit_id for update;
+  if v_unit.tenant_id is not null and v_unit.tenant_id <> p_tenant_id then
+    raise exception 'unit_occupied';
+  end if;
+
+  if p_full_name is not null then
+    insert into profiles (id, role, roles, full_name, email, email_bidx, phone, phone_bidx, email_verified_at, phone_verified_at)
+    values (p_tenant_id, 'tenant', array['tenant'], p_full_name, p_email, p_email_bidx, p_phone, p_phone_bidx, p_email_verified_at, p_phone_verified_at);
+    v_roles := array['tenant'];
+  else
+    update profiles
+       set roles = case
+             when 'tenant' = any(coalesce(nullif(roles, '{}'), array[role])) then coalesce(nullif(roles, '{}'), array[role])
+             else coalesce(nullif(roles, '{}'), array[role]) || 'tenant'
+           end,
+           updated_at = now()
+     where id = p_tenant_id
+     returning roles into v_roles;
+    if not found then
+      raise exception 'profile_not_found';
+    end if;
+  end if;
+
+  update units set tenant_id = p_tenant_id, status = 'occupied', updated_at = now() where id = v_unit.id;
+  update invitations set status = 'accepted' where id = v_invitation.id;
+  -- Other invitations to the now occupied unit can no longer be taken up
+  update invitations set status = 'expired' where unit_id = v_unit.id and status = 'pending';
+
+  return jsonb_build_object('unit_id', v_unit.id, 'building_id', v_unit.building_id, 'roles', v_roles);
+end;
+$$;
+
+revoke execute on function public.accept_invitation(text, uuid, text, text, text, text, text, timestamptz, timestamptz) from public;
+grant execute on function public.accept_invitation(text, uuid, text, text, text, text, text, timestamptz, timestamptz) to service_role;
@@ -0,0 +1,42 @@
+-- The avatars bucket is kept: it may hold files
+
+drop policy if exists audit_log_select on audit_log;
+drop policy if exists api_keys_select_own on api_keys;
+drop policy if exists notifications_select_own on notifications;
+drop policy if exists documents_select on documents;
+drop policy if exists maintenance_requests_select on maintenance_requests;
+drop policy if exists payments_select on payments;
+drop policy if exists invitations_select on invitations;
+drop policy if exists units_select on units;
+drop policy if exists building_members_select on building_members;
+drop policy if exists building_owners_select on building_owners;
+drop policy if exists buildings_select on buildings;
+drop policy if exists organisation_members_select on organisation_members;
+drop policy if exists organisations_select_member on organisations;
+drop policy if exists profiles_select_own on profiles;
+
+alter table profiles disable row level security;
+alter table organisations disable row level security;
+alter table organisation_members disable row level security;
+alter table buildings disable row level security;
+alter table building_owners disable row level security;
+alter table building_members disable row level security;
+alter table units disable row level security;
+alter table invitations disable row level security;
+alter table payments disable row level security;
+alter table maintenance_requests disable row level security;
+alter table documents disable row level security;
+alter table notifications disable row level security;
+alter table api_keys disable row level security;
+alter table webhook_events disable row level security;
+alter table impersonation_sessions disable row level security;
+alter table audit_log disable row level security;
+alter table auth_tokens disable row level security;
+alter table phone_otps disable row level security;
+alter table user_mfa disable row level security;
+alter table mfa_recovery_codes disable row level security;
+alter table login_lockouts disable row level security;
+alter table rate_limit_buckets disable row level security;
+
+drop function if exists public.organisation_role(uuid);
+drop function if exists public.building_roles(uuid);
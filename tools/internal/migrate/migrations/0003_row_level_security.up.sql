@@ -0,0 +1,144 @@
+-- Row-level security on every table. The API uses the service-role key,
+-- which bypasses RLS and applies the access rules itself. The policies
+-- below only let a signed-in user (role "authenticated", e.g. the mobile
+-- app reading Supabase directly) read rows they could also read through
+-- the API; nothing may be written, and the anon key sees nothing.
+
+-- building_roles returns the caller's roles on a building, as the API's
+-- access.Resolver computes them: the landlord (unless listed as a
+-- non-managing co-owner) and managing co-owners are "owner", other
+-- co-owners "co_owner", then active staff and organisation roles.
+-- Organisation "owner" members only see buildings they own.
+create or replace function public.building_roles(p_building_id uuid)
+returns text[]
+language sql
+stable
+security definer
+set search_path = public
+as $$
+  select coalesce(array_agg(distinct r.role), '{}')
+    from (
+      select 'owner' as role from buildings b
+       where b.id = p_building_id and b.landlord_id = auth.uid()
+         and not exists (
+           select 1 from building_owners o
+            where o.building_id = b.id and o.user_id = auth.uid() and not o.managing
+         )
+      union all
+      select case when managing then 'owner' else 'co_owner' end from building_owners
+       where building_id = p_building_id and user_id = auth.uid()
+      union all
+      select role from building_members
+       where building_id = p_building_id and user_id = auth.uid() and status = 'active'
+      union all
+      select m.role from buildings b
+        join organisation_members m on m.organisation_id = b.organisation_id
+       where b.id = p_building_id and m.user_id = auth.uid() and m.status = 'active' and m.role <> 'owner'
+    ) r
+$$;
+
+revoke execute on function public.building_roles(uuid) from public;
+grant execute on function public.building_roles(uuid) to authenticated, service_role;
+
+-- organisation_role returns the caller's active role in an organisation,
+-- or null. Policies on organisation_members use it rather than querying
+-- the table they protect, which would recurse.
+create or replace function public.organisation_role(p_organisation_id uuid)
+returns text
+language sql
+stable
+security definer
+set search_path = public
+as $$
+  select role from organisation_members
+   where organisation_id = p_organisation_id and user_id = auth.uid() and status = 'active'
+   limit 1
+$$;
+
+revoke execute on function public.organisation_role(uuid) from public;
+grant execute on function public.organisation_role(uuid) to authenticated, service_role;
+
+alter table profiles enable row level security;
+alter table organisations enable row level security;
+alter table organisation_members enable row level security;
+alter table buildings enable row level security;
+alter table building_owners enable row level security;
+alter table building_members enable row level security;
+alter table units enable row level security;
+alter table invitations enable row level security;
+alter table payments enable row level security;
+alter table maintenance_requests enable row level security;
+alter table documents enable row level security;
+alter table notifications enable row level security;
+alter table api_keys enable row level security;
+alter table webhook_events enable row level security;
+alter table impersonation_sessions enable row level security;
+alter table audit_log enable row level security;
+alter table auth_tokens enable row level security;
+alter table phone_otps enable row level security;
+alter table user_mfa enable row level security;
+alter table mfa_recovery_codes enable row level security;
+alter table login_lockouts enable row level security;
+alter table rate_limit_buckets enable row level security;
+
+-- Tables without a policy (webhook events, impersonation sessions, auth
+-- tokens, OTPs, 2FA secrets, lockouts, rate limits) are API-only.
+
+create policy profiles_select_own on profiles for select to authenticated
+  using (id = auth.uid());
+
+create policy organisations_select_member on organisations for select to authenticated
+  using (organisation_role(id) is not null);
+
+create policy organisation_members_select on organisation_members for select to authenticated
+  using (user_id = auth.uid() or organisation_role(organisation_id) = 'admin');
+
+create policy buildings_select on buildings for select to authenticated
+  using (building_roles(id) <> '{}');
+
+create policy building_owners_select on building_owners for select to authenticated
+  using (user_id = auth.uid() or building_roles(building_id) && array['owner', 'admin']);
+
+create policy building_members_select on building_members for select to authenticated
+  using (user_id = auth.uid() or building_roles(building_id) && array['owner', 'admin']);
+
+create policy units_select on units for select to authenticated
+  using (tenant_id = auth.uid() or building_roles(building_id) <> '{}');
+
+create policy invitations_select on invitations for select to authenticated
+  using (exists (
+    select 1 from units u
+     where u.id = invitations.unit_id and building_roles(u.building_id) && array['owner', 'admin', 'manager']
+  ));
+
+-- Tenants see only their own payments (rule #1); staff need financials:view
+create policy payments_select on payments for select to authenticated
+  using (tenant_id = auth.uid() or building_roles(building_id) && array['owner', 'admin', 'manager', 'accountant']);
+
+create policy maintenance_requests_select on maintenance_requests for select to authenticated
+  using (tenant_id = auth.uid() or building_roles(building_id) && array['owner', 'admin', 'manager', 'caretaker']);
+
+create policy documents_select on documents for select to authenticated
+  using (
+    uploaded_by = auth.uid()
+    or unit_id in (select id from units where tenant_id = auth.uid())
+    or building_roles(building_id) && array['owner', 'admin', 'manager']
+  );
+
+create policy notifications_select_own on notifications for select to authenticated
+  using (user_id = auth.uid());
+
+create policy api_keys_select_own on api_keys for select to authenticated
+  using (user_id = auth.uid());
+
+create policy audit_log_select on audit_log for select to authenticated
+  using (building_roles(building_id) && array['owner', 'admin']);
+
+-- Avatars are public images (rule #26); the bucket only exists on Supabase
+do $$
+begin
+  if to_regclass('storage.buckets') is not null then
+    insert into storage.buckets (id, name, public) values ('avatars', 'avatars', true)
+    on conflict (id) do nothing;
+  end if;
+end $$;
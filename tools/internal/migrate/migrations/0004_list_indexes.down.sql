@@ -0,0 +1,10 @@
+drop index if exists invitations_unit_id_created_at_idx;
+drop index if exists documents_building_id_created_at_idx;
+drop index if exists maintenance_requests_building_id_idx;
+drop index if exists payments_building_id_amount_idx;
+drop index if exists payments_building_id_idx;
+drop index if exists payments_tenant_id_idx;
+
+create index if not exists payments_tenant_id_idx on payments (tenant_id, created_at desc);
+create index if not exists payments_building_id_idx on payments (building_id, created_at desc);
+create index if not exists maintenance_requests_building_id_idx on maintenance_requests (building_id, created_at desc);
@@ -0,0 +1,16 @@
+-- Indexes for paged lists. Lists order by a column then id, so indexes on
+-- a list's scope and default order (created_at) end in id and serve
+-- keyset pages and created_at ranges; they replace the (scope, created_at
+-- desc) indexes from 0001. Payments also get one for sorting and
+-- filtering by amount.
+
+drop index if exists payments_tenant_id_idx;
+drop index if exists payments_building_id_idx;
+drop index if exists maintenance_requests_building_id_idx;
+
+create index if not exists payments_tenant_id_idx on payments (tenant_id, created_at desc, id desc);
+create index if not exists payments_building_id_idx on payments (building_id, created_at desc, id desc);
+create index if not exists payments_building_id_amount_idx on payments (building_id, amount);
+create index if not exists maintenance_requests_building_id_idx on maintenance_requests (building_id, created_at desc, id desc);
+create index if not exists documents_building_id_created_at_idx on documents (building_id, created_at desc, id desc);
+create index if not exists invitations_unit_id_created_at_idx on invitations (unit_id, created_at desc, id desc);
@@ -0,0 +1,9 @@
+drop function if exists public.search_portfolio(uuid[], uuid[], text, text, text, text, text, integer);
+
+drop index if exists payments_paystack_reference_trgm_idx;
+drop index if exists buildings_address_trgm_idx;
+drop index if exists buildings_name_trgm_idx;
+drop index if exists units_unit_number_trgm_idx;
+drop index if exists profiles_full_name_trgm_idx;
+
+-- pg_trgm is left installed: other objects may use it
@@ -0,0 +1,113 @@
+-- Portfolio search (GET /api/v1/search). Free text is matched with pg_trgm:
+-- substrings (ilike) and fuzzy word matches (<%), both served by trigram
+-- GIN indexes. Emails and phones are encrypted, so tenants are only found
+-- by them exactly, through their blind indexes (or, with encryption off, by
+-- the plain values).
+
+create extension if not exists pg_trgm;
+
+create index if not exists profiles_full_name_trgm_idx on profiles using gin (full_name gin_trgm_ops);
+create index if not exists units_unit_number_trgm_idx on units using gin (unit_number gin_trgm_ops);
+create index if not exists buildings_name_trgm_idx on buildings using gin (name gin_trgm_ops);
+create index if not exists buildings_address_trgm_idx on buildings using gin (address gin_trgm_ops);
+create index if not exists payments_paystack_reference_trgm_idx on payments using gin (paystack_reference gin_trgm_ops);
+
+-- search_portfolio returns {"tenants", "units", "buildings", "payments"},
+-- each at most p_limit rows ranked best first. Tenants, units and buildings
+-- come from p_building_ids, payments from p_financial_ids; the API passes
+-- the buildings the caller may see (and see the financials of). rank is 1
+-- for an exact match, otherwise the pg_trgm word similarity.
+-- p_email and p_phone arrive encrypted from the API's encryption transport;
+-- only their blind indexes are used. Without encryption no blind index is
+-- passed, and they are compared in plain: emails case-insensitively, phones
+-- by their last ten digits, so "0803 000 0003" finds "+2348030000003".
+create or replace function public.search_portfolio(
+  p_building_ids uuid[],
+  p_financial_ids uuid[],
+  p_query text default null,
+  p_email text default null,
+  p_email_bidx text default null,
+  p_phone text default null,
+  p_phone_bidx text default null,
+  p_limit integer default 10
+) returns jsonb
+language plpgsql
+stable
+security definer
+set search_path = public, extensions
+as $$
+declare
+  v_query text := nullif(btrim(p_query), '');
+  v_like text := '%' || replace(replace(replace(v_query, '\', '\\'), '%', '\%'), '_', '\_') || '%';
+begin
+  return jsonb_build_object(
+    'tenants', (
+      select coalesce(jsonb_agg(to_jsonb(t) order by t.rank desc, t.full_name), '[]')
+        from (select p.id, p.full_name, p.email, p.phone,
+                     u.id as unit_id, u.unit_number, b.id as building_id, b.name as building_name,
+                     case when c.exact or lower(p.full_name) = lower(v_query) then 1
+                          else word_similarity(v_query, p.full_name) end as rank
+                from units u
+                join profiles p on p.id = u.tenant_id
+                join buildings b on b.id = u.building_id
+                cross join lateral (
+                  select coalesce(p.email_bidx = p_email_bidx
+                           or p_email_bidx is null and lower(p.email) = lower(p_email)
+                           or p.phone_bidx = p_phone_bidx
+                           or p_phone_bidx is null
+                              and right(regexp_replace(p.phone, '\D', '', 'g'), 10) = right(regexp_replace(p_phone, '\D', '', 'g'), 10),
+                         false) as exact
+                ) c
+               where u.building_id = any(p_building_ids)
+                 and (c.exact or p.full_name ilike v_like or v_query <% p.full_name)
+               order by rank desc, p.full_name
+               limit p_limit) t
+    ),
+    'units', (
+      select coalesce(jsonb_agg(to_jsonb(t) order by t.rank desc, t.unit_number), '[]')
+        from (select u.id, u.unit_number, u.status, b.id as building_id, b.name as building_name,
+                     p.full_name as tenant_name,
+                     case when lower(u.unit_number) = lower(v_query) then 1
+                          else word_similarity(v_query, u.unit_number) end as rank
+                from units u
+                join buildings b on b.id = u.building_id
+                left join profiles p on p.id = u.tenant_id
+               where u.building_id = any(p_building_ids)
+                 and (u.unit_number ilike v_like or v_query <% u.unit_number)
+               order by rank desc, u.unit_number
+               limit p_limit) t
+    ),
+    'buildings', (
+      select coalesce(jsonb_agg(to_jsonb(t) order by t.rank desc, t.name), '[]')
+        from (select b.id, b.name, b.address,
+                     case when lower(b.name) = lower(v_query) then 1
+                          else greatest(word_similarity(v_query, b.name), word_similarity(v_query, b.address)) end as rank
+                from buildings b
+               where b.id = any(p_building_ids)
+                 and (b.name ilike v_like or b.address ilike v_like
+                      or v_query <% b.name or v_query <% b.address)
+               order by rank desc, b.name
+               limit p_limit) t
+    ),
+    'payments', (
+      select coalesce(jsonb_agg(to_jsonb(t) order by t.rank desc, t.created_at desc), '[]')
+        from (select pm.id, pm.paystack_reference, pm.amount, pm.status, pm.period, pm.paid_at, pm.created_at,
+                     p.full_name as tenant_name, u.unit_number, b.name as building_name,
+                     case when pm.id::text = lower(v_query) or lower(pm.paystack_reference) = lower(v_query) then 1
+                          else word_similarity(v_query, pm.paystack_reference) end as rank
+                from payments pm
+                join units u on u.id = pm.unit_id
+                join buildings b on b.id = pm.building_id
+                left join profiles p on p.id = pm.tenant_id
+               where pm.building_id = any(p_financial_ids)
+                 and (pm.id::text = lower(v_query) or pm.paystack_reference ilike v_like
+                      or v_query <% pm.paystack_reference)
+               order by rank desc, pm.created_at desc
+               limit p_limit) t
+    )
+  );
+end;
+$$;
+
+revoke execute on function public.search_portfolio(uuid[], uuid[], text, text, text, text, text, integer) from public;
+grant execute on function public.search_portfolio(uuid[], uuid[], text, text, text, text, text, integer) to service_role;
@@ -0,0 +1,9 @@
+drop function if exists public.restore_building(uuid);
+drop function if exists public.archive_building(uuid);
+drop function if exists public.archive_unit(uuid);
+
+drop index if exists units_archived_at_idx;
+drop index if exists buildings_archived_at_idx;
+
+alter table units drop column if exists archived_at;
+alter table buildings drop column if exists archived_at;
@@ -0,0 +1,114 @@
+-- Buildings and units are archived instead of deleted, so payment history
+-- keeps pointing at them. Lists and dashboards leave archived rows out
+-- unless asked. Units archived with their building share its archived_at,
+-- which is how restoring the building knows which units to bring back.
+
+alter table buildings add column if not exists archived_at timestamptz;
+alter table units add column if not exists archived_at timestamptz;
+
+create index if not exists buildings_archived_at_idx on buildings (archived_at) where archived_at is not null;
+create index if not exists units_archived_at_idx on units (building_id, archived_at) where archived_at is not null;
+
+-- Archiving runs in the database so that checking for tenants and archiving
+-- happen in one transaction: accept_invitation cannot link a tenant to a
+-- unit between the two. Locks are taken in accept_invitation's order
+-- (invitations, then units) so the two cannot deadlock.
+--
+-- Errors (as the PostgREST "message"):
+--   not_found      no such building or unit
+--   unit_occupied  the unit, or a unit of the building, has a tenant
+
+-- archive_unit archives a vacant unit and expires its pending invitations
+create or replace function public.archive_unit(p_unit_id uuid)
+returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_unit units%rowtype;
+begin
+  perform 1 from invitations where unit_id = p_unit_id and status = 'pending' for update;
+  select * into v_unit from units where id = p_unit_id for update;
+  if not found then
+    raise exception 'not_found';
+  end if;
+  if v_unit.tenant_id is not null then
+    raise exception 'unit_occupied';
+  end if;
+  if v_unit.archived_at is not null then
+    return to_jsonb(v_unit);
+  end if;
+
+  update invitations set status = 'expired' where unit_id = p_unit_id and status = 'pending';
+  update units set archived_at = now() where id = p_unit_id returning * into v_unit;
+  return to_jsonb(v_unit);
+end;
+$$;
+
+-- archive_building archives a building whose units are all vacant, with
+-- its units, and expires their pending invitations
+create or replace function public.archive_building(p_building_id uuid)
+returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_building buildings%rowtype;
+begin
+  select * into v_building from buildings where id = p_building_id for update;
+  if not found then
+    raise exception 'not_found';
+  end if;
+  if v_building.archived_at is not null then
+    return to_jsonb(v_building);
+  end if;
+
+  perform 1 from invitations i join units u on u.id = i.unit_id
+   where u.building_id = p_building_id and i.status = 'pending'
+     for update of i;
+  perform 1 from units where building_id = p_building_id for update;
+  if exists (select 1 from units where building_id = p_building_id and tenant_id is not null) then
+    raise exception 'unit_occupied';
+  end if;
+
+  update invitations set status = 'expired'
+   where status = 'pending' and unit_id in (select id from units where building_id = p_building_id);
+  update units set archived_at = now() where building_id = p_building_id and archived_at is null;
+  update buildings set archived_at = now() where id = p_building_id returning * into v_building;
+  return to_jsonb(v_building);
+end;
+$$;
+
+-- restore_building restores a building and the units archived with it
+-- (those sharing its archived_at); units archived earlier stay archived
+create or replace function public.restore_building(p_building_id uuid)
+returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_building buildings%rowtype;
+begin
+  select * into v_building from buildings where id = p_building_id for update;
+  if not found then
+    raise exception 'not_found';
+  end if;
+  if v_building.archived_at is null then
+    return to_jsonb(v_building);
+  end if;
+
+  update units set archived_at = null where building_id = p_building_id and archived_at = v_building.archived_at;
+  update buildings set archived_at = null where id = p_building_id returning * into v_building;
+  return to_jsonb(v_building);
+end;
+$$;
+
+revoke execute on function public.archive_unit(uuid) from public;
+revoke execute on function public.archive_building(uuid) from public;
+revoke execute on function public.restore_building(uuid) from public;
+grant execute on function public.archive_unit(uuid) to service_role;
+grant execute on function public.archive_building(uuid) to service_role;
+grant execute on function public.restore_building(uuid) to service_role;
@@ -0,0 +1,71 @@
+-- Payments waiting for their tenant cannot be kept once tenant_id is
+-- required again
+delete from payments where tenant_id is null;
+
+create or replace function public.accept_invitation(
+  p_token text,
+  p_tenant_id uuid,
+  p_full_name text default null,
+  p_email text default null,
+  p_email_bidx text default null,
+  p_phone text default null,
+  p_phone_bidx text default null,
+  p_email_verified_at timestamptz default null,
+  p_phone_verified_at timestamptz default null
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_invitation invitations%rowtype;
+  v_unit units%rowtype;
+  v_roles text[];
+begin
+  select * into v_invitation from invitations where token = p_token for update;
+  if not found or v_invitation.status <> 'pending'
+     or (v_invitation.expires_at is not null and v_invitation.expires_at < now()) then
+    raise exception 'invitation_unavailable';
+  end if;
+
+  select * into v_unit from units where id = v_invitation.unit_id for update;
+  if v_unit.tenant_id is not null and v_unit.tenant_id <> p_tenant_id then
+    raise exception 'unit_occupied';
+  end if;
+
+  if p_full_name is not null then
+    insert into profiles (id, role, roles, full_name, email, email_bidx, phone, phone_bidx, email_verified_at, phone_verified_at)
+    values (p_tenant_id, 'tenant', array['tenant'], p_full_name, p_email, p_email_bidx, p_phone, p_phone_bidx, p_email_verified_at, p_phone_verified_at);
+    v_roles := array['tenant'];
+  else
+    update profiles
+       set roles = case
+             when 'tenant' = any(coalesce(nullif(roles, '{}'), array[role])) then coalesce(nullif(roles, '{}'), array[role])
+             else coalesce(nullif(roles, '{}'), array[role]) || 'tenant'
+           end,
+           updated_at = now()
+     where id = p_tenant_id
+     returning roles into v_roles;
+    if not found then
+      raise exception 'profile_not_found';
+    end if;
+  end if;
+
+  update units set tenant_id = p_tenant_id, status = 'occupied', updated_at = now() where id = v_unit.id;
+  update invitations set status = 'accepted' where id = v_invitation.id;
+  -- Other invitations to the now occupied unit can no longer be taken up
+  update invitations set status = 'expired' where unit_id = v_unit.id and status = 'pending';
+
+  return jsonb_build_object('unit_id', v_unit.id, 'building_id', v_unit.building_id, 'roles', v_roles);
+end;
+$$;
+
+drop function if exists public.import_batch(uuid, uuid, jsonb, jsonb);
+
+alter table payments drop constraint if exists payments_tenant_id_check;
+alter table payments alter column tenant_id set not null;
+drop index if exists payments_import_id_idx;
+alter table payments drop column if exists import_id;
+
+drop policy if exists imports_select on imports;
+drop table if exists imports;
@@ -0,0 +1,138 @@
+-- Bulk imports bring a landlord's existing units, tenants and past payments
+-- in from a spreadsheet. Each committed batch is recorded in imports, and
+-- the payments it brought in point at it, which is how they are told apart
+-- from payments made through the app.
+
+create table if not exists imports (
+  id uuid primary key default gen_random_uuid(),
+  building_id uuid not null constraint imports_building_id_fkey references buildings (id) on delete cascade,
+  created_by uuid constraint imports_created_by_fkey references profiles (id),
+  units_created integer not null default 0,
+  payments_created integer not null default 0,
+  created_at timestamptz not null default now()
+);
+create index if not exists imports_building_id_idx on imports (building_id, created_at desc);
+
+alter table imports enable row level security;
+
+create policy imports_select on imports for select to authenticated
+  using (building_roles(building_id) && array['owner', 'admin', 'manager']);
+
+alter table payments add column if not exists import_id uuid constraint payments_import_id_fkey references imports (id);
+create index if not exists payments_import_id_idx on payments (import_id) where import_id is not null;
+
+-- An imported payment can predate its tenant's account: it has no tenant
+-- until the tenant accepts their invitation to the unit
+alter table payments alter column tenant_id drop not null;
+alter table payments add constraint payments_tenant_id_check check (tenant_id is not null or import_id is not null);
+
+-- import_batch creates a batch's units and payments in one transaction.
+-- Units arrive with the IDs their payments refer to; every payment must be
+-- for a unit of the building, new or existing.
+--
+-- Errors (as the PostgREST "message"):
+--   unit_number_taken  a unit number is already used in the building
+--   not_found          a payment is for a unit outside the building
+create or replace function public.import_batch(
+  p_building_id uuid,
+  p_created_by uuid,
+  p_units jsonb,
+  p_payments jsonb
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_import imports%rowtype;
+begin
+  insert into imports (building_id, created_by, units_created, payments_created)
+  values (p_building_id, p_created_by, jsonb_array_length(p_units), jsonb_array_length(p_payments))
+  returning * into v_import;
+
+  begin
+    insert into units (id, building_id, unit_number, rent_amount, lease_start, lease_end)
+    select u.id, p_building_id, u.unit_number, u.rent_amount, u.lease_start, u.lease_end
+      from jsonb_to_recordset(p_units) as u(id uuid, unit_number text, rent_amount bigint, lease_start date, lease_end date);
+  exception when unique_violation then
+    raise exception 'unit_number_taken';
+  end;
+
+  if exists (
+    select 1 from jsonb_to_recordset(p_payments) as p(unit_id uuid)
+     where not exists (select 1 from units u where u.id = p.unit_id and u.building_id = p_building_id)
+  ) then
+    raise exception 'not_found';
+  end if;
+
+  insert into payments (tenant_id, unit_id, building_id, amount, status, payment_method, period, recorded_by, paid_at, import_id)
+  select p.tenant_id, p.unit_id, p_building_id, p.amount, 'successful', p.payment_method, p.period, p_created_by, p.paid_at, v_import.id
+    from jsonb_to_recordset(p_payments) as p(tenant_id uuid, unit_id uuid, amount bigint, payment_method text, period text, paid_at timestamptz);
+
+  return to_jsonb(v_import);
+end;
+$$;
+
+revoke execute on function public.import_batch(uuid, uuid, jsonb, jsonb) from public;
+grant execute on function public.import_batch(uuid, uuid, jsonb, jsonb) to service_role;
+
+-- accept_invitation as in 0002, now also giving the tenant the imported
+-- payments of their unit that were waiting for them
+create or replace function public.accept_invitation(
+  p_token text,
+  p_tenant_id uuid,
+  p_full_name text default null,
+  p_email text default null,
+  p_email_bidx text default null,
+  p_phone text default null,
+  p_phone_bidx text default null,
+  p_email_verified_at timestamptz default null,
+  p_phone_verified_at timestamptz default null
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_invitation invitations%rowtype;
+  v_unit units%rowtype;
+  v_roles text[];
+begin
+  select * into v_invitation from invitations where token = p_token for update;
+  if not found or v_invitation.status <> 'pending'
+     or (v_invitation.expires_at is not null and v_invitation.expires_at < now()) then
+    raise exception 'invitation_unavailable';
+  end if;
+
+  select * into v_unit from units where id = v_invitation.unit_id for update;
+  if v_unit.tenant_id is not null and v_unit.tenant_id <> p_tenant_id then
+    raise exception 'unit_occupied';
+  end if;
+
+  if p_full_name is not null then
+    insert into profiles (id, role, roles, full_name, email, email_bidx, phone, phone_bidx, email_verified_at, phone_verified_at)
+    values (p_tenant_id, 'tenant', array['tenant'], p_full_name, p_email, p_email_bidx, p_phone, p_phone_bidx, p_email_verified_at, p_phone_verified_at);
+    v_roles := array['tenant'];
+  else
+    update profiles
+       set roles = case
+             when 'tenant' = any(coalesce(nullif(roles, '{}'), array[role])) then coalesce(nullif(roles, '{}'), array[role])
+             else coalesce(nullif(roles, '{}'), array[role]) || 'tenant'
+           end,
+           updated_at = now()
+     where id = p_tenant_id
+     returning roles into v_roles;
+    if not found then
+      raise exception 'profile_not_found';
+    end if;
+  end if;
+
+  update units set tenant_id = p_tenant_id, status = 'occupied', updated_at = now() where id = v_unit.id;
+  update invitations set status = 'accepted' where id = v_invitation.id;
+  -- Other invitations to the now occupied unit can no longer be taken up
+  update invitations set status = 'expired' where unit_id = v_unit.id and status = 'pending';
+  update payments set tenant_id = p_tenant_id where unit_id = v_unit.id and tenant_id is null;
+
+  return jsonb_build_object('unit_id', v_unit.id, 'building_id', v_unit.building_id, 'roles', v_roles);
+end;
+$$;
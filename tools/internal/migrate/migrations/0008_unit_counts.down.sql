@@ -0,0 +1,3 @@
+-- total_units keeps its last counted values and becomes editable again
+drop trigger if exists units_count on units;
+drop function if exists public.units_count();
@@ -0,0 +1,30 @@
+-- buildings.total_units is the number of the building's units that are not
+-- archived. It used to be whatever the landlord typed in; now only this
+-- trigger writes it. Counts move by one per row, under the building's row
+-- lock, so concurrent inserts cannot lose an update.
+
+create or replace function public.units_count() returns trigger
+language plpgsql
+security definer
+set search_path = public
+as $$
+begin
+  if tg_op in ('UPDATE', 'DELETE') and old.archived_at is null then
+    update buildings set total_units = total_units - 1 where id = old.building_id;
+  end if;
+  if tg_op in ('INSERT', 'UPDATE') and new.archived_at is null then
+    update buildings set total_units = total_units + 1 where id = new.building_id;
+  end if;
+  return null;
+end;
+$$;
+
+revoke execute on function public.units_count() from public;
+
+drop trigger if exists units_count on units;
+create trigger units_count
+  after insert or delete or update of building_id, archived_at on units
+  for each row execute function public.units_count();
+
+update buildings b
+   set total_units = (select count(*) from units u where u.building_id = b.id and u.archived_at is null);
@@ -0,0 +1,238 @@
+-- A tenancy is one tenant's occupancy of a unit: when it started and ended
+-- and the rent agreed. Units used to hold only their current tenant, so a
+-- tenant leaving lost who lived there, when, and at what rent. Payments,
+-- maintenance requests and documents now point at the tenancy they belong
+-- to. units.tenant_id and units.status stay as a copy of the unit's active
+-- tenancy, which row-level security and archiving read; only
+-- accept_invitation and end_tenancy change them.
+
+create table if not exists tenancies (
+  id uuid primary key default gen_random_uuid(),
+  unit_id uuid not null constraint tenancies_unit_id_fkey references units (id) on delete cascade,
+  building_id uuid not null constraint tenancies_building_id_fkey references buildings (id) on delete cascade,
+  tenant_id uuid not null constraint tenancies_tenant_id_fkey references profiles (id),
+  invitation_id uuid constraint tenancies_invitation_id_fkey references invitations (id) on delete set null,
+  rent_amount bigint not null default 0 check (rent_amount >= 0),
+  start_date date not null default current_date,
+  end_date date,
+  status text not null default 'active' check (status in ('active', 'ended')),
+  ended_at timestamptz,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  constraint tenancies_dates_check check (end_date is null or end_date >= start_date),
+  constraint tenancies_ended_check check ((status = 'ended') = (ended_at is not null))
+);
+create unique index if not exists tenancies_active_unit_id_key on tenancies (unit_id) where status = 'active';
+create index if not exists tenancies_unit_id_idx on tenancies (unit_id, start_date desc);
+create index if not exists tenancies_tenant_id_idx on tenancies (tenant_id);
+
+alter table tenancies enable row level security;
+
+create policy tenancies_select on tenancies for select to authenticated
+  using (tenant_id = auth.uid() or building_roles(building_id) <> '{}');
+
+alter table payments add column if not exists tenancy_id uuid constraint payments_tenancy_id_fkey references tenancies (id);
+alter table maintenance_requests add column if not exists tenancy_id uuid constraint maintenance_requests_tenancy_id_fkey references tenancies (id);
+alter table documents add column if not exists tenancy_id uuid constraint documents_tenancy_id_fkey references tenancies (id);
+create index if not exists payments_tenancy_id_idx on payments (tenancy_id);
+create index if not exists maintenance_requests_tenancy_id_idx on maintenance_requests (tenancy_id);
+create index if not exists documents_tenancy_id_idx on documents (tenancy_id);
+
+-- Backfill: every occupied unit gets an active tenancy on its current
+-- terms, starting on its lease start, else the day its invitation was
+-- accepted. Nothing is known of earlier tenants.
+insert into tenancies (unit_id, building_id, tenant_id, invitation_id, rent_amount, start_date, end_date)
+select u.id, u.building_id, u.tenant_id, i.id, u.rent_amount, s.start_date,
+       case when u.lease_end >= s.start_date then u.lease_end end
+  from units u
+  left join lateral (
+    select id, created_at from invitations
+     where unit_id = u.id and status = 'accepted'
+     order by created_at desc limit 1
+  ) i on true
+  cross join lateral (select coalesce(u.lease_start, i.created_at::date, u.updated_at::date) as start_date) s
+ where u.tenant_id is not null
+   and not exists (select 1 from tenancies t where t.unit_id = u.id and t.status = 'active');
+
+update payments p set tenancy_id = t.id
+  from tenancies t
+ where p.tenancy_id is null and p.unit_id = t.unit_id and p.tenant_id = t.tenant_id;
+
+update maintenance_requests m set tenancy_id = t.id
+  from tenancies t
+ where m.tenancy_id is null and m.unit_id = t.unit_id and m.tenant_id = t.tenant_id;
+
+-- A unit's documents from the start of its tenancy on are taken to be the
+-- tenancy's; older ones were a previous tenant's
+update documents d set tenancy_id = t.id
+  from tenancies t
+ where d.tenancy_id is null and d.unit_id = t.unit_id and d.created_at >= t.start_date;
+
+-- Tenants see their own uploads and the documents of their tenancies, no
+-- longer everything ever filed against their unit
+drop policy if exists documents_select on documents;
+create policy documents_select on documents for select to authenticated
+  using (
+    uploaded_by = auth.uid()
+    or tenancy_id in (select id from tenancies where tenant_id = auth.uid())
+    or building_roles(building_id) && array['owner', 'admin', 'manager']
+  );
+
+-- accept_invitation as in 0007, now starting a tenancy on the unit's rent
+-- and lease dates and giving it the payments that were waiting
+create or replace function public.accept_invitation(
+  p_token text,
+  p_tenant_id uuid,
+  p_full_name text default null,
+  p_email text default null,
+  p_email_bidx text default null,
+  p_phone text default null,
+  p_phone_bidx text default null,
+  p_email_verified_at timestamptz default null,
+  p_phone_verified_at timestamptz default null
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_invitation invitations%rowtype;
+  v_unit units%rowtype;
+  v_tenancy_id uuid;
+  v_roles text[];
+begin
+  select * into v_invitation from invitations where token = p_token for update;
+  if not found or v_invitation.status <> 'pending'
+     or (v_invitation.expires_at is not null and v_invitation.expires_at < now()) then
+    raise exception 'invitation_unavailable';
+  end if;
+
+  select * into v_unit from units where id = v_invitation.unit_id for update;
+  if v_unit.tenant_id is not null and v_unit.tenant_id <> p_tenant_id then
+    raise exception 'unit_occupied';
+  end if;
+
+  if p_full_name is not null then
+    insert into profiles (id, role, roles, full_name, email, email_bidx, phone, phone_bidx, email_verified_at, phone_verified_at)
+    values (p_tenant_id, 'tenant', array['tenant'], p_full_name, p_email, p_email_bidx, p_phone, p_phone_bidx, p_email_verified_at, p_phone_verified_at);
+    v_roles := array['tenant'];
+  else
+    update profiles
+       set roles = case
+             when 'tenant' = any(coalesce(nullif(roles, '{}'), array[role])) then coalesce(nullif(roles, '{}'), array[role])
+             else coalesce(nullif(roles, '{}'), array[role]) || 'tenant'
+           end,
+           updated_at = now()
+     where id = p_tenant_id
+     returning roles into v_roles;
+    if not found then
+      raise exception 'profile_not_found';
+    end if;
+  end if;
+
+  -- The tenant may already live here, through an earlier invitation
+  select id into v_tenancy_id from tenancies where unit_id = v_unit.id and status = 'active';
+  if v_tenancy_id is null then
+    insert into tenancies (unit_id, building_id, tenant_id, invitation_id, rent_amount, start_date, end_date)
+    values (v_unit.id, v_unit.building_id, p_tenant_id, v_invitation.id, v_unit.rent_amount,
+            coalesce(v_unit.lease_start, current_date),
+            case when v_unit.lease_end >= coalesce(v_unit.lease_start, current_date) then v_unit.lease_end end)
+    returning id into v_tenancy_id;
+  end if;
+
+  update units set tenant_id = p_tenant_id, status = 'occupied', updated_at = now() where id = v_unit.id;
+  update invitations set status = 'accepted' where id = v_invitation.id;
+  -- Other invitations to the now occupied unit can no longer be taken up
+  update invitations set status = 'expired' where unit_id = v_unit.id and status = 'pending';
+  update payments set tenant_id = p_tenant_id, tenancy_id = v_tenancy_id where unit_id = v_unit.id and tenant_id is null;
+
+  return jsonb_build_object('unit_id', v_unit.id, 'building_id', v_unit.building_id, 'tenancy_id', v_tenancy_id, 'roles', v_roles);
+end;
+$$;
+
+-- import_batch as in 0007, now keeping the tenancy a payment for an
+-- occupied unit is credited to.
+--
+-- import_batch creates a batch's units and payments in one transaction.
+-- Units arrive with the IDs their payments refer to; every payment must be
+-- for a unit of the building, new or existing.
+--
+-- Errors (as the PostgREST "message"):
+--   unit_number_taken  a unit number is already used in the building
+--   not_found          a payment is for a unit outside the building
+create or replace function public.import_batch(
+  p_building_id uuid,
+  p_created_by uuid,
+  p_units jsonb,
+  p_payments jsonb
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_import imports%rowtype;
+begin
+  insert into imports (building_id, created_by, units_created, payments_created)
+  values (p_building_id, p_created_by, jsonb_array_length(p_units), jsonb_array_length(p_payments))
+  returning * into v_import;
+
+  begin
+    insert into units (id, building_id, unit_number, rent_amount, lease_start, lease_end)
+    select u.id, p_building_id, u.unit_number, u.rent_amount, u.lease_start, u.lease_end
+      from jsonb_to_recordset(p_units) as u(id uuid, unit_number text, rent_amount bigint, lease_start date, lease_end date);
+  exception when unique_violation then
+    raise exception 'unit_number_taken';
+  end;
+
+  if exists (
+    select 1 from jsonb_to_recordset(p_payments) as p(unit_id uuid)
+     where not exists (select 1 from units u where u.id = p.unit_id and u.building_id = p_building_id)
+  ) then
+    raise exception 'not_found';
+  end if;
+
+  insert into payments (tenant_id, tenancy_id, unit_id, building_id, amount, status, payment_method, period, recorded_by, paid_at, import_id)
+  select p.tenant_id, p.tenancy_id, p.unit_id, p_building_id, p.amount, 'successful', p.payment_method, p.period, p_created_by, p.paid_at, v_import.id
+    from jsonb_to_recordset(p_payments) as p(tenant_id uuid, tenancy_id uuid, unit_id uuid, amount bigint, payment_method text, period text, paid_at timestamptz);
+
+  return to_jsonb(v_import);
+end;
+$$;
+
+-- end_tenancy records the tenant moving out on p_end_date and frees the
+-- unit. The unit is locked first, as accept_invitation does.
+--
+-- Errors (as the PostgREST "message"):
+--   not_found      no such tenancy
+--   tenancy_ended  the tenancy has already ended
+create or replace function public.end_tenancy(p_tenancy_id uuid, p_end_date date)
+returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_tenancy tenancies%rowtype;
+begin
+  perform 1 from units where id = (select unit_id from tenancies where id = p_tenancy_id) for update;
+  select * into v_tenancy from tenancies where id = p_tenancy_id for update;
+  if not found then
+    raise exception 'not_found';
+  end if;
+  if v_tenancy.status <> 'active' then
+    raise exception 'tenancy_ended';
+  end if;
+
+  update tenancies
+     set status = 'ended', end_date = p_end_date, ended_at = now(), updated_at = now()
+   where id = p_tenancy_id
+  returning * into v_tenancy;
+  update units set tenant_id = null, status = 'vacant', updated_at = now()
+   where id = v_tenancy.unit_id and tenant_id = v_tenancy.tenant_id;
+  return to_jsonb(v_tenancy);
+end;
+$$;
+
+revoke execute on function public.end_tenancy(uuid, date) from public;
+grant execute on function public.end_tenancy(uuid, date) to service_role;
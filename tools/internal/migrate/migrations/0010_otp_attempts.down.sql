@@ -0,0 +1 @@
+drop function if exists public.take_otp_attempt(uuid, integer);
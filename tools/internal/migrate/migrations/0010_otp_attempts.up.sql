@@ -0,0 +1,25 @@
+-- take_otp_attempt spends one of an SMS code's guesses before the code is
+-- compared. The increment is a single conditional update, so concurrent
+-- guesses each use up an attempt and no code is ever compared more than
+-- p_max times. "taken" is false once the code is used up, consumed or
+-- expired.
+create or replace function public.take_otp_attempt(p_id uuid, p_max integer)
+returns jsonb
+language sql
+security definer
+set search_path = public
+as $$
+  with taken as (
+    update phone_otps
+       set attempts = attempts + 1
+     where id = p_id
+       and consumed_at is null
+       and expires_at > now()
+       and attempts < p_max
+    returning id
+  )
+  select jsonb_build_object('taken', exists (select 1 from taken));
+$$;
+
+revoke execute on function public.take_otp_attempt(uuid, integer) from public;
+grant execute on function public.take_otp_attempt(uuid, integer) to service_role;
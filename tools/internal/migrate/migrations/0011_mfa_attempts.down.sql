@@ -0,0 +1,8 @@
+drop function if exists public.take_auth_token_attempt(uuid, integer);
+
+alter table auth_tokens drop column if exists attempts;
+
+delete from auth_tokens where purpose = 'mfa_login';
+alter table auth_tokens drop constraint if exists auth_tokens_purpose_check;
+alter table auth_tokens add constraint auth_tokens_purpose_check
+  check (purpose in ('password_reset', 'email_verification', 'email_change'));
@@ -0,0 +1,33 @@
+-- Two-factor login challenges ('mfa_login' tokens) were missing from the
+-- purpose check, and now allow a few codes each instead of being burned by
+-- the first wrong one. take_auth_token_attempt spends one of a token's
+-- guesses with a single conditional update, like take_otp_attempt, so
+-- concurrent guesses can't exceed p_max. "taken" is false once the token is
+-- used up, used or expired.
+
+alter table auth_tokens drop constraint if exists auth_tokens_purpose_check;
+alter table auth_tokens add constraint auth_tokens_purpose_check
+  check (purpose in ('password_reset', 'email_verification', 'email_change', 'mfa_login'));
+
+alter table auth_tokens add column if not exists attempts integer not null default 0;
+
+create or replace function public.take_auth_token_attempt(p_id uuid, p_max integer)
+returns jsonb
+language sql
+security definer
+set search_path = public
+as $$
+  with taken as (
+    update auth_tokens
+       set attempts = attempts + 1
+     where id = p_id
+       and used_at is null
+       and expires_at > now()
+       and attempts < p_max
+    returning id
+  )
+  select jsonb_build_object('taken', exists (select 1 from taken));
+$$;
+
+revoke execute on function public.take_auth_token_attempt(uuid, integer) from public;
+grant execute on function public.take_auth_token_attempt(uuid, integer) to service_role;
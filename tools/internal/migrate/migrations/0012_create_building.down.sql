@@ -0,0 +1 @@
+drop function if exists public.create_building(jsonb, jsonb);
@@ -0,0 +1,43 @@
+-- create_building inserts a building and its first units in one
+-- transaction, so a building is never left without the units it was
+-- created with. The units_count trigger sets total_units; the building is
+-- returned after it has. Units take their building_id from the new row.
+--
+-- Returns {"building": ..., "units": [...]}.
+--
+-- Errors (as the PostgREST "message"):
+--   unit_number_taken  two of the units share a unit number
+create or replace function public.create_building(p_building jsonb, p_units jsonb)
+returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_building buildings%rowtype;
+  v_units jsonb;
+begin
+  insert into buildings (landlord_id, organisation_id, name, address, photo_url)
+  select b.landlord_id, b.organisation_id, b.name, b.address, b.photo_url
+    from jsonb_to_record(p_building) as b(landlord_id uuid, organisation_id uuid, name text, address text, photo_url text)
+  returning * into v_building;
+
+  begin
+    with created as (
+      insert into units (building_id, unit_number, rent_amount)
+      select v_building.id, u.unit_number, u.rent_amount
+        from jsonb_to_recordset(p_units) as u(unit_number text, rent_amount bigint)
+      returning *
+    )
+    select coalesce(jsonb_agg(to_jsonb(created)), '[]') into v_units from created;
+  exception when unique_violation then
+    raise exception 'unit_number_taken';
+  end;
+
+  select * into v_building from buildings where id = v_building.id;
+  return jsonb_build_object('building', to_jsonb(v_building), 'units', v_units);
+end;
+$$;
+
+revoke execute on function public.create_building(jsonb, jsonb) from public;
+grant execute on function public.create_building(jsonb, jsonb) to service_role;
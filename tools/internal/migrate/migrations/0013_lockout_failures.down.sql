@@ -0,0 +1 @@
+drop function if exists public.record_login_failure(text, integer, integer, integer, integer);
@@ -0,0 +1,49 @@
+-- record_login_failure counts a failure for a lockout key and locks it out
+-- in one call. The upsert takes the row lock before the count is read, so
+-- concurrent failures are each counted instead of overwriting one another.
+-- After p_threshold failures in a row the key is locked for p_base_seconds,
+-- doubling with each further failure up to p_max_seconds; failures older
+-- than p_window_seconds are forgotten.
+--
+-- Returns the login_lockouts row.
+create or replace function public.record_login_failure(
+  p_email text,
+  p_threshold integer,
+  p_base_seconds integer,
+  p_max_seconds integer,
+  p_window_seconds integer
+)
+returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+  v_lockout login_lockouts%rowtype;
+begin
+  insert into login_lockouts as l (email, failed_count, last_failed_at)
+  values (p_email, 1, now())
+  on conflict (email) do update
+     set failed_count = case
+                          when l.last_failed_at > now() - make_interval(secs => p_window_seconds)
+                          then l.failed_count + 1
+                          else 1
+                        end,
+         last_failed_at = now()
+  returning * into v_lockout;
+
+  if v_lockout.failed_count >= p_threshold then
+    update login_lockouts
+       set locked_until = now() + least(
+             make_interval(secs => p_base_seconds) * power(2, least(v_lockout.failed_count - p_threshold, 6)),
+             make_interval(secs => p_max_seconds))
+     where email = p_email
+    returning * into v_lockout;
+  end if;
+
+  return to_jsonb(v_lockout);
+end;
+$$;
+
+revoke execute on function public.record_login_failure(text, integer, integer, integer, integer) from public;
+grant execute on function public.record_login_failure(text, integer, integer, integer, integer) to service_role;
@@ -0,0 +1,5 @@
+-- Login lockouts are now keyed by a blind index of the email (an HMAC the
+-- API computes) instead of the address itself. Rows keyed by a plain email
+-- would never be read again and still hold the address, so they go; the
+-- two-factor keys ('MFA:<user id>') are kept.
+delete from login_lockouts where email like '%@%';
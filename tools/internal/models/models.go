@@ -1,29 +1,41 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Profile extends Supabase auth.users with app-specific data
 type Profile struct {
-	ID        string    `json:"id"`
-	Role      string    `json:"role"` // "landlord" or "tenant"
-	FullName  string    `json:"full_name"`
-	Email     string    `json:"email"`
-	Phone     *string   `json:"phone,omitempty"`
-	AvatarURL *string   `json:"avatar_url,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              string     `json:"id"`
+	Role            string     `json:"role"`  // default role: "landlord", "tenant" or "staff"
+	Roles           []string   `json:"roles"` // every role the account holds, including Role
+	FullName        string     `json:"full_name"`
+	Email           string     `json:"email"`
+	Phone           *string    `json:"phone,omitempty"`
+	AvatarURL       *string    `json:"avatar_url,omitempty"`
+	AvatarThumbURL  *string    `json:"avatar_thumb_url,omitempty"` // 256×256 square crop
+	PendingEmail    *string    `json:"pending_email,omitempty"`    // awaiting confirmation from the new inbox
+	PendingPhone    *string    `json:"pending_phone,omitempty"`    // awaiting an SMS code
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"` // set when the account is erased
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
 // Building represents a property managed by a landlord
 type Building struct {
-	ID         string    `json:"id"`
-	LandlordID string    `json:"landlord_id"`
-	Name       string    `json:"name"`
-	Address    string    `json:"address"`
-	TotalUnits int       `json:"total_units"`
-	PhotoURL   *string   `json:"photo_url,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID             string     `json:"id"`
+	LandlordID     string     `json:"landlord_id"` // the owner
+	OrganisationID *string    `json:"organisation_id,omitempty"`
+	Name           string     `json:"name"`
+	Address        string     `json:"address"`
+	TotalUnits     int        `json:"total_units"`
+	PhotoURL       *string    `json:"photo_url,omitempty"`
+	ArchivedAt     *time.Time `json:"archived_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 // BuildingWithStats adds computed fields for dashboard display
@@ -45,10 +57,29 @@ type Unit struct {
 	LeaseStart  *string    `json:"lease_start,omitempty"`
 	LeaseEnd    *string    `json:"lease_end,omitempty"`
 	Status      string     `json:"status"` // "occupied" or "vacant"
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
+// Tenancy is one tenant's occupancy of a unit, from move-in to move-out,
+// on the rent agreed for it. A unit has at most one active tenancy; the
+// unit's tenant_id and status follow it.
+type Tenancy struct {
+	ID           string     `json:"id"`
+	UnitID       string     `json:"unit_id"`
+	BuildingID   string     `json:"building_id"`
+	TenantID     string     `json:"tenant_id"`
+	InvitationID *string    `json:"invitation_id,omitempty"` // the invitation it was taken up through
+	RentAmount   int64      `json:"rent_amount"`             // in kobo
+	StartDate    string     `json:"start_date"`              // YYYY-MM-DD
+	EndDate      *string    `json:"end_date,omitempty"`      // agreed lease end; the move-out date once ended
+	Status       string     `json:"status"`                  // "active" or "ended"
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
 // UnitWithTenant includes tenant profile info for landlord views
 type UnitWithTenant struct {
 	Unit
@@ -61,20 +92,75 @@ type UnitWithTenant struct {
 // Payment represents a rent payment transaction
 type Payment struct {
 	ID                   string     `json:"id"`
-	TenantID             string     `json:"tenant_id"`
+	TenantID             string     `json:"tenant_id"` // empty on an imported payment until its tenant joins
+	TenancyID            *string    `json:"tenancy_id,omitempty"`
 	UnitID               string     `json:"unit_id"`
 	BuildingID           string     `json:"building_id"`
 	Amount               int64      `json:"amount"` // in kobo
 	Currency             string     `json:"currency"`
 	Status               string     `json:"status"` // "pending", "successful", "failed"
-	PaymentMethod        *string    `json:"payment_method,omitempty"`
+	PaymentMethod        *string    `json:"payment_method,omitempty"` // "card", "bank_transfer", "ussd", "cash"
 	PaystackReference    *string    `json:"paystack_reference,omitempty"`
 	PaystackTransactionID *string   `json:"paystack_transaction_id,omitempty"`
 	Period               string     `json:"period"` // e.g. "Jan 2026"
+	RecordedBy           *string    `json:"recorded_by,omitempty"` // staff member, for offline payments
+	ImportID             *string    `json:"import_id,omitempty"`   // set on historical payments brought in by an import
 	PaidAt               *time.Time `json:"paid_at,omitempty"`
 	CreatedAt            time.Time  `json:"created_at"`
 }
 
+// Import is a committed bulk import of units and past payments
+type Import struct {
+	ID              string    `json:"id"`
+	BuildingID      string    `json:"building_id"`
+	CreatedBy       *string   `json:"created_by,omitempty"`
+	UnitsCreated    int       `json:"units_created"`
+	PaymentsCreated int       `json:"payments_created"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// WebhookEvent is a raw delivery from a payment provider, kept for support
+type WebhookEvent struct {
+	ID             string          `json:"id"`
+	Provider       string          `json:"provider"` // "paystack"
+	Event          string          `json:"event"`    // e.g. "charge.success"
+	Reference      string          `json:"reference"`
+	Payload        json.RawMessage `json:"payload"`
+	SignatureValid bool            `json:"signature_valid"`
+	ProcessedAt    *time.Time      `json:"processed_at,omitempty"`
+	Error          *string         `json:"error,omitempty"`
+	ReceivedAt     time.Time       `json:"received_at"`
+}
+
+// ImpersonationSession lets a platform admin act as a user for support.
+// Only a hash of the session token is stored.
+type ImpersonationSession struct {
+	ID        string     `json:"id"`
+	AdminID   string     `json:"admin_id"`
+	UserID    string     `json:"user_id"`
+	Reason    string     `json:"reason"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AuditEntry is one row of the append-only audit log
+type AuditEntry struct {
+	ID           string          `json:"id"`
+	ActorID      *string         `json:"actor_id,omitempty"`
+	ActorRole    string          `json:"actor_role"`
+	Action       string          `json:"action"` // e.g. "building.update"
+	ResourceType string          `json:"resource_type"`
+	ResourceID   *string         `json:"resource_id,omitempty"`
+	BuildingID   *string         `json:"building_id,omitempty"`
+	Changes      json.RawMessage `json:"changes,omitempty"` // {"field": {"from": ..., "to": ...}}
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+	IP           string          `json:"ip"`
+	UserAgent    string          `json:"user_agent"`
+	RequestID    *string         `json:"request_id,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
 // PaymentWithDetails includes tenant and building names for display
 type PaymentWithDetails struct {
 	Payment
@@ -83,11 +169,126 @@ type PaymentWithDetails struct {
 	UnitNumber   string `json:"unit_number"`
 }
 
+// BuildingMember grants a user a staff role on a building. Rows start as
+// "invited" (user_id unset) and become "active" when the invite is accepted.
+type BuildingMember struct {
+	ID         string     `json:"id"`
+	BuildingID string     `json:"building_id"`
+	UserID     *string    `json:"user_id,omitempty"`
+	Role       string     `json:"role"`   // "manager", "caretaker", "accountant"
+	Status     string     `json:"status"` // "invited", "active", "revoked"
+	Email      *string    `json:"email,omitempty"`
+	Phone      *string    `json:"phone,omitempty"`
+	InvitedBy  string     `json:"invited_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// BuildingOwner is one co-owner's share of a building. When a building has
+// no rows its landlord_id owns 100% and manages it.
+type BuildingOwner struct {
+	ID         string    `json:"id"`
+	BuildingID string    `json:"building_id"`
+	UserID     string    `json:"user_id"`
+	ShareBps   int       `json:"share_bps"` // basis points; all owners of a building sum to 10000
+	Managing   bool      `json:"managing"`  // may edit the building and its ownership
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// APIKey lets a landlord's own tools call the API. Only a hash of the key
+// is stored; Prefix is kept so the landlord can tell keys apart.
+type APIKey struct {
+	ID                 string     `json:"id"`
+	UserID             string     `json:"user_id"`
+	Name               string     `json:"name"`
+	Prefix             string     `json:"prefix"`
+	Scopes             []string   `json:"scopes"`
+	RateLimitPerMinute int        `json:"rate_limit_per_minute"`
+	ExpiresAt          time.Time  `json:"expires_at"`
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// PhoneOTP is an SMS code sent to a phone number. Only an HMAC of the code
+// is stored.
+type PhoneOTP struct {
+	ID         string     `json:"id"`
+	Phone      string     `json:"phone"`
+	CodeHash   string     `json:"code_hash"`
+	Attempts   int        `json:"attempts"` // wrong guesses so far
+	ExpiresAt  time.Time  `json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AuthToken is a single-use token behind an emailed link or a login
+// challenge. Only a hash of the token is stored.
+type AuthToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Purpose   string     `json:"purpose"` // "password_reset", "email_verification", "mfa_login", "email_change"
+	TokenHash string     `json:"token_hash"`
+	Attempts  int        `json:"attempts"` // codes tried against an mfa_login challenge
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginLockout counts an email's recent failed logins
+type LoginLockout struct {
+	Email        string     `json:"email"` // trimmed and lower-cased
+	FailedCount  int        `json:"failed_count"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+}
+
+// UserMFA is a user's TOTP secret and two-factor settings. The secret is
+// pending until EnabledAt is set.
+type UserMFA struct {
+	UserID              string     `json:"user_id"`
+	Secret              string     `json:"secret"`
+	EnabledAt           *time.Time `json:"enabled_at"`
+	LastUsedStep        int64      `json:"last_used_step"`
+	RequireForSensitive bool       `json:"require_for_sensitive"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// Organisation is a property management company that manages buildings on
+// behalf of several owners
+type Organisation struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganisationMember links a user to an organisation. Staff roles apply to
+// every building the organisation manages; "owner" members are clients who
+// only see the buildings they own.
+type OrganisationMember struct {
+	ID             string     `json:"id"`
+	OrganisationID string     `json:"organisation_id"`
+	UserID         *string    `json:"user_id,omitempty"`
+	Role           string     `json:"role"`   // "admin", "manager", "caretaker", "accountant", "owner"
+	Status         string     `json:"status"` // "invited", "active", "revoked"
+	Email          *string    `json:"email,omitempty"`
+	Phone          *string    `json:"phone,omitempty"`
+	InvitedBy      string     `json:"invited_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
 // Invitation represents a tenant invite to a unit
 type Invitation struct {
 	ID         string    `json:"id"`
 	UnitID     string    `json:"unit_id"`
 	LandlordID string    `json:"landlord_id"`
+	InvitedBy  *string   `json:"invited_by,omitempty"` // landlord or building staff who sent it
 	Email      *string   `json:"email,omitempty"`
 	Phone      *string   `json:"phone,omitempty"`
 	Token      string    `json:"token"`
@@ -111,7 +312,7 @@ type Notification struct {
 	ID      string                 `json:"id"`
 	UserID  string                 `json:"user_id"`
 	Channel string                 `json:"channel"` // "email" or "sms"
-	Type    string                 `json:"type"`    // "payment_receipt", "late_reminder", "welcome", "tenant_invite"
+	Type    string                 `json:"type"`    // "payment_receipt", "late_reminder", "welcome", "tenant_invite", "password_reset", "email_verification", "login_otp"
 	Payload map[string]interface{} `json:"payload"`
 	SentAt  time.Time              `json:"sent_at"`
 	Status  string                 `json:"status"` // "sent" or "failed"
@@ -121,6 +322,7 @@ type Notification struct {
 type MaintenanceRequest struct {
 	ID          string    `json:"id"`
 	TenantID    string    `json:"tenant_id"`
+	TenancyID   *string   `json:"tenancy_id,omitempty"`
 	UnitID      string    `json:"unit_id"`
 	BuildingID  string    `json:"building_id"`
 	Title       string    `json:"title"`
@@ -137,6 +339,7 @@ type Document struct {
 	UploadedBy string    `json:"uploaded_by"`
 	BuildingID *string   `json:"building_id,omitempty"`
 	UnitID     *string   `json:"unit_id,omitempty"`
+	TenancyID  *string   `json:"tenancy_id,omitempty"` // the tenancy it belongs to, which its tenant sees it through
 	Name       string    `json:"name"`
 	Type       string    `json:"type"` // "lease_agreement", "receipt", "other"
 	FileURL    string    `json:"file_url"`
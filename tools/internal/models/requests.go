@@ -6,7 +6,7 @@ type SignupRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	FullName string `json:"full_name"`
-	Role     string `json:"role"` // "landlord" or "tenant"
+	Role     string `json:"role"` // "landlord", "tenant" or "staff"
 	Phone    string `json:"phone,omitempty"`
 }
 
@@ -15,34 +15,127 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// MFAChallengeResponse is returned by Login instead of a session when the
+// account has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// LoginMFARequest completes a login with the challenge token and a TOTP or recovery code
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
 type AuthResponse struct {
 	AccessToken  string  `json:"access_token"`
 	RefreshToken string  `json:"refresh_token"`
 	User         Profile `json:"user"`
 }
 
+// RequestOTPRequest asks for a one-time code to be sent by SMS
+type RequestOTPRequest struct {
+	Phone string `json:"phone"`
+}
+
+// VerifyOTPRequest logs in with an SMS code. FullName and Role are only
+// needed when no account exists for the phone yet (signup).
+type VerifyOTPRequest struct {
+	Phone    string `json:"phone"`
+	Code     string `json:"code"`
+	FullName string `json:"full_name,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+// --- Account Request ---
+
+// UpdateProfileRequest changes the caller's profile; omitted fields are
+// left alone. A new email or phone only takes effect once confirmed.
+type UpdateProfileRequest struct {
+	FullName *string `json:"full_name,omitempty"`
+	Email    *string `json:"email,omitempty"`
+	Phone    *string `json:"phone,omitempty"`
+}
+
+// ConfirmPhoneRequest confirms a pending phone change with its SMS code
+type ConfirmPhoneRequest struct {
+	Code string `json:"code"`
+}
+
+// DeleteAccountRequest confirms an account erasure; Confirm must be "DELETE"
+type DeleteAccountRequest struct {
+	Confirm string `json:"confirm"`
+}
+
+// --- Two-Factor Request ---
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type MFASettingsRequest struct {
+	RequireForSensitive bool   `json:"require_for_sensitive"`
+	Code                string `json:"code"`
+}
+
+type MFAEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// AcceptInviteRequest accepts an invite with either email+password or,
+// for phone invites, phone plus an SMS code from /auth/otp/request
 type AcceptInviteRequest struct {
 	Token    string `json:"token"`
 	FullName string `json:"full_name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password,omitempty"`
 	Phone    string `json:"phone,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// ClaimInviteRequest accepts a tenant invite for an already logged-in account
+type ClaimInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// AddRoleRequest gives an existing account another role
+type AddRoleRequest struct {
+	Role        string `json:"role"`                   // "landlord", "tenant" or "staff"
+	MakeDefault bool   `json:"make_default,omitempty"` // use it when no X-Active-Role is sent
 }
 
 // --- Building Request ---
 
 type CreateBuildingRequest struct {
-	Name       string `json:"name"`
-	Address    string `json:"address"`
-	TotalUnits int    `json:"total_units"`
-	PhotoURL   string `json:"photo_url,omitempty"`
+	Name           string `json:"name"`
+	Address        string `json:"address"`
+	PhotoURL       string `json:"photo_url,omitempty"`
+	OrganisationID string `json:"organisation_id,omitempty"` // create under an organisation (admins only)
+	OwnerID        string `json:"owner_id,omitempty"`        // owner member the building is held for; defaults to the caller
+	GenerateUnits  string `json:"generate_units,omitempty"`  // unit pattern such as "A1..A12" or "101..110, 201..210"
+	UnitRent       int64  `json:"unit_rent,omitempty"`       // rent of each generated unit, in kobo
 }
 
 type UpdateBuildingRequest struct {
-	Name       *string `json:"name,omitempty"`
-	Address    *string `json:"address,omitempty"`
-	TotalUnits *int    `json:"total_units,omitempty"`
-	PhotoURL   *string `json:"photo_url,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	Address  *string `json:"address,omitempty"`
+	PhotoURL *string `json:"photo_url,omitempty"`
 }
 
 // --- Unit Request ---
@@ -55,6 +148,13 @@ type CreateUnitRequest struct {
 	LeaseEnd   string `json:"lease_end,omitempty"`
 }
 
+// GenerateUnitsRequest adds the units of a pattern such as "A1..A12" or
+// "101..110, 201..210" to a building
+type GenerateUnitsRequest struct {
+	Pattern    string `json:"pattern"`
+	RentAmount int64  `json:"rent_amount"` // in kobo, for every unit
+}
+
 type UpdateUnitRequest struct {
 	UnitNumber *string `json:"unit_number,omitempty"`
 	RentAmount *int64  `json:"rent_amount,omitempty"`
@@ -62,6 +162,21 @@ type UpdateUnitRequest struct {
 	LeaseEnd   *string `json:"lease_end,omitempty"`
 }
 
+// --- Tenancy Request ---
+
+// UpdateTenancyRequest corrects a tenancy's terms. Dates are YYYY-MM-DD;
+// "" clears end_date.
+type UpdateTenancyRequest struct {
+	RentAmount *int64  `json:"rent_amount,omitempty"` // in kobo
+	StartDate  *string `json:"start_date,omitempty"`
+	EndDate    *string `json:"end_date,omitempty"`
+}
+
+// EndTenancyRequest records a tenant moving out
+type EndTenancyRequest struct {
+	EndDate string `json:"end_date,omitempty"` // YYYY-MM-DD, defaults to today
+}
+
 // --- Invitation Request ---
 
 type SendInviteRequest struct {
@@ -70,6 +185,79 @@ type SendInviteRequest struct {
 	Phone  string `json:"phone,omitempty"`
 }
 
+// --- Staff Request ---
+
+type InviteStaffRequest struct {
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+	Role  string `json:"role"` // "manager", "caretaker", "accountant"
+}
+
+type AcceptStaffInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// --- Ownership Request ---
+
+// SetBuildingOwnersRequest replaces a building's ownership list. Each owner
+// is identified by user_id or by the email of an existing account.
+type SetBuildingOwnersRequest struct {
+	Owners []BuildingOwnerInput `json:"owners"`
+}
+
+type BuildingOwnerInput struct {
+	UserID   string `json:"user_id,omitempty"`
+	Email    string `json:"email,omitempty"`
+	ShareBps int    `json:"share_bps"` // 2500 = 25%
+	Managing bool   `json:"managing"`
+}
+
+// --- Organisation Request ---
+
+type CreateOrganisationRequest struct {
+	Name string `json:"name"`
+}
+
+type InviteOrgMemberRequest struct {
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+	Role  string `json:"role"` // "admin", "manager", "caretaker", "accountant", "owner"
+}
+
+type AcceptOrgInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// AssignOrganisationRequest hands a building to an organisation, or takes it
+// back when organisation_id is null
+type AssignOrganisationRequest struct {
+	OrganisationID *string `json:"organisation_id"`
+}
+
+// --- API Key Request ---
+
+type CreateAPIKeyRequest struct {
+	Name               string   `json:"name"`
+	Scopes             []string `json:"scopes"`                          // e.g. ["payments:read"]
+	ExpiresInDays      int      `json:"expires_in_days,omitempty"`       // default 90, max 365
+	RateLimitPerMinute int      `json:"rate_limit_per_minute,omitempty"` // default 60, max 600
+}
+
+// CreateAPIKeyResponse is the only time the full key is shown
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// --- Admin Request ---
+
+// StartImpersonationRequest opens a read-only support session as user_id.
+// The reason is stored in the audit log.
+type StartImpersonationRequest struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
 // --- Payment Request ---
 
 type InitializePaymentRequest struct {
@@ -77,6 +265,16 @@ type InitializePaymentRequest struct {
 	Period string `json:"period"` // e.g. "Feb 2026"
 }
 
+// RecordOfflinePaymentRequest records cash or bank transfer rent collected by staff
+type RecordOfflinePaymentRequest struct {
+	UnitID        string `json:"unit_id"`
+	TenancyID     string `json:"tenancy_id,omitempty"` // an earlier tenancy of the unit; defaults to the current one
+	Amount        int64  `json:"amount"`               // in kobo
+	Period        string `json:"period"`
+	PaymentMethod string `json:"payment_method"`    // "cash" or "bank_transfer"
+	PaidAt        string `json:"paid_at,omitempty"` // YYYY-MM-DD, defaults to today
+}
+
 type InitializePaymentResponse struct {
 	AuthorizationURL string `json:"authorization_url"`
 	Reference        string `json:"reference"`
@@ -100,6 +298,7 @@ type UpdateMaintenanceStatusRequest struct {
 type UploadDocumentRequest struct {
 	BuildingID string `json:"building_id,omitempty"`
 	UnitID     string `json:"unit_id,omitempty"`
+	TenancyID  string `json:"tenancy_id,omitempty"` // defaults to the unit's current tenancy
 	Name       string `json:"name"`
 	Type       string `json:"type"` // "lease_agreement", "receipt", "other"
 }
@@ -127,6 +326,30 @@ type TenantDashboard struct {
 	NextAmount  int64    `json:"next_amount"` // kobo
 }
 
+// --- Import Response ---
+
+// ImportReport is the outcome of a bulk import or its dry run. Nothing is
+// written while Errors lists anything.
+type ImportReport struct {
+	DryRun          bool             `json:"dry_run"`
+	Units           int              `json:"units"`    // units to create
+	Tenants         int              `json:"tenants"`  // tenants to invite
+	Payments        int              `json:"payments"` // past payments to record
+	Errors          []ImportRowError `json:"errors"`
+	Import          *Import          `json:"import,omitempty"` // the committed batch
+	InvitationsSent int              `json:"invitations_sent"`
+	InviteFailures  []ImportRowError `json:"invite_failures,omitempty"`
+}
+
+// ImportRowError is a problem with one row of an import file, or with the
+// whole file when Row is 0
+type ImportRowError struct {
+	File    string `json:"file"` // "units" or "payments"
+	Row     int    `json:"row"`  // as numbered in the spreadsheet
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
 // --- Generic Response ---
 
 type APIResponse struct {
@@ -143,4 +366,5 @@ type PaginatedResponse struct {
 	Page       int         `json:"page"`
 	PerPage    int         `json:"per_page"`
 	TotalPages int         `json:"total_pages"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,17 @@
+package notify
+
+import "log"
+
+// LogSender writes messages to the server log instead of delivering them.
+// It is used in local development when no provider keys are configured.
+type LogSender struct{}
+
+func (LogSender) SendEmail(to, subject, html string) error {
+	log.Printf("📧 [dev email] to=%s subject=%q\n%s", to, subject, html)
+	return nil
+}
+
+func (LogSender) SendSMS(to, body string) error {
+	log.Printf("📱 [dev sms] to=%s\n%s", to, body)
+	return nil
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"log"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/aletheia/backend/internal/store"
+)
+
+// EmailSender delivers a single email message
+type EmailSender interface {
+	SendEmail(to, subject, html string) error
+}
+
+// SMSSender delivers a single text message to an E.164 phone number
+type SMSSender interface {
+	SendSMS(to, body string) error
+}
+
+// Notifier sends notifications through the configured providers and
+// records every attempt in the notifications table
+type Notifier struct {
+	log    store.NotificationStore
+	email  EmailSender
+	sms    SMSSender
+	appURL string
+}
+
+func New(notifications store.NotificationStore, email EmailSender, sms SMSSender, appURL string) *Notifier {
+	return &Notifier{log: notifications, email: email, sms: sms, appURL: appURL}
+}
+
+// AppURL returns the public base URL used to build links in messages
+func (n *Notifier) AppURL() string {
+	return n.appURL
+}
+
+// Email sends a rendered message to the given address on behalf of userID
+func (n *Notifier) Email(userID, to string, msg Message) error {
+	err := n.email.SendEmail(to, msg.Subject, msg.Body)
+	n.record(userID, "email", msg, err)
+	return err
+}
+
+// SMS sends the message body as a text to the given E.164 number
+func (n *Notifier) SMS(userID, to string, msg Message) error {
+	err := n.sms.SendSMS(to, msg.Body)
+	n.record(userID, "sms", msg, err)
+	return err
+}
+
+// record stores the notification outcome; failures here are only logged
+// so that a broken audit trail never blocks delivery
+func (n *Notifier) record(userID, channel string, msg Message, sendErr error) {
+	status := "sent"
+	if sendErr != nil {
+		status = "failed"
+		log.Printf("notify: %s %s to user %s failed: %v", channel, msg.Type, userID, sendErr)
+	}
+
+	if n.log == nil {
+		// Not recorded, e.g. in tests
+		return
+	}
+	err := n.log.RecordNotification(models.Notification{
+		UserID:  userID,
+		Channel: channel,
+		Type:    msg.Type,
+		Payload: msg.Payload,
+		SentAt:  time.Now().UTC(),
+		Status:  status,
+	})
+	if err != nil {
+		log.Printf("notify: failed to record %s notification: %v", msg.Type, err)
+	}
+}
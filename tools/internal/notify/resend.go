@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const resendAPIURL = "https://api.resend.com/emails"
+
+// ResendSender sends email through the Resend HTTP API
+type ResendSender struct {
+	apiKey string
+	from   string
+	http   *http.Client
+}
+
+func NewResendSender(apiKey, from string) *ResendSender {
+	return &ResendSender{
+		apiKey: apiKey,
+		from:   from,
+		http:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *ResendSender) SendEmail(to, subject, html string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"from":    s.from,
+		"to":      []string{to},
+		"subject": subject,
+		"html":    html,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, resendAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("resend: status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
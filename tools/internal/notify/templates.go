@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"fmt"
+	"html"
+)
+
+// Message is a rendered notification ready to be sent
+type Message struct {
+	Type    string // matches models.Notification.Type
+	Subject string
+	Body    string
+	Payload map[string]interface{}
+}
+
+// PasswordResetEmail renders the forgot-password email
+func PasswordResetEmail(name, link string, validFor string) Message {
+	return Message{
+		Type:    "password_reset",
+		Subject: "Reset your Aletheia password",
+		Body: fmt.Sprintf(`<p>Hi %s,</p>
+<p>We received a request to reset your Aletheia password. Use the link below to choose a new one:</p>
+<p><a href="%s">Reset password</a></p>
+<p>This link expires in %s and can only be used once. If you didn't ask for this, you can ignore this email.</p>`,
+			html.EscapeString(name), link, validFor),
+		Payload: map[string]interface{}{"valid_for": validFor},
+	}
+}
+
+// EmailVerificationEmail renders the confirm-your-address email sent after signup
+func EmailVerificationEmail(name, link string, validFor string) Message {
+	return Message{
+		Type:    "email_verification",
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(`<p>Hi %s,</p>
+<p>Please confirm that this email address belongs to you:</p>
+<p><a href="%s">Confirm email</a></p>
+<p>This link expires in %s.</p>`,
+			html.EscapeString(name), link, validFor),
+		Payload: map[string]interface{}{"valid_for": validFor},
+	}
+}
+
+// EmailChangeEmail asks the owner of a new address to confirm it
+func EmailChangeEmail(name, link string, validFor string) Message {
+	return Message{
+		Type:    "email_change",
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(`<p>Hi %s,</p>
+<p>You asked to use this address for your Aletheia account. Please confirm it:</p>
+<p><a href="%s">Confirm new email</a></p>
+<p>This link expires in %s. Until then, your old address stays on the account.</p>`,
+			html.EscapeString(name), link, validFor),
+		Payload: map[string]interface{}{"valid_for": validFor},
+	}
+}
+
+// EmailChangeNoticeEmail tells the current address that a change was requested
+func EmailChangeNoticeEmail(name, newEmail string) Message {
+	return Message{
+		Type:    "email_change_notice",
+		Subject: "Your Aletheia email address is being changed",
+		Body: fmt.Sprintf(`<p>Hi %s,</p>
+<p>Someone signed in to your account asked to change its email address to <strong>%s</strong>.</p>
+<p>If this wasn't you, change your password and contact support.</p>`,
+			html.EscapeString(name), html.EscapeString(newEmail)),
+		Payload: map[string]interface{}{},
+	}
+}
+
+// PhoneChangeSMS renders the code that confirms a new phone number
+func PhoneChangeSMS(code string, validFor string) Message {
+	return Message{
+		Type:    "phone_change_otp",
+		Body:    fmt.Sprintf("Your Aletheia code to confirm this number is %s. It expires in %s.", code, validFor),
+		Payload: map[string]interface{}{"valid_for": validFor},
+	}
+}
+
+// LoginCodeSMS renders the one-time login code text message
+func LoginCodeSMS(code string, validFor string) Message {
+	return Message{
+		Type:    "login_otp",
+		Body:    fmt.Sprintf("Your Aletheia code is %s. It expires in %s. Do not share it with anyone.", code, validFor),
+		Payload: map[string]interface{}{"valid_for": validFor},
+	}
+}
+
+// StaffInviteEmail invites someone to help run a building
+func StaffInviteEmail(buildingName, role, link string) Message {
+	return Message{
+		Type:    "staff_invite",
+		Subject: "You've been invited to help manage " + buildingName,
+		Body: fmt.Sprintf(`<p>Hello,</p>
+<p>You've been invited to join <strong>%s</strong> on Aletheia as <strong>%s</strong>.</p>
+<p><a href="%s">Accept invitation</a></p>
+<p>Sign in or create an account with this email address, then accept. The invitation expires in 7 days.</p>`,
+			html.EscapeString(buildingName), html.EscapeString(role), link),
+		Payload: map[string]interface{}{"building_name": buildingName, "role": role},
+	}
+}
+
+// StaffInviteSMS is the text-message version of StaffInviteEmail
+func StaffInviteSMS(buildingName, role, link string) Message {
+	return Message{
+		Type:    "staff_invite",
+		Body:    fmt.Sprintf("You've been invited to %s on Aletheia as %s. Accept within 7 days: %s", buildingName, role, link),
+		Payload: map[string]interface{}{"building_name": buildingName, "role": role},
+	}
+}
+
+// TenantInviteEmail invites a tenant to accept their unit on Aletheia
+func TenantInviteEmail(buildingName, unitNumber, link string) Message {
+	return Message{
+		Type:    "tenant_invite",
+		Subject: "Your landlord has invited you to " + buildingName,
+		Body: fmt.Sprintf(`<p>Hello,</p>
+<p>You've been invited to Aletheia as the tenant of unit <strong>%s</strong> at <strong>%s</strong>.</p>
+<p><a href="%s">Accept invitation</a></p>
+<p>You'll be able to pay rent, view your lease and raise maintenance requests.</p>`,
+			html.EscapeString(unitNumber), html.EscapeString(buildingName), link),
+		Payload: map[string]interface{}{"building_name": buildingName, "unit_number": unitNumber},
+	}
+}
+
+// TenantInviteSMS is the text-message version of TenantInviteEmail
+func TenantInviteSMS(buildingName, unitNumber, link string) Message {
+	return Message{
+		Type:    "tenant_invite",
+		Body:    fmt.Sprintf("You've been invited to Aletheia as tenant of unit %s at %s. Accept here: %s", unitNumber, buildingName, link),
+		Payload: map[string]interface{}{"building_name": buildingName, "unit_number": unitNumber},
+	}
+}
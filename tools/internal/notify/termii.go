@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const termiiAPIURL = "https://api.ng.termii.com/api/sms/send"
+
+// TermiiSender sends SMS through the Termii HTTP API
+type TermiiSender struct {
+	apiKey   string
+	senderID string
+	http     *http.Client
+}
+
+func NewTermiiSender(apiKey, senderID string) *TermiiSender {
+	return &TermiiSender{
+		apiKey:   apiKey,
+		senderID: senderID,
+		http:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *TermiiSender) SendSMS(to, body string) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"api_key": s.apiKey,
+		"to":      strings.TrimPrefix(to, "+"), // Termii expects 234XXXXXXXXXX
+		"from":    s.senderID,
+		"sms":     body,
+		"type":    "plain",
+		"channel": "dnd", // transactional route, delivered to DND numbers
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, termiiAPIURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("termii: status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
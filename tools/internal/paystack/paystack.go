@@ -0,0 +1,77 @@
+package paystack
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const apiURL = "https://api.paystack.co"
+
+// Client talks to the Paystack REST API with a secret key
+type Client struct {
+	secretKey string
+	http      *http.Client
+}
+
+func New(secretKey string) *Client {
+	return &Client{
+		secretKey: secretKey,
+		http:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Transaction is the subset of a Paystack transaction we reconcile against
+type Transaction struct {
+	ID        int64      `json:"id"`
+	Status    string     `json:"status"` // "success", "failed", "abandoned", "ongoing", ...
+	Reference string     `json:"reference"`
+	Amount    int64      `json:"amount"` // in kobo
+	Currency  string     `json:"currency"`
+	Channel   string     `json:"channel"` // "card", "bank", "ussd", "bank_transfer", ...
+	PaidAt    *time.Time `json:"paid_at"`
+}
+
+// VerifySignature checks the x-paystack-signature header, an HMAC-SHA512 of
+// the raw request body keyed with the secret key
+func (c *Client) VerifySignature(body []byte, signature string) bool {
+	mac := hmac.New(sha512.New, []byte(c.secretKey))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// Verify fetches the current state of a transaction by reference
+func (c *Client) Verify(reference string) (*Transaction, error) {
+	req, err := http.NewRequest(http.MethodGet, apiURL+"/transaction/verify/"+url.PathEscape(reference), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.secretKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status  bool        `json:"status"`
+		Message string      `json:"message"`
+		Data    Transaction `json:"data"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, fmt.Errorf("paystack: status %d: %s", resp.StatusCode, raw)
+	}
+	if resp.StatusCode >= 300 || !body.Status {
+		return nil, fmt.Errorf("paystack: status %d: %s", resp.StatusCode, body.Message)
+	}
+	return &body.Data, nil
+}
@@ -0,0 +1,54 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned for numbers that are not valid Nigerian mobile numbers
+var ErrInvalid = errors.New("invalid Nigerian phone number")
+
+// NormalizeNG converts a Nigerian mobile number in any common local or
+// international format to E.164 (e.g. "0803 123 4567" → "+2348031234567").
+//
+// Accepted inputs: 08031234567, 8031234567, 2348031234567, +2348031234567,
+// 002348031234567, with optional spaces, dashes, dots or parentheses.
+func NormalizeNG(raw string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			// leading plus is implied by the country code below
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalid
+		}
+	}
+	digits := b.String()
+
+	switch {
+	case strings.HasPrefix(digits, "00234"):
+		digits = digits[5:]
+	case strings.HasPrefix(digits, "234"):
+		digits = digits[3:]
+	case strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	}
+
+	// National significant number: 10 digits, mobile ranges start with 7, 8 or 9
+	if len(digits) != 10 || !strings.ContainsRune("789", rune(digits[0])) {
+		return "", ErrInvalid
+	}
+
+	return "+234" + digits, nil
+}
+
+// Mask hides all but the last four digits for display in responses and logs
+func Mask(e164 string) string {
+	if len(e164) <= 4 {
+		return e164
+	}
+	return strings.Repeat("*", len(e164)-4) + e164[len(e164)-4:]
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at PerMinute
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// PerMinute returns a limit of n requests per minute with a burst of n
+func PerMinute(n int) Limit {
+	return Limit{PerMinute: n, Burst: n}
+}
+
+// Store keeps bucket state. Take removes one token from the bucket at key
+// and reports whether it was available and, if not, how long until it is.
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (allowed bool, retryAfter time.Duration, err error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryStore is a per-process Store. Buckets that have refilled completely
+// are dropped periodically so the map does not grow without bound.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+const sweepEvery = 10000
+
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.calls%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	b := s.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	allowed, retryAfter := take(b, limit, now)
+	return allowed, retryAfter, nil
+}
+
+// sweep drops buckets idle for more than an hour; any limit we use has
+// refilled by then, so they would be recreated full anyway
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > time.Hour {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// take refills b for the time since it was last used and spends a token
+func take(b *bucket, limit Limit, now time.Time) (bool, time.Duration) {
+	rate := float64(limit.PerMinute) / 60 // tokens per second
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if rate <= 0 {
+		return false, time.Minute
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// Limiter applies limits against a Store
+type Limiter struct {
+	store Store
+}
+
+func New(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Allow spends one request from key's bucket. When the store fails the
+// request is allowed: a limiter outage should not take the API down.
+func (l *Limiter) Allow(key string, limit Limit) (bool, time.Duration) {
+	allowed, retryAfter, err := l.store.Take(key, limit, time.Now())
+	if err != nil {
+		log.Printf("%v", err)
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds for the Retry-After header
+func RetryAfterSeconds(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	supabase "github.com/supabase-community/supabase-go"
+)
+
+// SupabaseStore keeps buckets in Postgres through the rate_limit_take RPC,
+// so every API instance shares the same limits. It is slower than
+// MemoryStore and only worth it when running more than one instance.
+type SupabaseStore struct {
+	client *supabase.Client
+}
+
+func NewSupabaseStore(client *supabase.Client) *SupabaseStore {
+	return &SupabaseStore{client: client}
+}
+
+func (s *SupabaseStore) Take(key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	raw := s.client.Rpc("rate_limit_take", "", map[string]interface{}{
+		"p_key":        key,
+		"p_per_minute": limit.PerMinute,
+		"p_burst":      limit.Burst,
+	})
+	if raw == "" {
+		return true, 0, errors.New("ratelimit: empty response from rate_limit_take")
+	}
+
+	var result struct {
+		Allowed      *bool `json:"allowed"`
+		RetryAfterMs int64 `json:"retry_after_ms"`
+	}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil || result.Allowed == nil {
+		return true, 0, fmt.Errorf("ratelimit: unexpected rate_limit_take response: %.200s", raw)
+	}
+	return *result.Allowed, time.Duration(result.RetryAfterMs) * time.Millisecond, nil
+}
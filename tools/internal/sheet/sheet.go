@@ -0,0 +1,310 @@
+// Package sheet reads spreadsheet uploads — CSV, or the first worksheet of
+// an XLSX workbook — as rows of text, using only the standard library.
+package sheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxUnzipped caps how much of an XLSX is decompressed, so a small,
+// highly compressed file cannot expand into gigabytes of memory
+const maxUnzipped = 64 << 20
+
+// maxColumns is Excel's own limit (column XFD)
+const maxColumns = 16384
+
+var (
+	ErrUnsupported = errors.New("file must be a CSV or an Excel workbook (.xlsx)")
+	ErrEmpty       = errors.New("file has no header row")
+)
+
+// Table is a sheet with a header row. Header names are lower-cased with
+// spaces and hyphens turned into underscores, so "Unit Number" is
+// unit_number.
+type Table struct {
+	Header []string
+	Rows   []Row
+}
+
+// Row is a non-blank row below the header
+type Row struct {
+	Line  int // as numbered in the spreadsheet
+	Cells []string
+}
+
+// Get returns a row's value in the named column, trimmed; "" when the
+// column is missing or the row is short
+func (t Table) Get(row Row, column string) string {
+	for i, h := range t.Header {
+		if h == column {
+			if i < len(row.Cells) {
+				return strings.TrimSpace(row.Cells[i])
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// Has reports whether the sheet has the named column
+func (t Table) Has(column string) bool {
+	for _, h := range t.Header {
+		if h == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Read parses a CSV or XLSX file, told apart by its contents rather than
+// its name. The first non-blank row is the header; blank rows are dropped.
+func Read(data []byte) (Table, error) {
+	var rows []Row
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		rows, err = readXLSX(data)
+	case isText(data):
+		rows, err = readCSV(data)
+	default:
+		return Table{}, ErrUnsupported
+	}
+	if err != nil {
+		return Table{}, err
+	}
+
+	var t Table
+	for _, row := range rows {
+		switch {
+		case blank(row.Cells):
+		case t.Header == nil:
+			t.Header = make([]string, len(row.Cells))
+			for i, h := range row.Cells {
+				h = strings.ToLower(strings.TrimSpace(h))
+				t.Header[i] = strings.NewReplacer(" ", "_", "-", "_").Replace(h)
+			}
+		default:
+			t.Rows = append(t.Rows, row)
+		}
+	}
+	if t.Header == nil {
+		return Table{}, ErrEmpty
+	}
+	return t, nil
+}
+
+// Date parses a date cell: YYYY-MM-DD, DD/MM/YYYY, or the serial number
+// Excel stores dates as
+func Date(v string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2/1/2006"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil && n >= 1 && n < 2958466 {
+		// Day 1 is 1900-01-01, counting Lotus 1-2-3's 29 February 1900
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(n)), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date (use YYYY-MM-DD)", v)
+}
+
+// isText reports whether data looks like a text file: valid UTF-8 without
+// control characters other than whitespace
+func isText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, c := range data {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+func blank(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func readCSV(data []byte) ([]Row, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var rows []Row
+	for {
+		cells, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("file is not valid CSV: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		rows = append(rows, Row{Line: line, Cells: cells})
+	}
+}
+
+// The parts of SpreadsheetML that Read looks at
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Ref   int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(data []byte) ([]Row, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	budget := int64(maxUnzipped)
+	load := func(name string, v interface{}) error {
+		f, err := zr.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		lr := &io.LimitedReader{R: f, N: budget + 1}
+		raw, err := io.ReadAll(lr)
+		if err != nil {
+			return err
+		}
+		if budget -= int64(len(raw)); budget < 0 {
+			return errors.New("workbook is too large")
+		}
+		return xml.Unmarshal(raw, v)
+	}
+
+	var wb xlsxWorkbook
+	var rels xlsxRelationships
+	if load("xl/workbook.xml", &wb) != nil || load("xl/_rels/workbook.xml.rels", &rels) != nil || len(wb.Sheets) == 0 {
+		return nil, ErrUnsupported
+	}
+	sheetPath := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == wb.Sheets[0].RID {
+			if strings.HasPrefix(rel.Target, "/") {
+				sheetPath = strings.TrimPrefix(rel.Target, "/")
+			} else {
+				sheetPath = path.Join("xl", rel.Target)
+			}
+		}
+	}
+
+	var shared xlsxSharedStrings
+	if err := load("xl/sharedStrings.xml", &shared); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("workbook is damaged: %w", err)
+	}
+	var ws xlsxWorksheet
+	if err := load(sheetPath, &ws); err != nil {
+		return nil, fmt.Errorf("workbook is damaged: %w", err)
+	}
+
+	rows := make([]Row, 0, len(ws.Rows))
+	for i, xr := range ws.Rows {
+		var row []string
+		for j, c := range xr.Cells {
+			col := j
+			if c.Ref != "" {
+				col = column(c.Ref)
+			}
+			if col < 0 || col >= maxColumns {
+				return nil, errors.New("workbook is damaged: bad cell reference")
+			}
+			var v string
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, errors.New("workbook is damaged: bad shared string")
+				}
+				v = shared.Items[n].String()
+			case "inlineStr":
+				v = c.Inline.String()
+			default:
+				v = c.Value
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			row[col] = v
+		}
+		line := xr.Ref
+		if line == 0 {
+			line = i + 1
+		}
+		rows = append(rows, Row{Line: line, Cells: row})
+	}
+	return rows, nil
+}
+
+// column turns a cell reference such as "AB12" into a zero-based column
+func column(ref string) int {
+	n := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		n = n*26 + int(c-'A'+1)
+	}
+	return n - 1
+}
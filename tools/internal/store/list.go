@@ -0,0 +1,138 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PerPage bounds for ListOptions
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// ListOptions pages, sorts and filters a list. Lists are paged by Page
+// (1-based) or, when After is set, by keyset: the rows that follow After
+// in Sort order, which stays stable while rows are added. The zero value
+// is the first DefaultPerPage rows in the list's default order.
+//
+// Sort and filter columns are trusted: handlers only pass whitelisted ones.
+type ListOptions struct {
+	PerPage int
+	Page    int
+	After   *Cursor
+	Sort    Sort
+	Filters []Filter
+}
+
+// Sort orders a list by one column, then by id so the order is total
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// Cursor is the position of a row in a sorted list: its sort column value
+// (as it appears in the row's JSON) and its id
+type Cursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Filter is a condition on a column. Value is a string (IDs, statuses,
+// RFC 3339 timestamps), an int64 (amounts), for OpIn a []string, and for
+// OpNull a bool: true for IS NULL, false for IS NOT NULL.
+type Filter struct {
+	Column string
+	Op     string
+	Value  interface{}
+}
+
+// Filter operators
+const (
+	OpEq   = "eq"
+	OpIn   = "in"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+	OpNull = "null"
+)
+
+// Page is one page of a list. Total counts every row matching the scope
+// and filters, whichever page this is; Next is the cursor for the
+// following page, nil on the last one.
+type Page[T any] struct {
+	Items []T
+	Total int
+	Next  *Cursor
+}
+
+func (o ListOptions) perPage() int {
+	switch {
+	case o.PerPage < 1:
+		return DefaultPerPage
+	case o.PerPage > MaxPerPage:
+		return MaxPerPage
+	}
+	return o.PerPage
+}
+
+func (o ListOptions) offset() int {
+	if o.After != nil || o.Page < 2 {
+		return 0
+	}
+	return (o.Page - 1) * o.perPage()
+}
+
+func (o ListOptions) order(def Sort) Sort {
+	if o.Sort.Column == "" {
+		return def
+	}
+	return o.Sort
+}
+
+// finish makes a Page from the rows fetched for it. Stores fetch one row
+// more than a page holds, so the extra row tells whether another page
+// follows.
+func finish[T any](rows []T, total int, opts ListOptions, sort Sort) (Page[T], error) {
+	if rows == nil {
+		rows = []T{}
+	}
+	page := Page[T]{Items: rows, Total: total}
+	if n := opts.perPage(); len(rows) > n {
+		page.Items = rows[:n]
+		next, err := cursorOf(page.Items[n-1], sort.Column)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		page.Next = &next
+	}
+	return page, nil
+}
+
+// cursorOf reads a row's sort value and id from its JSON form, whose keys
+// are the column names
+func cursorOf(row interface{}, column string) (Cursor, error) {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return Cursor{}, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return Cursor{}, err
+	}
+	var id string
+	if err := json.Unmarshal(doc["id"], &id); err != nil {
+		return Cursor{}, fmt.Errorf("store: row has no id: %w", err)
+	}
+	return Cursor{Value: jsonText(doc[column]), ID: id}, nil
+}
+
+// jsonText is a JSON scalar as plain text: strings unquoted, numbers as
+// written
+func jsonText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
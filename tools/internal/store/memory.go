@@ -0,0 +1,2214 @@
+package store
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// Memory is an in-process Store for tests. It mirrors what the Supabase
+// store returns, including the embedded related rows, and is safe for
+// concurrent use. Create methods keep an ID or CreatedAt already set on the
+// value, so tests can seed fixed data through them.
+type Memory struct {
+	mu             sync.Mutex
+	buildings      []models.Building
+	units          []models.Unit
+	tenancies      []models.Tenancy
+	payments       []models.Payment
+	webhookEvents  []models.WebhookEvent
+	invitations    []models.Invitation
+	maintenance    []models.MaintenanceRequest
+	documents      []models.Document
+	profiles       []models.Profile
+	orgs           []models.Organisation
+	orgMembers     []member
+	staff          []member
+	imports        []models.Import
+	owners         []models.BuildingOwner
+	apiKeys        []apiKey
+	auditLog       []models.AuditEntry
+	notifications  []models.Notification
+	otps           []models.PhoneOTP
+	authTokens     []models.AuthToken
+	lockouts       []models.LoginLockout
+	mfa            []models.UserMFA
+	recoveryCodes  []recoveryCode
+	impersonations []impersonation
+}
+
+// member is a building_members or organisation_members row; scopeID is the
+// building or organisation
+type member struct {
+	ID, scopeID           string
+	UserID                *string
+	Role, Status          string
+	Email, Phone          *string
+	InvitedBy             string
+	CreatedAt, ExpiresAt  time.Time
+	AcceptedAt, RevokedAt *time.Time
+	tokenHash             string
+}
+
+func (mb member) building() models.BuildingMember {
+	return models.BuildingMember{ID: mb.ID, BuildingID: mb.scopeID, UserID: mb.UserID, Role: mb.Role, Status: mb.Status,
+		Email: mb.Email, Phone: mb.Phone, InvitedBy: mb.InvitedBy, CreatedAt: mb.CreatedAt, ExpiresAt: mb.ExpiresAt,
+		AcceptedAt: mb.AcceptedAt, RevokedAt: mb.RevokedAt}
+}
+
+func (mb member) organisation() models.OrganisationMember {
+	return models.OrganisationMember{ID: mb.ID, OrganisationID: mb.scopeID, UserID: mb.UserID, Role: mb.Role, Status: mb.Status,
+		Email: mb.Email, Phone: mb.Phone, InvitedBy: mb.InvitedBy, CreatedAt: mb.CreatedAt, ExpiresAt: mb.ExpiresAt,
+		AcceptedAt: mb.AcceptedAt, RevokedAt: mb.RevokedAt}
+}
+
+type impersonation struct {
+	models.ImpersonationSession
+	hash string
+}
+
+type apiKey struct {
+	models.APIKey
+	hash string
+}
+
+type recoveryCode struct {
+	userID, hash string
+	used         bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Store returns a Store with every part backed by m
+func (m *Memory) Store() *Store {
+	return &Store{
+		Buildings:     m,
+		Units:         m,
+		Tenancies:     m,
+		Payments:      m,
+		Invitations:   m,
+		Maintenance:   m,
+		Documents:     m,
+		Profiles:      m,
+		Organisations: m,
+		Staff:         m,
+		Stats:         m,
+		Search:        m,
+		Imports:       m,
+		Owners:        m,
+		APIKeys:       m,
+		Audit:         m,
+		Notifications: m,
+		OTPs:          m,
+		AuthTokens:    m,
+		Lockouts:      m,
+		MFA:           m,
+		Admin:         m,
+	}
+}
+
+// PutProfile adds or replaces a profile
+func (m *Memory) PutProfile(p models.Profile) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.profileIndex(p.ID); i >= 0 {
+		m.profiles[i] = p
+		return
+	}
+	m.profiles = append(m.profiles, p)
+}
+
+// AddOrganisationMember makes userID an active member of orgID
+func (m *Memory) AddOrganisationMember(orgID, userID, role string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	m.orgMembers = append(m.orgMembers, member{ID: uuid.NewString(), scopeID: orgID, UserID: &userID, Role: role, Status: "active",
+		InvitedBy: userID, CreatedAt: now, ExpiresAt: now, AcceptedAt: &now})
+}
+
+// PutAuditEntry adds an audit log entry, as audit.Logger would
+func (m *Memory) PutAuditEntry(e models.AuditEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&e.ID, &e.CreatedAt)
+	m.auditLog = append(m.auditLog, e)
+}
+
+// WebhookEvents returns the stored webhook deliveries, oldest first
+func (m *Memory) WebhookEvents() []models.WebhookEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.webhookEvents)
+}
+
+// stamp fills in an ID and creation time when the caller did not
+func stamp(id *string, created *time.Time) {
+	if *id == "" {
+		*id = uuid.NewString()
+	}
+	if created.IsZero() {
+		*created = time.Now().UTC()
+	}
+}
+
+// nullify turns pointers to empty strings into nil, as the Supabase store
+// leaves those columns NULL
+func nullify(fields ...**string) {
+	for _, f := range fields {
+		if *f != nil && **f == "" {
+			*f = nil
+		}
+	}
+}
+
+// patch applies column → value updates to a row by way of its JSON form,
+// so keys are the same column names PostgREST takes
+func patch[T any](row T, fields map[string]interface{}) (T, error) {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return row, err
+	}
+	doc := map[string]interface{}{}
+	json.Unmarshal(raw, &doc)
+	for k, v := range fields {
+		doc[k] = v
+	}
+	if raw, err = json.Marshal(doc); err != nil {
+		return row, err
+	}
+	var out T
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return row, err
+	}
+	return out, nil
+}
+
+func find[T any](rows []T, match func(T) bool) int {
+	return slices.IndexFunc(rows, match)
+}
+
+// paginate applies ListOptions to rows already limited to the list's
+// scope. Columns are read from each row's JSON form, like cursorOf, and
+// compared as numbers, timestamps or text.
+func paginate[T any](rows []T, opts ListOptions, def Sort) (Page[T], error) {
+	sortBy := opts.order(def)
+	type doc struct {
+		row    T
+		fields map[string]json.RawMessage
+	}
+	docs := make([]doc, 0, len(rows))
+	for _, row := range rows {
+		raw, err := json.Marshal(row)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		d := doc{row: row}
+		if err := json.Unmarshal(raw, &d.fields); err != nil {
+			return Page[T]{}, err
+		}
+		if matches(d.fields, opts.Filters) {
+			docs = append(docs, d)
+		}
+	}
+
+	order := func(a, b map[string]json.RawMessage) int {
+		c := compareJSON(a[sortBy.Column], jsonText(b[sortBy.Column]))
+		if c == 0 {
+			c = strings.Compare(jsonText(a["id"]), jsonText(b["id"]))
+		}
+		if sortBy.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortStableFunc(docs, func(a, b doc) int { return order(a.fields, b.fields) })
+
+	total := len(docs)
+	if c := opts.After; c != nil {
+		at := map[string]json.RawMessage{}
+		at[sortBy.Column], _ = json.Marshal(c.Value)
+		at["id"], _ = json.Marshal(c.ID)
+		i := 0
+		for i < len(docs) && order(docs[i].fields, at) <= 0 {
+			i++
+		}
+		docs = docs[i:]
+	}
+	docs = docs[min(opts.offset(), len(docs)):]
+	docs = docs[:min(opts.perPage()+1, len(docs))]
+
+	out := make([]T, len(docs))
+	for i, d := range docs {
+		out[i] = d.row
+	}
+	return finish(out, total, opts, sortBy)
+}
+
+// matches reports whether a row satisfies every filter. NULL matches
+// nothing but OpNull, as in SQL.
+func matches(fields map[string]json.RawMessage, filters []Filter) bool {
+	for _, f := range filters {
+		raw := fields[f.Column]
+		isNull := len(raw) == 0 || string(raw) == "null"
+		if f.Op == OpNull {
+			if isNull != (f.Value == true) {
+				return false
+			}
+			continue
+		}
+		if isNull {
+			return false
+		}
+		var ok bool
+		switch f.Op {
+		case OpIn:
+			ok = slices.Contains(f.Value.([]string), jsonText(raw))
+		case OpEq:
+			ok = compareJSON(raw, fmt.Sprint(f.Value)) == 0
+		case OpGte:
+			ok = compareJSON(raw, fmt.Sprint(f.Value)) >= 0
+		case OpLt:
+			ok = compareJSON(raw, fmt.Sprint(f.Value)) < 0
+		case OpLte:
+			ok = compareJSON(raw, fmt.Sprint(f.Value)) <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareJSON compares a JSON value with the text form of another, as
+// numbers when the JSON value is one, as instants when both are
+// timestamps, and as text otherwise
+func compareJSON(raw json.RawMessage, b string) int {
+	a := jsonText(raw)
+	if len(raw) > 0 && raw[0] != '"' {
+		if x, err := strconv.ParseFloat(a, 64); err == nil {
+			if y, err := strconv.ParseFloat(b, 64); err == nil {
+				return cmp.Compare(x, y)
+			}
+		}
+	}
+	if x, err := time.Parse(time.RFC3339Nano, a); err == nil {
+		if y, err := time.Parse(time.RFC3339Nano, b); err == nil {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// Buildings
+
+func (m *Memory) ListBuildings(ids []string, opts ListOptions) (Page[models.Building], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Building{}
+	for _, b := range m.buildings {
+		if slices.Contains(ids, b.ID) {
+			out = append(out, b)
+		}
+	}
+	return paginate(out, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (m *Memory) BuildingsByID(ids []string, includeArchived bool) ([]models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Building{}
+	for _, b := range m.buildings {
+		if slices.Contains(ids, b.ID) && (includeArchived || b.ArchivedAt == nil) {
+			out = append(out, b)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.Building) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+func (m *Memory) GetBuilding(id string) (models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.building(id)
+	if !ok {
+		return models.Building{}, ErrNotFound
+	}
+	return b, nil
+}
+
+func (m *Memory) CreateBuilding(b models.Building) (models.Building, error) {
+	b, _, err := m.CreateBuildingWithUnits(b, nil)
+	return b, err
+}
+
+func (m *Memory) CreateBuildingWithUnits(b models.Building, units []models.Unit) (models.Building, []models.Unit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, u := range units {
+		if slices.ContainsFunc(units[:i], func(other models.Unit) bool { return other.UnitNumber == u.UnitNumber }) {
+			return models.Building{}, nil, ErrUnitNumberTaken
+		}
+	}
+
+	stamp(&b.ID, &b.CreatedAt)
+	nullify(&b.PhotoURL, &b.OrganisationID)
+	b.UpdatedAt, b.TotalUnits = b.CreatedAt, 0
+	m.buildings = append(m.buildings, b)
+
+	out := make([]models.Unit, len(units))
+	for i, u := range units {
+		u.BuildingID = b.ID
+		stamp(&u.ID, &u.CreatedAt)
+		nullify(&u.LeaseStart, &u.LeaseEnd)
+		u.UpdatedAt = u.CreatedAt
+		if u.Status == "" {
+			u.Status = "vacant"
+		}
+		m.units = append(m.units, u)
+		m.countUnits(b.ID)
+		out[i] = u
+	}
+	b, _ = m.building(b.ID)
+	return b, out, nil
+}
+
+func (m *Memory) UpdateBuilding(id string, fields map[string]interface{}) (models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.buildings, func(b models.Building) bool { return b.ID == id })
+	if i < 0 {
+		return models.Building{}, ErrNotFound
+	}
+	b, err := patch(m.buildings[i], fields)
+	if err != nil {
+		return models.Building{}, err
+	}
+	b.UpdatedAt = time.Now().UTC()
+	m.buildings[i] = b
+	return b, nil
+}
+
+func (m *Memory) ArchiveBuilding(id string) (models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.buildings, func(b models.Building) bool { return b.ID == id })
+	if i < 0 {
+		return models.Building{}, ErrNotFound
+	}
+	if m.buildings[i].ArchivedAt != nil {
+		return m.buildings[i], nil
+	}
+	for _, u := range m.units {
+		if u.BuildingID == id && u.TenantID != nil {
+			return models.Building{}, ErrUnitOccupied
+		}
+	}
+	now := time.Now().UTC()
+	for j, u := range m.units {
+		if u.BuildingID == id && u.ArchivedAt == nil {
+			m.archiveUnit(j, now)
+		}
+	}
+	m.buildings[i].ArchivedAt, m.buildings[i].UpdatedAt = &now, now
+	m.countUnits(id)
+	return m.buildings[i], nil
+}
+
+func (m *Memory) RestoreBuilding(id string) (models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.buildings, func(b models.Building) bool { return b.ID == id })
+	if i < 0 {
+		return models.Building{}, ErrNotFound
+	}
+	at := m.buildings[i].ArchivedAt
+	if at == nil {
+		return m.buildings[i], nil
+	}
+	now := time.Now().UTC()
+	for j, u := range m.units {
+		if u.BuildingID == id && u.ArchivedAt != nil && u.ArchivedAt.Equal(*at) {
+			m.units[j].ArchivedAt, m.units[j].UpdatedAt = nil, now
+		}
+	}
+	m.buildings[i].ArchivedAt, m.buildings[i].UpdatedAt = nil, now
+	m.countUnits(id)
+	return m.buildings[i], nil
+}
+
+func (m *Memory) LandlordBuildings(landlordID string) ([]models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Building{}
+	for _, b := range m.buildings {
+		if b.LandlordID == landlordID {
+			out = append(out, b)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.Building) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+// countUnits sets a building's TotalUnits to its units that are not
+// archived, as the units_count trigger keeps it in the database
+func (m *Memory) countUnits(buildingID string) {
+	i := find(m.buildings, func(b models.Building) bool { return b.ID == buildingID })
+	if i < 0 {
+		return
+	}
+	n := 0
+	for _, u := range m.units {
+		if u.BuildingID == buildingID && u.ArchivedAt == nil {
+			n++
+		}
+	}
+	m.buildings[i].TotalUnits = n
+}
+
+func (m *Memory) building(id string) (models.Building, bool) {
+	i := find(m.buildings, func(b models.Building) bool { return b.ID == id })
+	if i < 0 {
+		return models.Building{}, false
+	}
+	return m.buildings[i], true
+}
+
+// Units
+
+func (m *Memory) ListUnits(buildingID string, opts ListOptions) (Page[UnitListing], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []UnitListing{}
+	for _, u := range m.units {
+		if u.BuildingID != buildingID {
+			continue
+		}
+		listing := UnitListing{Unit: u}
+		if u.TenantID != nil {
+			if p, ok := m.profile(*u.TenantID); ok {
+				listing.Tenant = &TenantSummary{FullName: p.FullName, Email: p.Email, Phone: p.Phone, AvatarThumbURL: p.AvatarThumbURL}
+			}
+		}
+		out = append(out, listing)
+	}
+	return paginate(out, opts, Sort{Column: "unit_number"})
+}
+
+func (m *Memory) GetUnit(id string) (models.Unit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.unit(id)
+	if !ok {
+		return models.Unit{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *Memory) CreateUnit(u models.Unit) (models.Unit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if slices.ContainsFunc(m.units, func(other models.Unit) bool {
+		return other.BuildingID == u.BuildingID && other.UnitNumber == u.UnitNumber
+	}) {
+		return models.Unit{}, ErrUnitNumberTaken
+	}
+	stamp(&u.ID, &u.CreatedAt)
+	nullify(&u.LeaseStart, &u.LeaseEnd)
+	u.UpdatedAt = u.CreatedAt
+	if u.Status == "" {
+		u.Status = "vacant"
+	}
+	m.units = append(m.units, u)
+	m.countUnits(u.BuildingID)
+	return u, nil
+}
+
+func (m *Memory) CreateUnits(units []models.Unit) ([]models.Unit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, u := range units {
+		taken := func(other models.Unit) bool {
+			return other.BuildingID == u.BuildingID && other.UnitNumber == u.UnitNumber
+		}
+		if slices.ContainsFunc(m.units, taken) || slices.ContainsFunc(units[:i], taken) {
+			return nil, ErrUnitNumberTaken
+		}
+	}
+	out := make([]models.Unit, len(units))
+	for i, u := range units {
+		stamp(&u.ID, &u.CreatedAt)
+		nullify(&u.LeaseStart, &u.LeaseEnd)
+		u.UpdatedAt = u.CreatedAt
+		if u.Status == "" {
+			u.Status = "vacant"
+		}
+		m.units = append(m.units, u)
+		m.countUnits(u.BuildingID)
+		out[i] = u
+	}
+	return out, nil
+}
+
+func (m *Memory) UpdateUnit(id string, fields map[string]interface{}) (models.Unit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.units, func(u models.Unit) bool { return u.ID == id })
+	if i < 0 {
+		return models.Unit{}, ErrNotFound
+	}
+	u, err := patch(m.units[i], fields)
+	if err != nil {
+		return models.Unit{}, err
+	}
+	u.UpdatedAt = time.Now().UTC()
+	m.units[i] = u
+	m.countUnits(u.BuildingID)
+	return u, nil
+}
+
+func (m *Memory) ArchiveUnit(id string) (models.Unit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.units, func(u models.Unit) bool { return u.ID == id })
+	if i < 0 {
+		return models.Unit{}, ErrNotFound
+	}
+	if m.units[i].TenantID != nil {
+		return models.Unit{}, ErrUnitOccupied
+	}
+	if m.units[i].ArchivedAt == nil {
+		m.archiveUnit(i, time.Now().UTC())
+		m.countUnits(m.units[i].BuildingID)
+	}
+	return m.units[i], nil
+}
+
+// archiveUnit archives m.units[i] at the given time and expires its
+// pending invitations
+func (m *Memory) archiveUnit(i int, at time.Time) {
+	m.units[i].ArchivedAt, m.units[i].UpdatedAt = &at, at
+	for j, inv := range m.invitations {
+		if inv.UnitID == m.units[i].ID && inv.Status == "pending" {
+			m.invitations[j].Status = "expired"
+		}
+	}
+}
+
+func (m *Memory) unit(id string) (models.Unit, bool) {
+	i := find(m.units, func(u models.Unit) bool { return u.ID == id })
+	if i < 0 {
+		return models.Unit{}, false
+	}
+	return m.units[i], true
+}
+
+// Tenancies
+
+// PutTenancy adds a tenancy as the database backfill would, making an
+// active one its unit's tenant
+func (m *Memory) PutTenancy(t models.Tenancy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&t.ID, &t.CreatedAt)
+	t.UpdatedAt = t.CreatedAt
+	m.tenancies = append(m.tenancies, t)
+	if u := find(m.units, func(u models.Unit) bool { return u.ID == t.UnitID }); u >= 0 && t.Status == "active" {
+		m.units[u].TenantID, m.units[u].Status = &t.TenantID, "occupied"
+	}
+}
+
+func (m *Memory) ListTenancies(unitID string) ([]TenancyListing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []TenancyListing{}
+	for _, t := range m.tenancies {
+		if t.UnitID != unitID {
+			continue
+		}
+		listing := TenancyListing{Tenancy: t}
+		if p, ok := m.profile(t.TenantID); ok {
+			listing.Tenant = &TenantSummary{FullName: p.FullName, Email: p.Email, Phone: p.Phone, AvatarThumbURL: p.AvatarThumbURL}
+		}
+		out = append(out, listing)
+	}
+	slices.SortStableFunc(out, func(a, b TenancyListing) int { return newestTenancy(a.Tenancy, b.Tenancy) })
+	return out, nil
+}
+
+func (m *Memory) GetTenancy(id string) (models.Tenancy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.tenancies, func(t models.Tenancy) bool { return t.ID == id })
+	if i < 0 {
+		return models.Tenancy{}, ErrNotFound
+	}
+	return m.tenancies[i], nil
+}
+
+func (m *Memory) ActiveTenancy(unitID string) (models.Tenancy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.tenancies, func(t models.Tenancy) bool { return t.UnitID == unitID && t.Status == "active" })
+	if i < 0 {
+		return models.Tenancy{}, ErrNotFound
+	}
+	return m.tenancies[i], nil
+}
+
+func (m *Memory) TenanciesForTenant(tenantID string) ([]models.Tenancy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Tenancy{}
+	for _, t := range m.tenancies {
+		if t.TenantID == tenantID {
+			out = append(out, t)
+		}
+	}
+	slices.SortStableFunc(out, newestTenancy)
+	return out, nil
+}
+
+func (m *Memory) UpdateTenancy(id string, fields map[string]interface{}) (models.Tenancy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.tenancies, func(t models.Tenancy) bool { return t.ID == id })
+	if i < 0 {
+		return models.Tenancy{}, ErrNotFound
+	}
+	t, err := patch(m.tenancies[i], fields)
+	if err != nil {
+		return models.Tenancy{}, err
+	}
+	t.UpdatedAt = time.Now().UTC()
+	m.tenancies[i] = t
+	return t, nil
+}
+
+func (m *Memory) EndTenancy(id, endDate string) (models.Tenancy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.tenancies, func(t models.Tenancy) bool { return t.ID == id })
+	if i < 0 {
+		return models.Tenancy{}, ErrNotFound
+	}
+	t := &m.tenancies[i]
+	if t.Status != "active" {
+		return models.Tenancy{}, ErrTenancyEnded
+	}
+	now := time.Now().UTC()
+	t.Status, t.EndDate, t.EndedAt, t.UpdatedAt = "ended", &endDate, &now, now
+	if u := find(m.units, func(u models.Unit) bool { return u.ID == t.UnitID }); u >= 0 {
+		m.units[u].TenantID, m.units[u].Status, m.units[u].UpdatedAt = nil, "vacant", now
+	}
+	return *t, nil
+}
+
+// newestTenancy orders tenancies by start date, latest first
+func newestTenancy(a, b models.Tenancy) int {
+	return cmp.Or(strings.Compare(b.StartDate, a.StartDate), b.CreatedAt.Compare(a.CreatedAt))
+}
+
+// Payments
+
+func (m *Memory) ListPayments(f PaymentFilter, opts ListOptions) (Page[PaymentListing], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []PaymentListing{}
+	for _, p := range m.payments {
+		if (f.TenantID != "" && p.TenantID != f.TenantID) ||
+			(f.BuildingIDs != nil && !slices.Contains(f.BuildingIDs, p.BuildingID)) {
+			continue
+		}
+		listing := PaymentListing{Payment: p}
+		if t, ok := m.profile(p.TenantID); ok {
+			listing.Tenant = &PersonRef{FullName: t.FullName}
+		}
+		if b, ok := m.building(p.BuildingID); ok {
+			listing.Building = &BuildingRef{Name: b.Name}
+		}
+		if u, ok := m.unit(p.UnitID); ok {
+			listing.Unit = &UnitRef{UnitNumber: u.UnitNumber}
+		}
+		out = append(out, listing)
+	}
+	return paginate(out, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (m *Memory) GetPayment(id string) (models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.payments, func(p models.Payment) bool { return p.ID == id })
+	if i < 0 {
+		return models.Payment{}, ErrNotFound
+	}
+	return m.payments[i], nil
+}
+
+func (m *Memory) FindPaymentByReference(reference string) (models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.payments, func(p models.Payment) bool {
+		return p.PaystackReference != nil && *p.PaystackReference == reference
+	})
+	if i < 0 {
+		return models.Payment{}, ErrNotFound
+	}
+	return m.payments[i], nil
+}
+
+func (m *Memory) CreatePayment(p models.Payment) (models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&p.ID, &p.CreatedAt)
+	nullify(&p.PaymentMethod, &p.PaystackReference, &p.RecordedBy, &p.TenancyID)
+	m.payments = append(m.payments, p)
+	return p, nil
+}
+
+func (m *Memory) UpdateUnsettledPayment(id string, fields map[string]interface{}) (models.Payment, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.payments, func(p models.Payment) bool { return p.ID == id })
+	if i < 0 || m.payments[i].Status == "successful" {
+		return models.Payment{}, false, nil
+	}
+	p, err := patch(m.payments[i], fields)
+	if err != nil {
+		return models.Payment{}, false, err
+	}
+	m.payments[i] = p
+	return p, true, nil
+}
+
+func (m *Memory) RecordWebhookEvent(e models.WebhookEvent) (models.WebhookEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&e.ID, &e.ReceivedAt)
+	m.webhookEvents = append(m.webhookEvents, e)
+	return e, nil
+}
+
+func (m *Memory) UpdateWebhookEvent(id string, fields map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.webhookEvents, func(e models.WebhookEvent) bool { return e.ID == id })
+	if i < 0 {
+		return nil
+	}
+	e, err := patch(m.webhookEvents[i], fields)
+	if err != nil {
+		return err
+	}
+	m.webhookEvents[i] = e
+	return nil
+}
+
+// Invitations
+
+func (m *Memory) ListInvitations(buildingIDs []string, opts ListOptions) (Page[InvitationListing], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []InvitationListing{}
+	for _, inv := range m.invitations {
+		u, ok := m.unit(inv.UnitID)
+		if !ok || !slices.Contains(buildingIDs, u.BuildingID) {
+			continue
+		}
+		out = append(out, InvitationListing{Invitation: inv, Unit: &UnitRef{UnitNumber: u.UnitNumber, BuildingID: u.BuildingID}})
+	}
+	return paginate(out, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (m *Memory) GetPendingInvitation(token string) (models.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.invitations, func(inv models.Invitation) bool { return inv.Token == token && inv.Status == "pending" })
+	if i < 0 {
+		return models.Invitation{}, ErrNotFound
+	}
+	return m.invitations[i], nil
+}
+
+func (m *Memory) GetInvitationDetails(token string) (InvitationDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.invitations, func(inv models.Invitation) bool { return inv.Token == token && inv.Status == "pending" })
+	if i < 0 {
+		return InvitationDetails{}, ErrNotFound
+	}
+	return m.invitationDetails(m.invitations[i]), nil
+}
+
+func (m *Memory) GetInvitation(id string) (InvitationDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.invitations, func(inv models.Invitation) bool { return inv.ID == id })
+	if i < 0 {
+		return InvitationDetails{}, ErrNotFound
+	}
+	return m.invitationDetails(m.invitations[i]), nil
+}
+
+func (m *Memory) invitationDetails(inv models.Invitation) InvitationDetails {
+	details := InvitationDetails{Invitation: inv}
+	if u, ok := m.unit(details.UnitID); ok {
+		details.Unit = &UnitRef{UnitNumber: u.UnitNumber, RentAmount: u.RentAmount}
+		if b, ok := m.building(u.BuildingID); ok {
+			details.Buildings = &struct {
+				Building *BuildingRef `json:"buildings"`
+			}{&BuildingRef{Name: b.Name, Address: b.Address, PhotoURL: b.PhotoURL}}
+		}
+	}
+	return details
+}
+
+func (m *Memory) CreateInvitation(inv models.Invitation) (models.Invitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&inv.ID, &inv.CreatedAt)
+	nullify(&inv.InvitedBy, &inv.Email, &inv.Phone)
+	if inv.ExpiresAt.IsZero() {
+		inv.ExpiresAt = inv.CreatedAt.Add(7 * 24 * time.Hour)
+	}
+	m.invitations = append(m.invitations, inv)
+	return inv, nil
+}
+
+func (m *Memory) AcceptInvitation(a Acceptance) (Accepted, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	inv := find(m.invitations, func(inv models.Invitation) bool { return inv.Token == a.Token })
+	if inv < 0 || m.invitations[inv].Status != "pending" || (!m.invitations[inv].ExpiresAt.IsZero() && m.invitations[inv].ExpiresAt.Before(now)) {
+		return Accepted{}, ErrInvitationUnavailable
+	}
+	unitID := m.invitations[inv].UnitID
+	u := find(m.units, func(u models.Unit) bool { return u.ID == unitID })
+	if u < 0 {
+		return Accepted{}, ErrNotFound
+	}
+	if t := m.units[u].TenantID; t != nil && *t != a.TenantID {
+		return Accepted{}, ErrUnitOccupied
+	}
+
+	var roles []string
+	if p := a.NewProfile; p != nil {
+		profile := *p
+		profile.ID, profile.Role, profile.Roles = a.TenantID, "tenant", []string{"tenant"}
+		profile.CreatedAt, profile.UpdatedAt = now, now
+		m.profiles = append(m.profiles, profile)
+		roles = profile.Roles
+	} else {
+		i := m.profileIndex(a.TenantID)
+		if i < 0 {
+			return Accepted{}, ErrNotFound
+		}
+		roles = m.profiles[i].Roles
+		if len(roles) == 0 {
+			roles = []string{m.profiles[i].Role}
+		}
+		if !slices.Contains(roles, "tenant") {
+			roles = append(slices.Clone(roles), "tenant")
+		}
+		m.profiles[i].Roles = roles
+	}
+
+	tenancy := find(m.tenancies, func(t models.Tenancy) bool { return t.UnitID == unitID && t.Status == "active" })
+	if tenancy < 0 {
+		unit := m.units[u]
+		t := models.Tenancy{
+			ID:           uuid.NewString(),
+			UnitID:       unitID,
+			BuildingID:   unit.BuildingID,
+			TenantID:     a.TenantID,
+			InvitationID: &m.invitations[inv].ID,
+			RentAmount:   unit.RentAmount,
+			StartDate:    now.Format("2006-01-02"),
+			EndDate:      unit.LeaseEnd,
+			Status:       "active",
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if unit.LeaseStart != nil {
+			t.StartDate = *unit.LeaseStart
+		}
+		if t.EndDate != nil && *t.EndDate < t.StartDate {
+			t.EndDate = nil
+		}
+		m.tenancies = append(m.tenancies, t)
+		tenancy = len(m.tenancies) - 1
+	}
+	tenancyID := m.tenancies[tenancy].ID
+
+	m.units[u].TenantID = &a.TenantID
+	m.units[u].Status = "occupied"
+	m.units[u].UpdatedAt = now
+	m.invitations[inv].Status = "accepted"
+	// Other invitations to the now occupied unit can no longer be taken up
+	for i := range m.invitations {
+		if m.invitations[i].UnitID == unitID && m.invitations[i].Status == "pending" {
+			m.invitations[i].Status = "expired"
+		}
+	}
+	for i := range m.payments {
+		if m.payments[i].UnitID == unitID && m.payments[i].TenantID == "" {
+			m.payments[i].TenantID, m.payments[i].TenancyID = a.TenantID, &tenancyID
+		}
+	}
+	return Accepted{UnitID: unitID, BuildingID: m.units[u].BuildingID, TenancyID: tenancyID, Roles: slices.Clone(roles)}, nil
+}
+
+func (m *Memory) EraseInvitationContacts(e ContactErasure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, inv := range m.invitations {
+		if e.Email != "" && inv.Email != nil && strings.EqualFold(*inv.Email, e.Email) {
+			m.invitations[i].Email, m.invitations[i].Phone = &e.Pseudonym, nil
+		} else if e.Phone != "" && inv.Phone != nil && *inv.Phone == e.Phone {
+			m.invitations[i].Phone = nil
+		}
+	}
+	return nil
+}
+
+// Maintenance
+
+func (m *Memory) ListMaintenanceRequests(f MaintenanceFilter, opts ListOptions) (Page[MaintenanceListing], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []MaintenanceListing{}
+	for _, req := range m.maintenance {
+		if (f.TenantID != "" && req.TenantID != f.TenantID) ||
+			(f.BuildingIDs != nil && !slices.Contains(f.BuildingIDs, req.BuildingID)) {
+			continue
+		}
+		listing := MaintenanceListing{MaintenanceRequest: req}
+		if t, ok := m.profile(req.TenantID); ok {
+			listing.Tenant = &PersonRef{FullName: t.FullName, AvatarThumbURL: t.AvatarThumbURL}
+		}
+		if u, ok := m.unit(req.UnitID); ok {
+			listing.Unit = &UnitRef{UnitNumber: u.UnitNumber}
+		}
+		if b, ok := m.building(req.BuildingID); ok {
+			listing.Building = &BuildingRef{Name: b.Name}
+		}
+		out = append(out, listing)
+	}
+	return paginate(out, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (m *Memory) GetMaintenanceRequest(id string) (models.MaintenanceRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.maintenance, func(r models.MaintenanceRequest) bool { return r.ID == id })
+	if i < 0 {
+		return models.MaintenanceRequest{}, ErrNotFound
+	}
+	return m.maintenance[i], nil
+}
+
+func (m *Memory) CreateMaintenanceRequest(req models.MaintenanceRequest) (models.MaintenanceRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&req.ID, &req.CreatedAt)
+	nullify(&req.TenancyID)
+	req.UpdatedAt = req.CreatedAt
+	m.maintenance = append(m.maintenance, req)
+	return req, nil
+}
+
+func (m *Memory) UpdateMaintenanceRequest(id string, fields map[string]interface{}) (models.MaintenanceRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.maintenance, func(r models.MaintenanceRequest) bool { return r.ID == id })
+	if i < 0 {
+		return models.MaintenanceRequest{}, ErrNotFound
+	}
+	req, err := patch(m.maintenance[i], fields)
+	if err != nil {
+		return models.MaintenanceRequest{}, err
+	}
+	req.UpdatedAt = time.Now().UTC()
+	m.maintenance[i] = req
+	return req, nil
+}
+
+// Documents
+
+func (m *Memory) ListDocuments(f DocumentFilter, opts ListOptions) (Page[models.Document], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Document{}
+	for _, d := range m.documents {
+		if d.UploadedBy == f.UploadedBy ||
+			(d.TenancyID != nil && slices.Contains(f.TenancyIDs, *d.TenancyID)) ||
+			(d.BuildingID != nil && slices.Contains(f.BuildingIDs, *d.BuildingID)) {
+			out = append(out, d)
+		}
+	}
+	return paginate(out, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (m *Memory) CreateDocument(d models.Document) (models.Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&d.ID, &d.CreatedAt)
+	nullify(&d.BuildingID, &d.UnitID, &d.TenancyID)
+	m.documents = append(m.documents, d)
+	return d, nil
+}
+
+// Profiles
+
+func (m *Memory) GetProfile(id string) (models.Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.profile(id)
+	if !ok {
+		return models.Profile{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (m *Memory) ListProfiles(ids []string) ([]models.Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Profile{}
+	for _, p := range m.profiles {
+		if slices.Contains(ids, p.ID) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// FindProfileByEmail ignores case, as the email blind index does
+func (m *Memory) FindProfileByEmail(email string) (models.Profile, error) {
+	return m.findProfile(func(p models.Profile) bool {
+		return p.Email != "" && strings.EqualFold(p.Email, strings.TrimSpace(email))
+	})
+}
+
+func (m *Memory) FindProfileByPhone(phone string) (models.Profile, error) {
+	return m.findProfile(func(p models.Profile) bool { return p.Phone != nil && *p.Phone == phone })
+}
+
+func (m *Memory) findProfile(match func(models.Profile) bool) (models.Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.profiles, match)
+	if i < 0 {
+		return models.Profile{}, ErrNotFound
+	}
+	return m.profiles[i], nil
+}
+
+func (m *Memory) CreateProfile(p models.Profile) (models.Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.profileIndex(p.ID) >= 0 {
+		return models.Profile{}, fmt.Errorf("store: profile %s already exists", p.ID)
+	}
+	stamp(&p.ID, &p.CreatedAt)
+	p.UpdatedAt = p.CreatedAt
+	nullify(&p.Phone)
+	m.profiles = append(m.profiles, p)
+	return p, nil
+}
+
+func (m *Memory) UpdateProfile(id string, fields map[string]interface{}) (models.Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.profileIndex(id)
+	if i < 0 {
+		return models.Profile{}, ErrNotFound
+	}
+	p, err := patch(m.profiles[i], fields)
+	if err != nil {
+		return models.Profile{}, err
+	}
+	p.UpdatedAt = time.Now().UTC()
+	m.profiles[i] = p
+	return p, nil
+}
+
+func (m *Memory) MarkEmailVerified(id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.profileIndex(id); i >= 0 && m.profiles[i].EmailVerifiedAt == nil {
+		m.profiles[i].EmailVerifiedAt = &at
+	}
+	return nil
+}
+
+func (m *Memory) UpdateRoles(id string, roles []string, defaultRole string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.profileIndex(id)
+	if i < 0 {
+		return nil
+	}
+	m.profiles[i].Roles = slices.Clone(roles)
+	if defaultRole != "" {
+		m.profiles[i].Role = defaultRole
+	}
+	return nil
+}
+
+func (m *Memory) profile(id string) (models.Profile, bool) {
+	i := m.profileIndex(id)
+	if i < 0 {
+		return models.Profile{}, false
+	}
+	return m.profiles[i], true
+}
+
+func (m *Memory) profileIndex(id string) int {
+	return find(m.profiles, func(p models.Profile) bool { return p.ID == id })
+}
+
+// Organisations
+
+func (m *Memory) OrganisationRole(orgID, userID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, om := range m.orgMembers {
+		if om.scopeID == orgID && om.UserID != nil && *om.UserID == userID && om.Status == "active" {
+			return om.Role, nil
+		}
+	}
+	return "", nil
+}
+
+func (m *Memory) OrganisationRoles(userID string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return activeRoles(m.orgMembers, userID), nil
+}
+
+func (m *Memory) GetOrganisation(id string) (models.Organisation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.orgs, func(o models.Organisation) bool { return o.ID == id })
+	if i < 0 {
+		return models.Organisation{}, ErrNotFound
+	}
+	return m.orgs[i], nil
+}
+
+func (m *Memory) ListOrganisations(ids []string) ([]models.Organisation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Organisation{}
+	for _, o := range m.orgs {
+		if slices.Contains(ids, o.ID) {
+			out = append(out, o)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.Organisation) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (m *Memory) CreateOrganisation(o models.Organisation) (models.Organisation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&o.ID, &o.CreatedAt)
+	m.orgs = append(m.orgs, o)
+	creator := o.CreatedBy
+	m.orgMembers = append(m.orgMembers, member{ID: uuid.NewString(), scopeID: o.ID, UserID: &creator, Role: "admin", Status: "active",
+		InvitedBy: creator, CreatedAt: o.CreatedAt, ExpiresAt: o.CreatedAt, AcceptedAt: &o.CreatedAt})
+	return o, nil
+}
+
+func (m *Memory) OrganisationBuildings(orgID, landlordID string) ([]models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Building{}
+	for _, b := range m.buildings {
+		if b.OrganisationID != nil && *b.OrganisationID == orgID && (landlordID == "" || b.LandlordID == landlordID) {
+			out = append(out, b)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.Building) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (m *Memory) ListOrganisationMembers(orgID string) ([]OrganisationMemberListing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []OrganisationMemberListing{}
+	for _, mb := range m.liveMembers(m.orgMembers, orgID) {
+		out = append(out, OrganisationMemberListing{OrganisationMember: mb.organisation(), Profile: m.memberProfile(mb)})
+	}
+	return out, nil
+}
+
+func (m *Memory) OrganisationMemberExists(orgID, email, phone string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memberExists(m.orgMembers, orgID, email, phone), nil
+}
+
+func (m *Memory) CreateOrganisationInvite(om models.OrganisationMember, tokenHash string) (models.OrganisationMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb := member{ID: om.ID, scopeID: om.OrganisationID, Role: om.Role, Status: om.Status, Email: om.Email, Phone: om.Phone,
+		InvitedBy: om.InvitedBy, CreatedAt: om.CreatedAt, ExpiresAt: om.ExpiresAt, tokenHash: tokenHash}
+	m.orgMembers = addInvite(m.orgMembers, &mb)
+	return mb.organisation(), nil
+}
+
+func (m *Memory) FindOrganisationInvite(tokenHash string) (models.OrganisationMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, err := findInvite(m.orgMembers, tokenHash)
+	return mb.organisation(), err
+}
+
+func (m *Memory) AcceptOrganisationInvite(id, userID string) (models.OrganisationMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, err := acceptInvite(m.orgMembers, id, userID)
+	return mb.organisation(), err
+}
+
+func (m *Memory) RevokeOrganisationMember(orgID, id, exceptUserID string) (models.OrganisationMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, err := revokeMember(m.orgMembers, orgID, id, exceptUserID)
+	return mb.organisation(), err
+}
+
+func (m *Memory) RevokeOrganisationAccess(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revokeAccess(m.orgMembers, userID)
+	return nil
+}
+
+func (m *Memory) EraseOrganisationContacts(e ContactErasure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	eraseContacts(m.orgMembers, e)
+	return nil
+}
+
+// Staff
+
+func (m *Memory) ListStaff(buildingID string) ([]StaffListing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []StaffListing{}
+	for _, mb := range m.liveMembers(m.staff, buildingID) {
+		out = append(out, StaffListing{BuildingMember: mb.building(), Profile: m.memberProfile(mb)})
+	}
+	return out, nil
+}
+
+func (m *Memory) StaffMemberExists(buildingID, email, phone string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return memberExists(m.staff, buildingID, email, phone), nil
+}
+
+func (m *Memory) CreateStaffInvite(bm models.BuildingMember, tokenHash string) (models.BuildingMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb := member{ID: bm.ID, scopeID: bm.BuildingID, Role: bm.Role, Status: bm.Status, Email: bm.Email, Phone: bm.Phone,
+		InvitedBy: bm.InvitedBy, CreatedAt: bm.CreatedAt, ExpiresAt: bm.ExpiresAt, tokenHash: tokenHash}
+	m.staff = addInvite(m.staff, &mb)
+	return mb.building(), nil
+}
+
+func (m *Memory) FindStaffInvite(tokenHash string) (models.BuildingMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, err := findInvite(m.staff, tokenHash)
+	return mb.building(), err
+}
+
+func (m *Memory) AcceptStaffInvite(id, userID string) (models.BuildingMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, err := acceptInvite(m.staff, id, userID)
+	return mb.building(), err
+}
+
+func (m *Memory) RevokeStaff(buildingID, id string) (models.BuildingMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, err := revokeMember(m.staff, buildingID, id, "")
+	return mb.building(), err
+}
+
+func (m *Memory) StaffRoles(userID string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return activeRoles(m.staff, userID), nil
+}
+
+func (m *Memory) RevokeStaffAccess(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revokeAccess(m.staff, userID)
+	return nil
+}
+
+func (m *Memory) EraseStaffContacts(e ContactErasure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	eraseContacts(m.staff, e)
+	return nil
+}
+
+// Members of buildings and organisations are kept alike
+
+// liveMembers returns the invited and active members of a building or
+// organisation, oldest first
+func (m *Memory) liveMembers(rows []member, scopeID string) []member {
+	out := []member{}
+	for _, mb := range rows {
+		if mb.scopeID == scopeID && mb.Status != "revoked" {
+			out = append(out, mb)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b member) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out
+}
+
+func (m *Memory) memberProfile(mb member) *TenantSummary {
+	if mb.UserID == nil {
+		return nil
+	}
+	p, ok := m.profile(*mb.UserID)
+	if !ok {
+		return nil
+	}
+	return &TenantSummary{FullName: p.FullName, Email: p.Email, Phone: p.Phone, AvatarThumbURL: p.AvatarThumbURL}
+}
+
+func activeRoles(rows []member, userID string) map[string]string {
+	roles := map[string]string{}
+	for _, mb := range rows {
+		if mb.UserID != nil && *mb.UserID == userID && mb.Status == "active" {
+			roles[mb.scopeID] = mb.Role
+		}
+	}
+	return roles
+}
+
+func memberExists(rows []member, scopeID, email, phone string) bool {
+	return slices.ContainsFunc(rows, func(mb member) bool {
+		if mb.scopeID != scopeID || mb.Status == "revoked" {
+			return false
+		}
+		if email != "" {
+			return mb.Email != nil && strings.EqualFold(*mb.Email, email)
+		}
+		return mb.Phone != nil && *mb.Phone == phone
+	})
+}
+
+func addInvite(rows []member, mb *member) []member {
+	stamp(&mb.ID, &mb.CreatedAt)
+	nullify(&mb.Email, &mb.Phone)
+	if mb.Status == "" {
+		mb.Status = "invited"
+	}
+	if mb.ExpiresAt.IsZero() {
+		mb.ExpiresAt = mb.CreatedAt.Add(7 * 24 * time.Hour)
+	}
+	return append(rows, *mb)
+}
+
+func findInvite(rows []member, tokenHash string) (member, error) {
+	i := find(rows, func(mb member) bool { return mb.tokenHash == tokenHash && mb.Status == "invited" })
+	if i < 0 {
+		return member{}, ErrNotFound
+	}
+	return rows[i], nil
+}
+
+func acceptInvite(rows []member, id, userID string) (member, error) {
+	i := find(rows, func(mb member) bool { return mb.ID == id && mb.Status == "invited" })
+	if i < 0 {
+		return member{}, ErrNotFound
+	}
+	now := time.Now().UTC()
+	rows[i].UserID, rows[i].Status, rows[i].AcceptedAt, rows[i].tokenHash = &userID, "active", &now, ""
+	return rows[i], nil
+}
+
+func revokeMember(rows []member, scopeID, id, exceptUserID string) (member, error) {
+	i := find(rows, func(mb member) bool {
+		return mb.ID == id && mb.scopeID == scopeID && mb.Status != "revoked" &&
+			(exceptUserID == "" || mb.UserID == nil || *mb.UserID != exceptUserID)
+	})
+	if i < 0 {
+		return member{}, ErrNotFound
+	}
+	now := time.Now().UTC()
+	rows[i].Status, rows[i].RevokedAt, rows[i].tokenHash = "revoked", &now, ""
+	return rows[i], nil
+}
+
+func revokeAccess(rows []member, userID string) {
+	now := time.Now().UTC()
+	for i, mb := range rows {
+		if mb.UserID != nil && *mb.UserID == userID && mb.Status != "revoked" {
+			rows[i].Status, rows[i].RevokedAt = "revoked", &now
+		}
+	}
+}
+
+func eraseContacts(rows []member, e ContactErasure) {
+	for i, mb := range rows {
+		if (mb.UserID != nil && *mb.UserID == e.UserID) || (e.Email != "" && mb.Email != nil && strings.EqualFold(*mb.Email, e.Email)) {
+			rows[i].Email, rows[i].Phone = &e.Pseudonym, nil
+		} else if e.Phone != "" && mb.Phone != nil && *mb.Phone == e.Phone {
+			rows[i].Phone = nil
+		}
+	}
+}
+
+// Owners
+
+func (m *Memory) ListOwners(buildingID string) ([]models.BuildingOwner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.BuildingOwner{}
+	for _, o := range m.owners {
+		if o.BuildingID == buildingID {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) ReplaceOwners(buildingID string, owners []models.BuildingOwner) ([]models.BuildingOwner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.owners = slices.DeleteFunc(m.owners, func(o models.BuildingOwner) bool { return o.BuildingID == buildingID })
+	saved := make([]models.BuildingOwner, len(owners))
+	for i, o := range owners {
+		o.ID, o.CreatedAt, o.BuildingID = "", time.Time{}, buildingID
+		stamp(&o.ID, &o.CreatedAt)
+		saved[i] = o
+	}
+	m.owners = append(m.owners, saved...)
+	return saved, nil
+}
+
+func (m *Memory) ListOwnerships(userID string) ([]models.BuildingOwner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.BuildingOwner{}
+	for _, o := range m.owners {
+		if o.UserID == userID {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+// API keys
+
+func (m *Memory) CreateAPIKey(k models.APIKey, keyHash string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&k.ID, &k.CreatedAt)
+	m.apiKeys = append(m.apiKeys, apiKey{APIKey: k, hash: keyHash})
+	return k, nil
+}
+
+func (m *Memory) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.APIKey{}
+	for _, k := range slices.Backward(m.apiKeys) {
+		if k.UserID == userID {
+			out = append(out, k.APIKey)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.APIKey) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out, nil
+}
+
+func (m *Memory) RevokeAPIKey(id, userID string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.apiKeys, func(k apiKey) bool { return k.ID == id && k.UserID == userID && k.RevokedAt == nil })
+	if i < 0 {
+		return models.APIKey{}, ErrNotFound
+	}
+	now := time.Now().UTC()
+	m.apiKeys[i].RevokedAt = &now
+	return m.apiKeys[i].APIKey, nil
+}
+
+func (m *Memory) FindAPIKey(keyHash string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.apiKeys, func(k apiKey) bool { return k.hash == keyHash && k.RevokedAt == nil })
+	if i < 0 {
+		return models.APIKey{}, ErrNotFound
+	}
+	return m.apiKeys[i].APIKey, nil
+}
+
+func (m *Memory) TouchAPIKey(id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := find(m.apiKeys, func(k apiKey) bool { return k.ID == id }); i >= 0 {
+		m.apiKeys[i].LastUsedAt = &at
+	}
+	return nil
+}
+
+func (m *Memory) RevokeAPIKeys(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	for i, k := range m.apiKeys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			m.apiKeys[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// Audit log
+
+func (m *Memory) ListAuditEntries(f AuditFilter, offset, limit int) ([]models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.AuditEntry{}
+	for _, e := range slices.Backward(m.auditLog) {
+		if f.BuildingIDs != nil && (e.BuildingID == nil || !slices.Contains(f.BuildingIDs, *e.BuildingID)) {
+			continue
+		}
+		if (!f.From.IsZero() && e.CreatedAt.Before(f.From)) || (!f.To.IsZero() && !e.CreatedAt.Before(f.To)) {
+			continue
+		}
+		raw, _ := json.Marshal(e)
+		var fields map[string]json.RawMessage
+		json.Unmarshal(raw, &fields)
+		filters := make([]Filter, 0, len(f.Equal))
+		for col, v := range f.Equal {
+			filters = append(filters, Filter{Column: col, Op: OpEq, Value: v})
+		}
+		if matches(fields, filters) {
+			out = append(out, e)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.AuditEntry) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	out = out[min(offset, len(out)):]
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *Memory) RecordAuditEntry(e models.AuditEntry) error {
+	m.PutAuditEntry(e)
+	return nil
+}
+
+// Notifications
+
+func (m *Memory) RecordNotification(n models.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n.ID = uuid.NewString()
+	m.notifications = append(m.notifications, n)
+	return nil
+}
+
+// SMS codes
+
+func (m *Memory) RecentOTPs(phone string, since time.Time) ([]time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sent []time.Time
+	for _, o := range m.otps {
+		if o.Phone == phone && !o.CreatedAt.Before(since) {
+			sent = append(sent, o.CreatedAt)
+		}
+	}
+	slices.SortFunc(sent, func(a, b time.Time) int { return b.Compare(a) })
+	return sent, nil
+}
+
+func (m *Memory) CreateOTP(o models.PhoneOTP) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	for i := range m.otps {
+		if m.otps[i].Phone == o.Phone && m.otps[i].ConsumedAt == nil {
+			m.otps[i].ConsumedAt = &now
+		}
+	}
+	stamp(&o.ID, &o.CreatedAt)
+	m.otps = append(m.otps, o)
+	return nil
+}
+
+func (m *Memory) LatestOTP(phone string) (models.PhoneOTP, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range slices.Backward(m.otps) {
+		if o.Phone == phone && o.ConsumedAt == nil {
+			return o, nil
+		}
+	}
+	return models.PhoneOTP{}, ErrNotFound
+}
+
+func (m *Memory) TakeOTPAttempt(id string, max int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.otps, func(o models.PhoneOTP) bool {
+		return o.ID == id && o.ConsumedAt == nil && time.Now().Before(o.ExpiresAt) && o.Attempts < max
+	})
+	if i < 0 {
+		return false, nil
+	}
+	m.otps[i].Attempts++
+	return true, nil
+}
+
+func (m *Memory) ConsumeOTP(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.otps, func(o models.PhoneOTP) bool { return o.ID == id && o.ConsumedAt == nil })
+	if i < 0 {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	m.otps[i].ConsumedAt = &now
+	return true, nil
+}
+
+func (m *Memory) DeleteOTPs(phone string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.otps = slices.DeleteFunc(m.otps, func(o models.PhoneOTP) bool { return o.Phone == phone })
+	return nil
+}
+
+// Auth tokens
+
+func (m *Memory) CreateAuthToken(t models.AuthToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	for i, other := range m.authTokens {
+		if other.UserID == t.UserID && other.Purpose == t.Purpose && other.UsedAt == nil {
+			m.authTokens[i].UsedAt = &now
+		}
+	}
+	stamp(&t.ID, &t.CreatedAt)
+	m.authTokens = append(m.authTokens, t)
+	return nil
+}
+
+func (m *Memory) FindAuthToken(tokenHash, purpose string) (models.AuthToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.authTokens, func(t models.AuthToken) bool { return t.TokenHash == tokenHash && t.Purpose == purpose })
+	if i < 0 {
+		return models.AuthToken{}, ErrNotFound
+	}
+	return m.authTokens[i], nil
+}
+
+func (m *Memory) UseAuthToken(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.authTokens, func(t models.AuthToken) bool { return t.ID == id && t.UsedAt == nil })
+	if i < 0 {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	m.authTokens[i].UsedAt = &now
+	return true, nil
+}
+
+func (m *Memory) TakeAuthTokenAttempt(id string, max int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.authTokens, func(t models.AuthToken) bool {
+		return t.ID == id && t.UsedAt == nil && time.Now().Before(t.ExpiresAt) && t.Attempts < max
+	})
+	if i < 0 {
+		return false, nil
+	}
+	m.authTokens[i].Attempts++
+	return true, nil
+}
+
+func (m *Memory) DeleteAuthTokens(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.authTokens = slices.DeleteFunc(m.authTokens, func(t models.AuthToken) bool { return t.UserID == userID })
+	return nil
+}
+
+// Login lockouts
+
+func (m *Memory) GetLoginLockout(email string) (models.LoginLockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.lockouts, func(l models.LoginLockout) bool { return l.Email == email })
+	if i < 0 {
+		return models.LoginLockout{}, ErrNotFound
+	}
+	return m.lockouts[i], nil
+}
+
+func (m *Memory) RecordLoginFailure(email string, policy LockoutPolicy) (models.LoginLockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	i := find(m.lockouts, func(l models.LoginLockout) bool { return l.Email == email })
+	if i < 0 {
+		m.lockouts = append(m.lockouts, models.LoginLockout{Email: email})
+		i = len(m.lockouts) - 1
+	}
+	l := &m.lockouts[i]
+	if now.Sub(l.LastFailedAt) < policy.Window {
+		l.FailedCount++
+	} else {
+		l.FailedCount = 1
+	}
+	l.LastFailedAt = now
+	if wait := policy.lockFor(l.FailedCount); wait > 0 {
+		until := now.Add(wait)
+		l.LockedUntil = &until
+	}
+	return *l, nil
+}
+
+func (m *Memory) ClearLoginLockout(email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lockouts = slices.DeleteFunc(m.lockouts, func(l models.LoginLockout) bool { return l.Email == email })
+	return nil
+}
+
+// Two-factor
+
+func (m *Memory) GetMFA(userID string) (models.UserMFA, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.mfaIndex(userID)
+	if i < 0 {
+		return models.UserMFA{}, ErrNotFound
+	}
+	return m.mfa[i], nil
+}
+
+func (m *Memory) PutMFA(mfa models.UserMFA) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mfa.CreatedAt.IsZero() {
+		mfa.CreatedAt = time.Now().UTC()
+	}
+	if i := m.mfaIndex(mfa.UserID); i >= 0 {
+		m.mfa[i] = mfa
+		return nil
+	}
+	m.mfa = append(m.mfa, mfa)
+	return nil
+}
+
+func (m *Memory) UpdateMFA(userID string, fields map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.mfaIndex(userID)
+	if i < 0 {
+		return nil
+	}
+	mfa, err := patch(m.mfa[i], fields)
+	if err != nil {
+		return err
+	}
+	m.mfa[i] = mfa
+	return nil
+}
+
+func (m *Memory) AdvanceMFAStep(userID string, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.mfaIndex(userID)
+	if i < 0 || m.mfa[i].LastUsedStep >= step {
+		return false, nil
+	}
+	m.mfa[i].LastUsedStep = step
+	return true, nil
+}
+
+func (m *Memory) DeleteMFA(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mfa = slices.DeleteFunc(m.mfa, func(mfa models.UserMFA) bool { return mfa.UserID == userID })
+	m.recoveryCodes = slices.DeleteFunc(m.recoveryCodes, func(c recoveryCode) bool { return c.userID == userID })
+	return nil
+}
+
+func (m *Memory) CountRecoveryCodes(userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, c := range m.recoveryCodes {
+		if c.userID == userID && !c.used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Memory) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recoveryCodes = slices.DeleteFunc(m.recoveryCodes, func(c recoveryCode) bool { return c.userID == userID })
+	for _, h := range codeHashes {
+		m.recoveryCodes = append(m.recoveryCodes, recoveryCode{userID: userID, hash: h})
+	}
+	return nil
+}
+
+func (m *Memory) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.recoveryCodes, func(c recoveryCode) bool { return c.userID == userID && c.hash == codeHash && !c.used })
+	if i < 0 {
+		return false, nil
+	}
+	m.recoveryCodes[i].used = true
+	return true, nil
+}
+
+func (m *Memory) mfaIndex(userID string) int {
+	return find(m.mfa, func(mfa models.UserMFA) bool { return mfa.UserID == userID })
+}
+
+// Admin console
+
+func (m *Memory) FindProfilesByName(name string, limit int) ([]models.Profile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Profile{}
+	for _, p := range m.profiles {
+		if containsFold(p.FullName, name) {
+			out = append(out, p)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.Profile) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *Memory) FindBuildings(q string, limit int) ([]models.Building, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	isID := isUUID(q)
+	out := []models.Building{}
+	for _, b := range m.buildings {
+		if isID && (b.ID == q || b.LandlordID == q) ||
+			!isID && (containsFold(b.Name, q) || containsFold(b.Address, q)) {
+			out = append(out, b)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.Building) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *Memory) FindPayments(q, status string, limit int) ([]PaymentListing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	isID := isUUID(q)
+	out := []PaymentListing{}
+	for _, p := range m.payments {
+		var match bool
+		switch {
+		case q == "":
+			match = true
+		case isID:
+			match = p.ID == q || p.TenantID == q || p.BuildingID == q || p.UnitID == q
+		default:
+			match = p.PaystackReference != nil && *p.PaystackReference == q ||
+				p.PaystackTransactionID != nil && *p.PaystackTransactionID == q
+		}
+		if !match || (status != "" && p.Status != status) {
+			continue
+		}
+		listing := PaymentListing{Payment: p}
+		if t, ok := m.profile(p.TenantID); ok {
+			listing.Tenant = &PersonRef{FullName: t.FullName, Email: t.Email}
+		}
+		if b, ok := m.building(p.BuildingID); ok {
+			listing.Building = &BuildingRef{Name: b.Name}
+		}
+		if u, ok := m.unit(p.UnitID); ok {
+			listing.Unit = &UnitRef{UnitNumber: u.UnitNumber}
+		}
+		out = append(out, listing)
+	}
+	slices.SortStableFunc(out, func(a, b PaymentListing) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *Memory) ListWebhookEvents(reference, event string, limit int) ([]models.WebhookEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.WebhookEvent{}
+	for _, e := range m.webhookEvents {
+		if (reference == "" || e.Reference == reference) && (event == "" || e.Event == event) {
+			out = append(out, e)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b models.WebhookEvent) int { return b.ReceivedAt.Compare(a.ReceivedAt) })
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *Memory) CreateImpersonation(sess models.ImpersonationSession, tokenHash string) (models.ImpersonationSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stamp(&sess.ID, &sess.CreatedAt)
+	m.impersonations = append(m.impersonations, impersonation{ImpersonationSession: sess, hash: tokenHash})
+	return sess, nil
+}
+
+func (m *Memory) FindImpersonation(tokenHash, adminID string) (models.ImpersonationSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.impersonations, func(s impersonation) bool { return s.hash == tokenHash && s.AdminID == adminID && s.EndedAt == nil })
+	if i < 0 {
+		return models.ImpersonationSession{}, ErrNotFound
+	}
+	return m.impersonations[i].ImpersonationSession, nil
+}
+
+func (m *Memory) EndImpersonation(id, adminID string) (models.ImpersonationSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := find(m.impersonations, func(s impersonation) bool { return s.ID == id && s.AdminID == adminID && s.EndedAt == nil })
+	if i < 0 {
+		return models.ImpersonationSession{}, ErrNotFound
+	}
+	now := time.Now().UTC()
+	m.impersonations[i].EndedAt = &now
+	return m.impersonations[i].ImpersonationSession, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// Search
+
+// Search stands in for pg_trgm with substring matches, ranked by how much
+// of the value the query covers; exact matches rank 1 as in the database
+func (m *Memory) Search(q SearchQuery) (SearchResults, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := SearchResults{Tenants: []TenantHit{}, Units: []UnitHit{}, Buildings: []BuildingHit{}, Payments: []PaymentHit{}}
+	if q.empty() {
+		return res, nil
+	}
+
+	for _, u := range m.units {
+		if !slices.Contains(q.BuildingIDs, u.BuildingID) {
+			continue
+		}
+		b, _ := m.building(u.BuildingID)
+		var tenant *models.Profile
+		if u.TenantID != nil {
+			if p, ok := m.profile(*u.TenantID); ok {
+				tenant = &p
+			}
+		}
+		if tenant != nil {
+			rank := textRank(q.Text, tenant.FullName)
+			if q.Email != "" && strings.EqualFold(tenant.Email, q.Email) || q.Phone != "" && tenant.Phone != nil && samePhone(*tenant.Phone, q.Phone) {
+				rank = 1
+			}
+			if rank > 0 {
+				res.Tenants = append(res.Tenants, TenantHit{ID: tenant.ID, FullName: tenant.FullName, Email: tenant.Email, Phone: tenant.Phone,
+					UnitID: u.ID, UnitNumber: u.UnitNumber, BuildingID: b.ID, BuildingName: b.Name, Rank: rank})
+			}
+		}
+		if rank := textRank(q.Text, u.UnitNumber); rank > 0 {
+			hit := UnitHit{ID: u.ID, UnitNumber: u.UnitNumber, Status: u.Status, BuildingID: b.ID, BuildingName: b.Name, Rank: rank}
+			if tenant != nil {
+				hit.TenantName = &tenant.FullName
+			}
+			res.Units = append(res.Units, hit)
+		}
+	}
+	for _, b := range m.buildings {
+		if !slices.Contains(q.BuildingIDs, b.ID) {
+			continue
+		}
+		if rank := max(textRank(q.Text, b.Name), textRank(q.Text, b.Address)); rank > 0 {
+			res.Buildings = append(res.Buildings, BuildingHit{ID: b.ID, Name: b.Name, Address: b.Address, Rank: rank})
+		}
+	}
+	for _, p := range m.payments {
+		if !slices.Contains(q.FinancialIDs, p.BuildingID) {
+			continue
+		}
+		rank := 0.0
+		if p.PaystackReference != nil {
+			rank = textRank(q.Text, *p.PaystackReference)
+		}
+		if q.Text != "" && strings.EqualFold(p.ID, q.Text) {
+			rank = 1
+		}
+		if rank == 0 {
+			continue
+		}
+		hit := PaymentHit{ID: p.ID, PaystackReference: p.PaystackReference, Amount: p.Amount, Status: p.Status, Period: p.Period,
+			PaidAt: p.PaidAt, CreatedAt: p.CreatedAt, Rank: rank}
+		if t, ok := m.profile(p.TenantID); ok {
+			hit.TenantName = &t.FullName
+		}
+		if u, ok := m.unit(p.UnitID); ok {
+			hit.UnitNumber = u.UnitNumber
+		}
+		if b, ok := m.building(p.BuildingID); ok {
+			hit.BuildingName = b.Name
+		}
+		res.Payments = append(res.Payments, hit)
+	}
+
+	res.Tenants = best(res.Tenants, q.Limit, func(a, b TenantHit) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), strings.Compare(a.FullName, b.FullName))
+	})
+	res.Units = best(res.Units, q.Limit, func(a, b UnitHit) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), strings.Compare(a.UnitNumber, b.UnitNumber))
+	})
+	res.Buildings = best(res.Buildings, q.Limit, func(a, b BuildingHit) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), strings.Compare(a.Name, b.Name))
+	})
+	res.Payments = best(res.Payments, q.Limit, func(a, b PaymentHit) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), b.CreatedAt.Compare(a.CreatedAt))
+	})
+	return res, nil
+}
+
+// textRank is 1 when value equals query (ignoring case), the share of
+// value the query covers when it contains it, and 0 otherwise
+func textRank(query, value string) float64 {
+	q, v := strings.ToLower(query), strings.ToLower(value)
+	switch {
+	case q == "" || v == "":
+		return 0
+	case q == v:
+		return 1
+	case strings.Contains(v, q):
+		return float64(len(q)) / float64(len(v))
+	}
+	return 0
+}
+
+// samePhone compares the last ten digits of two numbers, as search_portfolio
+// does when phones are not encrypted
+func samePhone(a, b string) bool {
+	digits := func(s string) string {
+		s = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, s)
+		return s[max(len(s)-10, 0):]
+	}
+	return digits(a) == digits(b)
+}
+
+// best sorts hits and keeps the first limit
+func best[T any](hits []T, limit int, order func(a, b T) int) []T {
+	slices.SortStableFunc(hits, order)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Imports
+
+func (m *Memory) CommitImport(imp models.Import, units []models.Unit, payments []models.Payment) (models.Import, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, u := range units {
+		taken := func(other models.Unit) bool {
+			return other.BuildingID == imp.BuildingID && other.UnitNumber == u.UnitNumber
+		}
+		if slices.ContainsFunc(m.units, taken) || slices.ContainsFunc(units[:i], taken) {
+			return models.Import{}, ErrUnitNumberTaken
+		}
+	}
+	for _, p := range payments {
+		inBuilding := func(u models.Unit) bool { return u.ID == p.UnitID && u.BuildingID == imp.BuildingID }
+		if !slices.ContainsFunc(m.units, inBuilding) && !slices.ContainsFunc(units, inBuilding) {
+			return models.Import{}, ErrNotFound
+		}
+	}
+
+	stamp(&imp.ID, &imp.CreatedAt)
+	imp.UnitsCreated, imp.PaymentsCreated = len(units), len(payments)
+	m.imports = append(m.imports, imp)
+	for _, u := range units {
+		u.BuildingID, u.Status = imp.BuildingID, "vacant"
+		stamp(&u.ID, &u.CreatedAt)
+		nullify(&u.LeaseStart, &u.LeaseEnd)
+		u.UpdatedAt = u.CreatedAt
+		m.units = append(m.units, u)
+	}
+	m.countUnits(imp.BuildingID)
+	for _, p := range payments {
+		p.ID, p.CreatedAt = "", time.Time{}
+		stamp(&p.ID, &p.CreatedAt)
+		p.BuildingID, p.Status, p.RecordedBy, p.ImportID = imp.BuildingID, "successful", imp.CreatedBy, &imp.ID
+		nullify(&p.PaymentMethod)
+		m.payments = append(m.payments, p)
+	}
+	return imp, nil
+}
+
+// Stats
+
+func (m *Memory) Totals(buildingIDs, financialIDs []string) (Totals, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var t Totals
+	for _, u := range m.units {
+		if slices.Contains(buildingIDs, u.BuildingID) && u.ArchivedAt == nil {
+			t.Units++
+			if u.Status == "occupied" {
+				t.OccupiedUnits++
+			}
+		}
+	}
+	for _, p := range m.payments {
+		if !slices.Contains(financialIDs, p.BuildingID) {
+			continue
+		}
+		switch p.Status {
+		case "successful":
+			t.Collected += p.Amount
+		case "pending":
+			t.Pending += p.Amount
+		}
+	}
+	return t, nil
+}
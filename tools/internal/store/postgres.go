@@ -0,0 +1,1295 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/encryption"
+	"github.com/aletheia/backend/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresStore implements every store interface with SQL over a pgx pool.
+// Rows are selected as jsonb (to_jsonb plus jsonb_build_object for the
+// embedded rows), so they decode into the same types, under the same keys,
+// as PostgREST responses.
+type postgresStore struct {
+	pool   *pgxpool.Pool
+	cipher *encryption.Cipher
+}
+
+// NewPostgres returns a Store that talks to Postgres directly. The
+// connection's role must bypass row-level security, like the service role
+// key does for PostgREST. cipher encrypts and decrypts the columns in
+// encryption.Sensitive, as the encryption transport does for the Supabase
+// store; nil stores them in plain text.
+func NewPostgres(pool *pgxpool.Pool, cipher *encryption.Cipher) *Store {
+	s := &postgresStore{pool: pool, cipher: cipher}
+	return &Store{
+		Buildings:     s,
+		Units:         s,
+		Tenancies:     s,
+		Payments:      s,
+		Invitations:   s,
+		Maintenance:   s,
+		Documents:     s,
+		Profiles:      s,
+		Organisations: s,
+		Staff:         s,
+		Stats:         s,
+		Search:        s,
+		Imports:       s,
+		Owners:        s,
+		APIKeys:       s,
+		Audit:         s,
+		Notifications: s,
+		OTPs:          s,
+		AuthTokens:    s,
+		Lockouts:      s,
+		MFA:           s,
+		Admin:         s,
+	}
+}
+
+// querier is the pool or a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// UUID lists are passed as text[] and cast, since pgx only encodes single
+// strings into uuid parameters
+const uuidList = "::text[]::uuid[]"
+
+// selectRows runs a query whose only column is a row as jsonb and decodes
+// every row into T
+func selectRows[T any](s *postgresStore, q querier, sql string, args ...any) ([]T, error) {
+	rows, err := q.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []T{}
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		if s.cipher != nil && encryption.HasCiphertext(raw) {
+			if raw, err = s.cipher.DecryptJSON(raw); err != nil {
+				return nil, err
+			}
+		}
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// selectOne is selectRows for a single row, or ErrNotFound
+func selectOne[T any](s *postgresStore, q querier, sql string, args ...any) (T, error) {
+	var zero T
+	rows, err := selectRows[T](s, q, sql, args...)
+	if err != nil {
+		return zero, err
+	}
+	if len(rows) == 0 {
+		return zero, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+// insertRow inserts a row (column → value) and returns it as stored
+func insertRow[T any](s *postgresStore, q querier, table string, row map[string]interface{}) (T, error) {
+	if err := s.seal(table, row); err != nil {
+		var zero T
+		return zero, err
+	}
+	cols, args := columns(row)
+	placeholders := make([]string, len(cols))
+	for i := range cols {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	sql := fmt.Sprintf("insert into %s as t (%s) values (%s) returning to_jsonb(t)",
+		table, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+	return selectOne[T](s, q, sql, args...)
+}
+
+// updateRow changes the given columns of the row with the given id, like
+// a PostgREST PATCH, and returns it; ErrNotFound if there is no such row
+func updateRow[T any](s *postgresStore, q querier, table, id string, fields map[string]interface{}) (T, error) {
+	fields = maps.Clone(fields)
+	if err := s.seal(table, fields); err != nil {
+		var zero T
+		return zero, err
+	}
+	set, args := assignments(fields)
+	sql := fmt.Sprintf("update %s as t set %s where t.id = $%d returning to_jsonb(t)", table, set, len(args)+1)
+	return selectOne[T](s, q, sql, append(args, id)...)
+}
+
+// updateWhere is updateRow for every row matching cond, whose "?"s are
+// the placeholders of args
+func updateWhere[T any](s *postgresStore, q querier, table string, fields map[string]interface{}, cond string, args ...any) ([]T, error) {
+	fields = maps.Clone(fields)
+	if err := s.seal(table, fields); err != nil {
+		return nil, err
+	}
+	set, vals := assignments(fields)
+	c := conds{args: vals}
+	c.add(cond, args...)
+	return selectRows[T](s, q, fmt.Sprintf("update %s as t set %s%s returning to_jsonb(t)", table, set, c.where()), c.args...)
+}
+
+// upsertRow inserts a row, or updates the row with the same key to match
+func upsertRow(s *postgresStore, q querier, table, key string, row map[string]interface{}) error {
+	if err := s.seal(table, row); err != nil {
+		return err
+	}
+	key = pgx.Identifier{key}.Sanitize()
+	cols, args := columns(row)
+	placeholders := make([]string, len(cols))
+	var set []string
+	for i, c := range cols {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		if c != key {
+			set = append(set, c+" = excluded."+c)
+		}
+	}
+	sql := fmt.Sprintf("insert into %s (%s) values (%s) on conflict (%s) do update set %s",
+		table, strings.Join(cols, ", "), strings.Join(placeholders, ", "), key, strings.Join(set, ", "))
+	_, err := q.Exec(context.Background(), sql, args...)
+	return err
+}
+
+// assignments writes columns (column → value) as a set list, with
+// placeholders numbered from $1
+func assignments(fields map[string]interface{}) (string, []any) {
+	cols, args := columns(fields)
+	set := make([]string, len(cols))
+	for i, c := range cols {
+		set[i] = fmt.Sprintf("%s = $%d", c, i+1)
+	}
+	return strings.Join(set, ", "), args
+}
+
+// affected runs a statement and reports whether it changed a row
+func affected(q querier, sql string, args ...any) (bool, error) {
+	tag, err := q.Exec(context.Background(), sql, args...)
+	return tag.RowsAffected() > 0, err
+}
+
+// callFunction runs a database function that returns a row as jsonb,
+// turning the errors it raises by name into the store's
+func callFunction[T any](s *postgresStore, sql string, args ...any) (T, error) {
+	v, err := selectOne[T](s, s.pool, sql, args...)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Message {
+		case "not_found":
+			return v, ErrNotFound
+		case "unit_occupied":
+			return v, ErrUnitOccupied
+		case "unit_number_taken":
+			return v, ErrUnitNumberTaken
+		case "tenancy_ended":
+			return v, ErrTenancyEnded
+		}
+	}
+	return v, err
+}
+
+// columns returns a row's quoted column names in a stable order, with
+// their values
+func columns(row map[string]interface{}) ([]string, []any) {
+	names := make([]string, 0, len(row))
+	for k := range row {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	args := make([]any, len(names))
+	for i, k := range names {
+		args[i] = row[k]
+		names[i] = pgx.Identifier{k}.Sanitize()
+	}
+	return names, args
+}
+
+// seal encrypts a table's sensitive columns in row and fills in their
+// blind indexes
+func (s *postgresStore) seal(table string, row map[string]interface{}) error {
+	if s.cipher == nil {
+		return nil
+	}
+	return s.cipher.EncryptRow(table, row, encryption.Sensitive[table])
+}
+
+// lookup returns the column and value that find a plaintext value of a
+// sensitive column: its blind index while encrypting, else the column
+func (s *postgresStore) lookup(table, column, value string) (string, string) {
+	if s.cipher != nil {
+		for _, f := range encryption.Sensitive[table] {
+			if f.Column == column && f.Index != "" {
+				return f.Index, s.cipher.BlindIndex(f.Kind, value)
+			}
+		}
+	}
+	return column, value
+}
+
+// conds collects where conditions; each "?" in a condition is the
+// placeholder of the next argument
+type conds struct {
+	list []string
+	args []any
+}
+
+func (c *conds) add(cond string, args ...any) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(c.args)), 1)
+	}
+	c.list = append(c.list, cond)
+}
+
+func (c *conds) where() string {
+	if len(c.list) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(c.list, " and ")
+}
+
+// listRows runs a list query for one page: "select <sel> from <from>"
+// with the scope in c, plus the caller's filters and cursor on columns of
+// the table aliased as alias. The total is counted by a second query
+// without the cursor.
+func listRows[T any](s *postgresStore, sel, from, alias string, c conds, opts ListOptions, def Sort) (Page[T], error) {
+	sort := opts.order(def)
+	col := func(name string) string { return alias + "." + pgx.Identifier{name}.Sanitize() }
+	for _, f := range opts.Filters {
+		switch f.Op {
+		case OpIn:
+			c.add(col(f.Column)+" = any(?)", f.Value)
+		case OpEq:
+			c.add(col(f.Column)+" = ?", f.Value)
+		case OpGte:
+			c.add(col(f.Column)+" >= ?", f.Value)
+		case OpLt:
+			c.add(col(f.Column)+" < ?", f.Value)
+		case OpLte:
+			c.add(col(f.Column)+" <= ?", f.Value)
+		case OpNull:
+			if f.Value == true {
+				c.add(col(f.Column) + " is null")
+			} else {
+				c.add(col(f.Column) + " is not null")
+			}
+		default:
+			return Page[T]{}, fmt.Errorf("store: unknown filter operator %q", f.Op)
+		}
+	}
+
+	var total int
+	if err := s.pool.QueryRow(context.Background(), "select count(*) from "+from+c.where(), c.args...).Scan(&total); err != nil {
+		return Page[T]{}, err
+	}
+
+	dir, op := "asc", ">"
+	if sort.Desc {
+		dir, op = "desc", "<"
+	}
+	if a := opts.After; a != nil {
+		c.add(fmt.Sprintf("(%[1]s %[2]s ? or (%[1]s = ? and %[3]s %[2]s ?))", col(sort.Column), op, col("id")), a.Value, a.Value, a.ID)
+	}
+	sql := fmt.Sprintf("select %s from %s%s order by %s %s, %s %s limit %d offset %d",
+		sel, from, c.where(), col(sort.Column), dir, col("id"), dir, opts.perPage()+1, opts.offset())
+	rows, err := selectRows[T](s, s.pool, sql, c.args...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	return finish(rows, total, opts, sort)
+}
+
+// Buildings
+
+func (s *postgresStore) ListBuildings(ids []string, opts ListOptions) (Page[models.Building], error) {
+	if len(ids) == 0 {
+		return finish[models.Building](nil, 0, opts, Sort{})
+	}
+	var c conds
+	c.add("b.id = any(?"+uuidList+")", ids)
+	return listRows[models.Building](s, "to_jsonb(b)", "buildings b", "b", c, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *postgresStore) BuildingsByID(ids []string, includeArchived bool) ([]models.Building, error) {
+	return selectRows[models.Building](s, s.pool, `select to_jsonb(b) from buildings b
+		where b.id = any($1`+uuidList+`) and ($2 or b.archived_at is null)
+		order by b.created_at desc, b.id desc`, nonNil(ids), includeArchived)
+}
+
+func (s *postgresStore) GetBuilding(id string) (models.Building, error) {
+	return selectOne[models.Building](s, s.pool, `select to_jsonb(b) from buildings b where b.id = $1`, id)
+}
+
+func (s *postgresStore) CreateBuilding(b models.Building) (models.Building, error) {
+	return insertRow[models.Building](s, s.pool, "buildings", buildingRow(b))
+}
+
+func (s *postgresStore) CreateBuildingWithUnits(b models.Building, units []models.Unit) (models.Building, []models.Unit, error) {
+	var created models.Building
+	out := make([]models.Unit, len(units))
+	err := pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		var err error
+		if created, err = insertRow[models.Building](s, tx, "buildings", buildingRow(b)); err != nil {
+			return err
+		}
+		for i, u := range units {
+			u.BuildingID = created.ID
+			if out[i], err = insertRow[models.Unit](s, tx, "units", unitRow(u)); err != nil {
+				return err
+			}
+		}
+		// Reread for the total_units the units_count trigger set
+		created, err = selectOne[models.Building](s, tx, `select to_jsonb(b) from buildings b where b.id = $1`, created.ID)
+		return err
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return models.Building{}, nil, ErrUnitNumberTaken
+	}
+	if err != nil {
+		return models.Building{}, nil, err
+	}
+	return created, out, nil
+}
+
+func (s *postgresStore) UpdateBuilding(id string, fields map[string]interface{}) (models.Building, error) {
+	return updateRow[models.Building](s, s.pool, "buildings", id, fields)
+}
+
+// ArchiveBuilding and RestoreBuilding call the same functions as the
+// Supabase store
+func (s *postgresStore) ArchiveBuilding(id string) (models.Building, error) {
+	return callFunction[models.Building](s, `select archive_building($1)`, id)
+}
+
+func (s *postgresStore) RestoreBuilding(id string) (models.Building, error) {
+	return callFunction[models.Building](s, `select restore_building($1)`, id)
+}
+
+func (s *postgresStore) LandlordBuildings(landlordID string) ([]models.Building, error) {
+	return selectRows[models.Building](s, s.pool,
+		`select to_jsonb(b) from buildings b where b.landlord_id = $1 order by b.created_at desc, b.id desc`, landlordID)
+}
+
+// Units
+
+func (s *postgresStore) ListUnits(buildingID string, opts ListOptions) (Page[UnitListing], error) {
+	var c conds
+	c.add("u.building_id = ?", buildingID)
+	return listRows[UnitListing](s, `
+		to_jsonb(u) || jsonb_build_object('profiles', (
+		  select jsonb_build_object('full_name', p.full_name, 'email', p.email, 'phone', p.phone, 'avatar_thumb_url', p.avatar_thumb_url)
+		    from profiles p where p.id = u.tenant_id))`,
+		"units u", "u", c, opts, Sort{Column: "unit_number"})
+}
+
+func (s *postgresStore) GetUnit(id string) (models.Unit, error) {
+	return selectOne[models.Unit](s, s.pool, `select to_jsonb(u) from units u where u.id = $1`, id)
+}
+
+func (s *postgresStore) CreateUnit(u models.Unit) (models.Unit, error) {
+	created, err := insertRow[models.Unit](s, s.pool, "units", unitRow(u))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return models.Unit{}, ErrUnitNumberTaken
+	}
+	return created, err
+}
+
+func (s *postgresStore) CreateUnits(units []models.Unit) ([]models.Unit, error) {
+	out := make([]models.Unit, len(units))
+	err := pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		for i, u := range units {
+			created, err := insertRow[models.Unit](s, tx, "units", unitRow(u))
+			if err != nil {
+				return err
+			}
+			out[i] = created
+		}
+		return nil
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrUnitNumberTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *postgresStore) UpdateUnit(id string, fields map[string]interface{}) (models.Unit, error) {
+	return updateRow[models.Unit](s, s.pool, "units", id, fields)
+}
+
+func (s *postgresStore) ArchiveUnit(id string) (models.Unit, error) {
+	return callFunction[models.Unit](s, `select archive_unit($1)`, id)
+}
+
+// Tenancies
+
+func (s *postgresStore) ListTenancies(unitID string) ([]TenancyListing, error) {
+	return selectRows[TenancyListing](s, s.pool, `
+		select to_jsonb(t) || jsonb_build_object('profiles', (
+		  select jsonb_build_object('full_name', p.full_name, 'email', p.email, 'phone', p.phone, 'avatar_thumb_url', p.avatar_thumb_url)
+		    from profiles p where p.id = t.tenant_id))
+		  from tenancies t
+		 where t.unit_id = $1
+		 order by t.start_date desc, t.created_at desc`, unitID)
+}
+
+func (s *postgresStore) GetTenancy(id string) (models.Tenancy, error) {
+	return selectOne[models.Tenancy](s, s.pool, `select to_jsonb(t) from tenancies t where t.id = $1`, id)
+}
+
+func (s *postgresStore) ActiveTenancy(unitID string) (models.Tenancy, error) {
+	return selectOne[models.Tenancy](s, s.pool, `select to_jsonb(t) from tenancies t where t.unit_id = $1 and t.status = 'active'`, unitID)
+}
+
+func (s *postgresStore) TenanciesForTenant(tenantID string) ([]models.Tenancy, error) {
+	return selectRows[models.Tenancy](s, s.pool, `select to_jsonb(t) from tenancies t where t.tenant_id = $1 order by t.start_date desc, t.created_at desc`, tenantID)
+}
+
+func (s *postgresStore) UpdateTenancy(id string, fields map[string]interface{}) (models.Tenancy, error) {
+	return updateRow[models.Tenancy](s, s.pool, "tenancies", id, fields)
+}
+
+func (s *postgresStore) EndTenancy(id, endDate string) (models.Tenancy, error) {
+	return callFunction[models.Tenancy](s, `select end_tenancy($1, $2::date)`, id, endDate)
+}
+
+// Payments
+
+func (s *postgresStore) ListPayments(f PaymentFilter, opts ListOptions) (Page[PaymentListing], error) {
+	if f.BuildingIDs != nil && len(f.BuildingIDs) == 0 {
+		return finish[PaymentListing](nil, 0, opts, Sort{})
+	}
+	var c conds
+	if f.TenantID != "" {
+		c.add("p.tenant_id = ?", f.TenantID)
+	}
+	if f.BuildingIDs != nil {
+		c.add("p.building_id = any(?"+uuidList+")", f.BuildingIDs)
+	}
+	return listRows[PaymentListing](s, `
+		to_jsonb(p) || jsonb_build_object(
+		  'profiles', (select jsonb_build_object('full_name', t.full_name) from profiles t where t.id = p.tenant_id),
+		  'buildings', (select jsonb_build_object('name', b.name) from buildings b where b.id = p.building_id),
+		  'units', (select jsonb_build_object('unit_number', u.unit_number) from units u where u.id = p.unit_id))`,
+		"payments p", "p", c, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *postgresStore) GetPayment(id string) (models.Payment, error) {
+	return selectOne[models.Payment](s, s.pool, `select to_jsonb(p) from payments p where p.id = $1`, id)
+}
+
+func (s *postgresStore) FindPaymentByReference(reference string) (models.Payment, error) {
+	return selectOne[models.Payment](s, s.pool, `select to_jsonb(p) from payments p where p.paystack_reference = $1`, reference)
+}
+
+func (s *postgresStore) CreatePayment(p models.Payment) (models.Payment, error) {
+	return insertRow[models.Payment](s, s.pool, "payments", paymentRow(p))
+}
+
+// UpdateUnsettledPayment locks the payment row first, so a webhook and a
+// reconciliation settling the same payment wait for each other instead of
+// both seeing it pending
+func (s *postgresStore) UpdateUnsettledPayment(id string, fields map[string]interface{}) (models.Payment, bool, error) {
+	var p models.Payment
+	var ok bool
+	err := pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		var status string
+		err := tx.QueryRow(context.Background(), `select status from payments where id = $1 for update`, id).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) || status == "successful" {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if p, err = updateRow[models.Payment](s, tx, "payments", id, fields); err != nil {
+			return err
+		}
+		ok = true
+		return nil
+	})
+	return p, ok, err
+}
+
+func (s *postgresStore) RecordWebhookEvent(e models.WebhookEvent) (models.WebhookEvent, error) {
+	return insertRow[models.WebhookEvent](s, s.pool, "webhook_events", webhookEventRow(e))
+}
+
+func (s *postgresStore) UpdateWebhookEvent(id string, fields map[string]interface{}) error {
+	_, err := updateRow[models.WebhookEvent](s, s.pool, "webhook_events", id, fields)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// Invitations
+
+func (s *postgresStore) ListInvitations(buildingIDs []string, opts ListOptions) (Page[InvitationListing], error) {
+	if len(buildingIDs) == 0 {
+		return finish[InvitationListing](nil, 0, opts, Sort{})
+	}
+	var c conds
+	c.add("u.building_id = any(?"+uuidList+")", buildingIDs)
+	return listRows[InvitationListing](s,
+		`to_jsonb(i) || jsonb_build_object('units', jsonb_build_object('unit_number', u.unit_number, 'building_id', u.building_id))`,
+		"invitations i join units u on u.id = i.unit_id", "i", c, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *postgresStore) GetPendingInvitation(token string) (models.Invitation, error) {
+	return selectOne[models.Invitation](s, s.pool, `select to_jsonb(i) from invitations i where i.token = $1 and i.status = 'pending'`, token)
+}
+
+// invitationDetails selects an invitation with its unit and building
+const invitationDetails = `
+	select to_jsonb(i) || jsonb_build_object(
+	         'units', jsonb_build_object('unit_number', u.unit_number, 'rent_amount', u.rent_amount),
+	         'buildings', jsonb_build_object('buildings', jsonb_build_object('name', b.name, 'address', b.address, 'photo_url', b.photo_url)))
+	  from invitations i
+	  join units u on u.id = i.unit_id
+	  join buildings b on b.id = u.building_id`
+
+func (s *postgresStore) GetInvitationDetails(token string) (InvitationDetails, error) {
+	return selectOne[InvitationDetails](s, s.pool, invitationDetails+` where i.token = $1 and i.status = 'pending'`, token)
+}
+
+func (s *postgresStore) GetInvitation(id string) (InvitationDetails, error) {
+	return selectOne[InvitationDetails](s, s.pool, invitationDetails+` where i.id = $1`, id)
+}
+
+func (s *postgresStore) CreateInvitation(inv models.Invitation) (models.Invitation, error) {
+	return insertRow[models.Invitation](s, s.pool, "invitations", invitationRow(inv))
+}
+
+// AcceptInvitation calls the same accept_invitation function as the
+// Supabase store, which locks the invitation and unit rows and does every
+// write in one transaction
+func (s *postgresStore) AcceptInvitation(a Acceptance) (Accepted, error) {
+	args := map[string]interface{}{}
+	if p := a.NewProfile; p != nil {
+		args["p_full_name"] = p.FullName
+		if p.Email != "" {
+			args["p_email"] = p.Email
+		}
+		optional(args, "p_phone", p.Phone)
+		args["p_email_verified_at"] = p.EmailVerifiedAt
+		args["p_phone_verified_at"] = p.PhoneVerifiedAt
+	}
+	if s.cipher != nil {
+		if err := s.cipher.EncryptRow("rpc/accept_invitation", args, encryption.Sensitive["rpc/accept_invitation"]); err != nil {
+			return Accepted{}, err
+		}
+	}
+
+	var raw []byte
+	err := s.pool.QueryRow(context.Background(),
+		`select accept_invitation(p_token => $1, p_tenant_id => $2, p_full_name => $3, p_email => $4, p_email_bidx => $5,
+		                          p_phone => $6, p_phone_bidx => $7, p_email_verified_at => $8, p_phone_verified_at => $9)`,
+		a.Token, a.TenantID, args["p_full_name"], args["p_email"], args["p_email_bidx"],
+		args["p_phone"], args["p_phone_bidx"], args["p_email_verified_at"], args["p_phone_verified_at"],
+	).Scan(&raw)
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Message == "invitation_unavailable":
+		return Accepted{}, ErrInvitationUnavailable
+	case errors.As(err, &pgErr) && pgErr.Message == "unit_occupied":
+		return Accepted{}, ErrUnitOccupied
+	case err != nil:
+		return Accepted{}, fmt.Errorf("store: accept_invitation failed: %w", err)
+	}
+	var accepted Accepted
+	if err := json.Unmarshal(raw, &accepted); err != nil {
+		return Accepted{}, err
+	}
+	return accepted, nil
+}
+
+func (s *postgresStore) EraseInvitationContacts(e ContactErasure) error {
+	return pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		if e.Email != "" {
+			col, v := s.lookup("invitations", "email", e.Email)
+			if _, err := updateWhere[models.Invitation](s, tx, "invitations", map[string]interface{}{"email": e.Pseudonym, "phone": nil}, "t."+col+" = ?", v); err != nil {
+				return err
+			}
+		}
+		if e.Phone != "" {
+			col, v := s.lookup("invitations", "phone", e.Phone)
+			_, err := updateWhere[models.Invitation](s, tx, "invitations", map[string]interface{}{"phone": nil}, "t."+col+" = ?", v)
+			return err
+		}
+		return nil
+	})
+}
+
+// Maintenance
+
+func (s *postgresStore) ListMaintenanceRequests(f MaintenanceFilter, opts ListOptions) (Page[MaintenanceListing], error) {
+	if f.BuildingIDs != nil && len(f.BuildingIDs) == 0 {
+		return finish[MaintenanceListing](nil, 0, opts, Sort{})
+	}
+	var c conds
+	if f.TenantID != "" {
+		c.add("m.tenant_id = ?", f.TenantID)
+	}
+	if f.BuildingIDs != nil {
+		c.add("m.building_id = any(?"+uuidList+")", f.BuildingIDs)
+	}
+	return listRows[MaintenanceListing](s, `
+		to_jsonb(m) || jsonb_build_object(
+		  'profiles', (select jsonb_build_object('full_name', t.full_name, 'avatar_thumb_url', t.avatar_thumb_url) from profiles t where t.id = m.tenant_id),
+		  'units', (select jsonb_build_object('unit_number', u.unit_number) from units u where u.id = m.unit_id),
+		  'buildings', (select jsonb_build_object('name', b.name) from buildings b where b.id = m.building_id))`,
+		"maintenance_requests m", "m", c, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *postgresStore) GetMaintenanceRequest(id string) (models.MaintenanceRequest, error) {
+	return selectOne[models.MaintenanceRequest](s, s.pool, `select to_jsonb(m) from maintenance_requests m where m.id = $1`, id)
+}
+
+func (s *postgresStore) CreateMaintenanceRequest(m models.MaintenanceRequest) (models.MaintenanceRequest, error) {
+	return insertRow[models.MaintenanceRequest](s, s.pool, "maintenance_requests", maintenanceRow(m))
+}
+
+func (s *postgresStore) UpdateMaintenanceRequest(id string, fields map[string]interface{}) (models.MaintenanceRequest, error) {
+	return updateRow[models.MaintenanceRequest](s, s.pool, "maintenance_requests", id, fields)
+}
+
+// Documents
+
+func (s *postgresStore) ListDocuments(f DocumentFilter, opts ListOptions) (Page[models.Document], error) {
+	var c conds
+	c.add("(d.uploaded_by = ? or d.tenancy_id = any(?"+uuidList+") or d.building_id = any(?"+uuidList+"))",
+		f.UploadedBy, nonNil(f.TenancyIDs), nonNil(f.BuildingIDs))
+	return listRows[models.Document](s, "to_jsonb(d)", "documents d", "d", c, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *postgresStore) CreateDocument(d models.Document) (models.Document, error) {
+	return insertRow[models.Document](s, s.pool, "documents", documentRow(d))
+}
+
+// Profiles
+
+func (s *postgresStore) GetProfile(id string) (models.Profile, error) {
+	return selectOne[models.Profile](s, s.pool, `select to_jsonb(p) from profiles p where p.id = $1`, id)
+}
+
+func (s *postgresStore) ListProfiles(ids []string) ([]models.Profile, error) {
+	return selectRows[models.Profile](s, s.pool, `select to_jsonb(p) from profiles p where p.id = any($1`+uuidList+`)`, nonNil(ids))
+}
+
+func (s *postgresStore) FindProfileByEmail(email string) (models.Profile, error) {
+	col, v := s.lookup("profiles", "email", email)
+	return selectOne[models.Profile](s, s.pool, `select to_jsonb(p) from profiles p where p.`+col+` = $1`, v)
+}
+
+func (s *postgresStore) FindProfileByPhone(phone string) (models.Profile, error) {
+	col, v := s.lookup("profiles", "phone", phone)
+	return selectOne[models.Profile](s, s.pool, `select to_jsonb(p) from profiles p where p.`+col+` = $1`, v)
+}
+
+func (s *postgresStore) CreateProfile(p models.Profile) (models.Profile, error) {
+	return insertRow[models.Profile](s, s.pool, "profiles", profileRow(p))
+}
+
+func (s *postgresStore) UpdateProfile(id string, fields map[string]interface{}) (models.Profile, error) {
+	return updateRow[models.Profile](s, s.pool, "profiles", id, fields)
+}
+
+func (s *postgresStore) MarkEmailVerified(id string, at time.Time) error {
+	_, err := s.pool.Exec(context.Background(), `update profiles set email_verified_at = $2 where id = $1 and email_verified_at is null`, id, at)
+	return err
+}
+
+func (s *postgresStore) UpdateRoles(id string, roles []string, defaultRole string) error {
+	_, err := s.pool.Exec(context.Background(),
+		`update profiles set roles = $2, role = coalesce(nullif($3, ''), role) where id = $1`, id, roles, defaultRole)
+	return err
+}
+
+// Organisations
+
+func (s *postgresStore) OrganisationRole(orgID, userID string) (string, error) {
+	var role string
+	err := s.pool.QueryRow(context.Background(),
+		`select role from organisation_members where organisation_id = $1 and user_id = $2 and status = 'active'`, orgID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+func (s *postgresStore) OrganisationRoles(userID string) (map[string]string, error) {
+	return s.activeRoles("organisation_members", "organisation_id", userID)
+}
+
+func (s *postgresStore) GetOrganisation(id string) (models.Organisation, error) {
+	return selectOne[models.Organisation](s, s.pool, `select to_jsonb(o) from organisations o where o.id = $1`, id)
+}
+
+func (s *postgresStore) ListOrganisations(ids []string) ([]models.Organisation, error) {
+	return selectRows[models.Organisation](s, s.pool,
+		`select to_jsonb(o) from organisations o where o.id = any($1`+uuidList+`) order by o.name, o.id`, nonNil(ids))
+}
+
+// CreateOrganisation inserts the organisation and its creator's membership
+// in one transaction
+func (s *postgresStore) CreateOrganisation(o models.Organisation) (models.Organisation, error) {
+	var created models.Organisation
+	err := pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		var err error
+		if created, err = insertRow[models.Organisation](s, tx, "organisations", organisationRow(o)); err != nil {
+			return err
+		}
+		_, err = insertRow[models.OrganisationMember](s, tx, "organisation_members", creatorRow(created))
+		return err
+	})
+	return created, err
+}
+
+func (s *postgresStore) OrganisationBuildings(orgID, landlordID string) ([]models.Building, error) {
+	return selectRows[models.Building](s, s.pool, `select to_jsonb(b) from buildings b
+		where b.organisation_id = $1 and ($2 = '' or b.landlord_id::text = $2)
+		order by b.name, b.id`, orgID, landlordID)
+}
+
+func (s *postgresStore) ListOrganisationMembers(orgID string) ([]OrganisationMemberListing, error) {
+	return selectRows[OrganisationMemberListing](s, s.pool, liveMembersSQL("organisation_members", "organisation_id"), orgID)
+}
+
+func (s *postgresStore) OrganisationMemberExists(orgID, email, phone string) (bool, error) {
+	return s.memberExists("organisation_members", "organisation_id", orgID, email, phone)
+}
+
+func (s *postgresStore) CreateOrganisationInvite(m models.OrganisationMember, tokenHash string) (models.OrganisationMember, error) {
+	return insertRow[models.OrganisationMember](s, s.pool, "organisation_members", organisationInviteRow(m, tokenHash))
+}
+
+func (s *postgresStore) FindOrganisationInvite(tokenHash string) (models.OrganisationMember, error) {
+	return selectOne[models.OrganisationMember](s, s.pool,
+		`select to_jsonb(m) from organisation_members m where m.token_hash = $1 and m.status = 'invited'`, tokenHash)
+}
+
+func (s *postgresStore) AcceptOrganisationInvite(id, userID string) (models.OrganisationMember, error) {
+	return selectOne[models.OrganisationMember](s, s.pool, acceptInviteSQL("organisation_members"), id, userID)
+}
+
+func (s *postgresStore) RevokeOrganisationMember(orgID, id, exceptUserID string) (models.OrganisationMember, error) {
+	return selectOne[models.OrganisationMember](s, s.pool, revokeMemberSQL("organisation_members", "organisation_id")+
+		` and (m.user_id is null or m.user_id::text <> $3) returning to_jsonb(m)`, id, orgID, exceptUserID)
+}
+
+func (s *postgresStore) RevokeOrganisationAccess(userID string) error {
+	return s.revokeAccess("organisation_members", userID)
+}
+
+func (s *postgresStore) EraseOrganisationContacts(e ContactErasure) error {
+	return s.eraseContacts("organisation_members", e)
+}
+
+// Staff
+
+func (s *postgresStore) ListStaff(buildingID string) ([]StaffListing, error) {
+	return selectRows[StaffListing](s, s.pool, liveMembersSQL("building_members", "building_id"), buildingID)
+}
+
+func (s *postgresStore) StaffMemberExists(buildingID, email, phone string) (bool, error) {
+	return s.memberExists("building_members", "building_id", buildingID, email, phone)
+}
+
+func (s *postgresStore) CreateStaffInvite(m models.BuildingMember, tokenHash string) (models.BuildingMember, error) {
+	return insertRow[models.BuildingMember](s, s.pool, "building_members", staffInviteRow(m, tokenHash))
+}
+
+func (s *postgresStore) FindStaffInvite(tokenHash string) (models.BuildingMember, error) {
+	return selectOne[models.BuildingMember](s, s.pool,
+		`select to_jsonb(m) from building_members m where m.token_hash = $1 and m.status = 'invited'`, tokenHash)
+}
+
+func (s *postgresStore) AcceptStaffInvite(id, userID string) (models.BuildingMember, error) {
+	return selectOne[models.BuildingMember](s, s.pool, acceptInviteSQL("building_members"), id, userID)
+}
+
+func (s *postgresStore) RevokeStaff(buildingID, id string) (models.BuildingMember, error) {
+	return selectOne[models.BuildingMember](s, s.pool, revokeMemberSQL("building_members", "building_id")+` returning to_jsonb(m)`, id, buildingID)
+}
+
+func (s *postgresStore) StaffRoles(userID string) (map[string]string, error) {
+	return s.activeRoles("building_members", "building_id", userID)
+}
+
+func (s *postgresStore) RevokeStaffAccess(userID string) error {
+	return s.revokeAccess("building_members", userID)
+}
+
+func (s *postgresStore) EraseStaffContacts(e ContactErasure) error {
+	return s.eraseContacts("building_members", e)
+}
+
+// Staff and organisation members live in tables of the same shape; scope
+// is the column of the building or organisation they belong to. Table and
+// column names here are constants, never input.
+
+// liveMembersSQL selects the invited and active members of $1 with their
+// profiles, leaving out token hashes
+func liveMembersSQL(table, scope string) string {
+	return `select to_jsonb(m) - 'token_hash' - 'email_bidx' - 'phone_bidx' || jsonb_build_object('profiles', (
+		  select jsonb_build_object('full_name', p.full_name, 'email', p.email, 'phone', p.phone, 'avatar_thumb_url', p.avatar_thumb_url)
+		    from profiles p where p.id = m.user_id))
+		  from ` + table + ` m
+		 where m.` + scope + ` = $1 and m.status <> 'revoked'
+		 order by m.created_at, m.id`
+}
+
+// acceptInviteSQL makes the invited member $1 the active member $2
+func acceptInviteSQL(table string) string {
+	return `update ` + table + ` m set user_id = $2, status = 'active', accepted_at = now(), token_hash = null
+		where m.id = $1 and m.status = 'invited' returning to_jsonb(m)`
+}
+
+// revokeMemberSQL revokes member $1 of $2; callers add conditions and the
+// returning clause
+func revokeMemberSQL(table, scope string) string {
+	return `update ` + table + ` m set status = 'revoked', revoked_at = now(), token_hash = null
+		where m.id = $1 and m.` + scope + ` = $2 and m.status <> 'revoked'`
+}
+
+func (s *postgresStore) memberExists(table, scope, scopeID, email, phone string) (bool, error) {
+	col, v := s.lookup(table, "phone", phone)
+	if email != "" {
+		col, v = s.lookup(table, "email", email)
+	}
+	var exists bool
+	err := s.pool.QueryRow(context.Background(), `select exists (select 1 from `+table+`
+		where `+scope+` = $1 and status in ('invited', 'active') and `+col+` = $2)`, scopeID, v).Scan(&exists)
+	return exists, err
+}
+
+func (s *postgresStore) activeRoles(table, scope, userID string) (map[string]string, error) {
+	rows, err := s.pool.Query(context.Background(), `select `+scope+`::text, role from `+table+` where user_id = $1 and status = 'active'`, userID)
+	if err != nil {
+		return nil, err
+	}
+	roles := map[string]string{}
+	var id, role string
+	_, err = pgx.ForEachRow(rows, []any{&id, &role}, func() error {
+		roles[id] = role
+		return nil
+	})
+	return roles, err
+}
+
+func (s *postgresStore) revokeAccess(table, userID string) error {
+	_, err := s.pool.Exec(context.Background(), `update `+table+` set status = 'revoked', revoked_at = now(), token_hash = null
+		where user_id = $1 and status <> 'revoked'`, userID)
+	return err
+}
+
+// eraseContacts rewrites the user's own memberships, revoked or not, and
+// those still addressed to their email or phone, in one transaction
+func (s *postgresStore) eraseContacts(table string, e ContactErasure) error {
+	erased := map[string]interface{}{"email": e.Pseudonym, "phone": nil}
+	return pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		if _, err := updateWhere[json.RawMessage](s, tx, table, erased, "t.user_id = ?", e.UserID); err != nil {
+			return err
+		}
+		if e.Email != "" {
+			col, v := s.lookup(table, "email", e.Email)
+			if _, err := updateWhere[json.RawMessage](s, tx, table, erased, "t."+col+" = ?", v); err != nil {
+				return err
+			}
+		}
+		if e.Phone != "" {
+			col, v := s.lookup(table, "phone", e.Phone)
+			_, err := updateWhere[json.RawMessage](s, tx, table, map[string]interface{}{"phone": nil}, "t."+col+" = ?", v)
+			return err
+		}
+		return nil
+	})
+}
+
+// Owners
+
+func (s *postgresStore) ListOwners(buildingID string) ([]models.BuildingOwner, error) {
+	return selectRows[models.BuildingOwner](s, s.pool,
+		`select to_jsonb(o) from building_owners o where o.building_id = $1 order by o.created_at, o.id`, buildingID)
+}
+
+// ReplaceOwners swaps the rows in one transaction
+func (s *postgresStore) ReplaceOwners(buildingID string, owners []models.BuildingOwner) ([]models.BuildingOwner, error) {
+	var saved []models.BuildingOwner
+	err := pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		saved = nil
+		if _, err := tx.Exec(context.Background(), `delete from building_owners where building_id = $1`, buildingID); err != nil {
+			return err
+		}
+		for _, row := range ownerRows(buildingID, owners) {
+			o, err := insertRow[models.BuildingOwner](s, tx, "building_owners", row)
+			if err != nil {
+				return err
+			}
+			saved = append(saved, o)
+		}
+		return nil
+	})
+	return saved, err
+}
+
+func (s *postgresStore) ListOwnerships(userID string) ([]models.BuildingOwner, error) {
+	return selectRows[models.BuildingOwner](s, s.pool, `select to_jsonb(o) from building_owners o where o.user_id = $1`, userID)
+}
+
+// API keys
+
+func (s *postgresStore) CreateAPIKey(k models.APIKey, keyHash string) (models.APIKey, error) {
+	return insertRow[models.APIKey](s, s.pool, "api_keys", apiKeyRow(k, keyHash))
+}
+
+func (s *postgresStore) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	return selectRows[models.APIKey](s, s.pool,
+		`select to_jsonb(k) - 'key_hash' from api_keys k where k.user_id = $1 order by k.created_at desc, k.id desc`, userID)
+}
+
+func (s *postgresStore) RevokeAPIKey(id, userID string) (models.APIKey, error) {
+	return selectOne[models.APIKey](s, s.pool,
+		`update api_keys k set revoked_at = now() where k.id = $1 and k.user_id = $2 and k.revoked_at is null returning to_jsonb(k) - 'key_hash'`, id, userID)
+}
+
+func (s *postgresStore) FindAPIKey(keyHash string) (models.APIKey, error) {
+	return selectOne[models.APIKey](s, s.pool,
+		`select to_jsonb(k) - 'key_hash' from api_keys k where k.key_hash = $1 and k.revoked_at is null`, keyHash)
+}
+
+func (s *postgresStore) TouchAPIKey(id string, at time.Time) error {
+	_, err := s.pool.Exec(context.Background(), `update api_keys set last_used_at = $2 where id = $1`, id, at)
+	return err
+}
+
+func (s *postgresStore) RevokeAPIKeys(userID string) error {
+	_, err := s.pool.Exec(context.Background(), `update api_keys set revoked_at = now() where user_id = $1 and revoked_at is null`, userID)
+	return err
+}
+
+// Audit log
+
+func (s *postgresStore) ListAuditEntries(f AuditFilter, offset, limit int) ([]models.AuditEntry, error) {
+	var c conds
+	if f.BuildingIDs != nil {
+		c.add("a.building_id = any(?"+uuidList+")", f.BuildingIDs)
+	}
+	for col, v := range f.Equal {
+		c.add("a."+pgx.Identifier{col}.Sanitize()+" = ?", v)
+	}
+	if !f.From.IsZero() {
+		c.add("a.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		c.add("a.created_at < ?", f.To)
+	}
+	sql := fmt.Sprintf("select to_jsonb(a) from audit_log a%s order by a.created_at desc, a.id desc limit %d offset %d", c.where(), limit, offset)
+	return selectRows[models.AuditEntry](s, s.pool, sql, c.args...)
+}
+
+func (s *postgresStore) RecordAuditEntry(e models.AuditEntry) error {
+	_, err := insertRow[json.RawMessage](s, s.pool, "audit_log", auditRow(e))
+	return err
+}
+
+// Notifications
+
+func (s *postgresStore) RecordNotification(n models.Notification) error {
+	_, err := insertRow[json.RawMessage](s, s.pool, "notifications", notificationRow(n))
+	return err
+}
+
+// SMS codes
+
+func (s *postgresStore) RecentOTPs(phone string, since time.Time) ([]time.Time, error) {
+	col, v := s.lookup("phone_otps", "phone", phone)
+	rows, err := selectRows[models.PhoneOTP](s, s.pool,
+		`select to_jsonb(o) from phone_otps o where o.`+col+` = $1 and o.created_at >= $2 order by o.created_at desc`, v, since)
+	sent := make([]time.Time, len(rows))
+	for i, o := range rows {
+		sent[i] = o.CreatedAt
+	}
+	return sent, err
+}
+
+func (s *postgresStore) CreateOTP(o models.PhoneOTP) error {
+	col, v := s.lookup("phone_otps", "phone", o.Phone)
+	return pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(context.Background(), `update phone_otps set consumed_at = now() where `+col+` = $1 and consumed_at is null`, v); err != nil {
+			return err
+		}
+		_, err := insertRow[models.PhoneOTP](s, tx, "phone_otps", otpRow(o))
+		return err
+	})
+}
+
+func (s *postgresStore) LatestOTP(phone string) (models.PhoneOTP, error) {
+	col, v := s.lookup("phone_otps", "phone", phone)
+	return selectOne[models.PhoneOTP](s, s.pool,
+		`select to_jsonb(o) from phone_otps o where o.`+col+` = $1 and o.consumed_at is null order by o.created_at desc limit 1`, v)
+}
+
+func (s *postgresStore) TakeOTPAttempt(id string, max int) (bool, error) {
+	return affected(s.pool, `update phone_otps set attempts = attempts + 1
+		where id = $1 and consumed_at is null and expires_at > now() and attempts < $2`, id, max)
+}
+
+func (s *postgresStore) ConsumeOTP(id string) (bool, error) {
+	return affected(s.pool, `update phone_otps set consumed_at = now() where id = $1 and consumed_at is null`, id)
+}
+
+func (s *postgresStore) DeleteOTPs(phone string) error {
+	col, v := s.lookup("phone_otps", "phone", phone)
+	_, err := s.pool.Exec(context.Background(), `delete from phone_otps where `+col+` = $1`, v)
+	return err
+}
+
+// Auth tokens
+
+func (s *postgresStore) CreateAuthToken(t models.AuthToken) error {
+	return pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(context.Background(),
+			`update auth_tokens set used_at = now() where user_id = $1 and purpose = $2 and used_at is null`, t.UserID, t.Purpose); err != nil {
+			return err
+		}
+		_, err := insertRow[models.AuthToken](s, tx, "auth_tokens", authTokenRow(t))
+		return err
+	})
+}
+
+func (s *postgresStore) FindAuthToken(tokenHash, purpose string) (models.AuthToken, error) {
+	return selectOne[models.AuthToken](s, s.pool,
+		`select to_jsonb(t) from auth_tokens t where t.token_hash = $1 and t.purpose = $2`, tokenHash, purpose)
+}
+
+func (s *postgresStore) UseAuthToken(id string) (bool, error) {
+	return affected(s.pool, `update auth_tokens set used_at = now() where id = $1 and used_at is null`, id)
+}
+
+func (s *postgresStore) TakeAuthTokenAttempt(id string, max int) (bool, error) {
+	return affected(s.pool, `update auth_tokens set attempts = attempts + 1
+		where id = $1 and used_at is null and expires_at > now() and attempts < $2`, id, max)
+}
+
+func (s *postgresStore) DeleteAuthTokens(userID string) error {
+	_, err := s.pool.Exec(context.Background(), `delete from auth_tokens where user_id = $1`, userID)
+	return err
+}
+
+// Login lockouts
+
+func (s *postgresStore) GetLoginLockout(email string) (models.LoginLockout, error) {
+	return selectOne[models.LoginLockout](s, s.pool, `select to_jsonb(l) from login_lockouts l where l.email = $1`, email)
+}
+
+func (s *postgresStore) RecordLoginFailure(email string, policy LockoutPolicy) (models.LoginLockout, error) {
+	return callFunction[models.LoginLockout](s, `select record_login_failure($1, $2, $3, $4, $5)`,
+		email, policy.Threshold, int(policy.Base.Seconds()), int(policy.Max.Seconds()), int(policy.Window.Seconds()))
+}
+
+func (s *postgresStore) ClearLoginLockout(email string) error {
+	_, err := s.pool.Exec(context.Background(), `delete from login_lockouts where email = $1`, email)
+	return err
+}
+
+// Two-factor
+
+func (s *postgresStore) GetMFA(userID string) (models.UserMFA, error) {
+	return selectOne[models.UserMFA](s, s.pool, `select to_jsonb(m) from user_mfa m where m.user_id = $1`, userID)
+}
+
+func (s *postgresStore) PutMFA(m models.UserMFA) error {
+	return upsertRow(s, s.pool, "user_mfa", "user_id", mfaRow(m))
+}
+
+func (s *postgresStore) UpdateMFA(userID string, fields map[string]interface{}) error {
+	set, args := assignments(fields)
+	_, err := s.pool.Exec(context.Background(), fmt.Sprintf("update user_mfa set %s where user_id = $%d", set, len(args)+1), append(args, userID)...)
+	return err
+}
+
+func (s *postgresStore) AdvanceMFAStep(userID string, step int64) (bool, error) {
+	return affected(s.pool, `update user_mfa set last_used_step = $2 where user_id = $1 and last_used_step < $2`, userID, step)
+}
+
+func (s *postgresStore) DeleteMFA(userID string) error {
+	return pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(context.Background(), `delete from mfa_recovery_codes where user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(context.Background(), `delete from user_mfa where user_id = $1`, userID)
+		return err
+	})
+}
+
+func (s *postgresStore) CountRecoveryCodes(userID string) (int, error) {
+	var n int
+	err := s.pool.QueryRow(context.Background(), `select count(*) from mfa_recovery_codes where user_id = $1 and used_at is null`, userID).Scan(&n)
+	return n, err
+}
+
+func (s *postgresStore) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	return pgx.BeginFunc(context.Background(), s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(context.Background(), `delete from mfa_recovery_codes where user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(context.Background(),
+			`insert into mfa_recovery_codes (user_id, code_hash) select $1, unnest($2::text[])`, userID, codeHashes)
+		return err
+	})
+}
+
+func (s *postgresStore) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	return affected(s.pool, `update mfa_recovery_codes set used_at = now() where user_id = $1 and code_hash = $2 and used_at is null`, userID, codeHash)
+}
+
+// Admin console
+
+func (s *postgresStore) FindProfilesByName(name string, limit int) ([]models.Profile, error) {
+	return selectRows[models.Profile](s, s.pool, `select to_jsonb(p) from profiles p
+		where p.full_name ilike '%' || $1 || '%' order by p.created_at desc limit $2`, likeEscape(name), limit)
+}
+
+func (s *postgresStore) FindBuildings(q string, limit int) ([]models.Building, error) {
+	if isUUID(q) {
+		return selectRows[models.Building](s, s.pool, `select to_jsonb(b) from buildings b
+			where b.id = $1 or b.landlord_id = $1 order by b.created_at desc limit $2`, q, limit)
+	}
+	return selectRows[models.Building](s, s.pool, `select to_jsonb(b) from buildings b
+		where b.name ilike '%' || $1 || '%' or b.address ilike '%' || $1 || '%'
+		order by b.created_at desc limit $2`, likeEscape(q), limit)
+}
+
+func (s *postgresStore) FindPayments(q, status string, limit int) ([]PaymentListing, error) {
+	var c conds
+	switch {
+	case isUUID(q):
+		c.add("(p.id = ? or p.tenant_id = ? or p.building_id = ? or p.unit_id = ?)", q, q, q, q)
+	case q != "":
+		c.add("(p.paystack_reference = ? or p.paystack_transaction_id = ?)", q, q)
+	}
+	if status != "" {
+		c.add("p.status = ?", status)
+	}
+	return selectRows[PaymentListing](s, s.pool, `
+		select to_jsonb(p) || jsonb_build_object(
+		  'profiles', (select jsonb_build_object('full_name', t.full_name, 'email', t.email) from profiles t where t.id = p.tenant_id),
+		  'buildings', (select jsonb_build_object('name', b.name) from buildings b where b.id = p.building_id),
+		  'units', (select jsonb_build_object('unit_number', u.unit_number) from units u where u.id = p.unit_id))
+		  from payments p`+c.where()+fmt.Sprintf(" order by p.created_at desc limit %d", limit), c.args...)
+}
+
+func (s *postgresStore) ListWebhookEvents(reference, event string, limit int) ([]models.WebhookEvent, error) {
+	return selectRows[models.WebhookEvent](s, s.pool, `select to_jsonb(e) from webhook_events e
+		where ($1 = '' or e.reference = $1) and ($2 = '' or e.event = $2)
+		order by e.received_at desc limit $3`, reference, event, limit)
+}
+
+func (s *postgresStore) CreateImpersonation(sess models.ImpersonationSession, tokenHash string) (models.ImpersonationSession, error) {
+	return insertRow[models.ImpersonationSession](s, s.pool, "impersonation_sessions", impersonationRow(sess, tokenHash))
+}
+
+func (s *postgresStore) FindImpersonation(tokenHash, adminID string) (models.ImpersonationSession, error) {
+	return selectOne[models.ImpersonationSession](s, s.pool, `select to_jsonb(i) from impersonation_sessions i
+		where i.token_hash = $1 and i.admin_id = $2 and i.ended_at is null`, tokenHash, adminID)
+}
+
+func (s *postgresStore) EndImpersonation(id, adminID string) (models.ImpersonationSession, error) {
+	return selectOne[models.ImpersonationSession](s, s.pool, `update impersonation_sessions i set ended_at = now()
+		where i.id = $1 and i.admin_id = $2 and i.ended_at is null returning to_jsonb(i)`, id, adminID)
+}
+
+// likeEscape makes a search term match literally inside an ilike pattern
+func likeEscape(q string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q)
+}
+
+// Search
+
+// Search calls the same search_portfolio function as the Supabase store
+func (s *postgresStore) Search(q SearchQuery) (SearchResults, error) {
+	if q.empty() {
+		return SearchResults{Tenants: []TenantHit{}, Units: []UnitHit{}, Buildings: []BuildingHit{}, Payments: []PaymentHit{}}, nil
+	}
+	args := map[string]interface{}{}
+	for key, v := range map[string]string{"p_query": q.Text, "p_email": q.Email, "p_phone": q.Phone} {
+		if v != "" {
+			args[key] = v
+		}
+	}
+	if s.cipher != nil {
+		if err := s.cipher.EncryptRow("rpc/search_portfolio", args, encryption.Sensitive["rpc/search_portfolio"]); err != nil {
+			return SearchResults{}, err
+		}
+	}
+
+	return selectOne[SearchResults](s, s.pool,
+		`select search_portfolio(p_building_ids => $1`+uuidList+`, p_financial_ids => $2`+uuidList+`, p_query => $3,
+		                         p_email => $4, p_email_bidx => $5, p_phone => $6, p_phone_bidx => $7, p_limit => $8)`,
+		nonNil(q.BuildingIDs), nonNil(q.FinancialIDs), args["p_query"],
+		args["p_email"], args["p_email_bidx"], args["p_phone"], args["p_phone_bidx"], q.Limit)
+}
+
+// Imports
+
+func (s *postgresStore) CommitImport(imp models.Import, units []models.Unit, payments []models.Payment) (models.Import, error) {
+	unitRows, paymentRows := importRows(units, payments)
+	return callFunction[models.Import](s, `select import_batch($1, $2, $3::jsonb, $4::jsonb)`,
+		imp.BuildingID, imp.CreatedBy, unitRows, paymentRows)
+}
+
+// Stats
+
+func (s *postgresStore) Totals(buildingIDs, financialIDs []string) (Totals, error) {
+	var t Totals
+	err := s.pool.QueryRow(context.Background(), `
+		select u.total, u.occupied, p.collected, p.pending
+		  from (select count(*) as total,
+		               count(*) filter (where status = 'occupied') as occupied
+		          from units where building_id = any($1`+uuidList+`) and archived_at is null) u,
+		       (select coalesce(sum(amount) filter (where status = 'successful'), 0)::bigint as collected,
+		               coalesce(sum(amount) filter (where status = 'pending'), 0)::bigint as pending
+		          from payments where building_id = any($2`+uuidList+`)) p`,
+		nonNil(buildingIDs), nonNil(financialIDs),
+	).Scan(&t.Units, &t.OccupiedUnits, &t.Collected, &t.Pending)
+	return t, err
+}
+
+// nonNil turns a nil list into an empty one, which pgx sends as '{}'
+// rather than NULL
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
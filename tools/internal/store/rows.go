@@ -0,0 +1,308 @@
+package store
+
+import (
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+)
+
+// Rows for inserts, as column → value, shared by the Supabase and Postgres
+// stores. Columns left out keep their database default.
+
+// optional leaves empty strings out of an insert so the column keeps its
+// default (usually NULL)
+func optional(row map[string]interface{}, column string, value *string) {
+	if value != nil && *value != "" {
+		row[column] = *value
+	}
+}
+
+func buildingRow(b models.Building) map[string]interface{} {
+	row := map[string]interface{}{
+		"landlord_id": b.LandlordID,
+		"name":        b.Name,
+		"address":     b.Address,
+	}
+	optional(row, "photo_url", b.PhotoURL)
+	optional(row, "organisation_id", b.OrganisationID)
+	return row
+}
+
+func unitRow(u models.Unit) map[string]interface{} {
+	row := map[string]interface{}{
+		"building_id": u.BuildingID,
+		"unit_number": u.UnitNumber,
+		"rent_amount": u.RentAmount,
+	}
+	optional(row, "lease_start", u.LeaseStart)
+	optional(row, "lease_end", u.LeaseEnd)
+	if u.Status != "" {
+		row["status"] = u.Status
+	}
+	return row
+}
+
+func paymentRow(p models.Payment) map[string]interface{} {
+	row := map[string]interface{}{
+		"unit_id":     p.UnitID,
+		"building_id": p.BuildingID,
+		"amount":      p.Amount,
+		"currency":    p.Currency,
+		"status":      p.Status,
+		"period":      p.Period,
+	}
+	optional(row, "payment_method", p.PaymentMethod)
+	optional(row, "paystack_reference", p.PaystackReference)
+	optional(row, "recorded_by", p.RecordedBy)
+	optional(row, "import_id", p.ImportID)
+	optional(row, "tenancy_id", p.TenancyID)
+	if p.TenantID != "" {
+		row["tenant_id"] = p.TenantID
+	}
+	if p.PaidAt != nil {
+		row["paid_at"] = *p.PaidAt
+	}
+	return row
+}
+
+// importRows are the units and payments of an import as import_batch takes
+// them: units keep their IDs, payments refer to them
+func importRows(units []models.Unit, payments []models.Payment) (u, p []map[string]interface{}) {
+	u = make([]map[string]interface{}, len(units))
+	for i, unit := range units {
+		u[i] = unitRow(unit)
+		u[i]["id"] = unit.ID
+	}
+	p = make([]map[string]interface{}, len(payments))
+	for i, payment := range payments {
+		p[i] = paymentRow(payment)
+	}
+	return u, p
+}
+
+func webhookEventRow(e models.WebhookEvent) map[string]interface{} {
+	row := map[string]interface{}{
+		"provider":        e.Provider,
+		"event":           e.Event,
+		"reference":       e.Reference,
+		"payload":         e.Payload,
+		"signature_valid": e.SignatureValid,
+	}
+	return row
+}
+
+func invitationRow(inv models.Invitation) map[string]interface{} {
+	row := map[string]interface{}{
+		"unit_id":     inv.UnitID,
+		"landlord_id": inv.LandlordID,
+		"token":       inv.Token,
+		"status":      inv.Status,
+	}
+	optional(row, "invited_by", inv.InvitedBy)
+	optional(row, "email", inv.Email)
+	optional(row, "phone", inv.Phone)
+	return row
+}
+
+func maintenanceRow(m models.MaintenanceRequest) map[string]interface{} {
+	row := map[string]interface{}{
+		"tenant_id":   m.TenantID,
+		"unit_id":     m.UnitID,
+		"building_id": m.BuildingID,
+		"title":       m.Title,
+		"description": m.Description,
+		"priority":    m.Priority,
+		"status":      m.Status,
+	}
+	optional(row, "tenancy_id", m.TenancyID)
+	return row
+}
+
+func documentRow(d models.Document) map[string]interface{} {
+	row := map[string]interface{}{
+		"uploaded_by": d.UploadedBy,
+		"name":        d.Name,
+		"type":        d.Type,
+		"file_url":    d.FileURL,
+	}
+	optional(row, "building_id", d.BuildingID)
+	optional(row, "unit_id", d.UnitID)
+	optional(row, "tenancy_id", d.TenancyID)
+	if d.FileSize > 0 {
+		row["file_size"] = d.FileSize
+	}
+	return row
+}
+
+func profileRow(p models.Profile) map[string]interface{} {
+	row := map[string]interface{}{
+		"id":        p.ID,
+		"role":      p.Role,
+		"roles":     p.Roles,
+		"full_name": p.FullName,
+	}
+	optional(row, "email", &p.Email)
+	optional(row, "phone", p.Phone)
+	if p.EmailVerifiedAt != nil {
+		row["email_verified_at"] = *p.EmailVerifiedAt
+	}
+	if p.PhoneVerifiedAt != nil {
+		row["phone_verified_at"] = *p.PhoneVerifiedAt
+	}
+	return row
+}
+
+func ownerRows(buildingID string, owners []models.BuildingOwner) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(owners))
+	for i, o := range owners {
+		rows[i] = map[string]interface{}{
+			"building_id": buildingID,
+			"user_id":     o.UserID,
+			"share_bps":   o.ShareBps,
+			"managing":    o.Managing,
+		}
+	}
+	return rows
+}
+
+func organisationRow(o models.Organisation) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       o.Name,
+		"created_by": o.CreatedBy,
+	}
+}
+
+// creatorRow makes an organisation's creator its first admin
+func creatorRow(o models.Organisation) map[string]interface{} {
+	return map[string]interface{}{
+		"organisation_id": o.ID,
+		"user_id":         o.CreatedBy,
+		"role":            "admin",
+		"status":          "active",
+		"invited_by":      o.CreatedBy,
+		"expires_at":      o.CreatedAt,
+		"accepted_at":     o.CreatedAt,
+	}
+}
+
+func staffInviteRow(m models.BuildingMember, tokenHash string) map[string]interface{} {
+	row := inviteRow(m.Role, m.InvitedBy, m.Email, m.Phone, m.ExpiresAt, tokenHash)
+	row["building_id"] = m.BuildingID
+	return row
+}
+
+func organisationInviteRow(m models.OrganisationMember, tokenHash string) map[string]interface{} {
+	row := inviteRow(m.Role, m.InvitedBy, m.Email, m.Phone, m.ExpiresAt, tokenHash)
+	row["organisation_id"] = m.OrganisationID
+	return row
+}
+
+func inviteRow(role, invitedBy string, email, phone *string, expiresAt time.Time, tokenHash string) map[string]interface{} {
+	row := map[string]interface{}{
+		"role":       role,
+		"status":     "invited",
+		"invited_by": invitedBy,
+		"token_hash": tokenHash,
+	}
+	optional(row, "email", email)
+	optional(row, "phone", phone)
+	if !expiresAt.IsZero() {
+		row["expires_at"] = expiresAt
+	}
+	return row
+}
+
+// apiKeyColumns are the api_keys columns read back, leaving out the hash
+const apiKeyColumns = "id, user_id, name, prefix, scopes, rate_limit_per_minute, expires_at, last_used_at, revoked_at, created_at"
+
+func apiKeyRow(k models.APIKey, keyHash string) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":               k.UserID,
+		"name":                  k.Name,
+		"prefix":                k.Prefix,
+		"key_hash":              keyHash,
+		"scopes":                k.Scopes,
+		"rate_limit_per_minute": k.RateLimitPerMinute,
+		"expires_at":            k.ExpiresAt,
+	}
+}
+
+// auditRow leaves out empty changes and metadata, which stay NULL
+func auditRow(e models.AuditEntry) map[string]interface{} {
+	row := map[string]interface{}{
+		"actor_role":    e.ActorRole,
+		"action":        e.Action,
+		"resource_type": e.ResourceType,
+		"ip":            e.IP,
+		"user_agent":    e.UserAgent,
+	}
+	optional(row, "actor_id", e.ActorID)
+	optional(row, "resource_id", e.ResourceID)
+	optional(row, "building_id", e.BuildingID)
+	optional(row, "request_id", e.RequestID)
+	if len(e.Changes) > 0 {
+		row["changes"] = e.Changes
+	}
+	if len(e.Metadata) > 0 {
+		row["metadata"] = e.Metadata
+	}
+	return row
+}
+
+func notificationRow(n models.Notification) map[string]interface{} {
+	row := map[string]interface{}{
+		"channel": n.Channel,
+		"type":    n.Type,
+		"payload": n.Payload,
+		"sent_at": n.SentAt,
+		"status":  n.Status,
+	}
+	optional(row, "user_id", &n.UserID)
+	return row
+}
+
+func impersonationRow(sess models.ImpersonationSession, tokenHash string) map[string]interface{} {
+	return map[string]interface{}{
+		"admin_id":   sess.AdminID,
+		"user_id":    sess.UserID,
+		"reason":     sess.Reason,
+		"token_hash": tokenHash,
+		"expires_at": sess.ExpiresAt,
+	}
+}
+
+func otpRow(o models.PhoneOTP) map[string]interface{} {
+	return map[string]interface{}{
+		"phone":      o.Phone,
+		"code_hash":  o.CodeHash,
+		"attempts":   o.Attempts,
+		"expires_at": o.ExpiresAt,
+	}
+}
+
+func authTokenRow(t models.AuthToken) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":    t.UserID,
+		"purpose":    t.Purpose,
+		"token_hash": t.TokenHash,
+		"expires_at": t.ExpiresAt,
+	}
+}
+
+func mfaRow(m models.UserMFA) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":               m.UserID,
+		"secret":                m.Secret,
+		"enabled_at":            m.EnabledAt,
+		"last_used_step":        m.LastUsedStep,
+		"require_for_sensitive": m.RequireForSensitive,
+	}
+}
+
+func recoveryCodeRows(userID string, codeHashes []string) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(codeHashes))
+	for i, h := range codeHashes {
+		rows[i] = map[string]interface{}{"user_id": userID, "code_hash": h}
+	}
+	return rows
+}
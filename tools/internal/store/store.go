@@ -0,0 +1,653 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when a lookup by ID, token or reference
+	// matches nothing
+	ErrNotFound = errors.New("not found")
+	// ErrInvitationUnavailable is returned when accepting an invitation
+	// that is no longer pending or has expired
+	ErrInvitationUnavailable = errors.New("invitation is no longer pending")
+	// ErrUnitOccupied is returned when accepting an invitation for a unit
+	// that already has another tenant, and when archiving a unit (or a
+	// building with a unit) that has one
+	ErrUnitOccupied = errors.New("unit already has a tenant")
+	// ErrUnitNumberTaken is returned when a unit would get a number
+	// already used in its building
+	ErrUnitNumberTaken = errors.New("unit number already used in the building")
+	// ErrTenancyEnded is returned when ending a tenancy that has already
+	// ended
+	ErrTenancyEnded = errors.New("tenancy has already ended")
+)
+
+// Store is the data layer used by the handlers. NewSupabase backs it with
+// PostgREST, NewPostgres with a direct database connection; NewMemory keeps
+// everything in process for tests.
+type Store struct {
+	Buildings     BuildingStore
+	Units         UnitStore
+	Tenancies     TenancyStore
+	Payments      PaymentStore
+	Invitations   InvitationStore
+	Maintenance   MaintenanceStore
+	Documents     DocumentStore
+	Profiles      ProfileStore
+	Organisations OrganisationStore
+	Staff         StaffStore
+	Stats         StatsStore
+	Search        SearchStore
+	Imports       ImportStore
+	Owners        OwnerStore
+	APIKeys       APIKeyStore
+	Audit         AuditStore
+	Notifications NotificationStore
+	OTPs          OTPStore
+	AuthTokens    AuthTokenStore
+	Lockouts      LockoutStore
+	MFA           MFAStore
+	Admin         AdminStore
+}
+
+// Updates take a map of column → value, like PostgREST's PATCH: only the
+// columns present are changed. List methods take ListOptions for paging,
+// sorting and the caller's filters on top of their own scope.
+
+type BuildingStore interface {
+	// ListBuildings returns the buildings with the given IDs, newest first
+	ListBuildings(ids []string, opts ListOptions) (Page[models.Building], error)
+	// BuildingsByID returns every building with the given IDs, newest
+	// first and unpaged; archived ones only with includeArchived
+	BuildingsByID(ids []string, includeArchived bool) ([]models.Building, error)
+	GetBuilding(id string) (models.Building, error)
+	CreateBuilding(b models.Building) (models.Building, error)
+	// CreateBuildingWithUnits creates a building with its first units, all
+	// or nothing; the units get the new building's ID. It fails with
+	// ErrUnitNumberTaken when two units share a number.
+	CreateBuildingWithUnits(b models.Building, units []models.Unit) (models.Building, []models.Unit, error)
+	UpdateBuilding(id string, fields map[string]interface{}) (models.Building, error)
+	// ArchiveBuilding archives a building together with its units and
+	// expires their pending invitations. It fails with ErrUnitOccupied,
+	// changing nothing, while any of its units has a tenant.
+	ArchiveBuilding(id string) (models.Building, error)
+	// RestoreBuilding restores a building and the units archived with it;
+	// units archived on their own before stay archived
+	RestoreBuilding(id string) (models.Building, error)
+	// LandlordBuildings returns every building the user is the landlord
+	// of, archived ones included, newest first
+	LandlordBuildings(landlordID string) ([]models.Building, error)
+}
+
+type UnitStore interface {
+	// ListUnits returns a building's units by unit number, with tenants
+	ListUnits(buildingID string, opts ListOptions) (Page[UnitListing], error)
+	GetUnit(id string) (models.Unit, error)
+	// CreateUnit fails with ErrUnitNumberTaken when the unit's number is
+	// already used in its building
+	CreateUnit(u models.Unit) (models.Unit, error)
+	// CreateUnits creates several units, all or nothing. It fails with
+	// ErrUnitNumberTaken when a unit number is already used.
+	CreateUnits(units []models.Unit) ([]models.Unit, error)
+	UpdateUnit(id string, fields map[string]interface{}) (models.Unit, error)
+	// ArchiveUnit archives a unit and expires its pending invitations. It
+	// fails with ErrUnitOccupied while the unit has a tenant. Units are
+	// restored with UpdateUnit.
+	ArchiveUnit(id string) (models.Unit, error)
+}
+
+type TenancyStore interface {
+	// ListTenancies returns a unit's tenancies, newest first, with tenants
+	ListTenancies(unitID string) ([]TenancyListing, error)
+	GetTenancy(id string) (models.Tenancy, error)
+	// ActiveTenancy returns the unit's current tenancy, or ErrNotFound
+	// while it is vacant
+	ActiveTenancy(unitID string) (models.Tenancy, error)
+	// TenanciesForTenant returns a tenant's tenancies, newest first
+	TenanciesForTenant(tenantID string) ([]models.Tenancy, error)
+	UpdateTenancy(id string, fields map[string]interface{}) (models.Tenancy, error)
+	// EndTenancy ends an active tenancy on endDate (YYYY-MM-DD) and frees
+	// its unit. It fails with ErrTenancyEnded when it has already ended.
+	EndTenancy(id, endDate string) (models.Tenancy, error)
+}
+
+type PaymentStore interface {
+	// ListPayments returns matching payments, newest first
+	ListPayments(f PaymentFilter, opts ListOptions) (Page[PaymentListing], error)
+	GetPayment(id string) (models.Payment, error)
+	FindPaymentByReference(reference string) (models.Payment, error)
+	CreatePayment(p models.Payment) (models.Payment, error)
+	// UpdateUnsettledPayment changes a payment unless it is already
+	// successful; ok is false when it was (or it does not exist)
+	UpdateUnsettledPayment(id string, fields map[string]interface{}) (p models.Payment, ok bool, err error)
+	RecordWebhookEvent(e models.WebhookEvent) (models.WebhookEvent, error)
+	UpdateWebhookEvent(id string, fields map[string]interface{}) error
+}
+
+type InvitationStore interface {
+	// ListInvitations returns invitations for units in the buildings, newest first
+	ListInvitations(buildingIDs []string, opts ListOptions) (Page[InvitationListing], error)
+	GetPendingInvitation(token string) (models.Invitation, error)
+	// GetInvitationDetails returns a pending invitation with what the
+	// acceptance page shows about the unit and building
+	GetInvitationDetails(token string) (InvitationDetails, error)
+	// GetInvitation returns an invitation by ID in any status, with the
+	// same details
+	GetInvitation(id string) (InvitationDetails, error)
+	CreateInvitation(inv models.Invitation) (models.Invitation, error)
+	// AcceptInvitation starts the tenant's tenancy of the invited unit,
+	// creates or updates their profile, marks the invitation accepted and
+	// gives the tenancy the unit's imported payments that were waiting for
+	// one, all or nothing. It fails with ErrInvitationUnavailable when the invitation
+	// was accepted or expired meanwhile, and ErrUnitOccupied when the unit
+	// already has another tenant.
+	AcceptInvitation(a Acceptance) (Accepted, error)
+	// EraseInvitationContacts pseudonymises invitations addressed to an
+	// erased user's email and clears their phone from the rest
+	EraseInvitationContacts(e ContactErasure) error
+}
+
+type MaintenanceStore interface {
+	// ListMaintenanceRequests returns matching requests, newest first
+	ListMaintenanceRequests(f MaintenanceFilter, opts ListOptions) (Page[MaintenanceListing], error)
+	GetMaintenanceRequest(id string) (models.MaintenanceRequest, error)
+	CreateMaintenanceRequest(m models.MaintenanceRequest) (models.MaintenanceRequest, error)
+	UpdateMaintenanceRequest(id string, fields map[string]interface{}) (models.MaintenanceRequest, error)
+}
+
+type DocumentStore interface {
+	// ListDocuments returns matching documents, newest first
+	ListDocuments(f DocumentFilter, opts ListOptions) (Page[models.Document], error)
+	CreateDocument(d models.Document) (models.Document, error)
+}
+
+type ProfileStore interface {
+	GetProfile(id string) (models.Profile, error)
+	// ListProfiles returns the profiles with the given IDs, in no
+	// particular order
+	ListProfiles(ids []string) ([]models.Profile, error)
+	// FindProfileByEmail and FindProfileByPhone (E.164) return the profile
+	// with that contact, or ErrNotFound. Both columns are encrypted, so
+	// they are matched through their blind indexes.
+	FindProfileByEmail(email string) (models.Profile, error)
+	FindProfileByPhone(phone string) (models.Profile, error)
+	// CreateProfile stores a new user's profile, under the ID of their
+	// auth account
+	CreateProfile(p models.Profile) (models.Profile, error)
+	UpdateProfile(id string, fields map[string]interface{}) (models.Profile, error)
+	// MarkEmailVerified records when the email was verified, unless it
+	// already was
+	MarkEmailVerified(id string, at time.Time) error
+	// UpdateRoles replaces the roles held; defaultRole "" keeps the current default
+	UpdateRoles(id string, roles []string, defaultRole string) error
+}
+
+// OrganisationStore keeps organisations and their members. Members are
+// invited by email or phone and found by the hash of their invite token,
+// which is cleared once the invite is accepted or revoked.
+type OrganisationStore interface {
+	// OrganisationRole returns the user's role in an organisation they are
+	// an active member of, or "" if they are not
+	OrganisationRole(orgID, userID string) (string, error)
+	// OrganisationRoles returns organisation ID → role for each
+	// organisation the user is an active member of
+	OrganisationRoles(userID string) (map[string]string, error)
+	GetOrganisation(id string) (models.Organisation, error)
+	// ListOrganisations returns the organisations with the given IDs, by name
+	ListOrganisations(ids []string) ([]models.Organisation, error)
+	// CreateOrganisation creates an organisation with its creator as an
+	// active admin member
+	CreateOrganisation(o models.Organisation) (models.Organisation, error)
+	// OrganisationBuildings returns the buildings an organisation manages,
+	// by name; only landlordID's when it is set
+	OrganisationBuildings(orgID, landlordID string) ([]models.Building, error)
+	// ListOrganisationMembers returns an organisation's invited and active
+	// members, oldest first, with their profiles
+	ListOrganisationMembers(orgID string) ([]OrganisationMemberListing, error)
+	// OrganisationMemberExists reports whether the organisation has an
+	// invited or active member with the email, or without one the phone
+	OrganisationMemberExists(orgID, email, phone string) (bool, error)
+	CreateOrganisationInvite(m models.OrganisationMember, tokenHash string) (models.OrganisationMember, error)
+	// FindOrganisationInvite returns the invited member with the token
+	// hash, or ErrNotFound
+	FindOrganisationInvite(tokenHash string) (models.OrganisationMember, error)
+	// AcceptOrganisationInvite makes an invited member the user's, active;
+	// ErrNotFound when it is no longer invited
+	AcceptOrganisationInvite(id, userID string) (models.OrganisationMember, error)
+	// RevokeOrganisationMember revokes a member of the organisation other
+	// than exceptUserID; ErrNotFound when there is no such live member
+	RevokeOrganisationMember(orgID, id, exceptUserID string) (models.OrganisationMember, error)
+	// RevokeOrganisationAccess revokes all of a user's memberships
+	RevokeOrganisationAccess(userID string) error
+	// EraseOrganisationContacts pseudonymises an erased user's email and
+	// clears their phone on memberships, their own and those addressed to them
+	EraseOrganisationContacts(e ContactErasure) error
+}
+
+// StaffStore keeps building staff, invited and found like organisation
+// members
+type StaffStore interface {
+	// ListStaff returns a building's invited and active staff, oldest
+	// first, with their profiles
+	ListStaff(buildingID string) ([]StaffListing, error)
+	// StaffMemberExists reports whether the building has invited or active
+	// staff with the email, or without one the phone
+	StaffMemberExists(buildingID, email, phone string) (bool, error)
+	CreateStaffInvite(m models.BuildingMember, tokenHash string) (models.BuildingMember, error)
+	// FindStaffInvite returns the invited member with the token hash, or
+	// ErrNotFound
+	FindStaffInvite(tokenHash string) (models.BuildingMember, error)
+	// AcceptStaffInvite makes an invited member the user's, active;
+	// ErrNotFound when it is no longer invited
+	AcceptStaffInvite(id, userID string) (models.BuildingMember, error)
+	// RevokeStaff revokes a member of the building; ErrNotFound when there
+	// is no such live member
+	RevokeStaff(buildingID, id string) (models.BuildingMember, error)
+	// StaffRoles returns building ID → role for the user's active memberships
+	StaffRoles(userID string) (map[string]string, error)
+	// RevokeStaffAccess revokes all of a user's memberships
+	RevokeStaffAccess(userID string) error
+	// EraseStaffContacts is EraseOrganisationContacts for building staff
+	EraseStaffContacts(e ContactErasure) error
+}
+
+type StatsStore interface {
+	// Totals counts the units of buildingIDs, leaving out archived ones,
+	// and sums the payments of financialIDs (the buildings whose financials
+	// the caller may view)
+	Totals(buildingIDs, financialIDs []string) (Totals, error)
+}
+
+type SearchStore interface {
+	// Search finds tenants, units and buildings in q.BuildingIDs and
+	// payments in q.FinancialIDs, each group best match first
+	Search(q SearchQuery) (SearchResults, error)
+}
+
+type ImportStore interface {
+	// CommitImport creates an import's units and payments in one
+	// transaction, recording the batch as the payments' ImportID. Units
+	// come with their IDs set, so payments can refer to them. It fails with
+	// ErrUnitNumberTaken, changing nothing, when a unit number is taken.
+	CommitImport(imp models.Import, units []models.Unit, payments []models.Payment) (models.Import, error)
+}
+
+type OwnerStore interface {
+	// ListOwners returns a building's co-owners in the order they were
+	// added; none when its landlord owns it outright
+	ListOwners(buildingID string) ([]models.BuildingOwner, error)
+	// ReplaceOwners swaps a building's co-owners for owners and returns
+	// them as stored
+	ReplaceOwners(buildingID string, owners []models.BuildingOwner) ([]models.BuildingOwner, error)
+	// ListOwnerships returns the user's co-ownerships of any building
+	ListOwnerships(userID string) ([]models.BuildingOwner, error)
+}
+
+type APIKeyStore interface {
+	// CreateAPIKey stores a key under the hash of its secret
+	CreateAPIKey(k models.APIKey, keyHash string) (models.APIKey, error)
+	// ListAPIKeys returns a user's keys, newest first
+	ListAPIKeys(userID string) ([]models.APIKey, error)
+	// RevokeAPIKey revokes one of the user's keys; ErrNotFound when they
+	// have no such key that is still live
+	RevokeAPIKey(id, userID string) (models.APIKey, error)
+	// FindAPIKey returns the live key with the hash, expired or not, or
+	// ErrNotFound
+	FindAPIKey(keyHash string) (models.APIKey, error)
+	// TouchAPIKey records when a key was last used
+	TouchAPIKey(id string, at time.Time) error
+	// RevokeAPIKeys revokes all of a user's live keys
+	RevokeAPIKeys(userID string) error
+}
+
+type AuditStore interface {
+	// ListAuditEntries returns matching entries newest first, skipping
+	// offset and returning at most limit
+	ListAuditEntries(f AuditFilter, offset, limit int) ([]models.AuditEntry, error)
+	// RecordAuditEntry appends an entry; ID and CreatedAt are set by the store
+	RecordAuditEntry(e models.AuditEntry) error
+}
+
+// NotificationStore logs every email and SMS the notifier attempts
+type NotificationStore interface {
+	// RecordNotification appends an attempt; ID is set by the store
+	RecordNotification(n models.Notification) error
+}
+
+// OTPStore keeps the SMS codes sent to phone numbers (E.164)
+type OTPStore interface {
+	// RecentOTPs returns when codes were sent to phone since a time,
+	// newest first
+	RecentOTPs(phone string, since time.Time) ([]time.Time, error)
+	// CreateOTP stores a new code, consuming the phone's earlier ones
+	CreateOTP(o models.PhoneOTP) error
+	// LatestOTP returns the phone's newest unconsumed code, or ErrNotFound
+	LatestOTP(phone string) (models.PhoneOTP, error)
+	// TakeOTPAttempt counts a guess at a code before it is compared, in one
+	// conditional update; false when the code has no attempts left out of
+	// max, or is consumed or expired
+	TakeOTPAttempt(id string, max int) (bool, error)
+	// ConsumeOTP marks a code used; false when it already was
+	ConsumeOTP(id string) (bool, error)
+	// DeleteOTPs removes every code sent to phone
+	DeleteOTPs(phone string) error
+}
+
+type AuthTokenStore interface {
+	// CreateAuthToken stores a new token, revoking the user's unused ones
+	// for the same purpose
+	CreateAuthToken(t models.AuthToken) error
+	// FindAuthToken returns the token with the hash for purpose, used or
+	// not, or ErrNotFound
+	FindAuthToken(tokenHash, purpose string) (models.AuthToken, error)
+	// UseAuthToken marks a token used; false when it already was
+	UseAuthToken(id string) (bool, error)
+	// TakeAuthTokenAttempt counts one guess against an unused, unexpired
+	// token in a single conditional update; false once max are spent
+	TakeAuthTokenAttempt(id string, max int) (bool, error)
+	// DeleteAuthTokens removes all of a user's tokens, used or not
+	DeleteAuthTokens(userID string) error
+}
+
+type LockoutStore interface {
+	// GetLoginLockout returns an email's failure count, or ErrNotFound
+	GetLoginLockout(email string) (models.LoginLockout, error)
+	// RecordLoginFailure counts a failure for an email and locks it out
+	// under policy, in one atomic step so concurrent failures are all
+	// counted. It returns the updated lockout.
+	RecordLoginFailure(email string, policy LockoutPolicy) (models.LoginLockout, error)
+	ClearLoginLockout(email string) error
+}
+
+// LockoutPolicy says when RecordLoginFailure locks a key out: after
+// Threshold consecutive failures for Base, doubling with each further
+// failure up to Max. Failures older than Window are forgotten.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// lockFor is how long the count'th failure in a row locks a key out, or 0
+func (p LockoutPolicy) lockFor(count int) time.Duration {
+	if count < p.Threshold {
+		return 0
+	}
+	return min(p.Base<<min(count-p.Threshold, 6), p.Max)
+}
+
+type MFAStore interface {
+	// GetMFA returns a user's two-factor settings, or ErrNotFound
+	GetMFA(userID string) (models.UserMFA, error)
+	// PutMFA creates or replaces a user's two-factor settings
+	PutMFA(m models.UserMFA) error
+	UpdateMFA(userID string, fields map[string]interface{}) error
+	// AdvanceMFAStep records step as the last TOTP step used, unless it is
+	// not after the one already recorded; false then. This makes each code
+	// single-use even under concurrent requests.
+	AdvanceMFAStep(userID string, step int64) (bool, error)
+	// DeleteMFA removes a user's settings and recovery codes
+	DeleteMFA(userID string) error
+	// CountRecoveryCodes counts a user's unused recovery codes
+	CountRecoveryCodes(userID string) (int, error)
+	// ReplaceRecoveryCodes swaps a user's recovery codes for new ones,
+	// given by hash
+	ReplaceRecoveryCodes(userID string, codeHashes []string) error
+	// UseRecoveryCode marks the user's unused code with the hash used;
+	// false when there is none
+	UseRecoveryCode(userID, codeHash string) (bool, error)
+}
+
+// AdminStore serves the platform support console. Searches return at most
+// limit rows, newest first.
+type AdminStore interface {
+	// FindProfilesByName returns profiles whose name contains name,
+	// ignoring case
+	FindProfilesByName(name string, limit int) ([]models.Profile, error)
+	// FindBuildings matches q as a building or landlord ID when it is a
+	// UUID, else against names and addresses, ignoring case
+	FindBuildings(q string, limit int) ([]models.Building, error)
+	// FindPayments matches q as a payment, tenant, building or unit ID when
+	// it is a UUID, else as a Paystack reference or transaction ID. An
+	// empty q or status matches any.
+	FindPayments(q, status string, limit int) ([]PaymentListing, error)
+	// ListWebhookEvents returns webhook deliveries by received time; an
+	// empty reference or event matches any
+	ListWebhookEvents(reference, event string, limit int) ([]models.WebhookEvent, error)
+	// CreateImpersonation stores a session under the hash of its token
+	CreateImpersonation(sess models.ImpersonationSession, tokenHash string) (models.ImpersonationSession, error)
+	// FindImpersonation returns the admin's session with the token hash
+	// that has not been ended, expired or not, or ErrNotFound
+	FindImpersonation(tokenHash, adminID string) (models.ImpersonationSession, error)
+	// EndImpersonation ends one of the admin's sessions; ErrNotFound when
+	// it has already ended or is not theirs
+	EndImpersonation(id, adminID string) (models.ImpersonationSession, error)
+}
+
+// isUUID reports whether an admin search term is an ID
+func isUUID(q string) bool {
+	_, err := uuid.Parse(q)
+	return err == nil
+}
+
+// Totals are the landlord dashboard figures. Amounts are in kobo.
+type Totals struct {
+	Units         int
+	OccupiedUnits int
+	Collected     int64 // successful payments
+	Pending       int64
+}
+
+// PaymentFilter scopes ListPayments to what the caller may see. Empty
+// fields match everything, but an empty (non-nil) BuildingIDs matches
+// nothing.
+type PaymentFilter struct {
+	TenantID    string
+	BuildingIDs []string
+}
+
+// MaintenanceFilter narrows ListMaintenanceRequests like PaymentFilter
+type MaintenanceFilter struct {
+	TenantID    string
+	BuildingIDs []string
+}
+
+// DocumentFilter narrows ListDocuments. Tenants see their own uploads
+// (UploadedBy) plus the documents of their tenancies (TenancyIDs); staff see
+// their own uploads plus anything in BuildingIDs.
+type DocumentFilter struct {
+	UploadedBy  string
+	TenancyIDs  []string
+	BuildingIDs []string
+}
+
+// AuditFilter narrows ListAuditEntries. A nil BuildingIDs matches entries
+// about any building or none (admins); Equal matches columns exactly;
+// From and To bound created_at to [From, To) when set.
+type AuditFilter struct {
+	BuildingIDs []string
+	Equal       map[string]string
+	From, To    time.Time
+}
+
+// ContactErasure is what an account erasure scrubs from rows that copied
+// the user's contact details: their email becomes Pseudonym and their phone
+// is cleared. Phone is "" when they had none.
+type ContactErasure struct {
+	UserID    string
+	Email     string
+	Phone     string
+	Pseudonym string
+}
+
+// The listing types below carry the related rows PostgREST embeds, under
+// the same keys, so API responses keep their shape.
+
+// TenantSummary is the profile shown with a unit's tenant or a staff member
+type TenantSummary struct {
+	FullName       string  `json:"full_name"`
+	Email          string  `json:"email"`
+	Phone          *string `json:"phone"`
+	AvatarThumbURL *string `json:"avatar_thumb_url"`
+}
+
+type PersonRef struct {
+	FullName       string  `json:"full_name"`
+	Email          string  `json:"email,omitempty"` // only in admin searches
+	AvatarThumbURL *string `json:"avatar_thumb_url,omitempty"`
+}
+
+type BuildingRef struct {
+	Name     string  `json:"name"`
+	Address  string  `json:"address,omitempty"`
+	PhotoURL *string `json:"photo_url,omitempty"`
+}
+
+type UnitRef struct {
+	UnitNumber string `json:"unit_number"`
+	BuildingID string `json:"building_id,omitempty"`
+	RentAmount int64  `json:"rent_amount,omitempty"`
+}
+
+type UnitListing struct {
+	models.Unit
+	Tenant *TenantSummary `json:"profiles"`
+}
+
+type TenancyListing struct {
+	models.Tenancy
+	Tenant *TenantSummary `json:"profiles"`
+}
+
+type PaymentListing struct {
+	models.Payment
+	Tenant   *PersonRef   `json:"profiles"`
+	Building *BuildingRef `json:"buildings"`
+	Unit     *UnitRef     `json:"units"`
+}
+
+type MaintenanceListing struct {
+	models.MaintenanceRequest
+	Tenant   *PersonRef   `json:"profiles"`
+	Unit     *UnitRef     `json:"units"`
+	Building *BuildingRef `json:"buildings"`
+}
+
+type StaffListing struct {
+	models.BuildingMember
+	Profile *TenantSummary `json:"profiles"`
+}
+
+type OrganisationMemberListing struct {
+	models.OrganisationMember
+	Profile *TenantSummary `json:"profiles"`
+}
+
+type InvitationListing struct {
+	models.Invitation
+	Unit *UnitRef `json:"units"`
+}
+
+// Acceptance is a tenant taking up an invitation. NewProfile is set when
+// the tenant signed up through the invitation and has no profile yet;
+// otherwise the tenant role is added to their existing profile.
+type Acceptance struct {
+	Token      string // the invitation's token
+	TenantID   string
+	NewProfile *models.Profile
+}
+
+// Accepted is the outcome of an Acceptance
+type Accepted struct {
+	UnitID     string   `json:"unit_id"`
+	BuildingID string   `json:"building_id"`
+	TenancyID  string   `json:"tenancy_id"`
+	Roles      []string `json:"roles"` // the tenant's roles afterwards
+}
+
+// InvitationDetails nests the building under the unit, as the invite page
+// has always received it
+type InvitationDetails struct {
+	models.Invitation
+	Unit      *UnitRef `json:"units"`
+	Buildings *struct {
+		Building *BuildingRef `json:"buildings"`
+	} `json:"buildings"`
+}
+
+// SearchQuery is a portfolio search. Text is matched by similarity against
+// tenant names, unit numbers, building names and addresses and payment
+// references (or a payment ID exactly). Email and Phone (E.164) match
+// tenants exactly: they are stored encrypted. Limit caps each group.
+type SearchQuery struct {
+	Text         string
+	Email        string
+	Phone        string
+	BuildingIDs  []string
+	FinancialIDs []string
+	Limit        int
+}
+
+func (q SearchQuery) empty() bool {
+	return len(q.BuildingIDs) == 0 && len(q.FinancialIDs) == 0 || q.Text == "" && q.Email == "" && q.Phone == ""
+}
+
+// SearchResults are grouped by type. Rank runs from 0 to 1, where 1 is an
+// exact match.
+type SearchResults struct {
+	Tenants   []TenantHit   `json:"tenants"`
+	Units     []UnitHit     `json:"units"`
+	Buildings []BuildingHit `json:"buildings"`
+	Payments  []PaymentHit  `json:"payments"`
+}
+
+// TenantHit is a tenant with the unit they rent; a tenant of two units
+// appears once for each
+type TenantHit struct {
+	ID           string  `json:"id"`
+	FullName     string  `json:"full_name"`
+	Email        string  `json:"email"`
+	Phone        *string `json:"phone"`
+	UnitID       string  `json:"unit_id"`
+	UnitNumber   string  `json:"unit_number"`
+	BuildingID   string  `json:"building_id"`
+	BuildingName string  `json:"building_name"`
+	Rank         float64 `json:"rank"`
+}
+
+type UnitHit struct {
+	ID           string  `json:"id"`
+	UnitNumber   string  `json:"unit_number"`
+	Status       string  `json:"status"`
+	BuildingID   string  `json:"building_id"`
+	BuildingName string  `json:"building_name"`
+	TenantName   *string `json:"tenant_name"`
+	Rank         float64 `json:"rank"`
+}
+
+type BuildingHit struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Address string  `json:"address"`
+	Rank    float64 `json:"rank"`
+}
+
+type PaymentHit struct {
+	ID                string     `json:"id"`
+	PaystackReference *string    `json:"paystack_reference"`
+	Amount            int64      `json:"amount"`
+	Status            string     `json:"status"`
+	Period            string     `json:"period"`
+	PaidAt            *time.Time `json:"paid_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	TenantName        *string    `json:"tenant_name"`
+	UnitNumber        string     `json:"unit_number"`
+	BuildingName      string     `json:"building_name"`
+	Rank              float64    `json:"rank"`
+}
@@ -0,0 +1,1115 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aletheia/backend/internal/models"
+	postgrest "github.com/supabase-community/postgrest-go"
+	supabase "github.com/supabase-community/supabase-go"
+)
+
+// supabaseStore implements every store interface over PostgREST
+type supabaseStore struct {
+	client *supabase.Client
+}
+
+// NewSupabase returns a Store backed by the Supabase REST API
+func NewSupabase(client *supabase.Client) *Store {
+	s := &supabaseStore{client: client}
+	return &Store{
+		Buildings:     s,
+		Units:         s,
+		Tenancies:     s,
+		Payments:      s,
+		Invitations:   s,
+		Maintenance:   s,
+		Documents:     s,
+		Profiles:      s,
+		Organisations: s,
+		Staff:         s,
+		Stats:         s,
+		Search:        s,
+		Imports:       s,
+		Owners:        s,
+		APIKeys:       s,
+		Audit:         s,
+		Notifications: s,
+		OTPs:          s,
+		AuthTokens:    s,
+		Lockouts:      s,
+		MFA:           s,
+		Admin:         s,
+	}
+}
+
+// decode unmarshals a PostgREST array response
+func decode[T any](data []byte, err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	var rows []T
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// rpc runs a database function that returns a row, turning the errors it
+// raises by name (not_found, unit_occupied, unit_number_taken,
+// tenancy_ended) into the store's
+func rpc[T any](s *supabaseStore, name string, args map[string]interface{}) (T, error) {
+	var zero T
+	raw := s.client.Rpc(name, "", args)
+	var failure struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &failure); err != nil {
+		return zero, fmt.Errorf("store: unexpected %s response: %.200s", name, raw)
+	}
+	switch {
+	case failure.Message == "not_found":
+		return zero, ErrNotFound
+	case failure.Message == "unit_occupied":
+		return zero, ErrUnitOccupied
+	case failure.Message == "unit_number_taken":
+		return zero, ErrUnitNumberTaken
+	case failure.Message == "tenancy_ended":
+		return zero, ErrTenancyEnded
+	case failure.Code != "":
+		return zero, fmt.Errorf("store: %s failed: %.200s", name, raw)
+	}
+	var v T
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return zero, err
+	}
+	return v, nil
+}
+
+// first returns the only row of a response, or ErrNotFound
+func first[T any](data []byte, err error) (T, error) {
+	var zero T
+	rows, err := decode[T](data, err)
+	if err != nil {
+		return zero, err
+	}
+	if len(rows) == 0 {
+		return zero, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+// page runs a list query for one page. query returns the select with the
+// list's own scope filters; the caller's filters and the cursor go into a
+// single and=() group, so they never replace a scope filter on the same
+// column. The total comes from the exact count of the same request, except
+// after a cursor, which a second count-only request leaves out.
+func page[T any](query func(head bool) *postgrest.FilterBuilder, opts ListOptions, def Sort) (Page[T], error) {
+	sort := opts.order(def)
+	filtered := func(head, afterCursor bool) *postgrest.FilterBuilder {
+		q := query(head)
+		conds := make([]string, 0, len(opts.Filters)+1)
+		for _, f := range opts.Filters {
+			conds = append(conds, filterCond(f))
+		}
+		if afterCursor && opts.After != nil {
+			conds = append(conds, cursorCond(sort, *opts.After))
+		}
+		if len(conds) > 0 {
+			q = q.And(strings.Join(conds, ","), "")
+		}
+		return q
+	}
+
+	order := &postgrest.OrderOpts{Ascending: !sort.Desc}
+	offset := opts.offset()
+	data, count, err := filtered(false, true).Order(sort.Column, order).Order("id", order).Range(offset, offset+opts.perPage(), "").Execute()
+	rows, err := decode[T](data, err)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	if opts.After != nil {
+		if _, count, err = filtered(true, false).Execute(); err != nil {
+			return Page[T]{}, err
+		}
+	}
+	return finish(rows, int(count), opts, sort)
+}
+
+// filterCond writes a Filter as a PostgREST logic tree condition. Values
+// are quoted: timestamps contain characters the syntax reserves.
+func filterCond(f Filter) string {
+	if f.Op == OpNull {
+		if f.Value == true {
+			return f.Column + ".is.null"
+		}
+		return f.Column + ".not.is.null"
+	}
+	switch v := f.Value.(type) {
+	case []string:
+		items := make([]string, len(v))
+		for i := range v {
+			items[i] = quote(v[i])
+		}
+		return f.Column + ".in.(" + strings.Join(items, ",") + ")"
+	case string:
+		return f.Column + "." + f.Op + "." + quote(v)
+	default:
+		return fmt.Sprintf("%s.%s.%v", f.Column, f.Op, v)
+	}
+}
+
+// cursorCond matches the rows after c in sort order
+func cursorCond(sort Sort, c Cursor) string {
+	op := "gt"
+	if sort.Desc {
+		op = "lt"
+	}
+	v := quote(c.Value)
+	return fmt.Sprintf("or(%s.%s.%s,and(%s.eq.%s,id.%s.%s))", sort.Column, op, v, sort.Column, v, op, quote(c.ID))
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func execute(q *postgrest.FilterBuilder) ([]byte, error) {
+	data, _, err := q.Execute()
+	return data, err
+}
+
+// Buildings
+
+func (s *supabaseStore) ListBuildings(ids []string, opts ListOptions) (Page[models.Building], error) {
+	if len(ids) == 0 {
+		return finish[models.Building](nil, 0, opts, Sort{})
+	}
+	return page[models.Building](func(head bool) *postgrest.FilterBuilder {
+		return s.client.From("buildings").Select("*", "exact", head).In("id", ids)
+	}, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *supabaseStore) BuildingsByID(ids []string, includeArchived bool) ([]models.Building, error) {
+	if len(ids) == 0 {
+		return []models.Building{}, nil
+	}
+	q := s.client.From("buildings").Select("*", "exact", false).In("id", ids)
+	if !includeArchived {
+		q = q.Is("archived_at", "null")
+	}
+	return decode[models.Building](execute(q.Order("created_at", &postgrest.OrderOpts{Ascending: false}).Order("id", &postgrest.OrderOpts{Ascending: false})))
+}
+
+func (s *supabaseStore) GetBuilding(id string) (models.Building, error) {
+	return first[models.Building](execute(s.client.From("buildings").Select("*", "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) CreateBuilding(b models.Building) (models.Building, error) {
+	return first[models.Building](execute(s.client.From("buildings").Insert(buildingRow(b), false, "", "", "")))
+}
+
+// CreateBuildingWithUnits runs the create_building database function,
+// which inserts the building and its units in one transaction
+func (s *supabaseStore) CreateBuildingWithUnits(b models.Building, units []models.Unit) (models.Building, []models.Unit, error) {
+	rows := make([]map[string]interface{}, len(units))
+	for i, u := range units {
+		rows[i] = unitRow(u)
+	}
+	res, err := rpc[struct {
+		Building models.Building `json:"building"`
+		Units    []models.Unit   `json:"units"`
+	}](s, "create_building", map[string]interface{}{"p_building": buildingRow(b), "p_units": rows})
+	return res.Building, res.Units, err
+}
+
+func (s *supabaseStore) UpdateBuilding(id string, fields map[string]interface{}) (models.Building, error) {
+	return first[models.Building](execute(s.client.From("buildings").Update(fields, "", "").Eq("id", id)))
+}
+
+// ArchiveBuilding and RestoreBuilding run database functions, which check
+// and change the building and its units in one transaction
+func (s *supabaseStore) ArchiveBuilding(id string) (models.Building, error) {
+	return rpc[models.Building](s, "archive_building", map[string]interface{}{"p_building_id": id})
+}
+
+func (s *supabaseStore) RestoreBuilding(id string) (models.Building, error) {
+	return rpc[models.Building](s, "restore_building", map[string]interface{}{"p_building_id": id})
+}
+
+func (s *supabaseStore) LandlordBuildings(landlordID string) ([]models.Building, error) {
+	return decode[models.Building](execute(s.client.From("buildings").Select("*", "exact", false).
+		Eq("landlord_id", landlordID).Order("created_at", &postgrest.OrderOpts{Ascending: false})))
+}
+
+// Units
+
+func (s *supabaseStore) ListUnits(buildingID string, opts ListOptions) (Page[UnitListing], error) {
+	return page[UnitListing](func(head bool) *postgrest.FilterBuilder {
+		return s.client.From("units").Select("*, profiles!units_tenant_id_fkey(full_name, email, phone, avatar_thumb_url)", "exact", head).Eq("building_id", buildingID)
+	}, opts, Sort{Column: "unit_number"})
+}
+
+func (s *supabaseStore) GetUnit(id string) (models.Unit, error) {
+	return first[models.Unit](execute(s.client.From("units").Select("*", "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) CreateUnit(u models.Unit) (models.Unit, error) {
+	created, err := first[models.Unit](execute(s.client.From("units").Insert(unitRow(u), false, "", "", "")))
+	if err != nil && strings.HasPrefix(err.Error(), "(23505)") {
+		return models.Unit{}, ErrUnitNumberTaken
+	}
+	return created, err
+}
+
+// CreateUnits inserts every row in one request, which PostgREST runs as a
+// single statement
+func (s *supabaseStore) CreateUnits(units []models.Unit) ([]models.Unit, error) {
+	rows := make([]map[string]interface{}, len(units))
+	for i, u := range units {
+		rows[i] = unitRow(u)
+	}
+	created, err := decode[models.Unit](execute(s.client.From("units").Insert(rows, false, "", "", "")))
+	if err != nil && strings.HasPrefix(err.Error(), "(23505)") {
+		return nil, ErrUnitNumberTaken
+	}
+	return created, err
+}
+
+func (s *supabaseStore) UpdateUnit(id string, fields map[string]interface{}) (models.Unit, error) {
+	return first[models.Unit](execute(s.client.From("units").Update(fields, "", "").Eq("id", id)))
+}
+
+func (s *supabaseStore) ArchiveUnit(id string) (models.Unit, error) {
+	return rpc[models.Unit](s, "archive_unit", map[string]interface{}{"p_unit_id": id})
+}
+
+// Tenancies
+
+func (s *supabaseStore) ListTenancies(unitID string) ([]TenancyListing, error) {
+	return decode[TenancyListing](execute(s.client.From("tenancies").Select("*, profiles!tenancies_tenant_id_fkey(full_name, email, phone, avatar_thumb_url)", "exact", false).
+		Eq("unit_id", unitID).Order("start_date", &postgrest.OrderOpts{Ascending: false}).Order("created_at", &postgrest.OrderOpts{Ascending: false})))
+}
+
+func (s *supabaseStore) GetTenancy(id string) (models.Tenancy, error) {
+	return first[models.Tenancy](execute(s.client.From("tenancies").Select("*", "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) ActiveTenancy(unitID string) (models.Tenancy, error) {
+	return first[models.Tenancy](execute(s.client.From("tenancies").Select("*", "exact", false).Eq("unit_id", unitID).Eq("status", "active")))
+}
+
+func (s *supabaseStore) TenanciesForTenant(tenantID string) ([]models.Tenancy, error) {
+	return decode[models.Tenancy](execute(s.client.From("tenancies").Select("*", "exact", false).
+		Eq("tenant_id", tenantID).Order("start_date", &postgrest.OrderOpts{Ascending: false}).Order("created_at", &postgrest.OrderOpts{Ascending: false})))
+}
+
+func (s *supabaseStore) UpdateTenancy(id string, fields map[string]interface{}) (models.Tenancy, error) {
+	return first[models.Tenancy](execute(s.client.From("tenancies").Update(fields, "", "").Eq("id", id)))
+}
+
+func (s *supabaseStore) EndTenancy(id, endDate string) (models.Tenancy, error) {
+	return rpc[models.Tenancy](s, "end_tenancy", map[string]interface{}{"p_tenancy_id": id, "p_end_date": endDate})
+}
+
+// Payments
+
+func (s *supabaseStore) ListPayments(f PaymentFilter, opts ListOptions) (Page[PaymentListing], error) {
+	if f.BuildingIDs != nil && len(f.BuildingIDs) == 0 {
+		return finish[PaymentListing](nil, 0, opts, Sort{})
+	}
+	return page[PaymentListing](func(head bool) *postgrest.FilterBuilder {
+		q := s.client.From("payments").Select("*, profiles!payments_tenant_id_fkey(full_name), buildings(name), units(unit_number)", "exact", head)
+		if f.TenantID != "" {
+			q = q.Eq("tenant_id", f.TenantID)
+		}
+		if f.BuildingIDs != nil {
+			q = q.In("building_id", f.BuildingIDs)
+		}
+		return q
+	}, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *supabaseStore) GetPayment(id string) (models.Payment, error) {
+	return first[models.Payment](execute(s.client.From("payments").Select("*", "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) FindPaymentByReference(reference string) (models.Payment, error) {
+	return first[models.Payment](execute(s.client.From("payments").Select("*", "exact", false).Eq("paystack_reference", reference)))
+}
+
+func (s *supabaseStore) CreatePayment(p models.Payment) (models.Payment, error) {
+	return first[models.Payment](execute(s.client.From("payments").Insert(paymentRow(p), false, "", "", "")))
+}
+
+func (s *supabaseStore) UpdateUnsettledPayment(id string, fields map[string]interface{}) (models.Payment, bool, error) {
+	p, err := first[models.Payment](execute(s.client.From("payments").Update(fields, "", "").Eq("id", id).Neq("status", "successful")))
+	if err == ErrNotFound {
+		return p, false, nil
+	}
+	return p, err == nil, err
+}
+
+func (s *supabaseStore) RecordWebhookEvent(e models.WebhookEvent) (models.WebhookEvent, error) {
+	return first[models.WebhookEvent](execute(s.client.From("webhook_events").Insert(webhookEventRow(e), false, "", "", "")))
+}
+
+func (s *supabaseStore) UpdateWebhookEvent(id string, fields map[string]interface{}) error {
+	_, err := execute(s.client.From("webhook_events").Update(fields, "", "").Eq("id", id))
+	return err
+}
+
+// Invitations
+
+func (s *supabaseStore) ListInvitations(buildingIDs []string, opts ListOptions) (Page[InvitationListing], error) {
+	if len(buildingIDs) == 0 {
+		return finish[InvitationListing](nil, 0, opts, Sort{})
+	}
+	return page[InvitationListing](func(head bool) *postgrest.FilterBuilder {
+		return s.client.From("invitations").Select("*, units!inner(unit_number, building_id)", "exact", head).In("units.building_id", buildingIDs)
+	}, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *supabaseStore) GetPendingInvitation(token string) (models.Invitation, error) {
+	return first[models.Invitation](execute(s.client.From("invitations").Select("*", "exact", false).Eq("token", token).Eq("status", "pending")))
+}
+
+// invitationDetailsColumns embeds the unit and its building
+const invitationDetailsColumns = "*, units(unit_number, rent_amount), buildings:units(buildings(name, address, photo_url))"
+
+func (s *supabaseStore) GetInvitationDetails(token string) (InvitationDetails, error) {
+	return first[InvitationDetails](execute(s.client.From("invitations").Select(invitationDetailsColumns, "exact", false).Eq("token", token).Eq("status", "pending")))
+}
+
+func (s *supabaseStore) GetInvitation(id string) (InvitationDetails, error) {
+	return first[InvitationDetails](execute(s.client.From("invitations").Select(invitationDetailsColumns, "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) CreateInvitation(inv models.Invitation) (models.Invitation, error) {
+	return first[models.Invitation](execute(s.client.From("invitations").Insert(invitationRow(inv), false, "", "", "")))
+}
+
+// AcceptInvitation runs the accept_invitation database function, which
+// does every write in one transaction with the invitation and unit rows
+// locked. PostgREST has no transactions of its own.
+func (s *supabaseStore) AcceptInvitation(a Acceptance) (Accepted, error) {
+	args := map[string]interface{}{
+		"p_token":     a.Token,
+		"p_tenant_id": a.TenantID,
+	}
+	if p := a.NewProfile; p != nil {
+		args["p_full_name"] = p.FullName
+		if p.Email != "" {
+			args["p_email"] = p.Email
+		}
+		optional(args, "p_phone", p.Phone)
+		if p.EmailVerifiedAt != nil {
+			args["p_email_verified_at"] = p.EmailVerifiedAt
+		}
+		if p.PhoneVerifiedAt != nil {
+			args["p_phone_verified_at"] = p.PhoneVerifiedAt
+		}
+	}
+
+	raw := s.client.Rpc("accept_invitation", "", args)
+	if raw == "" {
+		return Accepted{}, errors.New("store: empty response from accept_invitation")
+	}
+	var result struct {
+		Accepted
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return Accepted{}, fmt.Errorf("store: unexpected accept_invitation response: %.200s", raw)
+	}
+	switch {
+	case result.Message == "invitation_unavailable":
+		return Accepted{}, ErrInvitationUnavailable
+	case result.Message == "unit_occupied":
+		return Accepted{}, ErrUnitOccupied
+	case result.Code != "" || result.UnitID == "":
+		return Accepted{}, fmt.Errorf("store: accept_invitation failed: %.200s", raw)
+	}
+	return result.Accepted, nil
+}
+
+// EraseInvitationContacts filters on the plain columns, which the
+// encryption transport points at the blind indexes
+func (s *supabaseStore) EraseInvitationContacts(e ContactErasure) error {
+	if e.Email != "" {
+		if _, err := execute(s.client.From("invitations").Update(map[string]interface{}{"email": e.Pseudonym, "phone": nil}, "", "").Eq("email", e.Email)); err != nil {
+			return err
+		}
+	}
+	if e.Phone != "" {
+		_, err := execute(s.client.From("invitations").Update(map[string]interface{}{"phone": nil}, "", "").Eq("phone", e.Phone))
+		return err
+	}
+	return nil
+}
+
+// Maintenance
+
+func (s *supabaseStore) ListMaintenanceRequests(f MaintenanceFilter, opts ListOptions) (Page[MaintenanceListing], error) {
+	if f.BuildingIDs != nil && len(f.BuildingIDs) == 0 {
+		return finish[MaintenanceListing](nil, 0, opts, Sort{})
+	}
+	return page[MaintenanceListing](func(head bool) *postgrest.FilterBuilder {
+		q := s.client.From("maintenance_requests").Select("*, profiles!maintenance_requests_tenant_id_fkey(full_name, avatar_thumb_url), units(unit_number), buildings(name)", "exact", head)
+		if f.TenantID != "" {
+			q = q.Eq("tenant_id", f.TenantID)
+		}
+		if f.BuildingIDs != nil {
+			q = q.In("building_id", f.BuildingIDs)
+		}
+		return q
+	}, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *supabaseStore) GetMaintenanceRequest(id string) (models.MaintenanceRequest, error) {
+	return first[models.MaintenanceRequest](execute(s.client.From("maintenance_requests").Select("*", "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) CreateMaintenanceRequest(m models.MaintenanceRequest) (models.MaintenanceRequest, error) {
+	return first[models.MaintenanceRequest](execute(s.client.From("maintenance_requests").Insert(maintenanceRow(m), false, "", "", "")))
+}
+
+func (s *supabaseStore) UpdateMaintenanceRequest(id string, fields map[string]interface{}) (models.MaintenanceRequest, error) {
+	return first[models.MaintenanceRequest](execute(s.client.From("maintenance_requests").Update(fields, "", "").Eq("id", id)))
+}
+
+// Documents
+
+func (s *supabaseStore) ListDocuments(f DocumentFilter, opts ListOptions) (Page[models.Document], error) {
+	return page[models.Document](func(head bool) *postgrest.FilterBuilder {
+		q := s.client.From("documents").Select("*", "exact", head)
+		filter := "uploaded_by.eq." + f.UploadedBy
+		if len(f.TenancyIDs) > 0 {
+			filter += ",tenancy_id.in.(" + strings.Join(f.TenancyIDs, ",") + ")"
+		}
+		if len(f.BuildingIDs) > 0 {
+			filter += ",building_id.in.(" + strings.Join(f.BuildingIDs, ",") + ")"
+		}
+		return q.Or(filter, "")
+	}, opts, Sort{Column: "created_at", Desc: true})
+}
+
+func (s *supabaseStore) CreateDocument(d models.Document) (models.Document, error) {
+	return first[models.Document](execute(s.client.From("documents").Insert(documentRow(d), false, "", "", "")))
+}
+
+// Profiles
+
+func (s *supabaseStore) GetProfile(id string) (models.Profile, error) {
+	return first[models.Profile](execute(s.client.From("profiles").Select("*", "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) ListProfiles(ids []string) ([]models.Profile, error) {
+	if len(ids) == 0 {
+		return []models.Profile{}, nil
+	}
+	return decode[models.Profile](execute(s.client.From("profiles").Select("*", "exact", false).In("id", ids)))
+}
+
+// FindProfileByEmail and FindProfileByPhone filter on the plain columns;
+// the encryption transport points the filters at the blind indexes
+func (s *supabaseStore) FindProfileByEmail(email string) (models.Profile, error) {
+	return first[models.Profile](execute(s.client.From("profiles").Select("*", "exact", false).Eq("email", email)))
+}
+
+func (s *supabaseStore) FindProfileByPhone(phone string) (models.Profile, error) {
+	return first[models.Profile](execute(s.client.From("profiles").Select("*", "exact", false).Eq("phone", phone)))
+}
+
+func (s *supabaseStore) CreateProfile(p models.Profile) (models.Profile, error) {
+	return first[models.Profile](execute(s.client.From("profiles").Insert(profileRow(p), false, "", "", "")))
+}
+
+func (s *supabaseStore) UpdateProfile(id string, fields map[string]interface{}) (models.Profile, error) {
+	return first[models.Profile](execute(s.client.From("profiles").Update(fields, "", "").Eq("id", id)))
+}
+
+func (s *supabaseStore) MarkEmailVerified(id string, at time.Time) error {
+	_, err := execute(s.client.From("profiles").Update(map[string]interface{}{"email_verified_at": at}, "", "").Eq("id", id).Is("email_verified_at", "null"))
+	return err
+}
+
+func (s *supabaseStore) UpdateRoles(id string, roles []string, defaultRole string) error {
+	update := map[string]interface{}{"roles": roles}
+	if defaultRole != "" {
+		update["role"] = defaultRole
+	}
+	_, err := execute(s.client.From("profiles").Update(update, "", "").Eq("id", id))
+	return err
+}
+
+// Organisations
+
+func (s *supabaseStore) OrganisationRole(orgID, userID string) (string, error) {
+	m, err := first[struct {
+		Role string `json:"role"`
+	}](execute(s.client.From("organisation_members").Select("role", "exact", false).Eq("organisation_id", orgID).Eq("user_id", userID).Eq("status", "active")))
+	if err == ErrNotFound {
+		return "", nil
+	}
+	return m.Role, err
+}
+
+func (s *supabaseStore) OrganisationRoles(userID string) (map[string]string, error) {
+	return s.activeRoles("organisation_members", "organisation_id", userID)
+}
+
+func (s *supabaseStore) GetOrganisation(id string) (models.Organisation, error) {
+	return first[models.Organisation](execute(s.client.From("organisations").Select("*", "exact", false).Eq("id", id)))
+}
+
+func (s *supabaseStore) ListOrganisations(ids []string) ([]models.Organisation, error) {
+	if len(ids) == 0 {
+		return []models.Organisation{}, nil
+	}
+	return decode[models.Organisation](execute(s.client.From("organisations").Select("*", "exact", false).
+		In("id", ids).Order("name", &postgrest.OrderOpts{Ascending: true})))
+}
+
+// CreateOrganisation inserts the organisation, then its creator's
+// membership. PostgREST cannot run both in one transaction; if the second
+// insert fails the organisation is left without members, visible to no one.
+func (s *supabaseStore) CreateOrganisation(o models.Organisation) (models.Organisation, error) {
+	created, err := first[models.Organisation](execute(s.client.From("organisations").Insert(organisationRow(o), false, "", "", "")))
+	if err != nil {
+		return created, err
+	}
+	_, err = execute(s.client.From("organisation_members").Insert(creatorRow(created), false, "", "", ""))
+	return created, err
+}
+
+func (s *supabaseStore) OrganisationBuildings(orgID, landlordID string) ([]models.Building, error) {
+	q := s.client.From("buildings").Select("*", "exact", false).Eq("organisation_id", orgID)
+	if landlordID != "" {
+		q = q.Eq("landlord_id", landlordID)
+	}
+	return decode[models.Building](execute(q.Order("name", &postgrest.OrderOpts{Ascending: true})))
+}
+
+func (s *supabaseStore) ListOrganisationMembers(orgID string) ([]OrganisationMemberListing, error) {
+	return decode[OrganisationMemberListing](execute(s.liveMembers("organisation_members", "organisation_id", orgID)))
+}
+
+func (s *supabaseStore) OrganisationMemberExists(orgID, email, phone string) (bool, error) {
+	return s.memberExists("organisation_members", "organisation_id", orgID, email, phone)
+}
+
+func (s *supabaseStore) CreateOrganisationInvite(m models.OrganisationMember, tokenHash string) (models.OrganisationMember, error) {
+	return first[models.OrganisationMember](execute(s.client.From("organisation_members").Insert(organisationInviteRow(m, tokenHash), false, "", "", "")))
+}
+
+func (s *supabaseStore) FindOrganisationInvite(tokenHash string) (models.OrganisationMember, error) {
+	return first[models.OrganisationMember](execute(s.client.From("organisation_members").Select("*", "exact", false).Eq("token_hash", tokenHash).Eq("status", "invited")))
+}
+
+func (s *supabaseStore) AcceptOrganisationInvite(id, userID string) (models.OrganisationMember, error) {
+	return first[models.OrganisationMember](execute(s.acceptInvite("organisation_members", id, userID)))
+}
+
+func (s *supabaseStore) RevokeOrganisationMember(orgID, id, exceptUserID string) (models.OrganisationMember, error) {
+	q := s.revokeMember("organisation_members", "organisation_id", orgID, id)
+	if exceptUserID != "" {
+		q = q.Or("user_id.is.null,user_id.neq."+exceptUserID, "")
+	}
+	return first[models.OrganisationMember](execute(q))
+}
+
+func (s *supabaseStore) RevokeOrganisationAccess(userID string) error {
+	return s.revokeAccess("organisation_members", userID)
+}
+
+func (s *supabaseStore) EraseOrganisationContacts(e ContactErasure) error {
+	return s.eraseContacts("organisation_members", e)
+}
+
+// Staff
+
+func (s *supabaseStore) ListStaff(buildingID string) ([]StaffListing, error) {
+	return decode[StaffListing](execute(s.liveMembers("building_members", "building_id", buildingID)))
+}
+
+func (s *supabaseStore) StaffMemberExists(buildingID, email, phone string) (bool, error) {
+	return s.memberExists("building_members", "building_id", buildingID, email, phone)
+}
+
+func (s *supabaseStore) CreateStaffInvite(m models.BuildingMember, tokenHash string) (models.BuildingMember, error) {
+	return first[models.BuildingMember](execute(s.client.From("building_members").Insert(staffInviteRow(m, tokenHash), false, "", "", "")))
+}
+
+func (s *supabaseStore) FindStaffInvite(tokenHash string) (models.BuildingMember, error) {
+	return first[models.BuildingMember](execute(s.client.From("building_members").Select("*", "exact", false).Eq("token_hash", tokenHash).Eq("status", "invited")))
+}
+
+func (s *supabaseStore) AcceptStaffInvite(id, userID string) (models.BuildingMember, error) {
+	return first[models.BuildingMember](execute(s.acceptInvite("building_members", id, userID)))
+}
+
+func (s *supabaseStore) RevokeStaff(buildingID, id string) (models.BuildingMember, error) {
+	return first[models.BuildingMember](execute(s.revokeMember("building_members", "building_id", buildingID, id)))
+}
+
+func (s *supabaseStore) StaffRoles(userID string) (map[string]string, error) {
+	return s.activeRoles("building_members", "building_id", userID)
+}
+
+func (s *supabaseStore) RevokeStaffAccess(userID string) error {
+	return s.revokeAccess("building_members", userID)
+}
+
+func (s *supabaseStore) EraseStaffContacts(e ContactErasure) error {
+	return s.eraseContacts("building_members", e)
+}
+
+// Staff and organisation members live in tables of the same shape; scope
+// is the column of the building or organisation they belong to
+
+func (s *supabaseStore) liveMembers(table, scope, scopeID string) *postgrest.FilterBuilder {
+	return s.client.From(table).Select("id, "+scope+", user_id, role, status, email, phone, invited_by, created_at, expires_at, accepted_at, revoked_at, profiles!"+table+"_user_id_fkey(full_name, email, phone, avatar_thumb_url)", "exact", false).
+		Eq(scope, scopeID).Neq("status", "revoked").Order("created_at", &postgrest.OrderOpts{Ascending: true})
+}
+
+func (s *supabaseStore) memberExists(table, scope, scopeID, email, phone string) (bool, error) {
+	q := s.client.From(table).Select("id", "exact", false).Eq(scope, scopeID).In("status", []string{"invited", "active"})
+	if email != "" {
+		q = q.Eq("email", email)
+	} else {
+		q = q.Eq("phone", phone)
+	}
+	return updated(execute(q))
+}
+
+func (s *supabaseStore) acceptInvite(table, id, userID string) *postgrest.FilterBuilder {
+	update := map[string]interface{}{
+		"user_id":     userID,
+		"status":      "active",
+		"accepted_at": time.Now().UTC(),
+		"token_hash":  nil,
+	}
+	return s.client.From(table).Update(update, "", "").Eq("id", id).Eq("status", "invited")
+}
+
+func (s *supabaseStore) revokeMember(table, scope, scopeID, id string) *postgrest.FilterBuilder {
+	update := map[string]interface{}{
+		"status":     "revoked",
+		"revoked_at": time.Now().UTC(),
+		"token_hash": nil,
+	}
+	return s.client.From(table).Update(update, "", "").Eq("id", id).Eq(scope, scopeID).Neq("status", "revoked")
+}
+
+func (s *supabaseStore) activeRoles(table, scope, userID string) (map[string]string, error) {
+	rows, err := decode[map[string]string](execute(s.client.From(table).Select(scope+", role", "exact", false).Eq("user_id", userID).Eq("status", "active")))
+	roles := make(map[string]string, len(rows))
+	for _, row := range rows {
+		roles[row[scope]] = row["role"]
+	}
+	return roles, err
+}
+
+func (s *supabaseStore) revokeAccess(table, userID string) error {
+	update := map[string]interface{}{"status": "revoked", "revoked_at": time.Now().UTC(), "token_hash": nil}
+	_, err := execute(s.client.From(table).Update(update, "", "").Eq("user_id", userID).Neq("status", "revoked"))
+	return err
+}
+
+// eraseContacts rewrites the user's own memberships, revoked or not, then
+// invitations still addressed to their email or phone
+func (s *supabaseStore) eraseContacts(table string, e ContactErasure) error {
+	erased := map[string]interface{}{"email": e.Pseudonym, "phone": nil}
+	if _, err := execute(s.client.From(table).Update(erased, "", "").Eq("user_id", e.UserID)); err != nil {
+		return err
+	}
+	if e.Email != "" {
+		if _, err := execute(s.client.From(table).Update(erased, "", "").Eq("email", e.Email)); err != nil {
+			return err
+		}
+	}
+	if e.Phone != "" {
+		_, err := execute(s.client.From(table).Update(map[string]interface{}{"phone": nil}, "", "").Eq("phone", e.Phone))
+		return err
+	}
+	return nil
+}
+
+// Owners
+
+func (s *supabaseStore) ListOwners(buildingID string) ([]models.BuildingOwner, error) {
+	return decode[models.BuildingOwner](execute(s.client.From("building_owners").Select("*", "exact", false).
+		Eq("building_id", buildingID).Order("created_at", &postgrest.OrderOpts{Ascending: true})))
+}
+
+// ReplaceOwners deletes, then inserts. PostgREST cannot run both in one
+// transaction; if the insert fails the building is left with no rows,
+// which means its landlord owns it outright.
+func (s *supabaseStore) ReplaceOwners(buildingID string, owners []models.BuildingOwner) ([]models.BuildingOwner, error) {
+	if _, err := execute(s.client.From("building_owners").Delete("", "").Eq("building_id", buildingID)); err != nil {
+		return nil, err
+	}
+	return decode[models.BuildingOwner](execute(s.client.From("building_owners").Insert(ownerRows(buildingID, owners), false, "", "", "")))
+}
+
+func (s *supabaseStore) ListOwnerships(userID string) ([]models.BuildingOwner, error) {
+	return decode[models.BuildingOwner](execute(s.client.From("building_owners").Select("*", "exact", false).Eq("user_id", userID)))
+}
+
+// API keys
+
+func (s *supabaseStore) CreateAPIKey(k models.APIKey, keyHash string) (models.APIKey, error) {
+	return first[models.APIKey](execute(s.client.From("api_keys").Insert(apiKeyRow(k, keyHash), false, "", "", "")))
+}
+
+func (s *supabaseStore) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	return decode[models.APIKey](execute(s.client.From("api_keys").Select(apiKeyColumns, "exact", false).
+		Eq("user_id", userID).Order("created_at", &postgrest.OrderOpts{Ascending: false})))
+}
+
+func (s *supabaseStore) RevokeAPIKey(id, userID string) (models.APIKey, error) {
+	update := map[string]interface{}{"revoked_at": time.Now().UTC()}
+	return first[models.APIKey](execute(s.client.From("api_keys").Update(update, "", "").Eq("id", id).Eq("user_id", userID).Is("revoked_at", "null")))
+}
+
+func (s *supabaseStore) FindAPIKey(keyHash string) (models.APIKey, error) {
+	return first[models.APIKey](execute(s.client.From("api_keys").Select(apiKeyColumns, "exact", false).Eq("key_hash", keyHash).Is("revoked_at", "null")))
+}
+
+func (s *supabaseStore) TouchAPIKey(id string, at time.Time) error {
+	_, err := execute(s.client.From("api_keys").Update(map[string]interface{}{"last_used_at": at}, "", "").Eq("id", id))
+	return err
+}
+
+func (s *supabaseStore) RevokeAPIKeys(userID string) error {
+	update := map[string]interface{}{"revoked_at": time.Now().UTC()}
+	_, err := execute(s.client.From("api_keys").Update(update, "", "").Eq("user_id", userID).Is("revoked_at", "null"))
+	return err
+}
+
+// Audit log
+
+func (s *supabaseStore) ListAuditEntries(f AuditFilter, offset, limit int) ([]models.AuditEntry, error) {
+	q := s.client.From("audit_log").Select("*", "exact", false)
+	if f.BuildingIDs != nil {
+		q = q.In("building_id", f.BuildingIDs)
+	}
+	for col, v := range f.Equal {
+		q = q.Eq(col, v)
+	}
+	if !f.From.IsZero() {
+		q = q.Gte("created_at", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q = q.Lt("created_at", f.To.Format(time.RFC3339))
+	}
+	return decode[models.AuditEntry](execute(q.Order("created_at", &postgrest.OrderOpts{Ascending: false}).Range(offset, offset+limit-1, "")))
+}
+
+func (s *supabaseStore) RecordAuditEntry(e models.AuditEntry) error {
+	_, err := execute(s.client.From("audit_log").Insert(auditRow(e), false, "", "", ""))
+	return err
+}
+
+// Notifications
+
+func (s *supabaseStore) RecordNotification(n models.Notification) error {
+	_, err := execute(s.client.From("notifications").Insert(notificationRow(n), false, "", "", ""))
+	return err
+}
+
+// SMS codes
+
+func (s *supabaseStore) RecentOTPs(phone string, since time.Time) ([]time.Time, error) {
+	rows, err := decode[models.PhoneOTP](execute(s.client.From("phone_otps").Select("created_at", "exact", false).
+		Eq("phone", phone).Gte("created_at", since.UTC().Format(time.RFC3339)).Order("created_at", &postgrest.OrderOpts{Ascending: false})))
+	sent := make([]time.Time, len(rows))
+	for i, o := range rows {
+		sent[i] = o.CreatedAt
+	}
+	return sent, err
+}
+
+func (s *supabaseStore) CreateOTP(o models.PhoneOTP) error {
+	revoke := map[string]interface{}{"consumed_at": time.Now().UTC()}
+	if _, err := execute(s.client.From("phone_otps").Update(revoke, "", "").Eq("phone", o.Phone).Is("consumed_at", "null")); err != nil {
+		return err
+	}
+	_, err := execute(s.client.From("phone_otps").Insert(otpRow(o), false, "", "", ""))
+	return err
+}
+
+func (s *supabaseStore) LatestOTP(phone string) (models.PhoneOTP, error) {
+	return first[models.PhoneOTP](execute(s.client.From("phone_otps").Select("*", "exact", false).
+		Eq("phone", phone).Is("consumed_at", "null").Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(1, "")))
+}
+
+func (s *supabaseStore) TakeOTPAttempt(id string, max int) (bool, error) {
+	res, err := rpc[struct {
+		Taken bool `json:"taken"`
+	}](s, "take_otp_attempt", map[string]interface{}{"p_id": id, "p_max": max})
+	return res.Taken, err
+}
+
+func (s *supabaseStore) ConsumeOTP(id string) (bool, error) {
+	update := map[string]interface{}{"consumed_at": time.Now().UTC()}
+	return updated(execute(s.client.From("phone_otps").Update(update, "", "").Eq("id", id).Is("consumed_at", "null")))
+}
+
+func (s *supabaseStore) DeleteOTPs(phone string) error {
+	_, err := execute(s.client.From("phone_otps").Delete("", "").Eq("phone", phone))
+	return err
+}
+
+// Auth tokens
+
+func (s *supabaseStore) CreateAuthToken(t models.AuthToken) error {
+	revoke := map[string]interface{}{"used_at": time.Now().UTC()}
+	if _, err := execute(s.client.From("auth_tokens").Update(revoke, "", "").Eq("user_id", t.UserID).Eq("purpose", t.Purpose).Is("used_at", "null")); err != nil {
+		return err
+	}
+	_, err := execute(s.client.From("auth_tokens").Insert(authTokenRow(t), false, "", "", ""))
+	return err
+}
+
+func (s *supabaseStore) FindAuthToken(tokenHash, purpose string) (models.AuthToken, error) {
+	return first[models.AuthToken](execute(s.client.From("auth_tokens").Select("*", "exact", false).Eq("token_hash", tokenHash).Eq("purpose", purpose)))
+}
+
+func (s *supabaseStore) UseAuthToken(id string) (bool, error) {
+	update := map[string]interface{}{"used_at": time.Now().UTC()}
+	return updated(execute(s.client.From("auth_tokens").Update(update, "", "").Eq("id", id).Is("used_at", "null")))
+}
+
+func (s *supabaseStore) TakeAuthTokenAttempt(id string, max int) (bool, error) {
+	res, err := rpc[struct {
+		Taken bool `json:"taken"`
+	}](s, "take_auth_token_attempt", map[string]interface{}{"p_id": id, "p_max": max})
+	return res.Taken, err
+}
+
+func (s *supabaseStore) DeleteAuthTokens(userID string) error {
+	_, err := execute(s.client.From("auth_tokens").Delete("", "").Eq("user_id", userID))
+	return err
+}
+
+// Login lockouts
+
+func (s *supabaseStore) GetLoginLockout(email string) (models.LoginLockout, error) {
+	return first[models.LoginLockout](execute(s.client.From("login_lockouts").Select("*", "exact", false).Eq("email", email)))
+}
+
+func (s *supabaseStore) RecordLoginFailure(email string, policy LockoutPolicy) (models.LoginLockout, error) {
+	return rpc[models.LoginLockout](s, "record_login_failure", map[string]interface{}{
+		"p_email":          email,
+		"p_threshold":      policy.Threshold,
+		"p_base_seconds":   int(policy.Base.Seconds()),
+		"p_max_seconds":    int(policy.Max.Seconds()),
+		"p_window_seconds": int(policy.Window.Seconds()),
+	})
+}
+
+func (s *supabaseStore) ClearLoginLockout(email string) error {
+	_, err := execute(s.client.From("login_lockouts").Delete("", "").Eq("email", email))
+	return err
+}
+
+// Two-factor
+
+func (s *supabaseStore) GetMFA(userID string) (models.UserMFA, error) {
+	return first[models.UserMFA](execute(s.client.From("user_mfa").Select("*", "exact", false).Eq("user_id", userID)))
+}
+
+func (s *supabaseStore) PutMFA(m models.UserMFA) error {
+	_, err := execute(s.client.From("user_mfa").Upsert(mfaRow(m), "user_id", "", ""))
+	return err
+}
+
+func (s *supabaseStore) UpdateMFA(userID string, fields map[string]interface{}) error {
+	_, err := execute(s.client.From("user_mfa").Update(fields, "", "").Eq("user_id", userID))
+	return err
+}
+
+func (s *supabaseStore) AdvanceMFAStep(userID string, step int64) (bool, error) {
+	update := map[string]interface{}{"last_used_step": step}
+	return updated(execute(s.client.From("user_mfa").Update(update, "", "").Eq("user_id", userID).Lt("last_used_step", strconv.FormatInt(step, 10))))
+}
+
+func (s *supabaseStore) DeleteMFA(userID string) error {
+	if _, err := execute(s.client.From("mfa_recovery_codes").Delete("", "").Eq("user_id", userID)); err != nil {
+		return err
+	}
+	_, err := execute(s.client.From("user_mfa").Delete("", "").Eq("user_id", userID))
+	return err
+}
+
+func (s *supabaseStore) CountRecoveryCodes(userID string) (int, error) {
+	_, count, err := s.client.From("mfa_recovery_codes").Select("id", "exact", true).Eq("user_id", userID).Is("used_at", "null").Execute()
+	return int(count), err
+}
+
+func (s *supabaseStore) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	if _, err := execute(s.client.From("mfa_recovery_codes").Delete("", "").Eq("user_id", userID)); err != nil {
+		return err
+	}
+	_, err := execute(s.client.From("mfa_recovery_codes").Insert(recoveryCodeRows(userID, codeHashes), false, "", "", ""))
+	return err
+}
+
+func (s *supabaseStore) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	update := map[string]interface{}{"used_at": time.Now().UTC()}
+	return updated(execute(s.client.From("mfa_recovery_codes").Update(update, "", "").Eq("user_id", userID).Eq("code_hash", codeHash).Is("used_at", "null")))
+}
+
+// Admin console
+
+func (s *supabaseStore) FindProfilesByName(name string, limit int) ([]models.Profile, error) {
+	return decode[models.Profile](execute(s.client.From("profiles").Select("*", "exact", false).
+		Ilike("full_name", "*"+name+"*").Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(limit, "")))
+}
+
+// FindBuildings, FindPayments and ListWebhookEvents take terms the admin
+// handler has already stripped of PostgREST syntax
+func (s *supabaseStore) FindBuildings(q string, limit int) ([]models.Building, error) {
+	query := s.client.From("buildings").Select("*", "exact", false)
+	if isUUID(q) {
+		query = query.Or("id.eq."+q+",landlord_id.eq."+q, "")
+	} else {
+		query = query.Or("name.ilike.*"+q+"*,address.ilike.*"+q+"*", "")
+	}
+	return decode[models.Building](execute(query.Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(limit, "")))
+}
+
+func (s *supabaseStore) FindPayments(q, status string, limit int) ([]PaymentListing, error) {
+	query := s.client.From("payments").Select("*, profiles!payments_tenant_id_fkey(full_name, email), buildings(name), units(unit_number)", "exact", false)
+	if isUUID(q) {
+		query = query.Or("id.eq."+q+",tenant_id.eq."+q+",building_id.eq."+q+",unit_id.eq."+q, "")
+	} else if q != "" {
+		query = query.Or("paystack_reference.eq."+q+",paystack_transaction_id.eq."+q, "")
+	}
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+	return decode[PaymentListing](execute(query.Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(limit, "")))
+}
+
+func (s *supabaseStore) ListWebhookEvents(reference, event string, limit int) ([]models.WebhookEvent, error) {
+	query := s.client.From("webhook_events").Select("*", "exact", false)
+	if reference != "" {
+		query = query.Eq("reference", reference)
+	}
+	if event != "" {
+		query = query.Eq("event", event)
+	}
+	return decode[models.WebhookEvent](execute(query.Order("received_at", &postgrest.OrderOpts{Ascending: false}).Limit(limit, "")))
+}
+
+func (s *supabaseStore) CreateImpersonation(sess models.ImpersonationSession, tokenHash string) (models.ImpersonationSession, error) {
+	return first[models.ImpersonationSession](execute(s.client.From("impersonation_sessions").Insert(impersonationRow(sess, tokenHash), false, "", "", "")))
+}
+
+func (s *supabaseStore) FindImpersonation(tokenHash, adminID string) (models.ImpersonationSession, error) {
+	return first[models.ImpersonationSession](execute(s.client.From("impersonation_sessions").Select("*", "exact", false).
+		Eq("token_hash", tokenHash).Eq("admin_id", adminID).Is("ended_at", "null")))
+}
+
+func (s *supabaseStore) EndImpersonation(id, adminID string) (models.ImpersonationSession, error) {
+	update := map[string]interface{}{"ended_at": time.Now().UTC()}
+	return first[models.ImpersonationSession](execute(s.client.From("impersonation_sessions").Update(update, "", "").
+		Eq("id", id).Eq("admin_id", adminID).Is("ended_at", "null")))
+}
+
+// updated reports whether a conditional update matched a row
+func updated(data []byte, err error) (bool, error) {
+	rows, err := decode[json.RawMessage](data, err)
+	return len(rows) > 0, err
+}
+
+// Search
+
+// Search runs the search_portfolio database function, which ranks with
+// pg_trgm; the encryption transport turns Email and Phone into blind
+// indexes on the way
+func (s *supabaseStore) Search(q SearchQuery) (SearchResults, error) {
+	if q.empty() {
+		return SearchResults{Tenants: []TenantHit{}, Units: []UnitHit{}, Buildings: []BuildingHit{}, Payments: []PaymentHit{}}, nil
+	}
+	args := map[string]interface{}{
+		"p_building_ids":  nonNil(q.BuildingIDs),
+		"p_financial_ids": nonNil(q.FinancialIDs),
+		"p_limit":         q.Limit,
+	}
+	for key, v := range map[string]string{"p_query": q.Text, "p_email": q.Email, "p_phone": q.Phone} {
+		if v != "" {
+			args[key] = v
+		}
+	}
+
+	raw := s.client.Rpc("search_portfolio", "", args)
+	var result struct {
+		SearchResults
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil || result.Code != "" || result.Tenants == nil {
+		return SearchResults{}, fmt.Errorf("store: search_portfolio failed: %.200s", raw)
+	}
+	return result.SearchResults, nil
+}
+
+// Imports
+
+func (s *supabaseStore) CommitImport(imp models.Import, units []models.Unit, payments []models.Payment) (models.Import, error) {
+	unitRows, paymentRows := importRows(units, payments)
+	return rpc[models.Import](s, "import_batch", map[string]interface{}{
+		"p_building_id": imp.BuildingID,
+		"p_created_by":  imp.CreatedBy,
+		"p_units":       unitRows,
+		"p_payments":    paymentRows,
+	})
+}
+
+// Stats
+
+// Totals adds up on this side: aggregate functions are off by default in
+// Supabase's PostgREST. The Postgres store sums in the database.
+func (s *supabaseStore) Totals(buildingIDs, financialIDs []string) (Totals, error) {
+	var t Totals
+	if len(buildingIDs) > 0 {
+		units, err := decode[struct {
+			Status string `json:"status"`
+		}](execute(s.client.From("units").Select("status", "exact", false).In("building_id", buildingIDs).Is("archived_at", "null")))
+		if err != nil {
+			return t, err
+		}
+		t.Units = len(units)
+		for _, u := range units {
+			if u.Status == "occupied" {
+				t.OccupiedUnits++
+			}
+		}
+	}
+	if len(financialIDs) > 0 {
+		payments, err := decode[struct {
+			Amount int64  `json:"amount"`
+			Status string `json:"status"`
+		}](execute(s.client.From("payments").Select("amount, status", "exact", false).In("building_id", financialIDs).In("status", []string{"successful", "pending"})))
+		if err != nil {
+			return t, err
+		}
+		for _, p := range payments {
+			if p.Status == "successful" {
+				t.Collected += p.Amount
+			} else {
+				t.Pending += p.Amount
+			}
+		}
+	}
+	return t, nil
+}